@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/handler"
+)
+
+// Config holds the server settings
+// Each setting is read from, in increasing order of precedence: its default, the JSON
+// config file, a LOOM_* environment variable and a command-line flag
+type Config struct {
+	Addr        string // Listen address, e.g. ":8080" or "127.0.0.1:8080"
+	BasePath    string // URL prefix when served behind a proxy, e.g. "/loom"
+	TemplateDir string // Templates directory replacing the embedded templates, reloaded on every page
+	StaticDir   string // Static files directory replacing the embedded files
+
+	ReadHeaderTimeout time.Duration // Time allowed to read the request headers
+	ReadTimeout       time.Duration // Time allowed to read the whole request, including the upload
+	WriteTimeout      time.Duration // Time allowed to process the request and write the response
+	IdleTimeout       time.Duration // Time a keep-alive connection may wait for the next request
+	ShutdownTimeout   time.Duration // Time running conversions get to finish on SIGINT/SIGTERM
+
+	MaxUploadBytes int64 // Largest request body accepted
+	MaxPixels      int   // Largest image accepted, in pixels (0 = no limit)
+	MaxCells       int   // Largest design or chain generated, in hooks x picks (0 = no limit)
+
+	RateLimit     float64       // Conversion requests per second allowed per client (0 = no limit)
+	RateBurst     int           // Conversion requests a client may make at once
+	RateLimitKey  string        // What identifies a client: "ip" or "api-key"
+	TrustProxy    bool          // Take the client IP from X-Forwarded-For
+	MaxConcurrent int           // Conversions running at once (0 = no limit)
+	QueueTimeout  time.Duration // Time a conversion waits for a free slot before getting 503
+
+	CacheBytes     int64  // Memory for converted images reused across requests (0 = no cache)
+	CacheDir       string // Directory keeping converted images on disk as well ("" = memory only)
+	CacheDiskBytes int64  // Disk space for the cache directory (0 = no limit)
+
+	Auth         bool          // Require an API key or a signed-in session for conversions
+	UsersFile    string        // JSON file holding users and their API keys
+	SessionTTL   time.Duration // How long a browser stays signed in
+	WorkspaceDir string        // Directory of the per-user workspaces ("" = no workspaces)
+	QuotaBytes   int64         // Workspace bytes of new users (0 = no limit)
+	QuotaItems   int           // Workspace items of new users (0 = no limit)
+
+	TLSCert string // Certificate file; with TLSKey, serves HTTPS
+	TLSKey  string // Private key file
+
+	PrintVersion bool // Print the build information and exit
+}
+
+// defaultConfig returns the settings used when nothing else is given
+func defaultConfig() Config {
+	return Config{
+		Addr:              ":" + defaultPort,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      120 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+		MaxUploadBytes:    handler.DefaultMaxUploadBytes,
+		MaxPixels:         handler.DefaultMaxPixels,
+		MaxCells:          handler.DefaultMaxCells,
+		RateLimit:         2,
+		RateBurst:         20,
+		RateLimitKey:      "ip",
+		MaxConcurrent:     runtime.NumCPU(),
+		QueueTimeout:      10 * time.Second,
+		CacheBytes:        64 << 20,
+		CacheDiskBytes:    1 << 30,
+		UsersFile:         "users.json",
+		SessionTTL:        24 * time.Hour,
+		QuotaBytes:        100 << 20,
+		QuotaItems:        500,
+	}
+}
+
+// setting is one configurable value, named the same in the config file and as a flag
+type setting struct {
+	name  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+	get   func(c *Config) string
+}
+
+// settings lists every configurable value
+var settings = []setting{
+	stringSetting("addr", "LOOM_ADDR", "Listen address", func(c *Config) *string { return &c.Addr }),
+	{
+		name:  "port",
+		env:   "PORT",
+		usage: "HTTP server port (shorthand for -addr :PORT)",
+		set:   func(c *Config, v string) error { c.Addr = ":" + v; return nil },
+		get:   func(c *Config) string { return "" },
+	},
+	stringSetting("base-path", "LOOM_BASE_PATH", "URL prefix when served behind a proxy, e.g. /loom", func(c *Config) *string { return &c.BasePath }),
+	stringSetting("templates", "LOOM_TEMPLATES", "Templates directory to use instead of the embedded templates, reloaded on every page (for development)", func(c *Config) *string { return &c.TemplateDir }),
+	stringSetting("static", "LOOM_STATIC", "Static files directory to use instead of the embedded files (for development)", func(c *Config) *string { return &c.StaticDir }),
+	durationSetting("read-header-timeout", "LOOM_READ_HEADER_TIMEOUT", "Time allowed to read the request headers", func(c *Config) *time.Duration { return &c.ReadHeaderTimeout }),
+	durationSetting("read-timeout", "LOOM_READ_TIMEOUT", "Time allowed to read the whole request", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("write-timeout", "LOOM_WRITE_TIMEOUT", "Time allowed to process a request and write the response", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("idle-timeout", "LOOM_IDLE_TIMEOUT", "Time an idle keep-alive connection is kept open", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("shutdown-timeout", "LOOM_SHUTDOWN_TIMEOUT", "Time running conversions get to finish on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	int64Setting("max-upload-bytes", "LOOM_MAX_UPLOAD_BYTES", "Largest request body accepted, in bytes", func(c *Config) *int64 { return &c.MaxUploadBytes }),
+	intSetting("max-pixels", "LOOM_MAX_PIXELS", "Largest image accepted, in pixels (0 = no limit)", func(c *Config) *int { return &c.MaxPixels }),
+	intSetting("max-cells", "LOOM_MAX_CELLS", "Largest design or chain generated, in hooks x picks (0 = no limit)", func(c *Config) *int { return &c.MaxCells }),
+	{
+		name:  "rate-limit",
+		env:   "LOOM_RATE_LIMIT",
+		usage: "Conversion requests per second allowed per client (0 = no limit)",
+		set: func(c *Config, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			c.RateLimit = f
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatFloat(c.RateLimit, 'g', -1, 64) },
+	},
+	intSetting("rate-burst", "LOOM_RATE_BURST", "Conversion requests a client may make at once", func(c *Config) *int { return &c.RateBurst }),
+	stringSetting("rate-limit-key", "LOOM_RATE_LIMIT_KEY", "What identifies a client for rate limiting: ip or api-key (the verified API key, falling back to the user or IP)", func(c *Config) *string { return &c.RateLimitKey }),
+	{
+		name:  "trust-proxy",
+		env:   "LOOM_TRUST_PROXY",
+		usage: "Take the client IP from X-Forwarded-For (only behind a reverse proxy that sets it)",
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.TrustProxy = b
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(c.TrustProxy) },
+	},
+	intSetting("max-concurrent", "LOOM_MAX_CONCURRENT", "Conversions running at once (0 = no limit)", func(c *Config) *int { return &c.MaxConcurrent }),
+	durationSetting("queue-timeout", "LOOM_QUEUE_TIMEOUT", "Time a conversion waits for a free slot before getting 503", func(c *Config) *time.Duration { return &c.QueueTimeout }),
+	int64Setting("cache-bytes", "LOOM_CACHE_BYTES", "Memory for converted images reused across requests, in bytes (0 = no cache)", func(c *Config) *int64 { return &c.CacheBytes }),
+	stringSetting("cache-dir", "LOOM_CACHE_DIR", "Directory keeping converted images on disk as well, across restarts", func(c *Config) *string { return &c.CacheDir }),
+	int64Setting("cache-disk-bytes", "LOOM_CACHE_DISK_BYTES", "Disk space for the cache directory, in bytes (0 = no limit)", func(c *Config) *int64 { return &c.CacheDiskBytes }),
+	{
+		name:  "auth",
+		env:   "LOOM_AUTH",
+		usage: "Require an API key or a signed-in session for conversions; leave off for local use",
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.Auth = b
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(c.Auth) },
+	},
+	stringSetting("users-file", "LOOM_USERS_FILE", "JSON file holding users and their API keys, created with an admin user on first start", func(c *Config) *string { return &c.UsersFile }),
+	durationSetting("session-ttl", "LOOM_SESSION_TTL", "How long a browser stays signed in", func(c *Config) *time.Duration { return &c.SessionTTL }),
+	stringSetting("workspace-dir", "LOOM_WORKSPACE_DIR", "Directory of the per-user workspaces of stored images, card sets and presets", func(c *Config) *string { return &c.WorkspaceDir }),
+	int64Setting("quota-bytes", "LOOM_QUOTA_BYTES", "Workspace bytes of new users (0 = no limit)", func(c *Config) *int64 { return &c.QuotaBytes }),
+	intSetting("quota-items", "LOOM_QUOTA_ITEMS", "Workspace items of new users (0 = no limit)", func(c *Config) *int { return &c.QuotaItems }),
+	stringSetting("tls-cert", "LOOM_TLS_CERT", "TLS certificate file (serves HTTPS together with -tls-key)", func(c *Config) *string { return &c.TLSCert }),
+	stringSetting("tls-key", "LOOM_TLS_KEY", "TLS private key file", func(c *Config) *string { return &c.TLSKey }),
+}
+
+// stringSetting returns a setting stored in a string field
+func stringSetting(name, env, usage string, field func(c *Config) *string) setting {
+	return setting{
+		name:  name,
+		env:   env,
+		usage: usage,
+		set:   func(c *Config, v string) error { *field(c) = v; return nil },
+		get:   func(c *Config) string { return *field(c) },
+	}
+}
+
+// intSetting returns a setting stored in an int field
+func intSetting(name, env, usage string, field func(c *Config) *int) setting {
+	return setting{
+		name:  name,
+		env:   env,
+		usage: usage,
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+// int64Setting returns a setting stored in an int64 field
+func int64Setting(name, env, usage string, field func(c *Config) *int64) setting {
+	return setting{
+		name:  name,
+		env:   env,
+		usage: usage,
+		set: func(c *Config, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return err
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+	}
+}
+
+// durationSetting returns a setting stored in a duration field, written like "30s" or "2m"
+func durationSetting(name, env, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{
+		name:  name,
+		env:   env,
+		usage: usage,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = d
+			return nil
+		},
+		get: func(c *Config) string { return field(c).String() },
+	}
+}
+
+// loadConfig builds the configuration from the defaults, the config file, the environment and the flags
+func loadConfig(args []string) (Config, error) {
+	defaults := defaultConfig()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("LOOM_CONFIG"), "JSON config file (env LOOM_CONFIG)")
+	printVersion := fs.Bool("version", false, "Print build information and exit")
+
+	// Flags are only recorded here and applied last, so they override the file and environment
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		s := s
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if def := s.get(&defaults); def != "" {
+			usage += fmt.Sprintf(" (default %q)", def)
+		}
+		fs.Func(s.name, usage, func(v string) error {
+			flagValues[s.name] = v
+			return s.set(&Config{}, v)
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if *printVersion {
+		return Config{PrintVersion: true}, nil
+	}
+
+	cfg := defaults
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flagValues[s.name]; ok {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("invalid -%s: %w", s.name, err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// loadFile applies the settings in a JSON config file, such as {"addr": ":9000", "write-timeout": "5m"}
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for name, raw := range values {
+		s, ok := findSetting(name)
+		if !ok {
+			return fmt.Errorf("unknown setting in config file %s: %s", path, name)
+		}
+		// Strings are unquoted; numbers are used as written
+		value := string(raw)
+		var str string
+		if json.Unmarshal(raw, &str) == nil {
+			value = str
+		}
+		if err := s.set(c, value); err != nil {
+			return fmt.Errorf("invalid %s in config file %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
+
+// findSetting looks a setting up by name
+func findSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Validate checks that the settings are usable
+func (c Config) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("invalid address: must not be empty")
+	}
+	if c.BasePath != "" && (!strings.HasPrefix(c.BasePath, "/") || strings.HasSuffix(c.BasePath, "/")) {
+		return fmt.Errorf("invalid base path: %s (must start with '/' and not end with '/')", c.BasePath)
+	}
+	if c.MaxUploadBytes <= 0 {
+		return fmt.Errorf("invalid max upload bytes: %d (must be positive)", c.MaxUploadBytes)
+	}
+	if c.MaxPixels < 0 {
+		return fmt.Errorf("invalid max pixels: %d (must be 0 or more)", c.MaxPixels)
+	}
+	if c.MaxCells < 0 {
+		return fmt.Errorf("invalid max cells: %d (must be 0 or more)", c.MaxCells)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit: %g (must be 0 or more)", c.RateLimit)
+	}
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		return fmt.Errorf("invalid rate burst: %d (must be at least 1)", c.RateBurst)
+	}
+	if c.RateLimitKey != "ip" && c.RateLimitKey != "api-key" {
+		return fmt.Errorf("invalid rate limit key: %s (must be ip or api-key)", c.RateLimitKey)
+	}
+	if c.MaxConcurrent < 0 {
+		return fmt.Errorf("invalid max concurrent: %d (must be 0 or more)", c.MaxConcurrent)
+	}
+	if c.CacheBytes < 0 {
+		return fmt.Errorf("invalid cache bytes: %d (must be 0 or more)", c.CacheBytes)
+	}
+	if c.CacheDiskBytes < 0 {
+		return fmt.Errorf("invalid cache disk bytes: %d (must be 0 or more)", c.CacheDiskBytes)
+	}
+	if c.CacheDir != "" && c.CacheBytes == 0 {
+		return fmt.Errorf("invalid cache settings: a cache directory needs cache bytes above 0")
+	}
+	if c.Auth && c.UsersFile == "" {
+		return fmt.Errorf("invalid auth settings: authentication needs a users file")
+	}
+	if c.Auth && c.SessionTTL <= 0 {
+		return fmt.Errorf("invalid session TTL: %s (must be positive)", c.SessionTTL)
+	}
+	if c.QuotaBytes < 0 {
+		return fmt.Errorf("invalid quota bytes: %d (must be 0 or more)", c.QuotaBytes)
+	}
+	if c.QuotaItems < 0 {
+		return fmt.Errorf("invalid quota items: %d (must be 0 or more)", c.QuotaItems)
+	}
+	for name, d := range map[string]time.Duration{
+		"read header timeout": c.ReadHeaderTimeout,
+		"read timeout":        c.ReadTimeout,
+		"write timeout":       c.WriteTimeout,
+		"idle timeout":        c.IdleTimeout,
+		"shutdown timeout":    c.ShutdownTimeout,
+		"queue timeout":       c.QueueTimeout,
+	} {
+		if d < 0 {
+			return fmt.Errorf("invalid %s: %s (must be 0 or more)", name, d)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("invalid TLS settings: give both a certificate and a key")
+	}
+	return nil
+}
+
+// TLS reports whether the server should serve HTTPS
+func (c Config) TLS() bool {
+	return c.TLSCert != ""
+}
@@ -1,80 +1,244 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+	"github.com/oscaralmgren/loom-punchcards/internal/buildinfo"
+	"github.com/oscaralmgren/loom-punchcards/internal/cache"
 	"github.com/oscaralmgren/loom-punchcards/internal/handler"
+	"github.com/oscaralmgren/loom-punchcards/internal/limit"
+	"github.com/oscaralmgren/loom-punchcards/internal/logging"
+	"github.com/oscaralmgren/loom-punchcards/internal/workspace"
+	"github.com/oscaralmgren/loom-punchcards/web"
 )
 
-const (
-	defaultPort        = "8080"
-	defaultTemplateDir = "web/templates"
-	defaultStaticDir   = "web/static"
-)
+const defaultPort = "8080"
 
 func main() {
-	// Command-line flags
-	port := flag.String("port", getEnv("PORT", defaultPort), "HTTP server port")
-	templateDir := flag.String("templates", defaultTemplateDir, "Templates directory")
-	staticDir := flag.String("static", defaultStaticDir, "Static files directory")
-	flag.Parse()
+	// Structured JSON logs; the standard logger goes through them too
+	log.SetFlags(0)
+	log.SetOutput(logging.Default().Writer())
+
+	// Settings from the flags, environment and config file
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	build := buildinfo.Read()
+	if cfg.PrintVersion {
+		fmt.Println("punchcard-server", build)
+		return
+	}
+
+	// Templates and static files are built in; a directory given on the command line replaces them
+	templates, templateSource := web.Templates(), "embedded"
+	if cfg.TemplateDir != "" {
+		dir := resolveDir(cfg.TemplateDir)
+		templates, templateSource = os.DirFS(dir), dir+" (reloaded on every page)"
+	}
+	static, staticSource := web.Static(), "embedded"
+	if cfg.StaticDir != "" {
+		dir := resolveDir(cfg.StaticDir)
+		static, staticSource = os.DirFS(dir), dir
+	}
 
 	// Print banner
 	printBanner()
 
 	// Initialize handler
-	h, err := handler.NewHandler(*templateDir)
+	h, err := handler.NewHandler(templates)
 	if err != nil {
 		log.Fatalf("Failed to initialize handler: %v", err)
 	}
+	h.ReloadTemplates = cfg.TemplateDir != ""
+	h.MaxUploadBytes = cfg.MaxUploadBytes
+	h.MaxPixels = cfg.MaxPixels
+	h.MaxCells = cfg.MaxCells
+	h.BasePath = cfg.BasePath
+	if cfg.RateLimit > 0 {
+		h.RateLimiter = limit.NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+	h.RateLimitByAPIKey = cfg.RateLimitKey == "api-key"
+	h.TrustProxy = cfg.TrustProxy
+	if cfg.MaxConcurrent > 0 {
+		h.Pool = limit.NewPool(cfg.MaxConcurrent, cfg.QueueTimeout)
+	}
+	if cfg.CacheBytes > 0 {
+		h.Cache, err = cache.New(cfg.CacheBytes, cfg.CacheDir, cfg.CacheDiskBytes)
+		if err != nil {
+			log.Fatalf("Failed to initialize cache: %v", err)
+		}
+	}
+
+	h.DefaultQuota = auth.Quota{MaxBytes: cfg.QuotaBytes, MaxItems: cfg.QuotaItems}
+	h.SecureCookies = cfg.TLS()
+	if cfg.Auth {
+		store, err := auth.OpenFileStore(cfg.UsersFile)
+		if err != nil {
+			log.Fatalf("Failed to open users file: %v", err)
+		}
+		h.Auth = auth.NewAuthenticator(store, cfg.SessionTTL)
+		h.LoginLimiter = limit.NewRateLimiter(handler.LoginRate, handler.LoginBurst)
+		token, err := h.Auth.Bootstrap(h.DefaultQuota)
+		if err != nil {
+			log.Fatalf("Failed to create the admin user: %v", err)
+		}
+		if token != "" {
+			// Shown once only; the users file keeps just its hash
+			log.Printf("Created user \"admin\" with API key %s (store it now, it is not shown again)", token)
+		}
+	}
+	if cfg.WorkspaceDir != "" {
+		h.Workspaces, err = workspace.Open(cfg.WorkspaceDir)
+		if err != nil {
+			log.Fatalf("Failed to initialize workspaces: %v", err)
+		}
+	}
 
 	// Set up routes
 	mux := http.NewServeMux()
 
 	// Static files
-	fs := http.FileServer(http.Dir(*staticDir))
+	fs := http.FileServer(http.FS(static))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	// API routes
+	// API routes; conversions need a user when authentication is on, are rate limited and share the pipeline pool
 	mux.HandleFunc("/", h.HomeHandler)
-	mux.HandleFunc("/upload", h.UploadHandler)
-	mux.HandleFunc("/preview", h.PreviewHandler)
-	mux.HandleFunc("/info", h.InfoHandler)
-	mux.HandleFunc("/upload-text", h.UploadTextHandler)
-	mux.HandleFunc("/preview-text", h.PreviewTextHandler)
-	mux.HandleFunc("/info-text", h.InfoTextHandler)
+	mux.HandleFunc("/upload", h.Authenticate(h.Limit(h.UploadHandler)))
+	mux.HandleFunc("/preview", h.Authenticate(h.Limit(h.PreviewHandler)))
+	mux.HandleFunc("/info", h.Authenticate(h.Limit(h.InfoHandler)))
+	mux.HandleFunc("/upload-text", h.Authenticate(h.Limit(h.UploadTextHandler)))
+	mux.HandleFunc("/preview-text", h.Authenticate(h.Limit(h.PreviewTextHandler)))
+	mux.HandleFunc("/info-text", h.Authenticate(h.Limit(h.InfoTextHandler)))
+	mux.HandleFunc("/stats-text", h.Authenticate(h.Limit(h.StatsTextHandler)))
+	mux.HandleFunc("/digitize", h.Authenticate(h.Limit(h.DigitizeHandler)))
+	mux.HandleFunc("/lettering", h.Authenticate(h.Limit(h.LetteringHandler)))
 	mux.HandleFunc("/health", h.HealthHandler)
 
+	// Versioned JSON API, sharing its implementation with the routes above
+	mux.HandleFunc("/api/v1/convert", h.Authenticate(h.Limit(h.APIConvertHandler)))
+	mux.HandleFunc("/api/v1/preview", h.Authenticate(h.Limit(h.APIPreviewHandler)))
+	mux.HandleFunc("/api/v1/info", h.Authenticate(h.Limit(h.APIInfoHandler)))
+	mux.HandleFunc("/api/v1/cardsets/convert", h.Authenticate(h.Limit(h.APICardSetConvertHandler)))
+	mux.HandleFunc("/api/v1/cardsets/preview", h.Authenticate(h.Limit(h.APICardSetPreviewHandler)))
+	mux.HandleFunc("/api/v1/cardsets/info", h.Authenticate(h.Limit(h.APICardSetInfoHandler)))
+	mux.HandleFunc("/api/v1/cardsets/stats", h.Authenticate(h.Limit(h.APICardSetStatsHandler)))
+	mux.HandleFunc("/api/v1/digitize", h.Authenticate(h.Limit(h.APIDigitizeHandler)))
+	mux.HandleFunc("/api/v1/lettering", h.Authenticate(h.Limit(h.APILetteringHandler)))
+	mux.HandleFunc("/api/v1/health", h.HealthHandler)
+
+	// Accounts and workspaces; sign-in attempts have their own limiter and take no conversion slot
+	mux.HandleFunc("/api/v1/auth/login", h.LoginHandler)
+	mux.HandleFunc("/api/v1/auth/logout", h.LogoutHandler)
+	mux.HandleFunc("/api/v1/auth/me", h.Authenticate(h.MeHandler))
+	mux.HandleFunc("/api/v1/workspace/", h.Authenticate(h.WorkspaceHandler))
+	mux.HandleFunc("/api/v1/admin/users", h.Authenticate(h.RequireAdmin(h.AdminUsersHandler)))
+	mux.HandleFunc("/api/v1/admin/users/", h.Authenticate(h.RequireAdmin(h.AdminUsersHandler)))
+	mux.HandleFunc("/api/v1/", h.APINotFoundHandler)
+
+	// Metrics are public on an open server, and for admins only once users sign in
+	metrics := h.MetricsHandler
+	if cfg.Auth {
+		metrics = h.Authenticate(h.RequireAdmin(h.MetricsHandler))
+	}
+	mux.HandleFunc("/metrics", metrics)
+
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           withBasePath(cfg.BasePath, h.Instrument(mux)),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
 	// Start server
-	addr := ":" + *port
-	log.Printf("Starting Jacquard Loom Punchcard Generator on http://localhost%s", addr)
-	log.Printf("Template directory: %s", *templateDir)
-	log.Printf("Static directory: %s", *staticDir)
+	scheme := "http"
+	if cfg.TLS() {
+		scheme = "https"
+	}
+	log.Printf("Starting Jacquard Loom Punchcard Generator on %s://%s%s/", scheme, displayAddr(cfg.Addr), cfg.BasePath)
+	log.Printf("Version: %s", build)
+	log.Printf("Templates: %s", templateSource)
+	log.Printf("Static files: %s", staticSource)
+	log.Printf("Limits: %d byte uploads, %d pixel images, %d cell chains", cfg.MaxUploadBytes, cfg.MaxPixels, cfg.MaxCells)
+	log.Printf("Throttling: %g requests/s per client (burst %d), %d concurrent conversions, %s queue timeout",
+		cfg.RateLimit, cfg.RateBurst, cfg.MaxConcurrent, cfg.QueueTimeout)
+	switch {
+	case cfg.CacheBytes == 0:
+		log.Printf("Cache: disabled")
+	case cfg.CacheDir == "":
+		log.Printf("Cache: %d bytes in memory", cfg.CacheBytes)
+	default:
+		log.Printf("Cache: %d bytes in memory, %d bytes in %s", cfg.CacheBytes, cfg.CacheDiskBytes, cfg.CacheDir)
+	}
+	if cfg.Auth {
+		log.Printf("Authentication: API keys and sessions, users in %s", cfg.UsersFile)
+	} else {
+		log.Printf("Authentication: disabled (local use)")
+	}
+	if cfg.WorkspaceDir != "" {
+		log.Printf("Workspaces: %s, new users get %d bytes and %d items", cfg.WorkspaceDir, cfg.QuotaBytes, cfg.QuotaItems)
+	}
 	log.Printf("Ready to generate punchcards! 🧵")
 
-	if err := http.ListenAndServe(addr, logRequest(mux)); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			serverErr <- server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	// Wait for a shutdown signal, then let running conversions finish
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		// Listening fails at startup, or serving fails later
+		log.Fatalf("Server error: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %s, waiting up to %s for running requests to finish", sig, cfg.ShutdownTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown did not complete: %v", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server error: %v", err)
 	}
+	log.Printf("Server stopped")
 }
 
-// logRequest is a middleware that logs HTTP requests
-func logRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
+// withBasePath serves the application under a URL prefix, redirecting the bare prefix to its index
+func withBasePath(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(basePath+"/", http.StripPrefix(basePath, next))
+	mux.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+	return mux
 }
 
-// getEnv retrieves an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// displayAddr returns the listen address as it is typed in a browser
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
 	}
-	return defaultValue
+	return addr
 }
 
 // printBanner prints an ASCII art banner
@@ -92,17 +256,7 @@ func printBanner() {
 ║                                                                   ║
 ╚═══════════════════════════════════════════════════════════════════╝
 `
-	log.Print(banner)
-}
-
-// ensureDirectories creates necessary directories if they don't exist
-func ensureDirectories(dirs ...string) error {
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
-		}
-	}
-	return nil
+	fmt.Fprint(os.Stderr, banner)
 }
 
 // getAbsPath returns the absolute path, resolving relative paths from the executable location
@@ -120,3 +274,16 @@ func getAbsPath(path string) (string, error) {
 
 	return filepath.Join(exPath, path), nil
 }
+
+// resolveDir returns the directory as given when it exists relative to the working directory,
+// and otherwise resolves it next to the executable, so an installed binary finds its web files
+func resolveDir(dir string) string {
+	if _, err := os.Stat(dir); err == nil {
+		return dir
+	}
+	abs, err := getAbsPath(dir)
+	if err != nil {
+		return dir
+	}
+	return abs
+}
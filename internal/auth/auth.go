@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// User roles
+const (
+	RoleAdmin = "admin" // May manage users, keys and quotas
+	RoleUser  = "user"
+)
+
+// Errors returned by stores and the authenticator
+var (
+	ErrNotFound           = errors.New("user not found")
+	ErrNameTaken          = errors.New("user name already taken")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Quota limits what a user may keep in their workspace
+type Quota struct {
+	MaxBytes int64 `json:"maxBytes"` // Stored bytes (0 = no limit)
+	MaxItems int   `json:"maxItems"` // Stored items (0 = no limit)
+}
+
+// User is an account that can sign in with a password or authenticate with an API key
+type User struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	PasswordHash string    `json:"passwordHash,omitempty"`
+	Keys         []APIKey  `json:"keys,omitempty"`
+	Quota        Quota     `json:"quota"`
+	Created      time.Time `json:"created"`
+}
+
+// NewUser creates a user with a random ID
+func NewUser(name, role string, quota Quota) (*User, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	return &User{ID: randomHex(8), Name: name, Role: role, Quota: quota, Created: time.Now().UTC()}, nil
+}
+
+// IsAdmin reports whether the user may manage other users
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// SetPassword replaces the user's password
+func (u *User) SetPassword(password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = hash
+	return nil
+}
+
+// Clone returns a deep copy of the user, so stores can hand users out without sharing them
+func (u *User) Clone() *User {
+	c := *u
+	c.Keys = append([]APIKey(nil), u.Keys...)
+	return &c
+}
+
+// ValidateName checks that a user name is 1 to 64 letters, digits, dots, dashes or underscores
+func ValidateName(name string) error {
+	if name == "" || len(name) > 64 {
+		return fmt.Errorf("invalid user name: %q (must be 1 to 64 characters)", name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("invalid user name: %q (only letters, digits, '.', '-' and '_')", name)
+		}
+	}
+	return nil
+}
+
+// ValidateRole checks that a role is admin or user
+func ValidateRole(role string) error {
+	if role != RoleAdmin && role != RoleUser {
+		return fmt.Errorf("invalid role: %s (must be 'admin' or 'user')", role)
+	}
+	return nil
+}
+
+// Store keeps users
+// FileStore is the built-in implementation; a database-backed store only needs these methods
+type Store interface {
+	List() ([]*User, error)
+	Get(id string) (*User, error)
+	GetByName(name string) (*User, error)
+	GetByKey(keyID string) (*User, error)
+	Put(u *User) error // Creates or replaces a user; names must be unique
+	Delete(id string) error
+}
+
+// Authenticator checks API keys and passwords against a store and keeps sign-in sessions
+type Authenticator struct {
+	Store      Store
+	SessionTTL time.Duration // How long a session lasts after sign-in
+
+	mu       sync.Mutex
+	sessions map[string]session
+	now      func() time.Time
+}
+
+type session struct {
+	userID  string
+	expires time.Time
+}
+
+// NewAuthenticator creates an authenticator whose sessions last ttl
+func NewAuthenticator(store Store, ttl time.Duration) *Authenticator {
+	return &Authenticator{
+		Store:      store,
+		SessionTTL: ttl,
+		sessions:   map[string]session{},
+		now:        time.Now,
+	}
+}
+
+// AuthenticateKey returns the user owning an API key
+func (a *Authenticator) AuthenticateKey(token string) (*User, error) {
+	id, secret, ok := ParseKey(token)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	u, err := a.Store.GetByKey(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range u.Keys {
+		if k.ID == id && k.Matches(secret) {
+			return u, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// Login checks a user's password and starts a session, returning its token
+func (a *Authenticator) Login(name, password string) (*User, string, error) {
+	u, err := a.Store.GetByName(name)
+	if errors.Is(err, ErrNotFound) {
+		// Spend the same time as a wrong password, so user names cannot be probed
+		CheckPassword(dummyHash(), password)
+		return nil, "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if u.PasswordHash == "" || !CheckPassword(u.PasswordHash, password) {
+		return nil, "", ErrInvalidCredentials
+	}
+
+	token := randomHex(32)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	for t, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, t)
+		}
+	}
+	a.sessions[token] = session{userID: u.ID, expires: now.Add(a.SessionTTL)}
+	return u, token, nil
+}
+
+// Session returns the user signed in with a session token
+func (a *Authenticator) Session(token string) (*User, error) {
+	a.mu.Lock()
+	s, ok := a.sessions[token]
+	if ok && a.now().After(s.expires) {
+		delete(a.sessions, token)
+		ok = false
+	}
+	a.mu.Unlock()
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	u, err := a.Store.Get(s.userID)
+	if errors.Is(err, ErrNotFound) {
+		a.Logout(token)
+		return nil, ErrInvalidCredentials
+	}
+	return u, err
+}
+
+// Logout ends a session
+func (a *Authenticator) Logout(token string) {
+	a.mu.Lock()
+	delete(a.sessions, token)
+	a.mu.Unlock()
+}
+
+// EndSessions ends every session of a user, after their password changes or they are deleted
+func (a *Authenticator) EndSessions(userID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for t, s := range a.sessions {
+		if s.userID == userID {
+			delete(a.sessions, t)
+		}
+	}
+}
+
+// Bootstrap creates an "admin" user with an API key when the store has no users yet
+// It returns the key, which is not stored and cannot be shown again, or "" when users already exist
+func (a *Authenticator) Bootstrap(quota Quota) (string, error) {
+	users, err := a.Store.List()
+	if err != nil || len(users) > 0 {
+		return "", err
+	}
+	u, err := NewUser("admin", RoleAdmin, quota)
+	if err != nil {
+		return "", err
+	}
+	token, key := NewKey("bootstrap")
+	u.Keys = append(u.Keys, key)
+	if err := a.Store.Put(u); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("auth: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
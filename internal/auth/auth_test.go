@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 section 11, PBKDF2-HMAC-SHA256 with P="passwd", S="salt", c=1, dkLen=64
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)); got != want {
+		t.Errorf("pbkdf2() = %s, want %s", got, want)
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword() rejected the right password")
+	}
+	if CheckPassword(hash, "correct horsf") {
+		t.Error("CheckPassword() accepted a wrong password")
+	}
+	if CheckPassword("md5$abc", "correct horse") {
+		t.Error("CheckPassword() accepted an unknown scheme")
+	}
+	if _, err := HashPassword("short"); err == nil {
+		t.Error("HashPassword() should reject a short password")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	token, key := NewKey("ci")
+	id, secret, ok := ParseKey(token)
+	if !ok || id != key.ID {
+		t.Fatalf("ParseKey(%q) = %q, %v, want ID %q", token, id, ok, key.ID)
+	}
+	if !key.Matches(secret) {
+		t.Error("key does not match its own secret")
+	}
+	if key.Matches(secret + "x") {
+		t.Error("key matches a wrong secret")
+	}
+	for _, bad := range []string{"", "loom_", "loom_abc_def", "other_0123456789abcdef_secret"} {
+		if _, _, ok := ParseKey(bad); ok {
+			t.Errorf("ParseKey(%q) should fail", bad)
+		}
+	}
+}
+
+func newTestStore(t *testing.T) (*FileStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	return store, path
+}
+
+func TestFileStore(t *testing.T) {
+	store, path := newTestStore(t)
+
+	u, err := NewUser("ada", RoleUser, Quota{MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("NewUser() error = %v", err)
+	}
+	_, key := NewKey("laptop")
+	u.Keys = append(u.Keys, key)
+	if err := store.Put(u); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	other, _ := NewUser("ada", RoleUser, Quota{})
+	if err := store.Put(other); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("Put() with a taken name error = %v, want ErrNameTaken", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("users file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("users file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// A new store over the same file sees the user
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	got, err := reopened.GetByKey(key.ID)
+	if err != nil || got.Name != "ada" || got.Quota.MaxBytes != 100 {
+		t.Errorf("GetByKey() = %+v, %v", got, err)
+	}
+
+	// Users handed out are copies
+	got.Name = "changed"
+	if again, _ := reopened.Get(u.ID); again.Name != "ada" {
+		t.Error("changing a returned user changed the store")
+	}
+
+	if err := reopened.Delete(u.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := reopened.GetByName("ada"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByName() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	store, _ := newTestStore(t)
+	a := NewAuthenticator(store, time.Hour)
+	clock := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return clock }
+
+	token, err := a.Bootstrap(Quota{})
+	if err != nil || token == "" {
+		t.Fatalf("Bootstrap() = %q, %v", token, err)
+	}
+	if again, _ := a.Bootstrap(Quota{}); again != "" {
+		t.Error("Bootstrap() created a second admin")
+	}
+
+	admin, err := a.AuthenticateKey(token)
+	if err != nil || !admin.IsAdmin() {
+		t.Fatalf("AuthenticateKey() = %+v, %v", admin, err)
+	}
+	if _, err := a.AuthenticateKey(token + "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateKey() with a wrong secret error = %v", err)
+	}
+
+	if err := admin.SetPassword("weaving123"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	store.Put(admin)
+
+	if _, _, err := a.Login("admin", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with a wrong password error = %v", err)
+	}
+	if _, _, err := a.Login("nobody", "weaving123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() of an unknown user error = %v", err)
+	}
+	_, session, err := a.Login("admin", "weaving123")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if u, err := a.Session(session); err != nil || u.ID != admin.ID {
+		t.Errorf("Session() = %+v, %v", u, err)
+	}
+
+	clock = clock.Add(2 * time.Hour)
+	if _, err := a.Session(session); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Session() after expiry error = %v", err)
+	}
+
+	_, session, _ = a.Login("admin", "weaving123")
+	a.EndSessions(admin.ID)
+	if _, err := a.Session(session); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Session() after EndSessions() error = %v", err)
+	}
+}
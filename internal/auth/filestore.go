@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileStore keeps users in a JSON file, rewritten on every change
+// It suits the handful of accounts a studio or workshop needs
+type FileStore struct {
+	path string
+
+	mu    sync.Mutex
+	users map[string]*User
+}
+
+// fileStoreData is the layout of the users file
+type fileStoreData struct {
+	Users []*User `json:"users"`
+}
+
+// OpenFileStore loads the users file at path, which is created on the first change if missing
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, users: map[string]*User{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var stored fileStoreData
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse users file %s: %w", path, err)
+	}
+	for _, u := range stored.Users {
+		s.users[u.ID] = u
+	}
+	return s, nil
+}
+
+// List returns every user, sorted by name
+func (s *FileStore) List() ([]*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u.Clone())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// Get returns the user with an ID
+func (s *FileStore) Get(id string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[id]; ok {
+		return u.Clone(), nil
+	}
+	return nil, ErrNotFound
+}
+
+// GetByName returns the user with a name
+func (s *FileStore) GetByName(name string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Name == name {
+			return u.Clone(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetByKey returns the user owning the API key with an ID
+func (s *FileStore) GetByKey(keyID string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		for _, k := range u.Keys {
+			if k.ID == keyID {
+				return u.Clone(), nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Put creates or replaces a user and saves the file
+func (s *FileStore) Put(u *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.users {
+		if other.Name == u.Name && other.ID != u.ID {
+			return fmt.Errorf("%w: %s", ErrNameTaken, u.Name)
+		}
+	}
+
+	previous, existed := s.users[u.ID]
+	s.users[u.ID] = u.Clone()
+	if err := s.save(); err != nil {
+		if existed {
+			s.users[u.ID] = previous
+		} else {
+			delete(s.users, u.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a user and saves the file
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	if err := s.save(); err != nil {
+		s.users[id] = previous
+		return err
+	}
+	return nil
+}
+
+// save writes the users file through a temporary file, so a crash never leaves it half written
+// The file holds password and key hashes, so only its owner may read it; s.mu must be held
+func (s *FileStore) save() error {
+	stored := fileStoreData{Users: make([]*User, 0, len(s.users))}
+	for _, u := range s.users {
+		stored.Users = append(stored.Users, u)
+	}
+	sort.Slice(stored.Users, func(i, j int) bool { return stored.Users[i].Name < stored.Users[j].Name })
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to save users file: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".users-*")
+	if err != nil {
+		return fmt.Errorf("failed to save users file: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save users file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPrefix starts every API key, so keys are easy to recognise in configs and leaks
+const keyPrefix = "loom_"
+
+// APIKey is a stored API key; only a hash of its secret is kept
+type APIKey struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+// NewKey creates an API key and returns the token to give its owner, "loom_<id>_<secret>"
+func NewKey(name string) (string, APIKey) {
+	id := randomHex(8)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("auth: reading random bytes: %v", err))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	key := APIKey{ID: id, Name: name, Hash: hashSecret(encoded), Created: time.Now().UTC()}
+	return keyPrefix + id + "_" + encoded, key
+}
+
+// ParseKey splits a token into its key ID and secret
+func ParseKey(token string) (string, string, bool) {
+	if !strings.HasPrefix(token, keyPrefix) {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, keyPrefix), "_")
+	if !ok || len(id) != 16 || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// Matches reports whether a secret belongs to the key
+func (k APIKey) Matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) == 1
+}
+
+// hashSecret hashes a key secret; secrets are random, so a plain hash is enough
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Password hashing parameters
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 210000
+	passwordSaltBytes  = 16
+	passwordKeyBytes   = 32
+	MinPasswordLength  = 8
+)
+
+// dummyHash is checked against when a user does not exist, to take as long as a real check
+// It is made on first use, since hashing is deliberately slow
+var (
+	dummyHashOnce sync.Once
+	dummyHashVal  string
+)
+
+func dummyHash() string {
+	dummyHashOnce.Do(func() { dummyHashVal = mustHashPassword("not a real password") })
+	return dummyHashVal
+}
+
+// HashPassword hashes a password with PBKDF2-HMAC-SHA256, as "pbkdf2-sha256$iterations$salt$key"
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("invalid password: must be at least %d characters", MinPasswordLength)
+	}
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, passwordIterations, passwordKeyBytes)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func mustHashPassword(password string) string {
+	hash, err := HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// CheckPassword reports whether a password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// pbkdf2 derives a key of keyLen bytes as in RFC 8018, using HMAC-SHA256
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var counter [4]byte
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,66 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the release version, set at build time with
+// -ldflags "-X github.com/oscaralmgren/loom-punchcards/internal/buildinfo.Version=v1.2.0"
+var Version = "dev"
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`    // VCS revision the binary was built from
+	Date      string `json:"date,omitempty"`      // Commit time, in RFC 3339
+	Modified  bool   `json:"modified,omitempty"`  // Built from a working tree with uncommitted changes
+	GoVersion string `json:"goVersion,omitempty"` // Go toolchain used to build the binary
+}
+
+// Read returns the build information, taking the commit from the VCS stamp Go embeds in the binary
+func Read() Info {
+	info := Info{Version: Version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.Date = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// String formats the build information on one line, e.g. "dev (commit 1d9ea59, 2024-03-01T12:00:00Z, go1.22.1)"
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, "commit "+commit)
+	}
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+	if i.GoVersion != "" {
+		details = append(details, i.GoVersion)
+	}
+	if len(details) == 0 {
+		return i.Version
+	}
+	return fmt.Sprintf("%s (%s)", i.Version, strings.Join(details, ", "))
+}
@@ -0,0 +1,34 @@
+package buildinfo
+
+import "testing"
+
+func TestInfoString(t *testing.T) {
+	tests := []struct {
+		name string
+		info Info
+		want string
+	}{
+		{"version only", Info{Version: "dev"}, "dev"},
+		{"full", Info{Version: "v1.2.0", Commit: "1d9ea59c0ffee", Date: "2024-03-01T12:00:00Z", GoVersion: "go1.22.1"},
+			"v1.2.0 (commit 1d9ea59, 2024-03-01T12:00:00Z, go1.22.1)"},
+		{"modified", Info{Version: "dev", Commit: "1d9ea59", Modified: true}, "dev (commit 1d9ea59-dirty)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	info := Read()
+	if info.Version != Version {
+		t.Errorf("Version = %q, want %q", info.Version, Version)
+	}
+	if info.GoVersion == "" {
+		t.Error("GoVersion is empty")
+	}
+}
@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier names where a value was found
+const (
+	TierMemory = "memory"
+	TierDisk   = "disk"
+)
+
+// Cache is a content-addressed store of encoded values
+// Recently used values are kept in memory within a byte budget; with a directory, every value is
+// also written to disk, within its own budget, so it survives eviction from memory and restarts
+type Cache struct {
+	maxBytes     int64
+	dir          string
+	diskMaxBytes int64
+
+	mu       sync.Mutex
+	lru      *list.List // Front is the most recently used
+	items    map[string]*list.Element
+	size     int64
+	diskSize int64
+}
+
+type entry struct {
+	key   string
+	value []byte
+}
+
+// New creates a cache holding up to maxBytes in memory
+// When dir is not empty it is used as the disk tier, holding up to diskMaxBytes (0 = no limit)
+func New(maxBytes int64, dir string, diskMaxBytes int64) (*Cache, error) {
+	c := &Cache{
+		maxBytes:     maxBytes,
+		dir:          dir,
+		diskMaxBytes: diskMaxBytes,
+		lru:          list.New(),
+		items:        map[string]*list.Element{},
+	}
+	if dir != "" {
+		// Cached results are users' designs, so only the server's own user may read them
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		files, err := c.diskFiles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, f := range files {
+			c.diskSize += f.size
+		}
+	}
+	return c, nil
+}
+
+// Key returns the content address of the given parts, a hex SHA-256 of their concatenation
+// Each part is length-prefixed, so different splits of the same bytes give different keys
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the value stored under key and the tier it was found in
+// A value found on disk is brought back into memory
+func (c *Cache) Get(key string) ([]byte, string, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		value := el.Value.(*entry).value
+		c.mu.Unlock()
+		return value, TierMemory, true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, "", false
+	}
+	path := c.path(key)
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", false
+	}
+	// Mark the file as recently used, so disk eviction keeps it
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	c.mu.Lock()
+	c.addMemory(key, value)
+	c.mu.Unlock()
+	return value, TierDisk, true
+}
+
+// Put stores a value under key, evicting the least recently used values over the budgets
+// Values larger than the memory budget are only kept on disk
+func (c *Cache) Put(key string, value []byte) error {
+	c.mu.Lock()
+	c.addMemory(key, value)
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil
+	}
+	return c.writeDisk(key, value)
+}
+
+// addMemory adds or refreshes a value in the memory tier; c.mu must be held
+func (c *Cache) addMemory(key string, value []byte) {
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		return
+	}
+	if int64(len(value)) > c.maxBytes {
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&entry{key: key, value: value})
+	c.size += int64(len(value))
+	for c.size > c.maxBytes {
+		oldest := c.lru.Back()
+		e := oldest.Value.(*entry)
+		c.lru.Remove(oldest)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.value))
+	}
+}
+
+// writeDisk stores a value in the disk tier, then trims the tier to its budget
+func (c *Cache) writeDisk(key string, value []byte) error {
+	path := c.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a concurrent Get never reads a partial value
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	c.diskSize += int64(len(value))
+	over := c.diskMaxBytes > 0 && c.diskSize > c.diskMaxBytes
+	c.mu.Unlock()
+	if over {
+		return c.trimDisk()
+	}
+	return nil
+}
+
+// diskFile is a value stored in the disk tier
+type diskFile struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// diskFiles lists the values stored in the disk tier
+func (c *Cache) diskFiles() ([]diskFile, error) {
+	var files []diskFile
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Base(path)[0] == '.' {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, diskFile{path: path, size: info.Size(), used: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// trimDisk removes the least recently used files until the disk tier is within its budget
+func (c *Cache) trimDisk() error {
+	files, err := c.diskFiles()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		if total <= c.diskMaxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+
+	c.mu.Lock()
+	c.diskSize = total
+	c.mu.Unlock()
+	return nil
+}
+
+// path returns the file holding a value, spread over subdirectories by the first two characters of the key
+func (c *Cache) path(key string) string {
+	if len(key) < 3 {
+		return filepath.Join(c.dir, key)
+	}
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Len returns the number of values in the memory tier
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Bytes returns the size of the values in the memory tier
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// DiskBytes returns the size of the values in the disk tier
+func (c *Cache) DiskBytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diskSize
+}
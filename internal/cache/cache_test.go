@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	a := Key([]byte("ab"), []byte("c"))
+	if a != Key([]byte("ab"), []byte("c")) {
+		t.Error("Key() is not deterministic")
+	}
+	if a == Key([]byte("a"), []byte("bc")) {
+		t.Error("Key() should depend on how the bytes are split into parts")
+	}
+	if len(a) != 64 {
+		t.Errorf("len(Key()) = %d, want 64", len(a))
+	}
+}
+
+func TestMemoryLRU(t *testing.T) {
+	c, err := New(10, "", 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	c.Put("a", []byte("aaaa"))
+	c.Put("b", []byte("bbbb"))
+	// Using a makes b the least recently used
+	if _, tier, ok := c.Get("a"); !ok || tier != TierMemory {
+		t.Fatalf("Get(a) = %v, %q, want a memory hit", ok, tier)
+	}
+	c.Put("c", []byte("cccc"))
+
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, _, ok := c.Get(key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+	if c.Len() != 2 || c.Bytes() != 8 {
+		t.Errorf("Len(), Bytes() = %d, %d, want 2, 8", c.Len(), c.Bytes())
+	}
+
+	// A value larger than the whole budget is not kept
+	c.Put("big", make([]byte, 11))
+	if _, _, ok := c.Get("big"); ok {
+		t.Error("value over the memory budget should not be cached")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d after an oversized Put, want 2", c.Len())
+	}
+}
+
+func TestDiskTier(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := New(4, dir, 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	c.Put("aaaa", []byte("1234"))
+	c.Put("bbbb", []byte("5678")) // Evicts aaaa from memory
+
+	value, tier, ok := c.Get("aaaa")
+	if !ok || tier != TierDisk || !bytes.Equal(value, []byte("1234")) {
+		t.Fatalf("Get(aaaa) = %q, %q, %v, want a disk hit", value, tier, ok)
+	}
+	if _, tier, _ := c.Get("aaaa"); tier != TierMemory {
+		t.Errorf("second Get(aaaa) tier = %q, want the value promoted to memory", tier)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "aa", "aaaa")); err != nil {
+		t.Errorf("value file missing: %v", err)
+	}
+	// Only the server's user may read cached designs
+	for _, d := range []string{dir, filepath.Join(dir, "aa")} {
+		if info, err := os.Stat(d); err != nil || info.Mode().Perm() != 0o700 {
+			t.Errorf("Stat(%s) = %v, %v, want mode 0700", d, info.Mode().Perm(), err)
+		}
+	}
+
+	// A new cache over the same directory finds the stored values
+	reopened, err := New(4, dir, 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if reopened.DiskBytes() != 8 {
+		t.Errorf("DiskBytes() = %d, want 8", reopened.DiskBytes())
+	}
+	if _, tier, ok := reopened.Get("bbbb"); !ok || tier != TierDisk {
+		t.Errorf("Get(bbbb) after reopening = %v, %q, want a disk hit", ok, tier)
+	}
+}
+
+func TestDiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(0, dir, 10)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	c.Put("aaaa", []byte("1234"))
+	c.Put("bbbb", []byte("5678"))
+	// Make aaaa the oldest file, then write one over the budget
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "aa", "aaaa"), old, old)
+	c.Put("cccc", []byte("9012"))
+
+	if _, _, ok := c.Get("aaaa"); ok {
+		t.Error("oldest value should have been removed from disk")
+	}
+	for _, key := range []string{"bbbb", "cccc"} {
+		if _, _, ok := c.Get(key); !ok {
+			t.Errorf("%s should still be on disk", key)
+		}
+	}
+	if c.DiskBytes() != 8 {
+		t.Errorf("DiskBytes() = %d, want 8", c.DiskBytes())
+	}
+}
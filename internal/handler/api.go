@@ -0,0 +1,558 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Error codes returned in the error envelope, so clients can act on a failure without parsing its message
+const (
+	CodeInvalidBody        = "INVALID_BODY"
+	CodeMissingFile        = "MISSING_FILE"
+	CodeMissingField       = "MISSING_FIELD"
+	CodeInvalidCardType    = "INVALID_CARD_TYPE"
+	CodeInvalidColorMode   = "INVALID_COLOR_MODE"
+	CodeInvalidFormat      = "INVALID_FORMAT"
+	CodeInvalidTransform   = "INVALID_TRANSFORM"
+	CodeInvalidPreprocess  = "INVALID_PREPROCESS"
+	CodeInvalidSett        = "INVALID_SETT"
+	CodeInvalidFrame       = "INVALID_FRAME"
+	CodeInvalidLoomProfile = "INVALID_LOOM_PROFILE"
+	CodeInvalidPageSize    = "INVALID_PAGE_SIZE"
+	CodeInvalidAlpha       = "INVALID_TRANSPARENCY"
+	CodeInvalidAnchor      = "INVALID_ANCHOR"
+	CodeInvalidCardLayout  = "INVALID_CARD_LAYOUT"
+	CodeInvalidLettering   = "INVALID_LETTERING"
+	CodeInvalidPosition    = "INVALID_POSITION"
+	CodeInvalidRange       = "INVALID_RANGE"
+	CodeInvalidWeave       = "INVALID_WEAVE"
+	CodeInvalidChain       = "INVALID_CHAIN"
+	CodeInvalidLoop        = "INVALID_LOOP"
+	CodeInvalidSection     = "INVALID_SECTION"
+	CodeUploadTooLarge     = "UPLOAD_TOO_LARGE"
+	CodeImageTooLarge      = "IMAGE_TOO_LARGE"
+	CodeImageDecodeFailed  = "IMAGE_DECODE_FAILED"
+	CodeEmptyImage         = "EMPTY_IMAGE"
+	CodeCardSetParseFailed = "CARD_SET_PARSE_FAILED"
+	CodeWidthMismatch      = "WIDTH_MISMATCH"
+	CodeDigitizeFailed     = "DIGITIZE_FAILED"
+	CodeRenderFailed       = "RENDER_FAILED"
+	CodeGenerateFailed     = "GENERATE_FAILED"
+	CodeExportFailed       = "EXPORT_FAILED"
+	CodeNotAcceptable      = "NOT_ACCEPTABLE"
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	CodeNotFound           = "NOT_FOUND"
+	CodeInvalidUser        = "INVALID_USER"
+	CodeNameTaken          = "NAME_TAKEN"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeQuotaExceeded      = "QUOTA_EXCEEDED"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeOverloaded         = "OVERLOADED"
+	CodeInternal           = "INTERNAL"
+)
+
+// APIError is a failed request, written as a JSON envelope on /api/v1 and as plain text elsewhere
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// FieldError describes the problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// newAPIError creates an error without field details
+func newAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// invalidOption reports a rejected option as 400 Bad Request
+// The detail names the offending field when err is a FieldError, and otherwise the option group
+// Errors that already say what is invalid, e.g. "invalid format: …", are not prefixed again
+func invalidOption(code, field, label string, err error) *APIError {
+	detail := FieldError{Field: field, Message: err.Error()}
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		detail = *fe
+	}
+	message := fmt.Sprintf("Invalid %s: %v", label, err)
+	if rest := strings.TrimPrefix(err.Error(), "invalid "); rest != err.Error() {
+		message = "Invalid " + rest
+	}
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    code,
+		Message: message,
+		Details: []FieldError{detail},
+	}
+}
+
+// apiPrefix is the path prefix of the versioned JSON API
+const apiPrefix = "/api/v1/"
+
+// isAPIRequest reports whether a request is for the versioned API, which answers errors in JSON
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix)
+}
+
+// writeError sends an error as the JSON envelope for API requests and as plain text otherwise
+func writeError(w http.ResponseWriter, r *http.Request, e *APIError) {
+	if !isAPIRequest(r) {
+		http.Error(w, e.Message, e.Status)
+		return
+	}
+
+	body := *e
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		body.RequestID = info.id
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(struct {
+		Error *APIError `json:"error"`
+	}{&body})
+}
+
+// result is what an endpoint sends back on success
+type result struct {
+	status      int // 200 OK when not set
+	contentType string
+	body        []byte
+	filename    string            // Sent as an attachment with this name when set
+	headers     map[string]string // Extra response headers
+}
+
+// jsonResult encodes a value as a JSON response
+func jsonResult(v interface{}) *result {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v)
+	return &result{contentType: "application/json", body: buf.Bytes()}
+}
+
+// upload is a file sent with a request, as a multipart file or inside a JSON body
+type upload struct {
+	Filename string
+	Data     []byte
+}
+
+// Size returns the length of the file in bytes
+func (u *upload) Size() int64 {
+	return int64(len(u.Data))
+}
+
+// uploads are the files of a request by field name
+type uploads map[string]*upload
+
+// get returns a required file, failing with MISSING_FILE when it was not sent
+func (u uploads) get(field string) (*upload, *APIError) {
+	if f, ok := u[field]; ok {
+		return f, nil
+	}
+	return nil, &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeMissingFile,
+		Message: "Failed to get uploaded file",
+		Details: []FieldError{{Field: field, Message: "no file uploaded"}},
+	}
+}
+
+// operation is the shared implementation of an endpoint, used by both the API and the legacy routes
+// Options are read with r.FormValue whichever encoding the request used
+type operation func(r *http.Request, files uploads) (*result, *APIError)
+
+// serve runs an operation for a POST request and writes its result or error
+// Results carry an ETag; a request repeating one listed in If-None-Match gets 304 without running the operation
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, op operation) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"))
+		return
+	}
+
+	files, apiErr := h.parseInput(w, r)
+	if apiErr == nil {
+		apiErr = h.applyWorkspace(r, files)
+	}
+	if apiErr == nil {
+		// The same request always gets the same response, so a client holding it need not wait for it again
+		etag := h.requestETag(r, files)
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+			w.Header().Set("ETag", etag)
+			if isAPIRequest(r) {
+				w.Header().Add("Vary", "Accept")
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var res *result
+		res, apiErr = op(r, files)
+		if apiErr == nil {
+			w.Header().Set("ETag", etag)
+			h.writeResult(w, r, res)
+			return
+		}
+	}
+	writeError(w, r, apiErr)
+}
+
+// writeResult sends a successful result
+func (h *Handler) writeResult(w http.ResponseWriter, r *http.Request, res *result) {
+	for name, value := range res.headers {
+		w.Header().Set(name, value)
+	}
+	if res.status == http.StatusNoContent {
+		w.WriteHeader(res.status)
+		return
+	}
+	w.Header().Set("Content-Type", res.contentType)
+	if res.filename != "" {
+		// Quoted when needed, since stored workspace items keep the names users gave them
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": res.filename}))
+	}
+	if isAPIRequest(r) {
+		// The format may have been picked from the Accept header
+		w.Header().Add("Vary", "Accept")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(res.body)))
+	if res.status != 0 {
+		w.WriteHeader(res.status)
+	}
+
+	if _, err := w.Write(res.body); err != nil {
+		logger(r).Warn("failed to write response", "error", err)
+	}
+}
+
+// parseInput reads the request options and files, limiting the body to MaxUploadBytes
+// Options come from a multipart or URL-encoded form, or from a JSON object whose keys are
+// the form field names; in JSON, files are objects with a filename and base64 data or text
+func (h *Handler) parseInput(w http.ResponseWriter, r *http.Request) (uploads, *APIError) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		return h.parseJSONInput(r)
+	}
+
+	err := r.ParseMultipartForm(h.MaxUploadBytes)
+	if errors.Is(err, http.ErrNotMultipart) {
+		// A URL-encoded form was parsed into r.Form before the multipart check
+		err = nil
+	}
+	if err != nil {
+		return nil, h.bodyError(err, "Failed to parse form")
+	}
+
+	files := uploads{}
+	if r.MultipartForm == nil {
+		return files, nil
+	}
+	for field, headers := range r.MultipartForm.File {
+		if len(headers) == 0 {
+			continue
+		}
+		file, err := headers[0].Open()
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to read file")
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to read file")
+		}
+		files[field] = &upload{Filename: headers[0].Filename, Data: data}
+	}
+	return files, nil
+}
+
+// jsonFile is a file in a JSON request body
+type jsonFile struct {
+	Filename string  `json:"filename"`
+	Data     *string `json:"data"` // Base64-encoded content, for images
+	Text     *string `json:"text"` // Plain content, for text and JSON card sets
+}
+
+// parseJSONInput reads a JSON object into r.Form, keeping its files aside
+// Strings, numbers and booleans become form values and arrays of them comma-separated lists
+func (h *Handler) parseJSONInput(r *http.Request) (uploads, *APIError) {
+	var fields map[string]json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, h.bodyError(err, fmt.Sprintf("Invalid JSON body: %v", err))
+	}
+
+	form := url.Values{}
+	for key, values := range r.URL.Query() {
+		form[key] = values
+	}
+	files := uploads{}
+	for key, raw := range fields {
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case len(raw) == 0 || string(raw) == "null":
+			continue
+		case raw[0] == '{':
+			file, err := decodeJSONFile(raw)
+			if err != nil {
+				return nil, &APIError{
+					Status:  http.StatusBadRequest,
+					Code:    CodeInvalidBody,
+					Message: fmt.Sprintf("Invalid file %s: %v", key, err),
+					Details: []FieldError{{Field: key, Message: err.Error()}},
+				}
+			}
+			files[key] = file
+		case raw[0] == '[':
+			var items []interface{}
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, jsonFieldError(key, err)
+			}
+			parts := make([]string, 0, len(items))
+			for _, item := range items {
+				value, err := jsonScalar(item)
+				if err != nil {
+					return nil, jsonFieldError(key, err)
+				}
+				parts = append(parts, value)
+			}
+			form.Set(key, strings.Join(parts, ","))
+		default:
+			var item interface{}
+			d := json.NewDecoder(bytes.NewReader(raw))
+			d.UseNumber()
+			if err := d.Decode(&item); err != nil {
+				return nil, jsonFieldError(key, err)
+			}
+			value, err := jsonScalar(item)
+			if err != nil {
+				return nil, jsonFieldError(key, err)
+			}
+			form.Set(key, value)
+		}
+	}
+
+	r.Form = form
+	r.PostForm = form
+	return files, nil
+}
+
+// decodeJSONFile decodes a file object from a JSON request body
+func decodeJSONFile(raw json.RawMessage) (*upload, error) {
+	var f jsonFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, err
+	}
+	switch {
+	case f.Data != nil:
+		data, err := base64.StdEncoding.DecodeString(*f.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		return &upload{Filename: f.Filename, Data: data}, nil
+	case f.Text != nil:
+		return &upload{Filename: f.Filename, Data: []byte(*f.Text)}, nil
+	default:
+		return nil, fmt.Errorf("file has neither data nor text")
+	}
+}
+
+// jsonScalar formats a JSON string, number or boolean as a form value
+func jsonScalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("must be a string, number or boolean")
+	}
+}
+
+// jsonFieldError reports a JSON body field with an unusable value
+func jsonFieldError(field string, err error) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeInvalidBody,
+		Message: fmt.Sprintf("Invalid %s: %v", field, err),
+		Details: []FieldError{{Field: field, Message: err.Error()}},
+	}
+}
+
+// bodyError reports a request body that could not be read, telling an oversized upload apart
+func (h *Handler) bodyError(err error, message string) *APIError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newAPIError(http.StatusRequestEntityTooLarge, CodeUploadTooLarge,
+			fmt.Sprintf("Upload too large (limit is %d bytes)", h.MaxUploadBytes))
+	}
+	return newAPIError(http.StatusBadRequest, CodeInvalidBody, message)
+}
+
+// negotiableFormats are the download formats the Accept header can select, in order of preference
+var negotiableFormats = []struct {
+	format    string
+	mediaType string
+}{
+	{"svg", "image/svg+xml"},
+	{"txt", "text/plain"},
+	{"json", "application/json"},
+}
+
+// requestFormat returns the download format: the format option when given, and otherwise, on the
+// API, the one the Accept header prefers among SVG, TXT and JSON
+func requestFormat(r *http.Request) (string, *APIError) {
+	format := r.FormValue("format")
+	if format == "" && isAPIRequest(r) {
+		var ok bool
+		format, ok = negotiateFormat(r.Header.Get("Accept"))
+		if !ok {
+			return "", &APIError{
+				Status:  http.StatusNotAcceptable,
+				Code:    CodeNotAcceptable,
+				Message: "None of the accepted media types can be produced (use image/svg+xml, text/plain or application/json, or the format option)",
+				Details: []FieldError{{Field: "Accept", Message: r.Header.Get("Accept")}},
+			}
+		}
+	}
+	if format == "" {
+		format = "svg" // Default to SVG
+	}
+	if err := validateExportFormat(format); err != nil {
+		return "", invalidOption(CodeInvalidFormat, "format", "format", err)
+	}
+	return format, nil
+}
+
+// negotiateFormat picks the format the Accept header rates highest, preferring SVG, then TXT, then JSON on ties
+// An absent header accepts anything; ok is false when no format is acceptable
+func negotiateFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return negotiableFormats[0].format, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, candidate := range negotiableFormats {
+		if q := acceptQuality(accept, candidate.mediaType); q > bestQ {
+			best, bestQ = candidate.format, q
+		}
+	}
+	return best, best != ""
+}
+
+// acceptQuality returns the q-value the Accept header gives a media type, from its most specific matching range
+func acceptQuality(accept, mediaType string) float64 {
+	typ := strings.SplitN(mediaType, "/", 2)[0]
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch rangeType {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality
+}
+
+// API endpoints: thin names for the shared operations under /api/v1
+
+// APIConvertHandler converts an image into punchcards in the requested or negotiated format
+func (h *Handler) APIConvertHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.convertImage)
+}
+
+// APIPreviewHandler returns an SVG preview of the first cards of an image
+func (h *Handler) APIPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.previewImage)
+}
+
+// APIInfoHandler returns information about the punchcards for an image
+func (h *Handler) APIInfoHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.imageInfo)
+}
+
+// APICardSetConvertHandler converts a text or JSON card set into another format
+func (h *Handler) APICardSetConvertHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.convertCardSet)
+}
+
+// APICardSetPreviewHandler returns an SVG preview of the first cards of a card set
+func (h *Handler) APICardSetPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.previewCardSet)
+}
+
+// APICardSetInfoHandler returns information about a card set
+func (h *Handler) APICardSetInfoHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.cardSetInfo)
+}
+
+// APICardSetStatsHandler returns hook usage statistics for a card set
+func (h *Handler) APICardSetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.cardSetStats)
+}
+
+// APIDigitizeHandler reads the hole pattern from a scan of a physical card
+func (h *Handler) APIDigitizeHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.digitizeCard)
+}
+
+// APILetteringHandler renders text as punchcards in the requested or negotiated format
+func (h *Handler) APILetteringHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.renderLettering)
+}
+
+// APINotFoundHandler answers unknown API paths with the JSON error envelope
+func (h *Handler) APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, newAPIError(http.StatusNotFound, CodeNotFound, fmt.Sprintf("No API endpoint at %s", r.URL.Path)))
+}
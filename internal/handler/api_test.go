@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/logging"
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+func TestMain(m *testing.M) {
+	// Requests log through the default logger, which would bury the test output
+	logging.SetDefault(logging.New(io.Discard))
+	os.Exit(m.Run())
+}
+
+// newTestHandler creates a handler serving a minimal page template
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	h, err := NewHandler(fstest.MapFS{"index.html": {Data: []byte("{{.BasePath}}")}})
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	return h
+}
+
+// testPNG encodes a striped image one 26x8 card wide
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 208, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 208; x++ {
+			if (x/8+y/4)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// multipartRequest builds a POST with the given form fields and files, keyed by field name
+func multipartRequest(t *testing.T, target string, fields map[string]string, files map[string][]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for name, data := range files {
+		fw, err := mw.CreateFormFile(name, name+".png")
+		if err != nil {
+			t.Fatalf("CreateFormFile() error = %v", err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// jsonRequest builds a POST with a JSON body
+func jsonRequest(t *testing.T, target string, body interface{}) *http.Request {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// decodeError reads the JSON error envelope of a response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) *APIError {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("error Content-Type = %q, want application/json (body %q)", ct, w.Body.String())
+	}
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil || envelope.Error == nil {
+		t.Fatalf("error body %q is not an error envelope: %v", w.Body.String(), err)
+	}
+	return envelope.Error
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	h := newTestHandler(t)
+	image := testPNG(t)
+
+	tests := []struct {
+		name       string
+		fields     map[string]string
+		files      map[string][]byte
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{"missing image", nil, nil, http.StatusBadRequest, CodeMissingFile, "image"},
+		{"card type", map[string]string{"cardType": "9x9"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidCardType, "cardType"},
+		{"format", map[string]string{"format": "gif"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidFormat, "format"},
+		{"white point", map[string]string{"whitePoint": "0"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidPreprocess, "preprocess"},
+		{"NaN gamma", map[string]string{"gamma": "NaN"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidPreprocess, "gamma"},
+		{"infinite ends per cm", map[string]string{"endsPerCm": "Inf"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidSett, "endsPerCm"},
+		{"sett", map[string]string{"wovenHeight": "10"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeInvalidSett, "sett"},
+		{"sett over the cell limit", map[string]string{"endsPerCm": "200", "wovenHeight": "1000"}, map[string][]byte{"image": image}, http.StatusBadRequest, CodeImageTooLarge, "image"},
+		{"undecodable image", nil, map[string][]byte{"image": []byte("not an image")}, http.StatusBadRequest, CodeImageDecodeFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.APIConvertHandler(w, multipartRequest(t, "/api/v1/convert", tt.fields, tt.files))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			apiErr := decodeError(t, w)
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("code = %s, want %s", apiErr.Code, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(apiErr.Details) == 0 || apiErr.Details[0].Field != tt.wantField) {
+				t.Errorf("details = %+v, want field %s", apiErr.Details, tt.wantField)
+			}
+			if strings.Contains(strings.ToLower(apiErr.Message), "invalid format: invalid") {
+				t.Errorf("message %q repeats itself", apiErr.Message)
+			}
+		})
+	}
+}
+
+func TestAPIMethodAndPath(t *testing.T) {
+	h := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	h.APIConvertHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/convert", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET = %d with Allow %q, want 405 with Allow POST", w.Code, w.Header().Get("Allow"))
+	}
+	if code := decodeError(t, w).Code; code != CodeMethodNotAllowed {
+		t.Errorf("GET code = %s, want %s", code, CodeMethodNotAllowed)
+	}
+
+	w = httptest.NewRecorder()
+	h.APINotFoundHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/nothing", nil))
+	if w.Code != http.StatusNotFound || decodeError(t, w).Code != CodeNotFound {
+		t.Errorf("unknown path = %d, want 404 %s", w.Code, CodeNotFound)
+	}
+
+	// The legacy routes answer errors in plain text
+	w = httptest.NewRecorder()
+	h.UploadHandler(w, multipartRequest(t, "/upload", nil, nil))
+	if w.Code != http.StatusBadRequest || strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("legacy error = %d %s, want 400 in plain text", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestAPIUploadTooLarge(t *testing.T) {
+	h := newTestHandler(t)
+	h.MaxUploadBytes = 1024
+
+	w := httptest.NewRecorder()
+	h.APIConvertHandler(w, multipartRequest(t, "/api/v1/convert", nil, map[string][]byte{"image": make([]byte, 4096)}))
+	if w.Code != http.StatusRequestEntityTooLarge || decodeError(t, w).Code != CodeUploadTooLarge {
+		t.Errorf("oversized upload = %d, want 413 %s", w.Code, CodeUploadTooLarge)
+	}
+}
+
+func TestAPIOutputTooLarge(t *testing.T) {
+	h := newTestHandler(t)
+
+	// A 2x2000 image is small, but 26 cards of 50x12 wide it resamples to 15.6 million picks
+	tall := image.NewGray(image.Rect(0, 0, 2, 2000))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, tall); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	w := httptest.NewRecorder()
+	h.APIConvertHandler(w, multipartRequest(t, "/api/v1/convert",
+		map[string]string{"cardType": "50x12", "cardsPerRow": "26"}, map[string][]byte{"image": buf.Bytes()}))
+	if w.Code != http.StatusBadRequest || decodeError(t, w).Code != CodeImageTooLarge {
+		t.Errorf("tall image = %d %s, want 400 %s", w.Code, w.Body.String(), CodeImageTooLarge)
+	}
+
+	// Header, footer and repeated designs expand the chain after the image is resampled
+	h.MaxCells = 208 * 1000
+	w = httptest.NewRecorder()
+	h.APIConvertHandler(w, multipartRequest(t, "/api/v1/convert",
+		map[string]string{"headerCards": "1000", "designRepeats": "2"}, map[string][]byte{"image": testPNG(t)}))
+	if w.Code != http.StatusBadRequest || decodeError(t, w).Code != CodeImageTooLarge {
+		t.Errorf("long chain = %d %s, want 400 %s", w.Code, w.Body.String(), CodeImageTooLarge)
+	}
+}
+
+func TestAPIInfoEstimateError(t *testing.T) {
+	h := newTestHandler(t)
+
+	// A woven width this narrow passes the sett check, but the weft density it implies is infinite
+	w := httptest.NewRecorder()
+	h.APIInfoHandler(w, multipartRequest(t, "/api/v1/info",
+		map[string]string{"wovenWidth": "1e-320"}, map[string][]byte{"image": testPNG(t)}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 (body %q)", w.Code, w.Body.String())
+	}
+	if apiErr := decodeError(t, w); apiErr.Code != CodeInvalidLoomProfile {
+		t.Errorf("code = %s, want %s", apiErr.Code, CodeInvalidLoomProfile)
+	}
+}
+
+func TestAPIAcceptNegotiation(t *testing.T) {
+	h := newTestHandler(t)
+	image := testPNG(t)
+
+	tests := []struct {
+		name            string
+		accept          string
+		format          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"no header", "", "", http.StatusOK, "image/svg+xml"},
+		{"any", "*/*", "", http.StatusOK, "image/svg+xml"},
+		{"text", "text/plain", "", http.StatusOK, "text/plain; charset=utf-8"},
+		{"json", "application/json", "", http.StatusOK, "application/json"},
+		{"weighted", "image/svg+xml;q=0.5, application/json", "", http.StatusOK, "application/json"},
+		{"format wins", "application/json", "txt", http.StatusOK, "text/plain; charset=utf-8"},
+		{"unacceptable", "image/png", "", http.StatusNotAcceptable, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{}
+			if tt.format != "" {
+				fields["format"] = tt.format
+			}
+			r := multipartRequest(t, "/api/v1/convert", fields, map[string][]byte{"image": image})
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			h.APIConvertHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantContentType)
+			}
+			if w.Code == http.StatusNotAcceptable {
+				if apiErr := decodeError(t, w); apiErr.Code != CodeNotAcceptable || apiErr.Details[0].Field != "Accept" {
+					t.Errorf("error = %+v, want %s on Accept", apiErr, CodeNotAcceptable)
+				}
+				return
+			}
+			if vary := w.Header().Get("Vary"); !strings.Contains(vary, "Accept") {
+				t.Errorf("Vary = %q, want Accept", vary)
+			}
+		})
+	}
+}
+
+func TestAPIJSONBody(t *testing.T) {
+	h := newTestHandler(t)
+	encoded := base64.StdEncoding.EncodeToString(testPNG(t))
+
+	t.Run("base64 image", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.APIConvertHandler(w, jsonRequest(t, "/api/v1/convert", map[string]interface{}{
+			"image":     map[string]string{"filename": "stripes.png", "data": encoded},
+			"format":    "txt",
+			"mirrorH":   true,
+			"colorMode": 2,
+		}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %q)", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "Card 1:") || w.Header().Get("X-Punchcard-Transform") != "mirror-h" {
+			t.Errorf("response = %q with transform %q, want text cards mirrored", w.Body.String(), w.Header().Get("X-Punchcard-Transform"))
+		}
+	})
+
+	t.Run("text card set", func(t *testing.T) {
+		var text bytes.Buffer
+		exporter := punchcard.NewTextExporter()
+		exporter.SetTitle("Test", 1)
+		card := &punchcard.Card{Number: 1, Width: 26, Height: 8, Matrix: make([][]int, 8)}
+		for y := range card.Matrix {
+			card.Matrix[y] = make([]int, 26)
+		}
+		if err := exporter.ExportCards([]*punchcard.Card{card}, &text); err != nil {
+			t.Fatalf("ExportCards() error = %v", err)
+		}
+		w := httptest.NewRecorder()
+		h.APICardSetStatsHandler(w, jsonRequest(t, "/api/v1/cardsets/stats", map[string]interface{}{
+			"textfile": map[string]string{"filename": "cards.txt", "text": text.String()},
+		}))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"totalCards":1`) {
+			t.Errorf("stats = %d %q, want one card", w.Code, w.Body.String())
+		}
+	})
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"malformed", `{"format": `, ""},
+		{"bad base64", `{"image": {"filename": "a.png", "data": "!!!"}}`, "image"},
+		{"empty file", `{"image": {"filename": "a.png"}}`, "image"},
+		{"nested option", `{"format": {"value": "txt"}, "image": {"data": ""}}`, "format"},
+		{"object in list", `{"image": {"data": ""}, "cardType": [{"a": 1}]}`, "cardType"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/convert", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.APIConvertHandler(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (body %q)", w.Code, w.Body.String())
+			}
+			apiErr := decodeError(t, w)
+			if apiErr.Code != CodeInvalidBody {
+				t.Errorf("code = %s, want %s", apiErr.Code, CodeInvalidBody)
+			}
+			if tt.wantField != "" && (len(apiErr.Details) == 0 || apiErr.Details[0].Field != tt.wantField) {
+				t.Errorf("details = %+v, want field %s", apiErr.Details, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestAPIRangedExport(t *testing.T) {
+	h := newTestHandler(t)
+	image := testPNG(t)
+
+	// Every format labels the exported cards against the whole set, not the range
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"svg", "Card #3/16"},
+		{"txt", "Card 3:"},
+		{"pdf", "cards 2-3 of 16"},
+		{"zip", "cards 2-3 of 16"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r := multipartRequest(t, "/api/v1/convert", map[string]string{"format": tt.format, "from": "2", "to": "3"}, map[string][]byte{"image": image})
+			w := httptest.NewRecorder()
+			h.APIConvertHandler(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (body %q)", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("X-Punchcard-Range"); got != "2-3" {
+				t.Errorf("X-Punchcard-Range = %q, want 2-3", got)
+			}
+			if got := w.Header().Get("X-Punchcard-Total"); got != "16" {
+				t.Errorf("X-Punchcard-Total = %q, want 16", got)
+			}
+
+			body := w.Body.Bytes()
+			if tt.format == "zip" {
+				body = firstZipFile(t, body)
+			}
+			if !bytes.Contains(body, []byte(tt.want)) {
+				t.Errorf("%s export does not contain %q", tt.format, tt.want)
+			}
+		})
+	}
+}
+
+// firstZipFile reads the first file of a zip archive
+func firstZipFile(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(zr.File) == 0 {
+		t.Fatalf("zip.NewReader() = %v, %v", zr, err)
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return content
+}
@@ -0,0 +1,570 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+	"github.com/oscaralmgren/loom-punchcards/internal/logging"
+)
+
+// SessionCookie is the cookie holding the session of a signed-in browser
+const SessionCookie = "loom_session"
+
+// LocalUserID owns the workspace used when authentication is disabled
+const LocalUserID = "local"
+
+// Sign-in attempts allowed per second, and at once, from one client IP and against one user name
+const (
+	LoginRate  = 0.1
+	LoginBurst = 10
+)
+
+type userKey struct{}
+
+type apiKeyIDKey struct{}
+
+// user returns the user a request was authenticated as
+// Without authentication every request acts as the local user, who may do anything
+func (h *Handler) user(r *http.Request) *auth.User {
+	if u, ok := r.Context().Value(userKey{}).(*auth.User); ok {
+		return u
+	}
+	if h.Auth == nil {
+		return &auth.User{ID: LocalUserID, Name: LocalUserID, Role: auth.RoleAdmin, Quota: h.DefaultQuota}
+	}
+	return nil
+}
+
+// Authenticate requires an API key, sent as "Authorization: Bearer <key>" or X-API-Key, or a session cookie
+// Unauthenticated requests get 401 Unauthorized; with authentication disabled every request passes
+func (h *Handler) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Auth == nil {
+			next(w, r)
+			return
+		}
+
+		u, viaCookie, err := h.authenticate(r)
+		if err != nil {
+			if !errors.Is(err, auth.ErrInvalidCredentials) {
+				logger(r).Error("failed to authenticate", "error", err)
+				writeError(w, r, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to authenticate"))
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="loom"`)
+			writeError(w, r, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Sign in or send an API key"))
+			return
+		}
+
+		// Browsers send cookies with requests other sites make, so changes must come from this site
+		if viaCookie && !safeMethod(r.Method) && !h.sameOrigin(r) {
+			writeError(w, r, newAPIError(http.StatusForbidden, CodeForbidden, "Cross-site request refused"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey{}, u)
+		if !viaCookie {
+			// Only a verified key identifies the client for rate limiting
+			if id, _, ok := auth.ParseKey(requestKey(r)); ok {
+				ctx = context.WithValue(ctx, apiKeyIDKey{}, id)
+			}
+		}
+		ctx = logging.NewContext(ctx, logger(r).With("user", u.Name))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// authenticate finds the user of a request's API key or session cookie
+func (h *Handler) authenticate(r *http.Request) (*auth.User, bool, error) {
+	if key := requestKey(r); key != "" {
+		u, err := h.Auth.AuthenticateKey(key)
+		return u, false, err
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return nil, false, auth.ErrInvalidCredentials
+	}
+	u, err := h.Auth.Session(cookie.Value)
+	return u, true, err
+}
+
+// requestKey returns the API key a request sends, as a bearer token or X-API-Key, or ""
+func requestKey(r *http.Request) string {
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(bearer, "Bearer "))
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// RequireAdmin lets only admins through; it is used inside Authenticate
+// The admin endpoints manage the user store, so they do not exist without authentication
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Auth == nil {
+			writeError(w, r, newAPIError(http.StatusNotFound, CodeNotFound, "Authentication is disabled"))
+			return
+		}
+		if u := h.user(r); u == nil || !u.IsAdmin() {
+			writeError(w, r, newAPIError(http.StatusForbidden, CodeForbidden, "Only admins may do this"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// safeMethod reports whether a method only reads
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin reports whether a request's Origin, when it has one, is the host it was sent to
+func (h *Handler) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); h.TrustProxy && forwarded != "" {
+		host = forwarded
+	}
+	return u.Host == host
+}
+
+// userView is a user as the API shows it, without password or key hashes
+type userView struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	HasPassword bool       `json:"hasPassword"`
+	Keys        []keyView  `json:"keys"`
+	Quota       auth.Quota `json:"quota"`
+	Created     time.Time  `json:"created"`
+}
+
+// keyView is an API key as the API shows it, without its hash
+type keyView struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+func newUserView(u *auth.User) userView {
+	v := userView{
+		ID:          u.ID,
+		Name:        u.Name,
+		Role:        u.Role,
+		HasPassword: u.PasswordHash != "",
+		Keys:        []keyView{},
+		Quota:       u.Quota,
+		Created:     u.Created,
+	}
+	for _, k := range u.Keys {
+		v.Keys = append(v.Keys, keyView{ID: k.ID, Name: k.Name, Created: k.Created})
+	}
+	return v
+}
+
+// LoginHandler signs a user in with their name and password, setting the session cookie
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveJSON(w, r, []string{http.MethodPost}, func(r *http.Request) (*result, *APIError) {
+		if h.Auth == nil {
+			return nil, newAPIError(http.StatusNotFound, CodeNotFound, "Authentication is disabled")
+		}
+		if _, apiErr := h.parseInput(w, r); apiErr != nil {
+			return nil, apiErr
+		}
+		if apiErr := h.limitLogin(w, r, r.FormValue("name")); apiErr != nil {
+			return nil, apiErr
+		}
+		u, token, err := h.Auth.Login(r.FormValue("name"), r.FormValue("password"))
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			setErrorClass(r, "unauthorized")
+			return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Wrong user name or password")
+		}
+		if err != nil {
+			logger(r).Error("failed to sign in", "error", err)
+			return nil, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to sign in")
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookie,
+			Value:    token,
+			Path:     h.cookiePath(),
+			MaxAge:   int(h.Auth.SessionTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   h.SecureCookies,
+			SameSite: http.SameSiteLaxMode,
+		})
+		logger(r).Info("signed in", "user", u.Name)
+		return jsonResult(newUserView(u)), nil
+	})
+}
+
+// limitLogin throttles sign-in attempts by client IP and by the user name tried, whatever identifies
+// clients for the conversion rate limit, so passwords cannot be guessed quickly from one address or
+// against one account from many
+func (h *Handler) limitLogin(w http.ResponseWriter, r *http.Request, name string) *APIError {
+	if h.LoginLimiter == nil {
+		return nil
+	}
+	for _, key := range []string{"ip:" + h.clientIP(r), "name:" + strings.ToLower(name)} {
+		if ok, wait := h.LoginLimiter.Allow(key); !ok {
+			setErrorClass(r, "rate_limited")
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			return newAPIError(http.StatusTooManyRequests, CodeRateLimited, "Too many sign-in attempts, please wait")
+		}
+	}
+	return nil
+}
+
+// LogoutHandler ends the session of the request's cookie
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveJSON(w, r, []string{http.MethodPost}, func(r *http.Request) (*result, *APIError) {
+		if h.Auth == nil {
+			return nil, newAPIError(http.StatusNotFound, CodeNotFound, "Authentication is disabled")
+		}
+		if cookie, err := r.Cookie(SessionCookie); err == nil {
+			h.Auth.Logout(cookie.Value)
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookie,
+			Path:     h.cookiePath(),
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   h.SecureCookies,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return &result{status: http.StatusNoContent}, nil
+	})
+}
+
+// MeResponse describes the requesting user and their workspace
+type MeResponse struct {
+	AuthEnabled bool       `json:"authEnabled"`
+	Workspaces  bool       `json:"workspaces"` // Whether workspaces are enabled
+	User        userView   `json:"user"`
+	Usage       *usageView `json:"usage,omitempty"`
+}
+
+// MeHandler returns the requesting user, their quota and how much of it they use
+func (h *Handler) MeHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveJSON(w, r, []string{http.MethodGet}, func(r *http.Request) (*result, *APIError) {
+		u := h.user(r)
+		resp := MeResponse{AuthEnabled: h.Auth != nil, Workspaces: h.Workspaces != nil, User: newUserView(u)}
+		if h.Workspaces != nil {
+			usage, apiErr := h.workspaceUsage(r, u)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			resp.Usage = usage
+		}
+		return jsonResult(&resp), nil
+	})
+}
+
+// cookiePath scopes the session cookie to the application
+func (h *Handler) cookiePath() string {
+	return h.BasePath + "/"
+}
+
+// serveJSON runs a request on one of the JSON management endpoints, which answer only the given methods
+func (h *Handler) serveJSON(w http.ResponseWriter, r *http.Request, methods []string, op func(r *http.Request) (*result, *APIError)) {
+	allowed := false
+	for _, m := range methods {
+		allowed = allowed || r.Method == m
+	}
+	if !allowed {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeError(w, r, newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"))
+		return
+	}
+
+	res, apiErr := op(r)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	h.writeResult(w, r, res)
+}
+
+// AdminUsersHandler manages users and their API keys under /api/v1/admin/users:
+//
+//	GET, POST            /users                    list or create users
+//	GET, PATCH, DELETE   /users/{id}               show, change or delete a user
+//	GET, POST            /users/{id}/keys          list or create a user's API keys
+//	DELETE               /users/{id}/keys/{keyId}  revoke an API key
+func (h *Handler) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	parts := pathParts(r.URL.Path, apiPrefix+"admin/users")
+	switch {
+	case len(parts) == 0:
+		h.serveJSON(w, r, []string{http.MethodGet, http.MethodPost}, func(r *http.Request) (*result, *APIError) {
+			if r.Method == http.MethodPost {
+				return h.createUser(w, r)
+			}
+			return h.listUsers(r)
+		})
+	case len(parts) == 1:
+		h.serveJSON(w, r, []string{http.MethodGet, http.MethodPatch, http.MethodDelete}, func(r *http.Request) (*result, *APIError) {
+			switch r.Method {
+			case http.MethodPatch:
+				return h.updateUser(w, r, parts[0])
+			case http.MethodDelete:
+				return h.deleteUser(r, parts[0])
+			}
+			u, apiErr := h.findUser(r, parts[0])
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			return jsonResult(newUserView(u)), nil
+		})
+	case len(parts) == 2 && parts[1] == "keys":
+		h.serveJSON(w, r, []string{http.MethodGet, http.MethodPost}, func(r *http.Request) (*result, *APIError) {
+			if r.Method == http.MethodPost {
+				return h.createKey(w, r, parts[0])
+			}
+			u, apiErr := h.findUser(r, parts[0])
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			return jsonResult(newUserView(u).Keys), nil
+		})
+	case len(parts) == 3 && parts[1] == "keys":
+		h.serveJSON(w, r, []string{http.MethodDelete}, func(r *http.Request) (*result, *APIError) {
+			return h.deleteKey(r, parts[0], parts[2])
+		})
+	default:
+		h.APINotFoundHandler(w, r)
+	}
+}
+
+// pathParts splits the path below a prefix into its segments
+func pathParts(path, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+// listUsers returns every user
+func (h *Handler) listUsers(r *http.Request) (*result, *APIError) {
+	users, err := h.Auth.Store.List()
+	if err != nil {
+		return nil, h.storeError(r, err)
+	}
+	views := make([]userView, 0, len(users))
+	for _, u := range users {
+		views = append(views, newUserView(u))
+	}
+	return jsonResult(views), nil
+}
+
+// createUser adds a user with the name, role, password and quota in the request
+// Users get the default quota unless one is given
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) (*result, *APIError) {
+	if _, apiErr := h.parseInput(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	role := r.FormValue("role")
+	if role == "" {
+		role = auth.RoleUser
+	}
+	u, err := auth.NewUser(r.FormValue("name"), role, h.DefaultQuota)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidUser, userField(err), "user", err)
+	}
+	if apiErr := applyUserChanges(r, u); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := h.Auth.Store.Put(u); err != nil {
+		return nil, h.storeError(r, err)
+	}
+
+	logger(r).Info("created user", "id", u.ID, "name", u.Name, "role", u.Role)
+	res := jsonResult(newUserView(u))
+	res.status = http.StatusCreated
+	return res, nil
+}
+
+// updateUser changes the name, role, password or quota of a user
+// Admins cannot take their own admin role away, so there is always one left
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request, id string) (*result, *APIError) {
+	if _, apiErr := h.parseInput(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	u, apiErr := h.findUser(r, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if name := r.FormValue("name"); name != "" {
+		if err := auth.ValidateName(name); err != nil {
+			return nil, invalidOption(CodeInvalidUser, "name", "user", err)
+		}
+		u.Name = name
+	}
+	if role := r.FormValue("role"); role != "" {
+		if err := auth.ValidateRole(role); err != nil {
+			return nil, invalidOption(CodeInvalidUser, "role", "user", err)
+		}
+		if u.ID == h.user(r).ID && role != auth.RoleAdmin {
+			return nil, newAPIError(http.StatusForbidden, CodeForbidden, "Admins cannot remove their own admin role")
+		}
+		u.Role = role
+	}
+	if apiErr := applyUserChanges(r, u); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := h.Auth.Store.Put(u); err != nil {
+		return nil, h.storeError(r, err)
+	}
+	if r.FormValue("password") != "" {
+		// Sessions signed in with the old password end
+		h.Auth.EndSessions(u.ID)
+	}
+
+	logger(r).Info("updated user", "id", u.ID, "name", u.Name)
+	return jsonResult(newUserView(u)), nil
+}
+
+// applyUserChanges sets the password and quota fields of a create or update request
+func applyUserChanges(r *http.Request, u *auth.User) *APIError {
+	if password := r.FormValue("password"); password != "" {
+		if err := u.SetPassword(password); err != nil {
+			return invalidOption(CodeInvalidUser, "password", "user", err)
+		}
+	}
+	if value := r.FormValue("maxBytes"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return invalidOption(CodeInvalidUser, "maxBytes", "quota",
+				fmt.Errorf("invalid maxBytes: %s (must be 0 or more, 0 = no limit)", value))
+		}
+		u.Quota.MaxBytes = n
+	}
+	if value := r.FormValue("maxItems"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return invalidOption(CodeInvalidUser, "maxItems", "quota",
+				fmt.Errorf("invalid maxItems: %s (must be 0 or more, 0 = no limit)", value))
+		}
+		u.Quota.MaxItems = n
+	}
+	return nil
+}
+
+// userField names the request field an error from auth.NewUser is about
+func userField(err error) string {
+	if strings.HasPrefix(err.Error(), "invalid role") {
+		return "role"
+	}
+	return "name"
+}
+
+// deleteUser removes a user, their sessions and their workspace
+func (h *Handler) deleteUser(r *http.Request, id string) (*result, *APIError) {
+	if id == h.user(r).ID {
+		return nil, newAPIError(http.StatusForbidden, CodeForbidden, "Admins cannot delete themselves")
+	}
+	if err := h.Auth.Store.Delete(id); err != nil {
+		return nil, h.storeError(r, err)
+	}
+	h.Auth.EndSessions(id)
+	if h.Workspaces != nil {
+		if err := h.Workspaces.DeleteOwner(id); err != nil {
+			logger(r).Warn("failed to delete workspace", "user_id", id, "error", err)
+		}
+	}
+
+	logger(r).Info("deleted user", "id", id)
+	return &result{status: http.StatusNoContent}, nil
+}
+
+// KeyResponse is a new API key; the token is shown only this once
+type KeyResponse struct {
+	Key   keyView `json:"key"`
+	Token string  `json:"token"`
+}
+
+// createKey gives a user a new API key
+func (h *Handler) createKey(w http.ResponseWriter, r *http.Request, id string) (*result, *APIError) {
+	if _, apiErr := h.parseInput(w, r); apiErr != nil {
+		return nil, apiErr
+	}
+	u, apiErr := h.findUser(r, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	token, key := auth.NewKey(r.FormValue("name"))
+	u.Keys = append(u.Keys, key)
+	if err := h.Auth.Store.Put(u); err != nil {
+		return nil, h.storeError(r, err)
+	}
+
+	logger(r).Info("created API key", "user_id", u.ID, "key_id", key.ID)
+	res := jsonResult(&KeyResponse{Key: keyView{ID: key.ID, Name: key.Name, Created: key.Created}, Token: token})
+	res.status = http.StatusCreated
+	return res, nil
+}
+
+// deleteKey revokes one of a user's API keys
+func (h *Handler) deleteKey(r *http.Request, id, keyID string) (*result, *APIError) {
+	u, apiErr := h.findUser(r, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	kept := u.Keys[:0]
+	for _, k := range u.Keys {
+		if k.ID != keyID {
+			kept = append(kept, k)
+		}
+	}
+	if len(kept) == len(u.Keys) {
+		return nil, newAPIError(http.StatusNotFound, CodeNotFound, fmt.Sprintf("No API key %s", keyID))
+	}
+	u.Keys = kept
+	if err := h.Auth.Store.Put(u); err != nil {
+		return nil, h.storeError(r, err)
+	}
+
+	logger(r).Info("revoked API key", "user_id", u.ID, "key_id", keyID)
+	return &result{status: http.StatusNoContent}, nil
+}
+
+// findUser looks a user up by ID, failing with NOT_FOUND
+func (h *Handler) findUser(r *http.Request, id string) (*auth.User, *APIError) {
+	u, err := h.Auth.Store.Get(id)
+	if err != nil {
+		return nil, h.storeError(r, err)
+	}
+	return u, nil
+}
+
+// storeError reports a failed user store operation
+func (h *Handler) storeError(r *http.Request, err error) *APIError {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		return newAPIError(http.StatusNotFound, CodeNotFound, "No such user")
+	case errors.Is(err, auth.ErrNameTaken):
+		return &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeNameTaken,
+			Message: fmt.Sprintf("Failed to save user: %v", err),
+			Details: []FieldError{{Field: "name", Message: err.Error()}},
+		}
+	}
+	logger(r).Error("user store failed", "error", err)
+	return newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to access users")
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+	"github.com/oscaralmgren/loom-punchcards/internal/limit"
+	"github.com/oscaralmgren/loom-punchcards/internal/workspace"
+)
+
+// testUser is a stored user with an API key and a password
+type testUser struct {
+	*auth.User
+	key string
+}
+
+const testPassword = "correct horse battery"
+
+// newAuthTestHandler creates a handler with authentication and workspaces in a temporary directory
+func newAuthTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := auth.OpenFileStore(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	workspaces, err := workspace.Open(filepath.Join(dir, "workspaces"))
+	if err != nil {
+		t.Fatalf("workspace.Open() error = %v", err)
+	}
+	h := newTestHandler(t)
+	h.Auth = auth.NewAuthenticator(store, time.Hour)
+	h.Workspaces = workspaces
+	return h
+}
+
+// addTestUser stores a user with the given role, an API key and testPassword
+func addTestUser(t *testing.T, h *Handler, name, role string) testUser {
+	t.Helper()
+	u, err := auth.NewUser(name, role, auth.Quota{})
+	if err != nil {
+		t.Fatalf("NewUser() error = %v", err)
+	}
+	if err := u.SetPassword(testPassword); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	token, key := auth.NewKey("test")
+	u.Keys = append(u.Keys, key)
+	if err := h.Auth.Store.Put(u); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	return testUser{User: u, key: token}
+}
+
+// signIn returns the session cookie of a user
+func signIn(t *testing.T, h *Handler, u testUser) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.LoginHandler(w, jsonRequest(t, "/api/v1/auth/login", map[string]string{"name": u.Name, "password": testPassword}))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == SessionCookie {
+			return c
+		}
+	}
+	t.Fatalf("sign in = %d %q, no session cookie", w.Code, w.Body.String())
+	return nil
+}
+
+// withKey sends a request with an API key
+func withKey(r *http.Request, key string) *http.Request {
+	r.Header.Set("Authorization", "Bearer "+key)
+	return r
+}
+
+func TestAuthenticateUnauthorized(t *testing.T) {
+	h := newAuthTestHandler(t)
+	addTestUser(t, h, "ada", auth.RoleUser)
+	convert := h.Authenticate(h.APIConvertHandler)
+
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+	}{
+		{"no credentials", func(r *http.Request) {}},
+		{"unknown key", func(r *http.Request) { withKey(r, "loom_0123456789abcdef_secret") }},
+		{"malformed key", func(r *http.Request) { r.Header.Set("X-API-Key", "not-a-key") }},
+		{"unknown session", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: SessionCookie, Value: "stale"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := multipartRequest(t, "/api/v1/convert", nil, nil)
+			tt.prepare(r)
+			w := httptest.NewRecorder()
+			convert(w, r)
+			if w.Code != http.StatusUnauthorized || decodeError(t, w).Code != CodeUnauthorized {
+				t.Fatalf("status = %d, want 401 %s (body %q)", w.Code, CodeUnauthorized, w.Body.String())
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 response has no WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	h := newAuthTestHandler(t)
+	admin := addTestUser(t, h, "grace", auth.RoleAdmin)
+	user := addTestUser(t, h, "ada", auth.RoleUser)
+	users := h.Authenticate(h.RequireAdmin(h.AdminUsersHandler))
+
+	w := httptest.NewRecorder()
+	users(w, withKey(httptest.NewRequest(http.MethodGet, "/api/v1/admin/users", nil), user.key))
+	if w.Code != http.StatusForbidden || decodeError(t, w).Code != CodeForbidden {
+		t.Errorf("user listing users = %d, want 403 %s", w.Code, CodeForbidden)
+	}
+
+	w = httptest.NewRecorder()
+	users(w, withKey(httptest.NewRequest(http.MethodGet, "/api/v1/admin/users", nil), admin.key))
+	if w.Code != http.StatusOK {
+		t.Errorf("admin listing users = %d, want 200 (body %q)", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthenticateCrossOrigin(t *testing.T) {
+	h := newAuthTestHandler(t)
+	user := addTestUser(t, h, "ada", auth.RoleUser)
+	cookie := signIn(t, h, user)
+	convert := h.Authenticate(h.APIConvertHandler)
+
+	tests := []struct {
+		name       string
+		origin     string
+		useKey     bool
+		wantStatus int
+	}{
+		// Past authentication, the request fails only for its missing image
+		{"cookie without origin", "", false, http.StatusBadRequest},
+		{"cookie from this site", "http://example.com", false, http.StatusBadRequest},
+		{"cookie from another site", "https://evil.example", false, http.StatusForbidden},
+		{"key from another site", "https://evil.example", true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := multipartRequest(t, "/api/v1/convert", nil, nil)
+			if tt.useKey {
+				withKey(r, user.key)
+			} else {
+				r.AddCookie(cookie)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			convert(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code == http.StatusForbidden && decodeError(t, w).Code != CodeForbidden {
+				t.Errorf("cross-site refusal is not %s", CodeForbidden)
+			}
+		})
+	}
+
+	// Reading is safe from anywhere
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	r.AddCookie(cookie)
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	h.Authenticate(h.MeHandler)(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("cross-site GET = %d, want 200", w.Code)
+	}
+}
+
+func TestAdminCannotDemoteOrDeleteThemselves(t *testing.T) {
+	h := newAuthTestHandler(t)
+	admin := addTestUser(t, h, "grace", auth.RoleAdmin)
+	other := addTestUser(t, h, "alan", auth.RoleAdmin)
+	users := h.Authenticate(h.RequireAdmin(h.AdminUsersHandler))
+	path := "/api/v1/admin/users/"
+
+	w := httptest.NewRecorder()
+	r := jsonRequest(t, path+admin.ID, map[string]string{"role": auth.RoleUser})
+	r.Method = http.MethodPatch
+	users(w, withKey(r, admin.key))
+	if w.Code != http.StatusForbidden || decodeError(t, w).Code != CodeForbidden {
+		t.Errorf("self-demotion = %d, want 403 %s", w.Code, CodeForbidden)
+	}
+
+	w = httptest.NewRecorder()
+	users(w, withKey(httptest.NewRequest(http.MethodDelete, path+admin.ID, nil), admin.key))
+	if w.Code != http.StatusForbidden || decodeError(t, w).Code != CodeForbidden {
+		t.Errorf("self-deletion = %d, want 403 %s", w.Code, CodeForbidden)
+	}
+	if u, err := h.Auth.Store.Get(admin.ID); err != nil || !u.IsAdmin() {
+		t.Fatalf("admin after refused changes = %v, %v, want still an admin", u, err)
+	}
+
+	// Other admins can be demoted and deleted
+	w = httptest.NewRecorder()
+	r = jsonRequest(t, path+other.ID, map[string]string{"role": auth.RoleUser})
+	r.Method = http.MethodPatch
+	users(w, withKey(r, admin.key))
+	if w.Code != http.StatusOK {
+		t.Errorf("demoting another admin = %d, want 200 (body %q)", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	users(w, withKey(httptest.NewRequest(http.MethodDelete, path+other.ID, nil), admin.key))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("deleting another admin = %d, want 204 (body %q)", w.Code, w.Body.String())
+	}
+}
+
+func TestWorkspaceItemsOfOtherUsers(t *testing.T) {
+	h := newAuthTestHandler(t)
+	owner := addTestUser(t, h, "ada", auth.RoleUser)
+	other := addTestUser(t, h, "alan", auth.RoleAdmin)
+	item, err := h.Workspaces.Put(owner.ID, workspace.KindImages, "stripes.png", "image/png", testPNG(t), auth.Quota{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	preset, err := h.Workspaces.Put(owner.ID, workspace.KindPresets, "txt", "application/json", []byte(`{"format":"txt"}`), auth.Quota{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	convert := h.Authenticate(h.APIConvertHandler)
+
+	tests := []struct {
+		name      string
+		user      testUser
+		fields    map[string]string
+		wantCode  int
+		wantField string
+	}{
+		{"owner's image", owner, map[string]string{"imageId": item.ID}, http.StatusOK, ""},
+		{"owner's preset", owner, map[string]string{"imageId": item.ID, "preset": preset.ID}, http.StatusOK, ""},
+		// Even an admin cannot reach into another user's workspace
+		{"other's image", other, map[string]string{"imageId": item.ID}, http.StatusNotFound, "imageId"},
+		{"other's preset", other, map[string]string{"preset": preset.ID}, http.StatusNotFound, "preset"},
+		{"other's card set", other, map[string]string{"cardSetId": item.ID}, http.StatusNotFound, "cardSetId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			convert(w, withKey(multipartRequest(t, "/api/v1/convert", tt.fields, nil), tt.user.key))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %q)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantField == "" {
+				return
+			}
+			if apiErr := decodeError(t, w); apiErr.Code != CodeNotFound || len(apiErr.Details) == 0 || apiErr.Details[0].Field != tt.wantField {
+				t.Errorf("error = %+v, want %s on %s", apiErr, CodeNotFound, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	h := newAuthTestHandler(t)
+	addTestUser(t, h, "ada", auth.RoleUser)
+	h.LoginLimiter = limit.NewRateLimiter(0.001, 2)
+
+	attempt := func(name, ip string) *httptest.ResponseRecorder {
+		r := jsonRequest(t, "/api/v1/auth/login", map[string]string{"name": name, "password": "wrong password"})
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.LoginHandler(w, r)
+		return w
+	}
+
+	// Two wrong guesses are answered, the third from the same address is refused
+	for i := 0; i < 2; i++ {
+		if w := attempt("ada", "192.0.2.1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d, want 401", i+1, w.Code)
+		}
+	}
+	if w := attempt("bob", "192.0.2.1"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("third attempt from one address = %d, want 429 with Retry-After", w.Code)
+	}
+
+	// Guesses at the same name from another address are refused too
+	if w := attempt("ADA", "198.51.100.7"); w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), CodeRateLimited) {
+		t.Errorf("third attempt at one name = %d %q, want 429 %s", w.Code, w.Body.String(), CodeRateLimited)
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/cache"
+	"github.com/oscaralmgren/loom-punchcards/internal/image"
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// cachedConversion is what the cache holds for an image: its binary matrix and, for a card type, its cards
+type cachedConversion struct {
+	Matrix [][]int
+	Cards  []*punchcard.Card
+}
+
+// imageCards runs the image pipeline and generates cards with the generator, or only the matrix
+// when it is nil; the result of an earlier request with the same image and options is reused
+func (h *Handler) imageCards(r *http.Request, processor *image.Processor, generator *punchcard.Generator, data []byte) ([][]int, []*punchcard.Card, *APIError) {
+	var key string
+	if h.Cache != nil {
+		key = conversionKey(r, processor, generator, data)
+		if conv, tier, ok := h.cachedConversion(key); ok {
+			h.metrics.cacheHits.Inc(tier)
+			logger(r).Info("conversion cache hit", "tier", tier, "width", len(conv.Matrix[0]), "height", len(conv.Matrix))
+			return conv.Matrix, conv.Cards, nil
+		}
+		h.metrics.cacheMisses.Inc()
+	}
+
+	matrix, apiErr := h.processImage(r, processor, data)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	var cards []*punchcard.Card
+	if generator != nil {
+		cards, apiErr = h.generateCards(r, generator, matrix)
+		if apiErr != nil {
+			return nil, nil, apiErr
+		}
+	}
+
+	if h.Cache != nil {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&cachedConversion{Matrix: matrix, Cards: cards}); err != nil {
+			logger(r).Warn("failed to encode conversion for the cache", "error", err)
+		} else if err := h.Cache.Put(key, buf.Bytes()); err != nil {
+			logger(r).Warn("failed to store conversion in the cache", "error", err)
+		}
+	}
+	return matrix, cards, nil
+}
+
+// cachedConversion looks a conversion up in the cache
+// Values are decoded on every hit, so callers may modify what they get
+func (h *Handler) cachedConversion(key string) (*cachedConversion, string, bool) {
+	data, tier, ok := h.Cache.Get(key)
+	if !ok {
+		return nil, "", false
+	}
+	var conv cachedConversion
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&conv); err != nil || len(conv.Matrix) == 0 {
+		return nil, "", false
+	}
+	return &conv, tier, true
+}
+
+// conversionKey addresses a conversion by the image bytes and every option that changes its result
+func conversionKey(r *http.Request, processor *image.Processor, generator *punchcard.Generator, data []byte) string {
+	ground := ""
+	if processor.Alpha == image.AlphaStructure {
+		ground = groundStructureName(r)
+	}
+	// The pre-processing chain is keyed as JSON, which writes its white point by value rather than address
+	preprocess, _ := json.Marshal(processor.Preprocess)
+	options := fmt.Sprintf("cards=%s width=%d height=%d colors=%d preprocess=%s sett=%+v frame=%d alpha=%s background=%v ground=%s antialias=%t maxPixels=%d maxCells=%d tile=%s/%d",
+		generatorKey(generator), processor.Width, processor.Height, processor.ColorMode, preprocess, processor.Sett,
+		processor.Frame, processor.Alpha, processor.Background, ground, processor.Antialias, processor.MaxPixels, processor.MaxCells, processor.Tile, processor.TileRows)
+	return cache.Key([]byte("conversion/v1"), []byte(options), data)
+}
+
+// requestETag identifies the response to a request by everything it depends on: the endpoint,
+// the options, the uploaded files, the negotiated format and the server build
+func (h *Handler) requestETag(r *http.Request, files uploads) string {
+	parts := [][]byte{[]byte(r.URL.Path), []byte(h.build.String())}
+	if isAPIRequest(r) {
+		parts = append(parts, []byte(r.Header.Get("Accept")))
+	}
+
+	keys := make([]string, 0, len(r.Form))
+	for key := range r.Form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, []byte(key+"="+strings.Join(r.Form[key], "\x00")))
+	}
+
+	fields := make([]string, 0, len(files))
+	for field := range files {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		parts = append(parts, []byte(field+"="+files[field].Filename), files[field].Data)
+	}
+
+	return `"` + cache.Key(parts...)[:32] + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists the ETag, using the weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// watchCache registers gauges reporting the size of the handler's conversion cache
+func (m *Metrics) watchCache(h *Handler) {
+	m.registry.NewGaugeFunc("loom_cache_entries", "Conversions held in the memory cache.", func() float64 {
+		if h.Cache == nil {
+			return 0
+		}
+		return float64(h.Cache.Len())
+	})
+	m.registry.NewGaugeFunc("loom_cache_bytes", "Size of the conversions held in the memory cache.", func() float64 {
+		if h.Cache == nil {
+			return 0
+		}
+		return float64(h.Cache.Bytes())
+	})
+	m.registry.NewGaugeFunc("loom_cache_disk_bytes", "Size of the conversions held in the disk cache.", func() float64 {
+		if h.Cache == nil {
+			return 0
+		}
+		return float64(h.Cache.DiskBytes())
+	})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPINotModified(t *testing.T) {
+	h := newTestHandler(t)
+	fields := map[string]string{"cardType": "26x8", "format": "txt"}
+	files := map[string][]byte{"image": testPNG(t)}
+
+	w := httptest.NewRecorder()
+	h.APIConvertHandler(w, multipartRequest(t, "/api/v1/convert", fields, files))
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("first response has no ETag")
+	}
+
+	// The same request again, rebuilt so the multipart boundary differs
+	r := multipartRequest(t, "/api/v1/convert", fields, files)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.APIConvertHandler(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("repeated request status = %d, want 304: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("304 ETag = %q, want %q", got, etag)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 body = %q, want empty", w.Body.String())
+	}
+
+	// A changed option is a different result
+	fields["format"] = "json"
+	r = multipartRequest(t, "/api/v1/convert", fields, files)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.APIConvertHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("changed request status = %d, want 200", w.Code)
+	}
+}
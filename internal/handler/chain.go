@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// parseGenerator reads the options shaping the chain of a conversion: the damask weaves, the
+// selvedge hooks at each side, the header, footer and separator cards around the design, and how
+// many cards wide it is
+func parseGenerator(r *http.Request, files uploads, cardType punchcard.CardType) (*punchcard.Generator, *APIError) {
+	generator := punchcard.NewGeneratorWithType(cardType)
+
+	damask, apiErr := parseDamask(r, files)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	generator.Damask = damask
+
+	fields := []struct {
+		key string
+		dst *int
+	}{
+		{"leftSelvedge", &generator.LeftSelvedge},
+		{"rightSelvedge", &generator.RightSelvedge},
+		{"headerCards", &generator.HeaderCards},
+		{"footerCards", &generator.FooterCards},
+		{"separatorCards", &generator.SeparatorCards},
+		{"designRepeats", &generator.Repeats},
+		{"cardsPerRow", &generator.CardsPerRow},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidOption(CodeInvalidChain, f.key, "chain layout",
+				fmt.Errorf("invalid %s: %s (must be a whole number)", f.key, value))
+		}
+		*f.dst = n
+	}
+	if err := generator.ValidateLayout(); err != nil {
+		return nil, invalidOption(CodeInvalidChain, "chain", "chain layout", err)
+	}
+
+	if generator.SelvedgeWeave, apiErr = parseWeave(r, files, "selvedgeWeave"); apiErr != nil {
+		return nil, apiErr
+	}
+	if generator.BorderWeave, apiErr = parseWeave(r, files, "borderWeave"); apiErr != nil {
+		return nil, apiErr
+	}
+	return generator, nil
+}
+
+// generatorKey describes the cards a generator makes from a matrix, for cache keys
+func generatorKey(g *punchcard.Generator) string {
+	if g == nil {
+		return "none"
+	}
+	return fmt.Sprintf("card=%dx%d sections=%d weave=%s selvedge=%d/%d/%s header=%d footer=%d separator=%d repeats=%d border=%s",
+		g.Dimensions.Width, g.Dimensions.Height, g.CardsPerRow, damaskKey(g.Damask), g.LeftSelvedge, g.RightSelvedge,
+		weaveKey(g.SelvedgeWeave), g.HeaderCards, g.FooterCards, g.SeparatorCards, g.Repeats, weaveKey(g.BorderWeave))
+}
+
+// ChainInfo describes how a design is placed on the chain
+type ChainInfo struct {
+	LeftSelvedge   int    `json:"leftSelvedge"`
+	RightSelvedge  int    `json:"rightSelvedge"`
+	SelvedgeWeave  string `json:"selvedgeWeave,omitempty"`
+	DesignHooks    int    `json:"designHooks"` // Hooks between the selvedges
+	HeaderCards    int    `json:"headerCards"`
+	FooterCards    int    `json:"footerCards"`
+	SeparatorCards int    `json:"separatorCards"`
+	Repeats        int    `json:"designRepeats"`
+	BorderWeave    string `json:"borderWeave,omitempty"`
+	CardsPerRow    int    `json:"cardsPerRow"` // Sections the design is split into, each its own chain
+}
+
+// newChainInfo describes the layout of a generator, or returns nil when it adds nothing to the design
+func newChainInfo(g *punchcard.Generator) *ChainInfo {
+	if g.LeftSelvedge == 0 && g.RightSelvedge == 0 && g.HeaderCards == 0 && g.FooterCards == 0 && g.Repeats <= 1 &&
+		g.CardsPerRow <= 1 {
+		return nil
+	}
+	info := &ChainInfo{
+		LeftSelvedge:   g.LeftSelvedge,
+		RightSelvedge:  g.RightSelvedge,
+		DesignHooks:    g.DesignWidth(),
+		HeaderCards:    g.HeaderCards,
+		FooterCards:    g.FooterCards,
+		SeparatorCards: g.SeparatorCards,
+		Repeats:        g.Repeats,
+		SelvedgeWeave:  weaveName(g.SelvedgeWeave),
+		BorderWeave:    weaveName(g.BorderWeave),
+		CardsPerRow:    g.CardsPerRow,
+	}
+	if info.Repeats < 1 {
+		info.Repeats = 1
+	}
+	if info.CardsPerRow < 1 {
+		info.CardsPerRow = 1
+	}
+	return info
+}
+
+// generationOptions records the weaves and chain layout of a generator in export settings
+func generationOptions(g *punchcard.Generator, options map[string]string) map[string]string {
+	if g.Damask != nil {
+		if options == nil {
+			options = map[string]string{}
+		}
+		options["weave"] = g.Damask.String()
+	}
+	if chain := newChainInfo(g); chain != nil {
+		if options == nil {
+			options = map[string]string{}
+		}
+		options["selvedge"] = fmt.Sprintf("%d/%d %s", chain.LeftSelvedge, chain.RightSelvedge, chain.SelvedgeWeave)
+		options["borders"] = fmt.Sprintf("header %d, footer %d, separator %d, %s", chain.HeaderCards, chain.FooterCards,
+			chain.SeparatorCards, chain.BorderWeave)
+		options["designRepeats"] = strconv.Itoa(chain.Repeats)
+		if chain.CardsPerRow > 1 {
+			options["cardsPerRow"] = strconv.Itoa(chain.CardsPerRow)
+		}
+	}
+	return options
+}
+
+// weaveName names a weave, or plain when it is nil
+func weaveName(w *punchcard.Weave) string {
+	if w == nil {
+		return "plain"
+	}
+	return w.Name
+}
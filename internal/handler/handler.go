@@ -3,33 +3,90 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"io"
-	"log"
+	"io/fs"
+	"math"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+	"github.com/oscaralmgren/loom-punchcards/internal/buildinfo"
+	"github.com/oscaralmgren/loom-punchcards/internal/cache"
 	"github.com/oscaralmgren/loom-punchcards/internal/image"
+	"github.com/oscaralmgren/loom-punchcards/internal/limit"
 	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+	"github.com/oscaralmgren/loom-punchcards/internal/workspace"
+)
+
+// Default request limits
+const (
+	DefaultMaxUploadBytes = 10 << 20 // 10 MB
+	DefaultMaxPixels      = 40000000 // 40 megapixels
+	DefaultMaxCells       = 16000000 // Hooks x picks of a generated chain
 )
 
 // Handler manages HTTP requests for the punchcard application
 type Handler struct {
-	templates *template.Template
+	templates  *template.Template
+	templateFS fs.FS
+	metrics    *Metrics
+	build      buildinfo.Info
+
+	MaxUploadBytes int64  // Largest request body accepted
+	MaxPixels      int    // Largest image accepted, in pixels (0 = no limit)
+	MaxCells       int    // Largest design or chain generated, in hooks x picks (0 = no limit)
+	BasePath       string // URL prefix the application is served under, e.g. "/loom"
+
+	RateLimiter       *limit.RateLimiter // Per-client request rate for the conversion endpoints (nil = no limit)
+	RateLimitByAPIKey bool               // Rate limit clients by their verified API key rather than their user or IP
+	LoginLimiter      *limit.RateLimiter // Sign-in attempts per client IP and per user name (nil = no limit)
+	TrustProxy        bool               // Take the client IP from X-Forwarded-For, when behind a reverse proxy
+	Pool              *limit.Pool        // Bounds how many conversions run at once (nil = no limit)
+
+	Cache *cache.Cache // Reuses converted images across requests (nil = no caching)
+
+	Auth          *auth.Authenticator   // Checks API keys and sessions (nil = open to everyone, as the local user)
+	Workspaces    *workspace.Workspaces // Per-user stored images, card sets and presets (nil = disabled)
+	DefaultQuota  auth.Quota            // Quota of new users, and of the local user
+	SecureCookies bool                  // Send the session cookie over HTTPS only
+
+	ReloadTemplates bool // Parse the templates again for every page, to see edits without a restart
 }
 
-// NewHandler creates a new HTTP handler
-func NewHandler(templateDir string) (*Handler, error) {
+// NewHandler creates a new HTTP handler serving the HTML templates in templates
+func NewHandler(templates fs.FS) (*Handler, error) {
 	// Parse templates
-	tmpl, err := template.ParseGlob(templateDir + "/*.html")
+	tmpl, err := parseTemplates(templates)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse templates: %w", err)
+		return nil, err
 	}
 
-	return &Handler{
-		templates: tmpl,
-	}, nil
+	h := &Handler{
+		templates:      tmpl,
+		templateFS:     templates,
+		metrics:        NewMetrics(),
+		build:          buildinfo.Read(),
+		MaxUploadBytes: DefaultMaxUploadBytes,
+		MaxPixels:      DefaultMaxPixels,
+		MaxCells:       DefaultMaxCells,
+	}
+	h.metrics.watchLimits(h)
+	h.metrics.watchCache(h)
+	return h, nil
+}
+
+// parseTemplates parses the HTML templates at the top of a file system
+func parseTemplates(templates fs.FS) (*template.Template, error) {
+	tmpl, err := template.ParseFS(templates, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return tmpl, nil
 }
 
 // HomeHandler serves the main page
@@ -39,9 +96,23 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.templates.ExecuteTemplate(w, "index.html", nil)
+	tmpl := h.templates
+	if h.ReloadTemplates {
+		reloaded, err := parseTemplates(h.templateFS)
+		if err != nil {
+			logger(r).Error("failed to reload templates", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tmpl = reloaded
+	}
+
+	err := tmpl.ExecuteTemplate(w, "index.html", struct {
+		BasePath    string
+		AuthEnabled bool
+	}{h.BasePath, h.Auth != nil})
 	if err != nil {
-		log.Printf("Error rendering template: %v", err)
+		logger(r).Error("failed to render template", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
@@ -54,562 +125,1442 @@ type UploadRequest struct {
 
 // UploadHandler handles image upload and processing
 func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	h.serve(w, r, h.convertImage)
+}
+
+// PreviewHandler generates a preview of the punchcards
+func (h *Handler) PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.previewImage)
+}
+
+// InfoHandler returns information about the generated punchcards
+func (h *Handler) InfoHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.imageInfo)
+}
+
+// convertImage processes an uploaded image into punchcards in a download format
+func (h *Handler) convertImage(r *http.Request, files uploads) (*result, *APIError) {
+	file, apiErr := files.get("image")
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	// Parse multipart form (max 10MB)
-	err := r.ParseMultipartForm(10 << 20)
+	logger(r).Info("received image", "filename", file.Filename, "bytes", file.Size())
+
+	// Get format parameter
+	format, apiErr := requestFormat(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get title parameter (optional)
+	title := r.FormValue("title")
+
+	// Get card type parameter
+	cardType, apiErr := parseCardType(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	dims := punchcard.GetCardDimensions(cardType)
+
+	// Get the weaves, selvedges and border cards of the chain
+	generator, apiErr := parseGenerator(r, files, cardType)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
 	}
 
-	// Get the uploaded file
-	file, header, err := r.FormFile("image")
+	// Get loom profile options for the production estimate
+	profile, repeats, err := parseLoomProfile(r, dims)
 	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
-	defer file.Close()
 
-	log.Printf("Received file: %s (%d bytes)", header.Filename, header.Size)
+	// Get page size for printable formats
+	pageSize, err := parsePageSize(r, format, dims)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidPageSize, "pageSize", "page size", err)
+	}
 
-	// Get color mode parameter
-	colorModeStr := r.FormValue("colorMode")
-	if colorModeStr == "" {
-		colorModeStr = "2" // Default to 2-color
+	// Get the range of cards to export, every card by default
+	rng, err := parseCardRange(r, false)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidRange, "range", "card range", err)
 	}
-	colorMode, err := strconv.Atoi(colorModeStr)
-	if err != nil || image.ValidateColorMode(colorMode) != nil {
-		http.Error(w, "Invalid color mode (must be 2, 4, or 8)", http.StatusBadRequest)
-		return
+
+	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600), less any selvedges
+	// Height is auto-calculated from aspect ratio
+	processorWidth := generator.DesignWidth()
+	processor, apiErr := h.newImageProcessor(r, processorWidth)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	// Get format parameter
-	format := r.FormValue("format")
-	if format == "" {
-		format = "svg" // Default to SVG
+	// The cloth sett also gives the estimate its weft density
+	if processor.Sett.IsSet() && r.FormValue("picksPerCm") == "" {
+		profile.PicksPerCm = processor.Sett.WeftDensity(processorWidth)
 	}
-	if format != "svg" && format != "pdf" && format != "txt" {
-		http.Error(w, "Invalid format (must be 'svg', 'pdf', or 'txt')", http.StatusBadRequest)
-		return
+
+	// Process the image to binary matrix and generate punchcards with the specified card type
+	_, cards, apiErr := h.imageCards(r, processor, generator, file.Data)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Reorient the cards for the loom before exporting
+	cards = transform.Apply(cards)
+
+	settings := &punchcard.GenerationSettings{
+		ColorMode: int(processor.ColorMode),
+		Source:    file.Filename,
+	}
+	settings.Options = generationOptions(generator, settings.Options)
+	return h.exportResult(r, cards, format, exportOptions{
+		Title:     title,
+		Settings:  settings,
+		Transform: transform,
+		Profile:   profile,
+		Repeats:   repeats,
+		PageSize:  pageSize,
+		Range:     rng,
+		Section:   r.FormValue("section"),
+	})
+}
+
+// previewImage returns a range of the cards of an uploaded image as an inline SVG, or their overview
+func (h *Handler) previewImage(r *http.Request, files uploads) (*result, *APIError) {
+	file, apiErr := files.get("image")
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
 	// Get title parameter (optional)
 	title := r.FormValue("title")
 
 	// Get card type parameter
-	cardTypeStr := r.FormValue("cardType")
-	if cardTypeStr == "" {
-		cardTypeStr = "26x8" // Default to 26x8
+	cardType, apiErr := parseCardType(r)
+	if apiErr != nil {
+		return nil, apiErr
 	}
-	if err := punchcard.ValidateCardType(cardTypeStr); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid card type: %v", err), http.StatusBadRequest)
-		return
+
+	// Get the weaves, selvedges and border cards of the chain
+	generator, apiErr := parseGenerator(r, files, cardType)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
+	}
+
+	// Get the cards to show, or the overview
+	preview, apiErr := parsePreviewOptions(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	processor, apiErr := h.newImageProcessor(r, generator.DesignWidth())
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	_, cards, apiErr := h.imageCards(r, processor, generator, file.Data)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Reorient the cards for the loom
+	cards = transform.Apply(cards)
+
+	return previewResult(cards, title, transform, preview)
+}
+
+// imageInfo describes the punchcards an uploaded image converts to
+func (h *Handler) imageInfo(r *http.Request, files uploads) (*result, *APIError) {
+	file, apiErr := files.get("image")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get card type parameter
+	cardType, apiErr := parseCardType(r)
+	if apiErr != nil {
+		return nil, apiErr
 	}
-	cardType := punchcard.CardType(cardTypeStr)
 	dims := punchcard.GetCardDimensions(cardType)
 
-	// Process the image
-	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600)
-	// Height is auto-calculated from aspect ratio
-	processorWidth := dims.Width * dims.Height
-	processor := image.NewProcessor(processorWidth, 0, image.ColorMode(colorMode))
+	// Get the weaves, selvedges and border cards of the chain
+	generator, apiErr := parseGenerator(r, files, cardType)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
-	// Read the file into memory
-	fileBytes, err := io.ReadAll(file)
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
 	}
 
-	// Process the image to binary matrix
-	matrix, err := processor.Process(bytes.NewReader(fileBytes))
+	// Get loom profile options for the production estimate
+	profile, repeats, err := parseLoomProfile(r, dims)
 	if err != nil {
-		log.Printf("Error processing image: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to process image: %v", err), http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
 
-	// Safety check: ensure matrix is not empty
-	if len(matrix) == 0 || len(matrix[0]) == 0 {
-		log.Printf("Error: processed image resulted in empty matrix")
-		http.Error(w, "Failed to process image: resulted in empty matrix", http.StatusBadRequest)
-		return
+	// Get the loop mode, checking the join of an endless chain
+	loop, apiErr := parseLoop(r)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	log.Printf("Processed image to %dx%d matrix", len(matrix[0]), len(matrix))
+	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600), less any selvedges
+	// Height is auto-calculated from aspect ratio
+	processorWidth := generator.DesignWidth()
+	processor, apiErr := h.newImageProcessor(r, processorWidth)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	sett := processor.Sett
 
-	// Generate punchcards with the specified card type
-	generator := punchcard.NewGeneratorWithType(cardType)
-	cards, err := generator.Generate(matrix)
-	if err != nil {
-		log.Printf("Error generating punchcards: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to generate punchcards: %v", err), http.StatusInternalServerError)
-		return
+	// The cloth sett also gives the estimate its weft density
+	if sett.IsSet() && r.FormValue("picksPerCm") == "" {
+		profile.PicksPerCm = sett.WeftDensity(processorWidth)
 	}
 
-	log.Printf("Generated %d punchcards", len(cards))
+	matrix, cards, apiErr := h.imageCards(r, processor, generator, file.Data)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	// Each section is a loop of its own, so its join is checked on its own
+	var joins []*punchcard.JoinReport
+	if loop.Loop {
+		for _, s := range punchcard.SplitSections(cards) {
+			joins = append(joins, generator.CheckLoop(s.Cards, loop.MaxFloat))
+		}
+	}
 
-	// Export based on format
-	var output bytes.Buffer
-	var contentType string
-	var filename string
+	// Reorient the cards for the loom
+	cards = transform.Apply(cards)
 
-	if format == "svg" {
-		exporter := punchcard.NewSVGExporter()
-		exporter.SetTitle(title, len(cards)) // Set title and total card count
-		err = exporter.ExportCards(cards, &output)
-		contentType = "image/svg+xml"
-		filename = "punchcards.svg"
-	} else if format == "txt" {
-		exporter := punchcard.NewTextExporter()
-		exporter.SetTitle(title, len(cards)) // Set title and total card count
-		err = exporter.ExportCards(cards, &output)
-		contentType = "text/plain; charset=utf-8"
-		filename = "punchcards.txt"
-	} else {
-		// For PDF, we'll export as SVG and let the client handle conversion
-		// Or we can use a simple PDF library
-		exporter := punchcard.NewSVGExporter()
-		exporter.SetTitle(title, len(cards)) // Set title and total card count
-		err = exporter.ExportCards(cards, &output)
-		contentType = "application/pdf"
-		filename = "punchcards.pdf"
-		// Note: In production, convert SVG to actual PDF here
-	}
+	// Generate metadata
+	metadata := punchcard.GenerateMetadata(cards)
 
+	// Create response
+	response := newInfoResponse(file.Filename, file.Size(), metadata)
+	response.Estimate, err = punchcard.EstimateProduction(cards, profile, repeats)
 	if err != nil {
-		log.Printf("Error exporting cards: %v", err)
-		http.Error(w, "Failed to export punchcards", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
+	response.Chain = newChainInfo(generator)
+	response.Sections = newSectionInfo(cards, func(info *SectionInfo, cards []*punchcard.Card) {
+		info.Weave = newWeaveInfo(cards, generator.Damask)
+	})
+	if response.Sections == nil {
+		response.Statistics = punchcard.GenerateStatistics(cards)
+		response.Weave = newWeaveInfo(cards, generator.Damask)
+	}
+	for i, join := range joins {
+		if response.Sections == nil {
+			response.Join = join
+		} else {
+			response.Sections[i].Join = join
+		}
+	}
+	response.ColorMode = processor.DescribeColorMode()
+	response.Transform = transform.String()
+	response.Preprocess = &processor.Preprocess
+	response.PreprocessSummary = processor.Preprocess.Describe()
+	response.Source, _ = image.Inspect(file.Data)
+	response.Frame = processor.Frame + 1
+	response.Alpha = processor.DescribeAlpha()
+	if processor.Alpha == image.AlphaStructure {
+		response.Alpha += " (" + groundStructureName(r) + ")"
+	}
+	if sett.IsSet() {
+		response.Sett = &sett
+		response.WovenWidthCm, response.WovenHeightCm = sett.WovenSize(processorWidth, len(matrix))
+	}
+
+	return jsonResult(response), nil
+}
 
-	// Set headers for download
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	w.Header().Set("Content-Length", strconv.Itoa(output.Len()))
+// InfoResponse is the JSON body returned by the info endpoints
+type InfoResponse struct {
+	Filename          string                        `json:"filename"`
+	FileSize          int64                         `json:"fileSize"`
+	Title             string                        `json:"title,omitempty"`
+	ColorMode         string                        `json:"colorMode,omitempty"`
+	TotalCards        int                           `json:"totalCards"`
+	CardDimensions    string                        `json:"cardDimensions"`
+	TotalRows         int                           `json:"totalRows"`
+	AverageDensity    string                        `json:"averageDensity"`
+	HolesPerCard      []int                         `json:"holesPerCard"`
+	Transform         string                        `json:"transform"`
+	Preprocess        *image.Preprocess             `json:"preprocess,omitempty"`
+	PreprocessSummary string                        `json:"preprocessSummary,omitempty"`
+	Source            *image.SourceInfo             `json:"source,omitempty"`
+	Frame             int                           `json:"frame,omitempty"`
+	Alpha             string                        `json:"alpha,omitempty"`
+	Sett              *image.Sett                   `json:"sett,omitempty"`
+	WovenWidthCm      float64                       `json:"wovenWidthCm,omitempty"`
+	WovenHeightCm     float64                       `json:"wovenHeightCm,omitempty"`
+	Statistics        *punchcard.Statistics         `json:"statistics,omitempty"`
+	Weave             *WeaveInfo                    `json:"weave,omitempty"`
+	Chain             *ChainInfo                    `json:"chain,omitempty"`
+	Sections          []SectionInfo                 `json:"sections,omitempty"`
+	Join              *punchcard.JoinReport         `json:"join,omitempty"`
+	Estimate          *punchcard.ProductionEstimate `json:"estimate,omitempty"`
+}
 
-	// Write output
-	_, err = w.Write(output.Bytes())
-	if err != nil {
-		log.Printf("Error writing response: %v", err)
+// newInfoResponse fills the fields shared by all info endpoints
+func newInfoResponse(filename string, size int64, metadata *punchcard.Metadata) *InfoResponse {
+	return &InfoResponse{
+		Filename:       filename,
+		FileSize:       size,
+		TotalCards:     metadata.TotalCards,
+		CardDimensions: fmt.Sprintf("%dx%d", metadata.CardWidth, metadata.CardHeight),
+		TotalRows:      metadata.TotalRows,
+		AverageDensity: fmt.Sprintf("%.1f%%", metadata.AverageDensity),
+		HolesPerCard:   metadata.HolesPerCard,
 	}
 }
 
-// PreviewHandler generates a preview of the punchcards
-func (h *Handler) PreviewHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// cardDimensions returns the dimensions of the cards in a set
+func cardDimensions(cards []*punchcard.Card) punchcard.CardDimensions {
+	if len(cards) == 0 {
+		return punchcard.GetCardDimensions(punchcard.CardType26x8)
 	}
+	return punchcard.CardDimensions{Width: cards[0].Width, Height: cards[0].Height}
+}
 
-	// Parse multipart form (max 10MB)
-	err := r.ParseMultipartForm(10 << 20)
+// process runs the image pipeline, recording the time of each stage
+func (h *Handler) process(r *http.Request, processor *image.Processor, data []byte) ([][]int, error) {
+	processor.Observe = h.metrics.observeStage
+	matrix, err := processor.Process(bytes.NewReader(data))
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+		setErrorClass(r, "process")
+		logger(r).Warn("failed to process image", "error", err)
 	}
+	return matrix, err
+}
 
-	// Get the uploaded file
-	file, _, err := r.FormFile("image")
+// generate turns a binary matrix into cards, recording the stage time and card count
+func (h *Handler) generate(r *http.Request, generator *punchcard.Generator, matrix [][]int) ([]*punchcard.Card, error) {
+	start := time.Now()
+	cardType, _ := punchcard.CardTypeForDimensions(generator.Dimensions)
+	generator.MaxCells = h.MaxCells
+	cards, err := generator.Generate(matrix)
+	h.metrics.observeStage(stageGenerate, time.Since(start))
 	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+		setErrorClass(r, "generate")
+		logger(r).Error("failed to generate punchcards", "error", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	// Get color mode parameter
-	colorModeStr := r.FormValue("colorMode")
-	if colorModeStr == "" {
-		colorModeStr = "2"
-	}
-	colorMode, err := strconv.Atoi(colorModeStr)
-	if err != nil || image.ValidateColorMode(colorMode) != nil {
-		http.Error(w, "Invalid color mode", http.StatusBadRequest)
-		return
+	h.metrics.cardsGenerated.Add(float64(len(cards)), string(cardType))
+	h.metrics.cardsPerJob.Observe(float64(len(cards)))
+	logger(r).Info("generated punchcards", "cards", len(cards), "card_type", string(cardType))
+	return cards, nil
+}
+
+// export renders cards in a download format, recording the stage time and the conversion
+func (h *Handler) export(r *http.Request, cards []*punchcard.Card, format string, opts exportOptions) (*bytes.Buffer, string, string, error) {
+	start := time.Now()
+	output, contentType, filename, err := exportCardSet(cards, format, opts)
+	h.metrics.observeStage(stageExport, time.Since(start))
+	if err != nil {
+		setErrorClass(r, "export")
+		logger(r).Error("failed to export punchcards", "format", format, "error", err)
+		return nil, "", "", err
 	}
 
-	// Get title parameter (optional)
-	title := r.FormValue("title")
+	h.metrics.conversions.Inc(format, cardTypeLabel(cards))
+	return output, contentType, filename, nil
+}
 
-	// Get card type parameter
+// parseCardType reads the card type, defaulting to 26x8
+func parseCardType(r *http.Request) (punchcard.CardType, *APIError) {
 	cardTypeStr := r.FormValue("cardType")
 	if cardTypeStr == "" {
 		cardTypeStr = "26x8" // Default to 26x8
 	}
 	if err := punchcard.ValidateCardType(cardTypeStr); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid card type: %v", err), http.StatusBadRequest)
-		return
+		return "", invalidOption(CodeInvalidCardType, "cardType", "card type", err)
 	}
-	cardType := punchcard.CardType(cardTypeStr)
-	dims := punchcard.GetCardDimensions(cardType)
+	return punchcard.CardType(cardTypeStr), nil
+}
 
-	// Process the image
-	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600)
-	// Height is auto-calculated from aspect ratio
-	processorWidth := dims.Width * dims.Height
-	processor := image.NewProcessor(processorWidth, 0, image.ColorMode(colorMode))
+// parseColorMode reads the number of colours to dither to, defaulting to 2
+func parseColorMode(r *http.Request) (int, *APIError) {
+	colorModeStr := r.FormValue("colorMode")
+	if colorModeStr == "" {
+		colorModeStr = "2" // Default to 2-color
+	}
+	colorMode, err := strconv.Atoi(colorModeStr)
+	if err != nil || image.ValidateColorMode(colorMode) != nil {
+		return 0, invalidOption(CodeInvalidColorMode, "colorMode", "color mode",
+			fmt.Errorf("invalid color mode: %s (must be 2, 4, or 8)", colorModeStr))
+	}
+	return colorMode, nil
+}
 
-	fileBytes, err := io.ReadAll(file)
+// newImageProcessor reads the colour, pre-processing, sett, frame and transparency options
+// into a processor producing rows of the given width
+func (h *Handler) newImageProcessor(r *http.Request, width int) (*image.Processor, *APIError) {
+	colorMode, apiErr := parseColorMode(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get image pre-processing options
+	preprocess, err := parsePreprocess(r)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidPreprocess, "preprocess", "pre-processing", err)
 	}
 
-	matrix, err := processor.Process(bytes.NewReader(fileBytes))
+	// Get cloth sett options
+	sett, err := parseSett(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to process image: %v", err), http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidSett, "sett", "sett", err)
+	}
+
+	// Get the GIF frame or TIFF page to convert
+	frame, err := parseFrame(r)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidFrame, "frame", "frame", err)
+	}
+
+	processor := image.NewProcessor(width, 0, image.ColorMode(colorMode))
+	processor.Preprocess = preprocess
+	processor.Sett = sett
+	processor.Frame = frame
+	processor.Antialias = formBoolDefault(r, "antialias", true)
+	processor.MaxPixels = h.MaxPixels
+	processor.MaxCells = h.MaxCells
+	if err := configureAlpha(r, processor); err != nil {
+		return nil, invalidOption(CodeInvalidAlpha, "alphaMode", "transparency option", err)
+	}
+	if err := configureTile(r, processor); err != nil {
+		return nil, invalidOption(CodeInvalidLoop, "tile", "loop option", err)
+	}
+	return processor, nil
+}
+
+// processImage runs the image pipeline, telling refused images apart from ones that could not be decoded
+func (h *Handler) processImage(r *http.Request, processor *image.Processor, data []byte) ([][]int, *APIError) {
+	matrix, err := h.process(r, processor, data)
+	switch {
+	case errors.Is(err, image.ErrImageTooLarge):
+		return nil, invalidOption(CodeImageTooLarge, "image", "image", err)
+	case errors.Is(err, image.ErrInvalidFrame):
+		return nil, invalidOption(CodeInvalidFrame, "frame", "frame", err)
+	case err != nil:
+		return nil, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeImageDecodeFailed,
+			Message: fmt.Sprintf("Failed to process image: %v", err),
+			Details: []FieldError{{Field: "image", Message: err.Error()}},
+		}
 	}
 
 	// Safety check: ensure matrix is not empty
 	if len(matrix) == 0 || len(matrix[0]) == 0 {
-		http.Error(w, "Failed to process image: resulted in empty matrix", http.StatusBadRequest)
-		return
+		logger(r).Warn("processed image is empty")
+		return nil, newAPIError(http.StatusBadRequest, CodeEmptyImage, "Failed to process image: resulted in empty matrix")
 	}
 
-	// Generate punchcards with the specified card type
-	generator := punchcard.NewGeneratorWithType(cardType)
-	cards, err := generator.Generate(matrix)
+	logger(r).Info("processed image", "width", len(matrix[0]), "height", len(matrix))
+	return matrix, nil
+}
+
+// generateCards turns a binary matrix into cards of the given type
+func (h *Handler) generateCards(r *http.Request, generator *punchcard.Generator, matrix [][]int) ([]*punchcard.Card, *APIError) {
+	cards, err := h.generate(r, generator, matrix)
+	if errors.Is(err, punchcard.ErrChainTooLarge) {
+		return nil, invalidOption(CodeImageTooLarge, "chain", "chain layout", err)
+	}
 	if err != nil {
-		http.Error(w, "Failed to generate punchcards", http.StatusInternalServerError)
-		return
+		code := CodeGenerateFailed
+		if errors.Is(err, punchcard.ErrWidthMismatch) {
+			code = CodeWidthMismatch
+		}
+		return nil, newAPIError(http.StatusInternalServerError, code, fmt.Sprintf("Failed to generate punchcards: %v", err))
+	}
+	return cards, nil
+}
+
+// exportResult renders cards, or the range of them in opts, as a download in the given format
+func (h *Handler) exportResult(r *http.Request, cards []*punchcard.Card, format string, opts exportOptions) (*result, *APIError) {
+	// A design split into sections is exported one section at a time, or as an archive of them all
+	if opts.Section != "" {
+		var apiErr *APIError
+		if cards, apiErr = selectSection(cards, opts.Section); apiErr != nil {
+			return nil, apiErr
+		}
+	} else if sections := punchcard.SplitSections(cards); len(sections) > 1 {
+		return h.sectionArchive(r, sections, format, opts)
+	}
+
+	total := len(cards)
+	if opts.Range.From != 0 {
+		var apiErr *APIError
+		cards, apiErr = selectRange(cards, opts.Range)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		opts.Total = total
+	}
+
+	output, contentType, filename, err := h.export(r, cards, format, opts)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to export punchcards")
 	}
+	res := &result{
+		contentType: contentType,
+		body:        output.Bytes(),
+		filename:    filename,
+		headers:     map[string]string{"X-Punchcard-Transform": opts.Transform.String()},
+	}
+	if opts.Range.From != 0 {
+		setRangeHeaders(res, opts.Range, total)
+	}
+	return res, nil
+}
 
-	// Generate preview (first 3 cards only)
-	previewCards := cards
-	if len(previewCards) > 3 {
-		previewCards = cards[:3]
+// validateExportFormat checks if the download format is supported
+func validateExportFormat(format string) error {
+	switch format {
+	case "svg", "pdf", "zip", "txt", "json", "report", "guide-txt", "guide-html", "guide-pdf":
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s (must be 'svg', 'pdf', 'zip', 'txt', 'json', 'report', 'guide-txt', 'guide-html', or 'guide-pdf')", format)
 	}
+}
+
+// exportOptions carries what a download format may need besides the cards
+type exportOptions struct {
+	Title     string
+	Settings  *punchcard.GenerationSettings
+	Transform punchcard.Transform   // Orientation already applied to the cards, recorded in the output
+	Profile   punchcard.LoomProfile // Loom and card stock for the production estimate report
+	Repeats   int                   // Times the chain is woven, for the production estimate report
+	PageSize  string                // Paper size for the imposed pages and the PDF punching guide
+	Range     punchcard.CardRange   // Cards to export (From 0 = every card)
+	Total     int                   // Cards in the whole set, when exporting part of it
+	Section   string                // Section to export ("" = every section, in one archive when there are several)
+}
 
-	// Export as SVG for preview
+// exportCardSet renders cards in the requested download format and returns
+// the output together with its content type and file name
+func exportCardSet(cards []*punchcard.Card, format string, opts exportOptions) (*bytes.Buffer, string, string, error) {
 	var output bytes.Buffer
-	exporter := punchcard.NewSVGExporter()
-	exporter.SetTitle(title, len(cards)) // Set title and total card count (not preview count)
-	err = exporter.ExportCards(previewCards, &output)
-	if err != nil {
-		http.Error(w, "Failed to generate preview", http.StatusInternalServerError)
-		return
+	var err error
+	var contentType string
+	var filename string
+
+	// Labels count cards in the whole set, even when only part of it is exported
+	total := len(cards)
+	if opts.Total > total {
+		total = opts.Total
+	}
+
+	switch format {
+	case "txt":
+		exporter := punchcard.NewTextExporter()
+		exporter.SetTitle(opts.Title, total) // Set title and total card count
+		exporter.Transform = opts.Transform
+		err = exporter.ExportCards(cards, &output)
+		contentType = "text/plain; charset=utf-8"
+		filename = "punchcards.txt"
+	case "json":
+		exporter := punchcard.NewJSONExporter()
+		exporter.SetTitle(opts.Title)
+		exporter.Settings = withTransform(opts.Settings, opts.Transform)
+		err = exporter.ExportCards(cards, &output)
+		contentType = "application/json"
+		filename = "punchcards.json"
+	case "pdf":
+		exporter := punchcard.NewPDFExporter()
+		exporter.PageSize = opts.PageSize
+		exporter.Title = opts.Title
+		exporter.Transform = opts.Transform
+		exporter.TotalCards = total
+		err = exporter.ExportCards(cards, &output)
+		contentType = "application/pdf"
+		filename = "punchcards.pdf"
+	case "zip":
+		exporter := punchcard.NewImpositionExporter()
+		exporter.PageSize = opts.PageSize
+		exporter.Title = opts.Title
+		exporter.Transform = opts.Transform
+		exporter.TotalCards = total
+		err = exporter.ExportSVGZip(cards, &output)
+		contentType = "application/zip"
+		filename = "punchcards.zip"
+	case "report":
+		exporter := punchcard.NewEstimateReportExporter(opts.Profile)
+		exporter.Title = opts.Title
+		exporter.Repeats = opts.Repeats
+		err = exporter.ExportCards(cards, &output)
+		contentType = "text/html; charset=utf-8"
+		filename = "estimate.html"
+	case "guide-txt", "guide-html", "guide-pdf":
+		exporter := punchcard.NewPunchingGuideExporter()
+		exporter.Title = opts.Title
+		exporter.PageSize = opts.PageSize
+		exporter.Transform = opts.Transform
+		switch format {
+		case "guide-txt":
+			err = exporter.ExportText(cards, &output)
+			contentType = "text/plain; charset=utf-8"
+		case "guide-html":
+			err = exporter.ExportHTML(cards, &output)
+			contentType = "text/html; charset=utf-8"
+		default:
+			err = exporter.ExportPDF(cards, &output)
+			contentType = "application/pdf"
+		}
+		filename = "punching-guide." + strings.TrimPrefix(format, "guide-")
+	default:
+		exporter := punchcard.NewSVGExporter()
+		exporter.SetTitle(opts.Title, total) // Set title and total card count
+		exporter.Transform = opts.Transform
+		err = exporter.ExportCards(cards, &output)
+		contentType = "image/svg+xml"
+		filename = "punchcards.svg"
 	}
 
-	// Return SVG directly for inline display
-	w.Header().Set("Content-Type", "image/svg+xml")
-	w.Write(output.Bytes())
+	return &output, contentType, filename, err
 }
 
-// InfoHandler returns information about the generated punchcards
-func (h *Handler) InfoHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// withTransform returns a copy of the settings with the transform recorded as an option
+func withTransform(settings *punchcard.GenerationSettings, transform punchcard.Transform) *punchcard.GenerationSettings {
+	result := &punchcard.GenerationSettings{}
+	if settings != nil {
+		*result = *settings
+		result.Options = make(map[string]string, len(settings.Options)+1)
+		for k, v := range settings.Options {
+			result.Options[k] = v
+		}
+	}
+	if transform.IsIdentity() {
+		delete(result.Options, punchcard.SettingsOptionTransform)
+	} else {
+		result.SetOption(punchcard.SettingsOptionTransform, transform.String())
 	}
+	return result
+}
 
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20)
+// parseTransform reads the orientation and polarity options from the form
+// Options can be given as individual checkboxes or as a comma-separated "transform" list
+func parseTransform(r *http.Request) (punchcard.Transform, error) {
+	transform, err := punchcard.ParseTransform(r.FormValue("transform"))
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+		return transform, err
+	}
+	return transform.Then(punchcard.Transform{
+		MirrorHorizontal: formBool(r, "mirrorH"),
+		MirrorVertical:   formBool(r, "mirrorV"),
+		Rotate180:        formBool(r, "rotate180"),
+		ReverseOrder:     formBool(r, "reverse"),
+		InvertPolarity:   formBool(r, "invert"),
+	}), nil
+}
+
+// parsePreprocess reads the image pre-processing options from the form
+// Levels are given as 0-255 and brightness/contrast as percentages, like in image editors
+func parsePreprocess(r *http.Request) (image.Preprocess, error) {
+	p := image.DefaultPreprocess()
+	p.AutoLevels = formBool(r, "autoLevels")
+	p.Invert = formBool(r, "invertImage")
+
+	var whitePoint float64
+	fields := []struct {
+		key   string
+		dst   *float64
+		scale float64
+	}{
+		{"channelR", &p.ChannelWeights[0], 1},
+		{"channelG", &p.ChannelWeights[1], 1},
+		{"channelB", &p.ChannelWeights[2], 1},
+		{"blackPoint", &p.BlackPoint, 255},
+		{"whitePoint", &whitePoint, 255},
+		{"gamma", &p.Gamma, 1},
+		{"brightness", &p.Brightness, 100},
+		{"contrast", &p.Contrast, 100},
+		{"blur", &p.BlurRadius, 1},
+		{"sharpen", &p.SharpenAmount, 1},
+		{"sharpenRadius", &p.SharpenRadius, 1},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		v, err := parseFloatField(f.key, value)
+		if err != nil {
+			return p, err
+		}
+		*f.dst = v / f.scale
+	}
+
+	if r.FormValue("whitePoint") != "" {
+		p.WhitePoint = &whitePoint
+	}
+	return p, p.Validate()
+}
+
+// parseFloatField parses a number given in a form field
+// ParseFloat also accepts "NaN" and "Inf", which no range check would catch, so they are refused here
+func parseFloatField(key, value string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, &FieldError{Field: key, Message: fmt.Sprintf("invalid %s: %s (must be a number)", key, value)}
 	}
+	return v, nil
+}
 
-	// Get the uploaded file
-	file, header, err := r.FormFile("image")
-	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+// parseSett reads the cloth sett options from the form
+func parseSett(r *http.Request) (image.Sett, error) {
+	var sett image.Sett
+
+	fields := []struct {
+		key string
+		dst *float64
+	}{
+		{"endsPerCm", &sett.EndsPerCm},
+		{"picksPerCm", &sett.PicksPerCm},
+		{"wovenWidth", &sett.WovenWidthCm},
+		{"wovenHeight", &sett.WovenHeightCm},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		v, err := parseFloatField(f.key, value)
+		if err != nil {
+			return sett, err
+		}
+		*f.dst = v
+	}
+
+	if value := r.FormValue("picksPerRow"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return sett, &FieldError{Field: "picksPerRow", Message: fmt.Sprintf("invalid picksPerRow: %s (must be a whole number)", value)}
+		}
+		sett.PicksPerRow = n
+	}
+
+	// Without a warp, picksPerCm is only the weft density of the production estimate
+	if !sett.IsSet() {
+		sett.PicksPerCm = 0
+	}
+	return sett, sett.Validate()
+}
+
+// parsePageSize reads the paper size for the printable formats
+// For the imposed templates it also checks that a card fits on the page
+func parsePageSize(r *http.Request, format string, dims punchcard.CardDimensions) (string, error) {
+	pageSize := r.FormValue("pageSize")
+	if pageSize == "" {
+		pageSize = "A4"
+	}
+	if err := punchcard.ValidatePageSize(pageSize); err != nil {
+		return "", err
 	}
-	defer file.Close()
+	if format == "pdf" || format == "zip" {
+		if _, err := punchcard.LayoutPage(punchcard.GetPageSize(pageSize), dims); err != nil {
+			return "", err
+		}
+	}
+	return pageSize, nil
+}
 
-	// Get color mode
-	colorModeStr := r.FormValue("colorMode")
-	if colorModeStr == "" {
-		colorModeStr = "2"
+// parseLoomProfile reads the loom and card stock options for the production estimate
+// Blank fields keep the defaults for cards of the given dimensions
+func parseLoomProfile(r *http.Request, dims punchcard.CardDimensions) (punchcard.LoomProfile, int, error) {
+	profile := punchcard.DefaultLoomProfile(dims)
+
+	fields := []struct {
+		key string
+		dst *float64
+	}{
+		{"picksPerMinute", &profile.PicksPerMinute},
+		{"picksPerCm", &profile.PicksPerCm},
+		{"cardWidthMm", &profile.CardWidthMm},
+		{"cardHeightMm", &profile.CardHeightMm},
+		{"cardThickness", &profile.CardThicknessMm},
+		{"cardGsm", &profile.CardGSM},
+		{"lacingPerCard", &profile.LacingPerCardCm},
+		{"sheetWidth", &profile.SheetWidthMm},
+		{"sheetHeight", &profile.SheetHeightMm},
+		{"sparePercent", &profile.SparePercent},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		v, err := parseFloatField(f.key, value)
+		if err != nil {
+			return profile, 0, err
+		}
+		*f.dst = v
+	}
+
+	repeats := 1
+	if value := r.FormValue("repeats"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return profile, 0, &FieldError{Field: "repeats", Message: fmt.Sprintf("invalid repeats: %s (must be 1 or more)", value)}
+		}
+		repeats = n
+	}
+
+	return profile, repeats, profile.Validate()
+}
+
+// parseCardLayout reads the measurements of a card to digitize, in millimeters
+// Blank fields keep the layout of a card printed by the SVG exporter, which has no peg holes
+func parseCardLayout(r *http.Request, cardType punchcard.CardType) (image.CardLayout, error) {
+	layout := image.LayoutForCard(punchcard.GetCardDimensions(cardType))
+
+	fields := []struct {
+		key string
+		dst *float64
+	}{
+		{"cardWidthMm", &layout.Width},
+		{"cardHeightMm", &layout.Height},
+		{"firstHoleXMm", &layout.OriginX},
+		{"firstHoleYMm", &layout.OriginY},
+		{"holeSpacingMm", &layout.Spacing},
+		{"holeRadiusMm", &layout.HoleRadius},
+		{"pegInsetMm", &layout.PegInset},
+		{"pegRadiusMm", &layout.PegRadius},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		v, err := parseFloatField(f.key, value)
+		if err != nil {
+			return layout, err
+		}
+		*f.dst = v
+	}
+	return layout, layout.Validate()
+}
+
+// configureAlpha reads the transparency options from the form into the processor
+func configureAlpha(r *http.Request, processor *image.Processor) error {
+	mode := r.FormValue("alphaMode")
+	if mode == "" {
+		mode = string(image.AlphaBackground)
 	}
-	colorMode, err := strconv.Atoi(colorModeStr)
-	if err != nil || image.ValidateColorMode(colorMode) != nil {
-		colorMode = 2
+	if err := image.ValidateAlphaMode(mode); err != nil {
+		return err
 	}
+	processor.Alpha = image.AlphaMode(mode)
 
-	// Get card type parameter
-	cardTypeStr := r.FormValue("cardType")
-	if cardTypeStr == "" {
-		cardTypeStr = "26x8" // Default to 26x8
+	if value := r.FormValue("background"); value != "" {
+		background, err := image.ParseHexColor(value)
+		if err != nil {
+			return err
+		}
+		processor.Background = background
 	}
-	if err := punchcard.ValidateCardType(cardTypeStr); err != nil {
-		cardTypeStr = "26x8" // Fallback to default if invalid
+
+	if processor.Alpha == image.AlphaStructure {
+		ground, err := image.GroundStructureByName(groundStructureName(r))
+		if err != nil {
+			return err
+		}
+		processor.Ground = ground
 	}
-	cardType := punchcard.CardType(cardTypeStr)
-	dims := punchcard.GetCardDimensions(cardType)
+	return nil
+}
 
-	// Process the image
-	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600)
-	// Height is auto-calculated from aspect ratio
-	processorWidth := dims.Width * dims.Height
-	processor := image.NewProcessor(processorWidth, 0, image.ColorMode(colorMode))
+// groundStructureName returns the requested ground structure, defaulting to the plain weave
+func groundStructureName(r *http.Request) string {
+	if name := r.FormValue("groundStructure"); name != "" {
+		return name
+	}
+	return image.DefaultGroundStructure
+}
 
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+// parseFrame reads the 1-based GIF frame or TIFF page from the form and returns it counted from 0
+func parseFrame(r *http.Request) (int, error) {
+	value := r.FormValue("frame")
+	if value == "" {
+		return 0, nil
 	}
+	frame, err := strconv.Atoi(value)
+	if err != nil || frame < 1 {
+		return 0, fmt.Errorf("invalid frame: %s (must be 1 or more)", value)
+	}
+	return frame - 1, nil
+}
 
-	matrix, err := processor.Process(bytes.NewReader(fileBytes))
-	if err != nil {
-		http.Error(w, "Failed to process image", http.StatusBadRequest)
-		return
+// formBool reads a checkbox-style boolean form value
+func formBool(r *http.Request, key string) bool {
+	switch r.FormValue(key) {
+	case "true", "on", "1":
+		return true
+	default:
+		return false
 	}
+}
 
-	// Safety check: ensure matrix is not empty
-	if len(matrix) == 0 || len(matrix[0]) == 0 {
-		http.Error(w, "Failed to process image: resulted in empty matrix", http.StatusBadRequest)
-		return
+// formBoolDefault reads a boolean form value, returning def when the field is absent
+func formBoolDefault(r *http.Request, key string, def bool) bool {
+	if r.FormValue(key) == "" {
+		return def
 	}
+	return formBool(r, key)
+}
 
-	// Generate punchcards with the specified card type
-	generator := punchcard.NewGeneratorWithType(cardType)
-	cards, err := generator.Generate(matrix)
+// parseLettering reads the lettering layout options from the form
+func parseLettering(r *http.Request) (*image.Lettering, error) {
+	lettering := image.NewLettering()
+	if font := r.FormValue("font"); font != "" {
+		lettering.Font = font
+	}
+	if align := r.FormValue("align"); align != "" {
+		lettering.Align = image.Alignment(align)
+	}
+
+	fields := []struct {
+		key string
+		dst *int
+	}{
+		{"height", &lettering.Height},
+		{"letterSpacing", &lettering.LetterSpacing},
+		{"lineSpacing", &lettering.LineSpacing},
+	}
+	for _, f := range fields {
+		value := r.FormValue(f.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, &FieldError{Field: f.key, Message: fmt.Sprintf("invalid %s: %s (must be a whole number)", f.key, value)}
+		}
+		*f.dst = n
+	}
+
+	lettering.Outline = formBool(r, "outline")
+	lettering.Monospace = formBool(r, "monospace")
+	return lettering, lettering.Validate()
+}
+
+// parsePosition reads an optional lettering position from the form, returning ok false when blank
+func parsePosition(r *http.Request, key string) (int, bool, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
 	if err != nil {
-		http.Error(w, "Failed to generate punchcards", http.StatusInternalServerError)
-		return
+		return 0, false, &FieldError{Field: key, Message: fmt.Sprintf("invalid %s: %s (must be a whole number)", key, value)}
 	}
+	return n, true, nil
+}
 
-	// Generate metadata
-	metadata := punchcard.GenerateMetadata(cards)
+// UploadTextHandler handles uploading and processing text or JSON format punchcard files
+func (h *Handler) UploadTextHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.convertCardSet)
+}
 
-	// Create response
-	response := map[string]interface{}{
-		"filename":        header.Filename,
-		"fileSize":        header.Size,
-		"colorMode":       processor.DescribeColorMode(),
-		"totalCards":      metadata.TotalCards,
-		"cardDimensions":  fmt.Sprintf("%dx%d", metadata.CardWidth, metadata.CardHeight),
-		"totalRows":       metadata.TotalRows,
-		"averageDensity":  fmt.Sprintf("%.1f%%", metadata.AverageDensity),
-		"holesPerCard":    metadata.HolesPerCard,
-	}
+// PreviewTextHandler generates a preview from an uploaded text file
+func (h *Handler) PreviewTextHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.previewCardSet)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// InfoTextHandler returns information about an uploaded text file
+func (h *Handler) InfoTextHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.cardSetInfo)
 }
 
-// UploadTextHandler handles uploading and processing text format punchcard files
-func (h *Handler) UploadTextHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// StatsTextHandler returns hook usage statistics for an uploaded text or JSON card set
+func (h *Handler) StatsTextHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.cardSetStats)
+}
 
-	// Parse multipart form (max 10MB)
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+// DigitizeHandler reads the hole pattern from a scan or photo of a physical card
+// and returns it as an editable text pattern with per-hole confidence values
+func (h *Handler) DigitizeHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.digitizeCard)
+}
+
+// LetteringHandler renders text with a bitmap font and returns it as punchcards
+// The lettering is placed on its own, or composited into an optional uploaded image
+func (h *Handler) LetteringHandler(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.renderLettering)
+}
+
+// cardSetUpload is an uploaded card set, reoriented by the request's transform options
+type cardSetUpload struct {
+	file    *upload
+	parsed  *punchcard.ParseResult
+	cards   []*punchcard.Card   // The cards after the request's transform
+	applied punchcard.Transform // Orientation applied before the upload followed by the request's
+}
+
+// parseCardSetUpload reads the uploaded text or JSON card set and applies the transform options
+func parseCardSetUpload(r *http.Request, files uploads) (*cardSetUpload, *APIError) {
+	file, apiErr := files.get("textfile")
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	// Get the uploaded file
-	file, header, err := r.FormFile("textfile")
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
 	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
 	}
-	defer file.Close()
 
-	log.Printf("Received text file: %s (%d bytes)", header.Filename, header.Size)
+	// Parse the card set (text or JSON format)
+	parsed, err := punchcard.ParseCardSet(file.Data)
+	if err != nil {
+		setErrorClass(r, "parse")
+		logger(r).Warn("failed to parse card set", "error", err)
+		code := CodeCardSetParseFailed
+		if errors.Is(err, punchcard.ErrWidthMismatch) {
+			code = CodeWidthMismatch
+		}
+		return nil, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    code,
+			Message: fmt.Sprintf("Failed to parse card set file: %v", err),
+			Details: []FieldError{{Field: "textfile", Message: err.Error()}},
+		}
+	}
+
+	// Reorient the cards, keeping track of any orientation applied before the upload
+	return &cardSetUpload{
+		file:    file,
+		parsed:  parsed,
+		cards:   transform.Apply(parsed.Cards),
+		applied: parsed.Transform.Then(transform),
+	}, nil
+}
 
-	// Get format parameter for export (svg, pdf, or txt)
-	format := r.FormValue("format")
-	if format == "" {
-		format = "svg" // Default to SVG
+// convertCardSet converts an uploaded card set into a download format
+func (h *Handler) convertCardSet(r *http.Request, files uploads) (*result, *APIError) {
+	// Get format parameter for export
+	format, apiErr := requestFormat(r)
+	if apiErr != nil {
+		return nil, apiErr
 	}
-	if format != "svg" && format != "pdf" && format != "txt" {
-		http.Error(w, "Invalid format (must be 'svg', 'pdf', or 'txt')", http.StatusBadRequest)
-		return
+
+	set, apiErr := parseCardSetUpload(r, files)
+	if apiErr != nil {
+		return nil, apiErr
 	}
+	logger(r).Info("parsed card set", "filename", set.file.Filename, "bytes", set.file.Size(), "cards", len(set.cards))
 
-	// Read the file content
-	fileBytes, err := io.ReadAll(file)
+	// Get loom profile options for the production estimate
+	profile, repeats, err := parseLoomProfile(r, cardDimensions(set.cards))
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
 
-	// Parse the text format
-	parser := punchcard.NewTextParser()
-	result, err := parser.Parse(string(fileBytes))
+	// Get page size for printable formats
+	pageSize, err := parsePageSize(r, format, cardDimensions(set.cards))
 	if err != nil {
-		log.Printf("Error parsing text file: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to parse text file: %v", err), http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidPageSize, "pageSize", "page size", err)
 	}
 
-	log.Printf("Parsed %d cards from text file", len(result.Cards))
+	// Get the range of cards to export, every card by default
+	rng, err := parseCardRange(r, false)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidRange, "range", "card range", err)
+	}
+
+	return h.exportResult(r, set.cards, format, exportOptions{
+		Title:     set.parsed.Title,
+		Settings:  set.parsed.Settings,
+		Transform: set.applied,
+		Profile:   profile,
+		Repeats:   repeats,
+		PageSize:  pageSize,
+		Range:     rng,
+		Section:   r.FormValue("section"),
+	})
+}
 
-	// Export based on format
-	var output bytes.Buffer
-	var contentType string
-	var filename string
+// previewCardSet returns a range of the cards of an uploaded card set as an inline SVG, or their overview
+func (h *Handler) previewCardSet(r *http.Request, files uploads) (*result, *APIError) {
+	preview, apiErr := parsePreviewOptions(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	set, apiErr := parseCardSetUpload(r, files)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return previewResult(set.cards, set.parsed.Title, set.applied, preview)
+}
 
-	if format == "svg" {
-		exporter := punchcard.NewSVGExporter()
-		exporter.SetTitle(result.Title, len(result.Cards))
-		err = exporter.ExportCards(result.Cards, &output)
-		contentType = "image/svg+xml"
-		filename = "punchcards.svg"
-	} else if format == "txt" {
-		exporter := punchcard.NewTextExporter()
-		exporter.SetTitle(result.Title, len(result.Cards))
-		err = exporter.ExportCards(result.Cards, &output)
-		contentType = "text/plain; charset=utf-8"
-		filename = "punchcards.txt"
-	} else {
-		// For PDF
-		exporter := punchcard.NewSVGExporter()
-		exporter.SetTitle(result.Title, len(result.Cards))
-		err = exporter.ExportCards(result.Cards, &output)
-		contentType = "application/pdf"
-		filename = "punchcards.pdf"
+// cardSetInfo describes an uploaded card set
+func (h *Handler) cardSetInfo(r *http.Request, files uploads) (*result, *APIError) {
+	set, apiErr := parseCardSetUpload(r, files)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
+	// Get loom profile options for the production estimate
+	profile, repeats, err := parseLoomProfile(r, cardDimensions(set.cards))
 	if err != nil {
-		log.Printf("Error exporting cards: %v", err)
-		http.Error(w, "Failed to export punchcards", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
 
-	// Set headers for download
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	w.Header().Set("Content-Length", strconv.Itoa(output.Len()))
+	// Get the loop mode, checking the join of an endless chain
+	loop, apiErr := parseLoop(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Generate metadata
+	metadata := punchcard.GenerateMetadata(set.cards)
 
-	// Write output
-	_, err = w.Write(output.Bytes())
+	// Create response
+	response := newInfoResponse(set.file.Filename, set.file.Size(), metadata)
+	response.Estimate, err = punchcard.EstimateProduction(set.cards, profile, repeats)
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
+	}
+	response.Title = set.parsed.Title
+	response.Transform = set.applied.String()
+	response.Sections = newSectionInfo(set.cards, func(info *SectionInfo, cards []*punchcard.Card) {
+		if loop.Loop {
+			info.Join = punchcard.CheckJoin(cards, loop.MaxFloat)
+		}
+	})
+	if response.Sections == nil {
+		response.Statistics = punchcard.GenerateStatistics(set.cards)
+		if loop.Loop {
+			response.Join = punchcard.CheckJoin(set.cards, loop.MaxFloat)
+		}
 	}
+
+	return jsonResult(response), nil
 }
 
-// PreviewTextHandler generates a preview from an uploaded text file
-func (h *Handler) PreviewTextHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// StatsResponse is the JSON body returned by the statistics endpoint
+type StatsResponse struct {
+	Filename   string                `json:"filename"`
+	Title      string                `json:"title,omitempty"`
+	Transform  string                `json:"transform"`
+	Statistics *punchcard.Statistics `json:"statistics,omitempty"`
+	Sections   []SectionInfo         `json:"sections,omitempty"` // Statistics of each section, instead of the whole set
+}
+
+// cardSetStats returns hook usage statistics for an uploaded card set
+func (h *Handler) cardSetStats(r *http.Request, files uploads) (*result, *APIError) {
+	// Statistics describe the cards as they will be laced, after any reorientation
+	set, apiErr := parseCardSetUpload(r, files)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+	response := &StatsResponse{
+		Filename:  set.file.Filename,
+		Title:     set.parsed.Title,
+		Transform: set.applied.String(),
+		Sections:  newSectionInfo(set.cards, nil),
+	}
+	if response.Sections == nil {
+		response.Statistics = punchcard.GenerateStatistics(set.cards)
 	}
+	return jsonResult(response), nil
+}
 
-	// Get the uploaded file
-	file, _, err := r.FormFile("textfile")
-	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+// digitizeCard reads a scanned card into a download, by default a text pattern followed by its confidence block
+func (h *Handler) digitizeCard(r *http.Request, files uploads) (*result, *APIError) {
+	// Get the uploaded scan
+	file, apiErr := files.get("image")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get format parameter
+	format, apiErr := digitizeFormat(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	logger(r).Info("received card scan", "filename", file.Filename, "bytes", file.Size())
+
+	// Get card type parameter
+	cardType, apiErr := parseCardType(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get anchor parameter (outline or peg holes)
+	anchor := r.FormValue("anchor")
+	if anchor == "" {
+		anchor = string(image.AnchorOutline)
+	}
+	if err := image.ValidateAnchor(anchor); err != nil {
+		return nil, invalidOption(CodeInvalidAnchor, "anchor", "anchor", err)
 	}
-	defer file.Close()
 
-	// Read the file content
-	fileBytes, err := io.ReadAll(file)
+	// Get loom profile options for the production estimate, and page size for printable formats
+	dims := punchcard.GetCardDimensions(cardType)
+	profile, repeats, err := parseLoomProfile(r, dims)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
+	}
+	pageSize, err := parsePageSize(r, format, dims)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidPageSize, "pageSize", "page size", err)
 	}
 
-	// Parse the text format
-	parser := punchcard.NewTextParser()
-	result, err := parser.Parse(string(fileBytes))
+	// Get title parameter (optional)
+	title := r.FormValue("title")
+
+	// Get the measurements of the card, by default those of a card printed by this tool
+	layout, err := parseCardLayout(r, cardType)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to parse text file: %v", err), http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidCardLayout, "cardLayout", "card layout", err)
 	}
 
-	// Generate preview (first 3 cards only)
-	previewCards := result.Cards
-	if len(previewCards) > 3 {
-		previewCards = result.Cards[:3]
+	digitizer := image.NewDigitizer(cardType)
+	digitizer.Layout = layout
+	digitizer.Anchor = image.Anchor(anchor)
+	digitizer.MaxPixels = h.MaxPixels
+	digitizer.HolesLight = r.FormValue("holesLight") == "true"
+
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
 	}
 
-	// Export as SVG for preview
-	var output bytes.Buffer
-	exporter := punchcard.NewSVGExporter()
-	exporter.SetTitle(result.Title, len(result.Cards))
-	err = exporter.ExportCards(previewCards, &output)
+	digitized, err := digitizer.Digitize(bytes.NewReader(file.Data))
 	if err != nil {
-		http.Error(w, "Failed to generate preview", http.StatusInternalServerError)
-		return
+		setErrorClass(r, "digitize")
+		logger(r).Warn("failed to digitize card", "error", err)
+		code := CodeDigitizeFailed
+		if errors.Is(err, image.ErrImageTooLarge) {
+			code = CodeImageTooLarge
+		}
+		return nil, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    code,
+			Message: fmt.Sprintf("Failed to digitize card: %v", err),
+			Details: []FieldError{{Field: "image", Message: err.Error()}},
+		}
+	}
+	digitized.ApplyTransform(transform)
+
+	uncertain := digitized.Uncertain(digitizer.UncertainBelow)
+	logger(r).Info("digitized card", "holes", digitized.Card.CountHoles(), "uncertain", len(uncertain))
+
+	// Export in the requested format; the text pattern is followed by the confidence block
+	output, contentType, filename, err := h.export(r, []*punchcard.Card{digitized.Card}, format, exportOptions{
+		Title:     title,
+		Transform: transform,
+		Profile:   profile,
+		Repeats:   repeats,
+		PageSize:  pageSize,
+	})
+	if err == nil && format == "txt" {
+		err = digitized.WriteConfidence(output, digitizer.UncertainBelow)
 	}
+	if err != nil {
+		setErrorClass(r, "export")
+		logger(r).Error("failed to export digitized card", "error", err)
+		return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to export digitized card")
+	}
+
+	return &result{
+		contentType: contentType,
+		body:        output.Bytes(),
+		filename:    "digitized" + path.Ext(filename),
+		headers: map[string]string{
+			"X-Uncertain-Holes":     strconv.Itoa(len(uncertain)),
+			"X-Punchcard-Transform": transform.String(),
+		},
+	}, nil
+}
 
-	// Return SVG directly for inline display
-	w.Header().Set("Content-Type", "image/svg+xml")
-	w.Write(output.Bytes())
+// digitizeFormat returns the download format of a digitized card: the text pattern with its
+// confidence block unless the format option or, on the API, the Accept header asks for another
+func digitizeFormat(r *http.Request) (string, *APIError) {
+	if r.FormValue("format") == "" && (!isAPIRequest(r) || strings.TrimSpace(r.Header.Get("Accept")) == "") {
+		return "txt", nil
+	}
+	return requestFormat(r)
 }
 
-// InfoTextHandler returns information about an uploaded text file
-func (h *Handler) InfoTextHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// renderLettering renders text as punchcards, on its own or composited into an uploaded image
+func (h *Handler) renderLettering(r *http.Request, files uploads) (*result, *APIError) {
+	text := r.FormValue("text")
+	if strings.TrimSpace(text) == "" {
+		return nil, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeMissingField,
+			Message: "No text provided",
+			Details: []FieldError{{Field: "text", Message: "text is required"}},
+		}
+	}
+
+	// Get format parameter
+	format, apiErr := requestFormat(r)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20)
+	// Get title parameter (optional)
+	title := r.FormValue("title")
+
+	// Get card type parameter
+	cardType, apiErr := parseCardType(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	dims := punchcard.GetCardDimensions(cardType)
+
+	// Get the weaves, selvedges and border cards of the chain
+	generator, apiErr := parseGenerator(r, files, cardType)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Get orientation and polarity options
+	transform, err := parseTransform(r)
 	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidTransform, "transform", "transform", err)
 	}
 
-	// Get the uploaded file
-	file, header, err := r.FormFile("textfile")
+	// Get loom profile options for the production estimate
+	profile, repeats, err := parseLoomProfile(r, dims)
 	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidLoomProfile, "loomProfile", "loom profile", err)
 	}
-	defer file.Close()
 
-	// Read the file content
-	fileBytes, err := io.ReadAll(file)
+	// Get page size for printable formats
+	pageSize, err := parsePageSize(r, format, dims)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
-		return
+		return nil, invalidOption(CodeInvalidPageSize, "pageSize", "page size", err)
 	}
 
-	// Parse the text format
-	parser := punchcard.NewTextParser()
-	result, err := parser.Parse(string(fileBytes))
+	// Get the range of cards to export, every card by default
+	rng, err := parseCardRange(r, false)
 	if err != nil {
-		http.Error(w, "Failed to parse text file", http.StatusBadRequest)
-		return
+		return nil, invalidOption(CodeInvalidRange, "range", "card range", err)
 	}
 
-	// Generate metadata
-	metadata := punchcard.GenerateMetadata(result.Cards)
+	// Get font and layout options
+	lettering, err := parseLettering(r)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidLettering, "lettering", "lettering", err)
+	}
 
-	// Create response
-	response := map[string]interface{}{
-		"filename":       header.Filename,
-		"fileSize":       header.Size,
-		"title":          result.Title,
-		"totalCards":     metadata.TotalCards,
-		"cardDimensions": fmt.Sprintf("%dx%d", metadata.CardWidth, metadata.CardHeight),
-		"totalRows":      metadata.TotalRows,
-		"averageDensity": fmt.Sprintf("%.1f%%", metadata.AverageDensity),
-		"holesPerCard":   metadata.HolesPerCard,
+	bitmap, err := lettering.Render(text)
+	if err != nil {
+		return nil, invalidOption(CodeRenderFailed, "text", "text", err)
+	}
+
+	logger(r).Info("rendered lettering", "lines", strings.Count(text, "\n")+1, "width", bitmap.Width, "height", bitmap.Height)
+
+	// Image width should be Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600), less any selvedges
+	processorWidth := generator.DesignWidth()
+	settings := &punchcard.GenerationSettings{
+		Source:  "lettering",
+		Options: map[string]string{"text": text, "font": lettering.Font},
+	}
+	settings.Options = generationOptions(generator, settings.Options)
+
+	var matrix [][]int
+	if file, ok := files["image"]; ok {
+		logger(r).Info("received image", "filename", file.Filename, "bytes", file.Size())
+
+		processor, apiErr := h.newImageProcessor(r, processorWidth)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		matrix, _, apiErr = h.imageCards(r, processor, nil, file.Data)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		// Place the lettering, centred on any axis without an explicit position
+		x, ok, err := parsePosition(r, "x")
+		if err != nil {
+			return nil, invalidOption(CodeInvalidPosition, "x", "position", err)
+		}
+		if !ok {
+			x = (len(matrix[0]) - bitmap.Width) / 2
+		}
+		y, ok, err := parsePosition(r, "y")
+		if err != nil {
+			return nil, invalidOption(CodeInvalidPosition, "y", "position", err)
+		}
+		if !ok {
+			y = (len(matrix) - bitmap.Height) / 2
+		}
+		bitmap.CompositeInto(matrix, x, y)
+
+		settings.ColorMode = int(processor.ColorMode)
+		settings.Source = file.Filename
+		settings.Options["position"] = fmt.Sprintf("%d,%d", x, y)
+	} else {
+		matrix, err = bitmap.Matrix(processorWidth)
+		if err != nil {
+			return nil, invalidOption(CodeRenderFailed, "text", "text", err)
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// Generate punchcards with the specified card type
+	cards, apiErr := h.generateCards(r, generator, matrix)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// Reorient the cards for the loom before exporting
+	cards = transform.Apply(cards)
+
+	return h.exportResult(r, cards, format, exportOptions{
+		Title:     title,
+		Settings:  settings,
+		Transform: transform,
+		Profile:   profile,
+		Repeats:   repeats,
+		PageSize:  pageSize,
+		Range:     rng,
+		Section:   r.FormValue("section"),
+	})
+}
+
+// HealthResponse is the JSON body returned by the health check
+type HealthResponse struct {
+	Status  string         `json:"status"`
+	Service string         `json:"service"`
+	Build   buildinfo.Info `json:"build"`
 }
 
 // HealthHandler provides a health check endpoint
 func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
-		"service": "Jacquard Loom Punchcard Generator",
+	json.NewEncoder(w).Encode(&HealthResponse{
+		Status:  "healthy",
+		Service: "Jacquard Loom Punchcard Generator",
+		Build:   h.build,
 	})
 }
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/limit"
+)
+
+// Limit guards a conversion endpoint with the per-client rate limit and the concurrency pool
+// Refused requests get 429 Too Many Requests, and requests that waited too long for a
+// pipeline slot get 503 Service Unavailable, both with a Retry-After header
+func (h *Handler) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.RateLimiter != nil {
+			if ok, wait := h.RateLimiter.Allow(h.clientKey(r)); !ok {
+				setErrorClass(r, "rate_limited")
+				w.Header().Set("Retry-After", retryAfterSeconds(wait))
+				writeError(w, r, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please slow down"))
+				return
+			}
+		}
+
+		if h.Pool != nil {
+			start := time.Now()
+			release, err := h.Pool.Acquire(r.Context())
+			h.metrics.queueWait.Observe(time.Since(start).Seconds())
+			if err != nil {
+				if !errors.Is(err, limit.ErrQueueTimeout) {
+					// The client went away while queued; there is no one to answer
+					setErrorClass(r, "canceled")
+					return
+				}
+				setErrorClass(r, "overloaded")
+				w.Header().Set("Retry-After", retryAfterSeconds(h.Pool.QueueTimeout))
+				writeError(w, r, newAPIError(http.StatusServiceUnavailable, CodeOverloaded, "Server is busy, please try again shortly"))
+				return
+			}
+			defer release()
+		}
+
+		next(w, r)
+	}
+}
+
+// clientKey identifies the client a request is rate limited as: its API key when RateLimitByAPIKey
+// is set and Authenticate verified the key, its user when authentication is enabled, and otherwise
+// its IP address. An unverified key is never used, so inventing keys cannot buy fresh buckets
+func (h *Handler) clientKey(r *http.Request) string {
+	if h.RateLimitByAPIKey {
+		if id, ok := r.Context().Value(apiKeyIDKey{}).(string); ok {
+			return "key:" + id
+		}
+	}
+	if h.Auth != nil {
+		if u := h.user(r); u != nil {
+			return "user:" + u.ID
+		}
+	}
+	return "ip:" + h.clientIP(r)
+}
+
+// clientIP returns the request's client address, taken from X-Forwarded-For when TrustProxy is set
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// The proxy appends the address it saw, so the first entry is the original client
+			first := strings.TrimSpace(strings.Split(forwarded, ",")[0])
+			if net.ParseIP(first) != nil {
+				return first
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfterSeconds formats a wait as whole seconds for the Retry-After header, rounding up
+func retryAfterSeconds(wait time.Duration) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/image"
+)
+
+// DefaultJoinCards is how many cards either side of the join a loop preview shows
+const DefaultJoinCards = 3
+
+// loopOptions are the options of chains woven as an endless loop, the last card laced to the first
+type loopOptions struct {
+	Loop     bool
+	MaxFloat int // Longest warp float allowed across the join (0 = no limit)
+}
+
+// parseLoop reads the loop mode and its float limit
+func parseLoop(r *http.Request) (loopOptions, *APIError) {
+	opts := loopOptions{Loop: formBool(r, "loop")}
+	if value := r.FormValue("maxFloat"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return opts, invalidOption(CodeInvalidLoop, "maxFloat", "loop option",
+				fmt.Errorf("invalid maxFloat: %s (must be 1 or more picks)", value))
+		}
+		opts.MaxFloat = n
+	}
+	return opts, nil
+}
+
+// configureTile sets how the image is made to repeat vertically, from the tile and tileRows options
+func configureTile(r *http.Request, processor *image.Processor) error {
+	mode := r.FormValue("tile")
+	if mode == "none" {
+		mode = ""
+	}
+	if err := image.ValidateTileMode(mode); err != nil {
+		return &FieldError{Field: "tile", Message: err.Error()}
+	}
+	processor.Tile = image.TileMode(mode)
+
+	if value := r.FormValue("tileRows"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return &FieldError{Field: "tileRows", Message: fmt.Sprintf("invalid tileRows: %s (must be 1 or more)", value)}
+		}
+		processor.TileRows = n
+	}
+	return nil
+}
+
+// parseJoinCards reads how many cards either side of the join a loop preview shows
+// Previews of a loop show the join unless a range of cards is asked for
+func parseJoinCards(r *http.Request) (int, error) {
+	if !formBool(r, "loop") || r.FormValue("from") != "" || r.FormValue("to") != "" || r.FormValue("page") != "" {
+		return 0, nil
+	}
+	n, ok, err := parseCardNumber(r, "joinCards")
+	if err != nil || !ok {
+		return DefaultJoinCards, err
+	}
+	return n, nil
+}
+
+// setJoinHeaders reports which cards either side of the join a result holds, e.g. "206-208,1-3"
+func setJoinHeaders(res *result, join, total int) {
+	if res.headers == nil {
+		res.headers = map[string]string{}
+	}
+	cards := "1-" + strconv.Itoa(total)
+	if 2*join < total {
+		cards = fmt.Sprintf("%d-%d,1-%d", total-join+1, total, join)
+	}
+	res.headers["X-Punchcard-Range"] = cards
+	res.headers["X-Punchcard-Total"] = strconv.Itoa(total)
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/logging"
+	"github.com/oscaralmgren/loom-punchcards/internal/metrics"
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// Metrics holds the application's Prometheus metrics
+type Metrics struct {
+	registry        *metrics.Registry
+	requests        *metrics.Counter   // Requests by route and status code
+	requestDuration *metrics.Histogram // Request latency by route
+	requestBytes    *metrics.Histogram // Upload size by route
+	responseBytes   *metrics.Counter   // Bytes sent by route
+	stageDuration   *metrics.Histogram // Pipeline stage latency
+	conversions     *metrics.Counter   // Successful downloads by format and card type
+	errors          *metrics.Counter   // Failed requests by error class
+	cardsGenerated  *metrics.Counter   // Cards generated by card type
+	cardsPerJob     *metrics.Histogram // Cards generated per request
+	queueWait       *metrics.Histogram // Time spent waiting for a pipeline slot
+	cacheHits       *metrics.Counter   // Conversions reused from the cache by tier
+	cacheMisses     *metrics.Counter   // Conversions not found in the cache
+}
+
+// Pipeline stages timed in addition to the image processor's decode, resize and dither
+const (
+	stageGenerate = "generate"
+	stageExport   = "export"
+)
+
+// NewMetrics registers the application metrics in a new registry
+func NewMetrics() *Metrics {
+	r := metrics.NewRegistry()
+	return &Metrics{
+		registry: r,
+		requests: r.NewCounter("loom_http_requests_total",
+			"HTTP requests by route and status code.", "route", "code"),
+		requestDuration: r.NewHistogram("loom_http_request_duration_seconds",
+			"HTTP request latency by route.", metrics.DefaultBuckets, "route"),
+		requestBytes: r.NewHistogram("loom_http_request_bytes",
+			"HTTP request body size by route.", []float64{1 << 10, 16 << 10, 128 << 10, 1 << 20, 4 << 20, 16 << 20}, "route"),
+		responseBytes: r.NewCounter("loom_http_response_bytes_total",
+			"Bytes written in HTTP responses by route.", "route"),
+		stageDuration: r.NewHistogram("loom_stage_duration_seconds",
+			"Pipeline stage latency: decode, resize, dither, generate and export.", metrics.DefaultBuckets, "stage"),
+		conversions: r.NewCounter("loom_conversions_total",
+			"Card sets exported by format and card type.", "format", "card_type"),
+		errors: r.NewCounter("loom_errors_total",
+			"Failed requests by error class.", "class"),
+		cardsGenerated: r.NewCounter("loom_cards_generated_total",
+			"Punchcards generated by card type.", "card_type"),
+		cardsPerJob: r.NewHistogram("loom_cards_per_job",
+			"Punchcards generated per request.", []float64{1, 10, 50, 100, 250, 500, 1000, 2500, 5000}),
+		queueWait: r.NewHistogram("loom_pipeline_queue_wait_seconds",
+			"Time conversion requests waited for a free pipeline slot.", metrics.DefaultBuckets),
+		cacheHits: r.NewCounter("loom_cache_hits_total",
+			"Conversions reused from the cache by tier: memory or disk.", "tier"),
+		cacheMisses: r.NewCounter("loom_cache_misses_total",
+			"Conversions not found in the cache."),
+	}
+}
+
+// watchLimits registers gauges reporting the state of the handler's rate limiter and pipeline pool
+func (m *Metrics) watchLimits(h *Handler) {
+	m.registry.NewGaugeFunc("loom_pipeline_in_flight", "Conversions currently running.", func() float64 {
+		if h.Pool == nil {
+			return 0
+		}
+		return float64(h.Pool.InUse())
+	})
+	m.registry.NewGaugeFunc("loom_pipeline_queued", "Conversions waiting for a free pipeline slot.", func() float64 {
+		if h.Pool == nil {
+			return 0
+		}
+		return float64(h.Pool.Waiting())
+	})
+	m.registry.NewGaugeFunc("loom_rate_limit_clients", "Clients currently tracked by the rate limiter.", func() float64 {
+		if h.RateLimiter == nil {
+			return 0
+		}
+		return float64(h.RateLimiter.Clients())
+	})
+}
+
+// observeStage records the time a pipeline stage took
+func (m *Metrics) observeStage(stage string, elapsed time.Duration) {
+	m.stageDuration.Observe(elapsed.Seconds(), stage)
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format
+func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	h.metrics.registry.Handler().ServeHTTP(w, r)
+}
+
+// requestInfo is what a handler reports back to the middleware about its request
+type requestInfo struct {
+	id         string
+	errorClass string // Why the request failed, when the status code alone does not say
+}
+
+type requestInfoKey struct{}
+
+// setErrorClass records why a request failed, for the error counter and the request log
+func setErrorClass(r *http.Request, class string) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.errorClass = class
+	}
+}
+
+// logger returns the request's logger, which adds the request ID to every entry
+func logger(r *http.Request) *logging.Logger {
+	return logging.FromContext(r.Context())
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Instrument assigns each request an ID, logs it as a structured entry and records its metrics
+// Routes are labelled with the mux pattern that served them, which keeps the label set bounded
+func (h *Handler) Instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		info := &requestInfo{id: id}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		ctx = logging.NewContext(ctx, logging.Default().With("request_id", id))
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		_, route := mux.Handler(r)
+		mux.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+
+		code := strconv.Itoa(rec.status)
+		h.metrics.requests.Inc(route, code)
+		h.metrics.requestDuration.Observe(elapsed.Seconds(), route)
+		h.metrics.responseBytes.Add(float64(rec.bytes), route)
+		if r.ContentLength > 0 {
+			h.metrics.requestBytes.Observe(float64(r.ContentLength), route)
+		}
+
+		fields := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", float64(elapsed.Microseconds()) / 1000,
+			"bytes_in", r.ContentLength,
+			"bytes_out", rec.bytes,
+			"remote", r.RemoteAddr,
+		}
+		switch {
+		case rec.status >= 400:
+			class := info.errorClass
+			if class == "" {
+				class = statusErrorClass(rec.status)
+			}
+			h.metrics.errors.Inc(class)
+			fields = append(fields, "error_class", class)
+			if rec.status >= 500 {
+				logger(r).Error("request failed", fields...)
+			} else {
+				logger(r).Warn("request rejected", fields...)
+			}
+		default:
+			logger(r).Info("request", fields...)
+		}
+	})
+}
+
+// statusErrorClass names the error class of a failed request from its status code
+func statusErrorClass(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusNotAcceptable:
+		return "not_acceptable"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "overloaded"
+	}
+	if status >= 500 {
+		return "internal"
+	}
+	return "client"
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 16-character hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cardTypeLabel names the card type of a card set for metric labels
+func cardTypeLabel(cards []*punchcard.Card) string {
+	if len(cards) == 0 {
+		return "unknown"
+	}
+	cardType, err := punchcard.CardTypeForDimensions(cardDimensions(cards))
+	if err != nil {
+		return "unknown"
+	}
+	return string(cardType)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// DefaultPreviewCards is how many cards a preview shows, and how many cards make a page
+const DefaultPreviewCards = 3
+
+// Overview scale limits, in pixels per hole
+const (
+	DefaultOverviewScale = 2
+	MaxOverviewScale     = 8
+)
+
+// previewOptions say what a preview shows: a range of cards, or an overview of the whole design
+type previewOptions struct {
+	Range    punchcard.CardRange
+	Overview bool
+	Scale    int    // Pixels per hole in the overview
+	Join     int    // Cards either side of the join shown instead of the range, for loops (0 = the range)
+	Section  string // Section shown of a design split into sections ("" = the first)
+}
+
+// parsePreviewOptions reads the card range and overview options of a preview
+// A preview shows its first page of cards when no range is given, and an overview the whole set
+func parsePreviewOptions(r *http.Request) (previewOptions, *APIError) {
+	opts := previewOptions{Overview: formBool(r, "overview"), Scale: DefaultOverviewScale, Section: r.FormValue("section")}
+
+	rng, err := parseCardRange(r, !opts.Overview)
+	if err != nil {
+		return opts, invalidOption(CodeInvalidRange, "range", "card range", err)
+	}
+	opts.Range = rng
+
+	if value := r.FormValue("overviewScale"); value != "" {
+		scale, err := strconv.Atoi(value)
+		if err != nil || scale < 1 || scale > MaxOverviewScale {
+			return opts, invalidOption(CodeInvalidRange, "overviewScale", "overview scale",
+				fmt.Errorf("invalid overviewScale: %s (must be 1 to %d)", value, MaxOverviewScale))
+		}
+		opts.Scale = scale
+	}
+
+	// An overview always shows the whole design
+	if !opts.Overview {
+		join, err := parseJoinCards(r)
+		if err != nil {
+			return opts, invalidOption(CodeInvalidRange, "joinCards", "card range", err)
+		}
+		opts.Join = join
+	}
+	return opts, nil
+}
+
+// parseCardRange reads the cards to show or download, as from and to card numbers (both included)
+// or as a page of perPage cards; without any, a preview gets its first page and a download every card
+func parseCardRange(r *http.Request, preview bool) (punchcard.CardRange, error) {
+	from, hasFrom, err := parseCardNumber(r, "from")
+	if err != nil {
+		return punchcard.CardRange{}, err
+	}
+	to, hasTo, err := parseCardNumber(r, "to")
+	if err != nil {
+		return punchcard.CardRange{}, err
+	}
+	page, hasPage, err := parseCardNumber(r, "page")
+	if err != nil {
+		return punchcard.CardRange{}, err
+	}
+	perPage, hasPerPage, err := parseCardNumber(r, "perPage")
+	if err != nil {
+		return punchcard.CardRange{}, err
+	}
+	if !hasPerPage {
+		perPage = DefaultPreviewCards
+	}
+
+	switch {
+	case hasPage && (hasFrom || hasTo):
+		return punchcard.CardRange{}, &FieldError{Field: "page", Message: "give either page or from and to, not both"}
+	case hasPage:
+		return punchcard.PageRange(page, perPage), nil
+	case !hasFrom:
+		from = 1
+	}
+
+	rng := punchcard.CardRange{From: from, To: to}
+	if !hasTo && preview {
+		rng.To = from + perPage - 1
+	}
+	if err := rng.Validate(); err != nil {
+		return punchcard.CardRange{}, &FieldError{Field: "to", Message: err.Error()}
+	}
+	return rng, nil
+}
+
+// parseCardNumber reads a 1-based card or page number from the form
+func parseCardNumber(r *http.Request, key string) (int, bool, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false, &FieldError{Field: key, Message: fmt.Sprintf("invalid %s: %s (must be 1 or more)", key, value)}
+	}
+	return n, true, nil
+}
+
+// selectRange returns the cards in a range, failing with INVALID_RANGE when it starts past the last card
+func selectRange(cards []*punchcard.Card, rng punchcard.CardRange) ([]*punchcard.Card, *APIError) {
+	selected, err := rng.Select(cards)
+	if err != nil {
+		field := "range"
+		if errors.Is(err, punchcard.ErrRangeOutOfBounds) {
+			field = "from"
+		}
+		return nil, invalidOption(CodeInvalidRange, field, "card range", err)
+	}
+	return selected, nil
+}
+
+// setRangeHeaders reports which cards of the whole set a result holds
+func setRangeHeaders(res *result, rng punchcard.CardRange, total int) {
+	if res.headers == nil {
+		res.headers = map[string]string{}
+	}
+	first, last := rng.Bounds(total)
+	res.headers["X-Punchcard-Range"] = fmt.Sprintf("%d-%d", first, last)
+	res.headers["X-Punchcard-Total"] = strconv.Itoa(total)
+}
+
+// previewResult renders a range of cards as an inline SVG titled with the size of the whole set,
+// or an overview of the design as a PNG
+// For a loop it shows the cards either side of the join, the last ones followed by the first ones
+// Of a design split into sections, it shows one section
+func previewResult(cards []*punchcard.Card, title string, transform punchcard.Transform, opts previewOptions) (*result, *APIError) {
+	cards, apiErr := previewSection(cards, opts.Section)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	previewCards, apiErr := selectRange(cards, opts.Range)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if opts.Join > 0 {
+		previewCards = punchcard.JoinCards(cards, opts.Join)
+	}
+	if opts.Overview {
+		return overviewResult(cards, previewCards, transform, opts)
+	}
+
+	var output bytes.Buffer
+	exporter := punchcard.NewSVGExporter()
+	exporter.SetTitle(title, len(cards)) // Set title and total card count (not preview count)
+	exporter.Transform = transform
+	if err := exporter.ExportCards(previewCards, &output); err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to generate preview")
+	}
+
+	res := &result{
+		contentType: "image/svg+xml",
+		body:        output.Bytes(),
+		headers:     map[string]string{"X-Punchcard-Transform": transform.String()},
+	}
+	setPreviewHeaders(res, cards, opts)
+	return res, nil
+}
+
+// overviewResult renders the selected cards as one PNG, one row of pixels per card
+// X-Overview-Row-Height and X-Overview-Gutter let a client map a click on the image back to a card
+func overviewResult(cards, selected []*punchcard.Card, transform punchcard.Transform, opts previewOptions) (*result, *APIError) {
+	exporter := punchcard.NewOverviewExporter()
+	exporter.Scale = opts.Scale
+	img, err := exporter.Render(selected)
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, CodeRenderFailed, fmt.Sprintf("Failed to generate overview: %v", err))
+	}
+
+	var output bytes.Buffer
+	if err := png.Encode(&output, img); err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to generate overview")
+	}
+
+	res := &result{
+		contentType: "image/png",
+		body:        output.Bytes(),
+		headers: map[string]string{
+			"X-Punchcard-Transform": transform.String(),
+			"X-Overview-Row-Height": strconv.Itoa(img.Bounds().Dy() / len(selected)),
+			"X-Overview-Gutter":     strconv.Itoa(punchcard.OverviewGutter),
+		},
+	}
+	setPreviewHeaders(res, cards, opts)
+	return res, nil
+}
+
+// setPreviewHeaders reports the cards a preview shows, a range or the cards either side of the join,
+// and their section
+func setPreviewHeaders(res *result, cards []*punchcard.Card, opts previewOptions) {
+	total := len(cards)
+	if total > 0 && cards[0].Section != "" {
+		res.headers["X-Punchcard-Section"] = cards[0].Section
+	}
+	if opts.Join > 0 {
+		setJoinHeaders(res, opts.Join, total)
+		return
+	}
+	setRangeHeaders(res, opts.Range, total)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// selectSection returns the cards of the named section, failing with INVALID_SECTION when there is none
+func selectSection(cards []*punchcard.Card, name string) ([]*punchcard.Card, *APIError) {
+	section, err := punchcard.FindSection(punchcard.SplitSections(cards), name)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidSection, "section", "section", err)
+	}
+	return section.Cards, nil
+}
+
+// previewSection returns the cards of the section a preview shows: the named one, or the first
+// when the cards are split into sections and none is named
+func previewSection(cards []*punchcard.Card, name string) ([]*punchcard.Card, *APIError) {
+	if name != "" {
+		return selectSection(cards, name)
+	}
+	if sections := punchcard.SplitSections(cards); len(sections) > 1 {
+		return sections[0].Cards, nil
+	}
+	return cards, nil
+}
+
+// sectionArchive exports each section of a wide design, or the range of it in opts, in the given
+// format and returns the files as one ZIP archive, e.g. punchcards-A.svg and punchcards-B.svg
+func (h *Handler) sectionArchive(r *http.Request, sections []*punchcard.Section, format string, opts exportOptions) (*result, *APIError) {
+	outputs := make(map[string]*bytes.Buffer, len(sections))
+	var filename string
+	for _, s := range sections {
+		cards := s.Cards
+		sectionOpts := opts
+		if opts.Range.From != 0 {
+			var apiErr *APIError
+			if cards, apiErr = selectRange(cards, opts.Range); apiErr != nil {
+				return nil, apiErr
+			}
+			sectionOpts.Total = len(s.Cards)
+		}
+		output, _, name, err := h.export(r, cards, format, sectionOpts)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to export punchcards")
+		}
+		outputs[s.Name], filename = output, name
+	}
+
+	var archive bytes.Buffer
+	err := punchcard.WriteSectionArchive(&archive, sections, filename, func(s *punchcard.Section, w io.Writer) error {
+		_, err := outputs[s.Name].WriteTo(w)
+		return err
+	})
+	if err != nil {
+		setErrorClass(r, "export")
+		logger(r).Error("failed to archive sections", "sections", len(sections), "error", err)
+		return nil, newAPIError(http.StatusInternalServerError, CodeExportFailed, "Failed to export punchcards")
+	}
+
+	names := make([]string, len(sections))
+	for i, s := range sections {
+		names[i] = s.Name
+	}
+	res := &result{
+		contentType: "application/zip",
+		body:        archive.Bytes(),
+		filename:    strings.TrimSuffix(filename, path.Ext(filename)) + "-sections.zip",
+		headers: map[string]string{
+			"X-Punchcard-Transform": opts.Transform.String(),
+			"X-Punchcard-Sections":  strings.Join(names, ","),
+		},
+	}
+	if opts.Range.From != 0 {
+		setRangeHeaders(res, opts.Range, len(sections[0].Cards))
+	}
+	return res, nil
+}
+
+// SectionInfo describes one of the parallel chains of a design wider than one card
+type SectionInfo struct {
+	Name       string                `json:"name"`
+	Cards      int                   `json:"cards"`
+	Statistics *punchcard.Statistics `json:"statistics,omitempty"`
+	Weave      *WeaveInfo            `json:"weave,omitempty"`
+	Join       *punchcard.JoinReport `json:"join,omitempty"`
+}
+
+// newSectionInfo describes the sections of a card set, each with the statistics of its own chain,
+// and lets describe add more about each one when it is set; it returns nil when the cards are not
+// split into sections
+// Hooks, picks and floats only make sense within a chain, so sectioned cards are never measured whole
+func newSectionInfo(cards []*punchcard.Card, describe func(info *SectionInfo, cards []*punchcard.Card)) []SectionInfo {
+	sections := punchcard.SplitSections(cards)
+	if len(sections) < 2 {
+		return nil
+	}
+	info := make([]SectionInfo, len(sections))
+	for i, s := range sections {
+		info[i] = SectionInfo{Name: s.Name, Cards: len(s.Cards), Statistics: punchcard.GenerateStatistics(s.Cards)}
+		if describe != nil {
+			describe(&info[i], s.Cards)
+		}
+	}
+	return info
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// parseDamask reads the weaves filling the figure and ground of an image, from the figureWeave
+// and groundWeave options; each is a built-in weave name, or a file in the weave text format
+// It returns nil when neither is given, leaving every pixel one lift
+func parseDamask(r *http.Request, files uploads) (*punchcard.Damask, *APIError) {
+	figure, apiErr := parseWeave(r, files, "figureWeave")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	ground, apiErr := parseWeave(r, files, "groundWeave")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if figure == nil && ground == nil {
+		return nil, nil
+	}
+	return &punchcard.Damask{Figure: figure, Ground: ground}, nil
+}
+
+// parseWeave reads one weave option; "none" or nothing leaves its pixels solid
+func parseWeave(r *http.Request, files uploads, field string) (*punchcard.Weave, *APIError) {
+	if file, ok := files[field]; ok {
+		w, err := punchcard.ParseWeave(string(file.Data))
+		if err != nil {
+			return nil, invalidOption(CodeInvalidWeave, field, "weave", err)
+		}
+		if w.Name == "custom" && file.Filename != "" {
+			w.Name = file.Filename
+		}
+		return w, nil
+	}
+
+	name := r.FormValue(field)
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	w, err := punchcard.WeaveByName(name)
+	if err != nil {
+		return nil, invalidOption(CodeInvalidWeave, field, "weave", err)
+	}
+	return w, nil
+}
+
+// damaskKey describes a damask for cache keys, including the repeats of custom weaves
+func damaskKey(d *punchcard.Damask) string {
+	if d == nil {
+		return "none"
+	}
+	return "figure=" + weaveKey(d.Figure) + " ground=" + weaveKey(d.Ground)
+}
+
+// weaveKey describes a weave for cache keys by its repeat, or "nil"
+func weaveKey(w *punchcard.Weave) string {
+	if w == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%q", w.String())
+}
+
+// WeaveInfo describes the weaves of a conversion and the floats they leave in the cloth
+type WeaveInfo struct {
+	Damask      string `json:"damask,omitempty"` // The weaves, e.g. "figure satin-8, ground sateen-8"
+	LongestWarp int    `json:"longestWarpFloat"` // Most picks an end stays up or down
+	LongestWeft int    `json:"longestWeftFloat"` // Most hooks a pick leaves up or down
+}
+
+// newWeaveInfo measures the floats of a card set
+func newWeaveInfo(cards []*punchcard.Card, d *punchcard.Damask) *WeaveInfo {
+	info := &WeaveInfo{}
+	if d != nil {
+		info.Damask = d.String()
+	}
+	info.LongestWarp, info.LongestWeft = punchcard.LongestFloats(cards)
+	return info
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+	"github.com/oscaralmgren/loom-punchcards/internal/workspace"
+)
+
+// usageView is how much of their quota a user has used
+type usageView struct {
+	workspace.Usage
+	Quota auth.Quota `json:"quota"`
+}
+
+// workspaceUsage returns the requesting user's usage and quota
+func (h *Handler) workspaceUsage(r *http.Request, u *auth.User) (*usageView, *APIError) {
+	usage, err := h.Workspaces.Usage(u.ID)
+	if err != nil {
+		return nil, workspaceError(r, err)
+	}
+	return &usageView{Usage: usage, Quota: u.Quota}, nil
+}
+
+// WorkspaceListResponse lists the items of one kind in a workspace
+type WorkspaceListResponse struct {
+	Items []workspace.Item `json:"items"`
+	Usage *usageView       `json:"usage"`
+}
+
+// WorkspaceHandler serves the requesting user's workspace under /api/v1/workspace:
+//
+//	GET, POST    /{kind}       list items, or store a new one
+//	GET, DELETE  /{kind}/{id}  download or delete an item
+//
+// Kinds are images (sent as the image file), cardsets (the textfile file) and presets
+// (the conversion options of the request itself, stored as a JSON object)
+func (h *Handler) WorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	parts := pathParts(r.URL.Path, apiPrefix+"workspace")
+	if h.Workspaces == nil {
+		writeError(w, r, newAPIError(http.StatusNotFound, CodeNotFound, "Workspaces are disabled"))
+		return
+	}
+	if len(parts) == 0 || len(parts) > 2 {
+		h.APINotFoundHandler(w, r)
+		return
+	}
+	kind := parts[0]
+	if err := workspace.ValidateKind(kind); err != nil {
+		writeError(w, r, &APIError{
+			Status:  http.StatusNotFound,
+			Code:    CodeNotFound,
+			Message: fmt.Sprintf("No API endpoint at %s", r.URL.Path),
+			Details: []FieldError{{Field: "kind", Message: err.Error()}},
+		})
+		return
+	}
+	u := h.user(r)
+
+	if len(parts) == 1 {
+		h.serveJSON(w, r, []string{http.MethodGet, http.MethodPost}, func(r *http.Request) (*result, *APIError) {
+			if r.Method == http.MethodPost {
+				return h.storeItem(w, r, u, kind)
+			}
+			items, err := h.Workspaces.List(u.ID, kind)
+			if err != nil {
+				return nil, workspaceError(r, err)
+			}
+			usage, apiErr := h.workspaceUsage(r, u)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			return jsonResult(&WorkspaceListResponse{Items: items, Usage: usage}), nil
+		})
+		return
+	}
+
+	id := parts[1]
+	h.serveJSON(w, r, []string{http.MethodGet, http.MethodDelete}, func(r *http.Request) (*result, *APIError) {
+		if r.Method == http.MethodDelete {
+			if err := h.Workspaces.Delete(u.ID, kind, id); err != nil {
+				return nil, workspaceError(r, err)
+			}
+			logger(r).Info("deleted workspace item", "kind", kind, "id", id)
+			return &result{status: http.StatusNoContent}, nil
+		}
+		item, data, err := h.Workspaces.Get(u.ID, kind, id)
+		if err != nil {
+			return nil, workspaceError(r, err)
+		}
+		return &result{contentType: item.ContentType, body: data, filename: item.Name}, nil
+	})
+}
+
+// storeItem saves the image, card set or preset sent with a request
+// The item is named by the name option, or else by the uploaded file's name
+func (h *Handler) storeItem(w http.ResponseWriter, r *http.Request, u *auth.User, kind string) (*result, *APIError) {
+	files, apiErr := h.parseInput(w, r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	var name, contentType string
+	var data []byte
+	switch kind {
+	case workspace.KindImages:
+		file, apiErr := files.get("image")
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		contentType = http.DetectContentType(file.Data)
+		if !strings.HasPrefix(contentType, "image/") {
+			return nil, &APIError{
+				Status:  http.StatusBadRequest,
+				Code:    CodeImageDecodeFailed,
+				Message: fmt.Sprintf("Not an image: %s", file.Filename),
+				Details: []FieldError{{Field: "image", Message: "detected " + contentType}},
+			}
+		}
+		name, data = file.Filename, file.Data
+	case workspace.KindCardSets:
+		upload, apiErr := parseCardSetUpload(r, files)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		name, data = upload.file.Filename, upload.file.Data
+		contentType = "text/plain; charset=utf-8"
+		if punchcard.IsJSONCardSet(data) {
+			contentType = "application/json"
+		}
+	case workspace.KindPresets:
+		preset := map[string]string{}
+		for key := range r.Form {
+			if !presetExcluded[key] {
+				preset[key] = r.Form.Get(key)
+			}
+		}
+		if len(preset) == 0 {
+			return nil, &APIError{
+				Status:  http.StatusBadRequest,
+				Code:    CodeMissingField,
+				Message: "A preset needs at least one option",
+				Details: []FieldError{{Field: "preset", Message: "no options sent"}},
+			}
+		}
+		data, _ = json.MarshalIndent(preset, "", "  ")
+		contentType = "application/json"
+	}
+	if n := r.FormValue("name"); n != "" {
+		name = n
+	}
+	if name == "" {
+		name = kind
+	}
+
+	item, err := h.Workspaces.Put(u.ID, kind, name, contentType, data, u.Quota)
+	if err != nil {
+		return nil, workspaceError(r, err)
+	}
+	logger(r).Info("stored workspace item", "kind", kind, "id", item.ID, "bytes", item.Size)
+	res := jsonResult(&item)
+	res.status = http.StatusCreated
+	res.headers = map[string]string{"Location": h.BasePath + apiPrefix + "workspace/" + kind + "/" + item.ID}
+	return res, nil
+}
+
+// presetExcluded are the form fields a preset does not keep: its own name, and the
+// fields that pick stored items or cards, which belong to a single request
+var presetExcluded = map[string]bool{
+	"name": true, "preset": true, "imageId": true, "cardSetId": true,
+	"from": true, "to": true, "page": true, "perPage": true,
+}
+
+// applyWorkspace fills a conversion request from the user's workspace:
+// preset=<id> supplies the options the request leaves out, imageId=<id> the image
+// and cardSetId=<id> the card set, unless the request uploads its own
+func (h *Handler) applyWorkspace(r *http.Request, files uploads) *APIError {
+	presetID, imageID, cardSetID := r.FormValue("preset"), r.FormValue("imageId"), r.FormValue("cardSetId")
+	if presetID == "" && imageID == "" && cardSetID == "" {
+		return nil
+	}
+	if h.Workspaces == nil {
+		return newAPIError(http.StatusNotFound, CodeNotFound, "Workspaces are disabled")
+	}
+	u := h.user(r)
+	if u == nil {
+		return newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Sign in or send an API key")
+	}
+
+	if presetID != "" {
+		_, data, err := h.Workspaces.Get(u.ID, workspace.KindPresets, presetID)
+		if err != nil {
+			return workspaceFieldError(r, "preset", err)
+		}
+		var preset map[string]string
+		if err := json.Unmarshal(data, &preset); err != nil {
+			return newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to read preset")
+		}
+		keys := make([]string, 0, len(preset))
+		for key := range preset {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, given := r.Form[key]; !given {
+				r.Form.Set(key, preset[key])
+			}
+		}
+	}
+
+	for field, stored := range map[string]struct{ kind, id string }{
+		"image":    {workspace.KindImages, imageID},
+		"textfile": {workspace.KindCardSets, cardSetID},
+	} {
+		if _, uploaded := files[field]; uploaded || stored.id == "" {
+			continue
+		}
+		item, data, err := h.Workspaces.Get(u.ID, stored.kind, stored.id)
+		if err != nil {
+			idField := "imageId"
+			if field == "textfile" {
+				idField = "cardSetId"
+			}
+			return workspaceFieldError(r, idField, err)
+		}
+		files[field] = &upload{Filename: item.Name, Data: data}
+	}
+	return nil
+}
+
+// workspaceError reports a failed workspace operation
+func workspaceError(r *http.Request, err error) *APIError {
+	switch {
+	case errors.Is(err, workspace.ErrNotFound):
+		return newAPIError(http.StatusNotFound, CodeNotFound, "No such item in your workspace")
+	case errors.Is(err, workspace.ErrQuotaExceeded):
+		setErrorClass(r, "quota_exceeded")
+		return newAPIError(http.StatusForbidden, CodeQuotaExceeded, fmt.Sprintf("Failed to store item: %v", err))
+	}
+	logger(r).Error("workspace failed", "error", err)
+	return newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to access workspace")
+}
+
+// workspaceFieldError reports a stored item a conversion request names that could not be read
+func workspaceFieldError(r *http.Request, field string, err error) *APIError {
+	apiErr := workspaceError(r, err)
+	apiErr.Details = []FieldError{{Field: field, Message: err.Error()}}
+	return apiErr
+}
@@ -0,0 +1,138 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// AlphaMode defines how transparent pixels are converted
+type AlphaMode string
+
+const (
+	AlphaBackground AlphaMode = "background" // Composite onto the background colour
+	AlphaGround     AlphaMode = "ground"     // Transparent pixels are ground (no lift)
+	AlphaStructure  AlphaMode = "structure"  // Fill transparent regions with a ground structure
+)
+
+// ValidateAlphaMode checks if the alpha mode is supported
+func ValidateAlphaMode(mode string) error {
+	switch AlphaMode(mode) {
+	case AlphaBackground, AlphaGround, AlphaStructure:
+		return nil
+	default:
+		return fmt.Errorf("invalid alpha mode: %s (must be 'background', 'ground', or 'structure')", mode)
+	}
+}
+
+// GroundStructure returns the lift (1) or no lift (0) for hook x on pick y
+type GroundStructure func(x, y int) int
+
+// DefaultGroundStructure is the name of the structure used when none is chosen
+const DefaultGroundStructure = "plain"
+
+// GroundStructureByName returns the lifts of a weave from the punchcard weave library as a ground
+// structure, so transparent areas accept the same weaves as damask, selvedges and borders
+func GroundStructureByName(name string) (GroundStructure, error) {
+	w, err := punchcard.WeaveByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ground structure: %w", err)
+	}
+	return w.Lift, nil
+}
+
+// defaultGround is the structure used for transparent areas when none is chosen
+var defaultGround, _ = GroundStructureByName(DefaultGroundStructure)
+
+// ParseHexColor parses a colour written as "#rrggbb" or "#rgb"
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %s (must be #rrggbb or #rgb)", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %s (must be #rrggbb or #rgb)", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// hasTransparency reports whether any pixel of the image is not fully opaque
+func hasTransparency(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alphaMask returns the image's alpha channel as a grayscale image (255 = opaque)
+func alphaMask(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	mask := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			mask.SetGray(x, y, color.Gray{Y: uint8(a >> 8)})
+		}
+	}
+	return mask
+}
+
+// composite draws the image over a solid background so transparent areas take its colour
+func composite(img image.Image, background color.Color) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
+
+// transparentCells converts a resized alpha mask to a matrix with 1 where the pixel is mostly transparent
+func transparentCells(mask *image.Gray) [][]int {
+	bounds := mask.Bounds()
+	cells := make([][]int, bounds.Dy())
+	for y := range cells {
+		cells[y] = make([]int, bounds.Dx())
+		for x := range cells[y] {
+			if mask.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y < 128 {
+				cells[y][x] = 1
+			}
+		}
+	}
+	return cells
+}
+
+// fillTransparent replaces transparent cells with the ground structure, or with no lift when it is nil
+func fillTransparent(matrix, transparent [][]int, ground GroundStructure) {
+	for y := range matrix {
+		if y >= len(transparent) {
+			break
+		}
+		for x := range matrix[y] {
+			if x >= len(transparent[y]) || transparent[y][x] == 0 {
+				continue
+			}
+			if ground != nil {
+				matrix[y][x] = ground(x, y)
+			} else {
+				matrix[y][x] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// createLogoImage creates a transparent image with an opaque black square in the middle
+func createLogoImage(size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := size / 4; y < size*3/4; y++ {
+		for x := size / 4; x < size*3/4; x++ {
+			img.Set(x, y, color.NRGBA{A: 255})
+		}
+	}
+	return img
+}
+
+func processLogo(t *testing.T, p *Processor) [][]int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createLogoImage(16)); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	matrix, err := p.Process(&buf)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	return matrix
+}
+
+func TestProcessAlphaModes(t *testing.T) {
+	t.Run("background", func(t *testing.T) {
+		matrix := processLogo(t, NewProcessor(8, 8, TwoColor))
+		if matrix[0][0] != 0 {
+			t.Error("transparent corner should not be punched on a white background")
+		}
+		if matrix[4][4] != 1 {
+			t.Error("opaque black centre should be punched")
+		}
+	})
+
+	t.Run("dark background", func(t *testing.T) {
+		p := NewProcessor(8, 8, TwoColor)
+		p.Background = color.Black
+		matrix := processLogo(t, p)
+		if matrix[0][0] != 1 {
+			t.Error("transparent corner should be punched on a black background")
+		}
+	})
+
+	t.Run("ground", func(t *testing.T) {
+		p := NewProcessor(8, 8, TwoColor)
+		p.Alpha = AlphaGround
+		p.Background = color.Black
+		matrix := processLogo(t, p)
+		for _, pos := range [][2]int{{0, 0}, {0, 7}, {7, 0}, {7, 7}} {
+			if matrix[pos[0]][pos[1]] != 0 {
+				t.Errorf("transparent cell %v should be ground", pos)
+			}
+		}
+		if matrix[4][4] != 1 {
+			t.Error("opaque black centre should be punched")
+		}
+	})
+
+	t.Run("structure", func(t *testing.T) {
+		p := NewProcessor(8, 8, TwoColor)
+		p.Alpha = AlphaStructure
+		matrix := processLogo(t, p)
+		tabby, _ := GroundStructureByName("tabby")
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 8; x++ {
+				if matrix[y][x] != tabby(x, y) {
+					t.Fatalf("transparent cell (%d, %d) = %d, want tabby %d", y, x, matrix[y][x], tabby(x, y))
+				}
+			}
+		}
+		if matrix[4][4] != 1 {
+			t.Error("opaque black centre should be punched")
+		}
+	})
+}
+
+func TestGroundStructureByName(t *testing.T) {
+	// Ground structures are the weaves of the punchcard library, under the same names
+	for _, name := range append(punchcard.WeaveNames(), "tabby", "twill", "basket") {
+		s, err := GroundStructureByName(name)
+		if err != nil {
+			t.Errorf("GroundStructureByName(%q) error = %v", name, err)
+			continue
+		}
+		w, _ := punchcard.WeaveByName(name)
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				if s(x, y) != w.Lift(x, y) {
+					t.Fatalf("GroundStructureByName(%q)(%d, %d) = %d, want %d", name, x, y, s(x, y), w.Lift(x, y))
+				}
+			}
+		}
+	}
+	for _, name := range []string{"satin-99", "herringbone"} {
+		if _, err := GroundStructureByName(name); err == nil {
+			t.Errorf("GroundStructureByName(%q) should fail", name)
+		}
+	}
+}
+
+func TestValidateAlphaMode(t *testing.T) {
+	for _, mode := range []string{"background", "ground", "structure"} {
+		if err := ValidateAlphaMode(mode); err != nil {
+			t.Errorf("ValidateAlphaMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := ValidateAlphaMode("none"); err == nil {
+		t.Error("ValidateAlphaMode() should reject unknown modes")
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  color.RGBA
+		wantError bool
+	}{
+		{"#ffffff", color.RGBA{255, 255, 255, 255}, false},
+		{"#1a2b3c", color.RGBA{0x1a, 0x2b, 0x3c, 255}, false},
+		{"f00", color.RGBA{255, 0, 0, 255}, false},
+		{"#12345", color.RGBA{}, true},
+		{"#gggggg", color.RGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseHexColor(tt.input)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("ParseHexColor() error = %v, wantError %v", err, tt.wantError)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseHexColor() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
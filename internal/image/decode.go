@@ -0,0 +1,310 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Errors reported for images the processor refuses, so callers can tell them from corrupt files
+var (
+	ErrImageTooLarge = errors.New("image too large")
+	ErrInvalidFrame  = errors.New("invalid frame")
+)
+
+// SourceInfo describes an uploaded image before processing
+type SourceInfo struct {
+	Format string `json:"format"` // Detected format: png, jpeg, gif, bmp, tiff, webp or svg
+	Width  int    `json:"width"`  // Width in pixels
+	Height int    `json:"height"` // Height in pixels
+	Frames int    `json:"frames"` // GIF frames or TIFF pages (1 for other formats)
+}
+
+// Inspect sniffs the image format from the content and reads its size and frame count
+func Inspect(data []byte) (*SourceInfo, error) {
+	if IsSVG(data) {
+		width, height, err := svgSize(data)
+		if err != nil {
+			return nil, err
+		}
+		return &SourceInfo{Format: "svg", Width: int(math.Round(width)), Height: int(math.Round(height)), Frames: 1}, nil
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	info := &SourceInfo{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		Frames: 1,
+	}
+
+	// Frames and pages are counted from the file structure, without decoding any of them
+	switch format {
+	case "gif":
+		ends, err := gifFrameEnds(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		info.Frames = len(ends)
+	case "tiff":
+		offsets, err := tiffPageOffsets(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		info.Frames = len(offsets)
+	}
+
+	return info, nil
+}
+
+// checkPixels rejects images larger than limit pixels before they are decoded or rasterised
+// A limit of 0 or less disables the check
+func checkPixels(width, height, limit int) error {
+	if limit > 0 && int64(width)*int64(height) > int64(limit) {
+		return fmt.Errorf("%w: %dx%d pixels (limit is %d pixels)", ErrImageTooLarge, width, height, limit)
+	}
+	return nil
+}
+
+// decodeFrame decodes an image, selecting a frame of an animated GIF or a page of a multi-page TIFF
+// Frames are numbered from 0; other formats only have frame 0. Images over maxPixels are rejected
+// from their header, so a small compressed file cannot expand into a huge bitmap: the selected TIFF
+// page is checked by its own header, and a GIF frame by all the frames decoded to draw it
+func decodeFrame(data []byte, frame, maxPixels int) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkPixels(config.Width, config.Height, maxPixels); err != nil {
+		return nil, format, err
+	}
+
+	var img image.Image
+	switch {
+	case format == "gif" && frame > 0:
+		img, err = decodeGIFFrame(data, frame, maxPixels)
+	case format == "tiff" && frame > 0:
+		img, err = decodeTIFFPage(data, frame, maxPixels)
+	case frame > 0:
+		return nil, format, fmt.Errorf("%w: %d (%s images have a single frame)", ErrInvalidFrame, frame+1, format)
+	default:
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, format, nil
+}
+
+// decodeGIFFrame renders an animation up to the given frame, honouring each frame's disposal method
+// GIF frames are often partial updates, so a later frame is only meaningful on top of its predecessors
+// Only the frames up to the chosen one are decoded, and together, each the size of the screen at
+// most, they must fit within maxPixels
+func decodeGIFFrame(data []byte, frame, maxPixels int) (image.Image, error) {
+	ends, err := gifFrameEnds(data)
+	if err != nil {
+		return nil, err
+	}
+	if frame >= len(ends) {
+		return nil, fmt.Errorf("%w: %d is out of range (image has %d frames)", ErrInvalidFrame, frame+1, len(ends))
+	}
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height*(frame+1), maxPixels); err != nil {
+		return nil, fmt.Errorf("%w (frames 1 to %d of %dx%d pixels)", err, frame+1, config.Width, config.Height)
+	}
+
+	// Cut the file after the chosen frame, so the frames after it are never decoded
+	truncated := make([]byte, ends[frame], ends[frame]+1)
+	copy(truncated, data)
+	anim, err := gif.DecodeAll(bytes.NewReader(append(truncated, gifTrailer)))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if bounds.Empty() {
+		bounds = anim.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+
+	for i := 0; i <= frame; i++ {
+		var previous *image.RGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, anim.Image[i].Bounds(), anim.Image[i], anim.Image[i].Bounds().Min, draw.Over)
+		if i == frame {
+			break
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, anim.Image[i].Bounds(), image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous.Pix)
+		}
+	}
+
+	return canvas, nil
+}
+
+// decodeTIFFPage decodes a page of a multi-page TIFF, rejecting it from its header when it is over maxPixels
+// The TIFF decoder only reads the first page, so the header is patched to point at the chosen one
+func decodeTIFFPage(data []byte, page, maxPixels int) (image.Image, error) {
+	offsets, err := tiffPageOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	if page >= len(offsets) {
+		return nil, fmt.Errorf("%w: page %d is out of range (image has %d pages)", ErrInvalidFrame, page+1, len(offsets))
+	}
+
+	patched := make([]byte, len(data))
+	copy(patched, data)
+	tiffByteOrder(patched).PutUint32(patched[4:8], offsets[page])
+
+	config, err := tiff.DecodeConfig(bytes.NewReader(patched))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height, maxPixels); err != nil {
+		return nil, fmt.Errorf("%w (page %d)", err, page+1)
+	}
+	return tiff.Decode(bytes.NewReader(patched))
+}
+
+// tiffByteOrder returns the byte order given by a TIFF header
+func tiffByteOrder(data []byte) binary.ByteOrder {
+	if data[0] == 'M' {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// tiffPageOffsets walks the chain of image file directories and returns the offset of each page
+func tiffPageOffsets(data []byte) ([]uint32, error) {
+	if len(data) < 8 || !(bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))) {
+		return nil, fmt.Errorf("invalid TIFF header")
+	}
+	order := tiffByteOrder(data)
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	for offset := order.Uint32(data[4:8]); offset != 0; {
+		if seen[offset] {
+			return nil, fmt.Errorf("invalid TIFF: directory loop at offset %d", offset)
+		}
+		seen[offset] = true
+
+		if int64(offset)+2 > int64(len(data)) {
+			return nil, fmt.Errorf("invalid TIFF: directory offset %d beyond end of file", offset)
+		}
+		entries := int64(order.Uint16(data[offset:]))
+		next := int64(offset) + 2 + entries*12
+		if next+4 > int64(len(data)) {
+			return nil, fmt.Errorf("invalid TIFF: directory at offset %d is truncated", offset)
+		}
+
+		offsets = append(offsets, offset)
+		offset = order.Uint32(data[next:])
+	}
+
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("invalid TIFF: no pages")
+	}
+	return offsets, nil
+}
+
+// GIF block introducers
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2c
+	gifTrailer    = 0x3b
+	gifColorTable = 0x80 // Flag of a screen or image descriptor followed by a colour table
+)
+
+// gifFrameEnds walks the blocks of a GIF and returns the offset just past each frame's image data
+// Nothing is decompressed, so frames can be counted, and the file cut after one, at little cost
+func gifFrameEnds(data []byte) ([]int, error) {
+	if len(data) < 13 || !(bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return nil, fmt.Errorf("invalid GIF header")
+	}
+	pos := 13
+	if flags := data[10]; flags&gifColorTable != 0 {
+		pos += 3 << (flags&7 + 1)
+	}
+
+	// skipSubBlocks moves past a chain of data sub-blocks and its terminator
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return fmt.Errorf("invalid GIF: truncated data at offset %d", pos)
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	var ends []int
+	for pos < len(data) {
+		switch data[pos] {
+		case gifExtension:
+			pos += 2 // Introducer and label
+			if err := skipSubBlocks(); err != nil {
+				return nil, err
+			}
+		case gifImage:
+			if pos+10 > len(data) {
+				return nil, fmt.Errorf("invalid GIF: truncated image descriptor at offset %d", pos)
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&gifColorTable != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			pos++ // LZW minimum code size
+			if err := skipSubBlocks(); err != nil {
+				return nil, err
+			}
+			ends = append(ends, pos)
+		case gifTrailer:
+			pos = len(data)
+		default:
+			return nil, fmt.Errorf("invalid GIF: unknown block 0x%02x at offset %d", data[pos], pos)
+		}
+	}
+
+	if len(ends) == 0 {
+		return nil, fmt.Errorf("invalid GIF: no frames")
+	}
+	return ends, nil
+}
@@ -0,0 +1,326 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// createBilevelTIFF builds an uncompressed 1-bit TIFF with one page per image
+// Each page is filled white (1) or black (0) as given
+func createBilevelTIFF(width, height int, pages []bool) []byte {
+	var buf bytes.Buffer
+	order := binary.LittleEndian
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, order, uint32(0)) // First IFD offset, patched below
+
+	rowBytes := (width + 7) / 8
+	prevNext := 4
+	for _, white := range pages {
+		// Pixel data
+		stripOffset := buf.Len()
+		fill := byte(0x00)
+		if white {
+			fill = 0xff
+		}
+		buf.Write(bytes.Repeat([]byte{fill}, rowBytes*height))
+
+		// Image file directory
+		if buf.Len()%2 == 1 {
+			buf.WriteByte(0)
+		}
+		ifdOffset := buf.Len()
+		b := buf.Bytes()
+		order.PutUint32(b[prevNext:], uint32(ifdOffset))
+
+		entries := [][3]uint32{
+			{256, 4, uint32(width)},
+			{257, 4, uint32(height)},
+			{258, 3, 1}, // BitsPerSample
+			{259, 3, 1}, // No compression
+			{262, 3, 1}, // BlackIsZero
+			{273, 4, uint32(stripOffset)},
+			{278, 4, uint32(height)},
+			{279, 4, uint32(rowBytes * height)},
+		}
+		binary.Write(&buf, order, uint16(len(entries)))
+		for _, e := range entries {
+			binary.Write(&buf, order, uint16(e[0]))
+			binary.Write(&buf, order, uint16(e[1]))
+			binary.Write(&buf, order, uint32(1))
+			if e[1] == 3 {
+				binary.Write(&buf, order, uint16(e[2]))
+				binary.Write(&buf, order, uint16(0))
+			} else {
+				binary.Write(&buf, order, e[2])
+			}
+		}
+		prevNext = buf.Len()
+		binary.Write(&buf, order, uint32(0))
+	}
+
+	return buf.Bytes()
+}
+
+// createAnimatedGIF builds a GIF whose frames are solid black or white
+func createAnimatedGIF(width, height int, frames []bool) []byte {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for _, white := range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		if white {
+			for i := range frame.Pix {
+				frame.Pix[i] = 1
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, anim)
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	var pngBuf, bmpBuf bytes.Buffer
+	png.Encode(&pngBuf, createCheckerboardImage(8, 4, 2))
+	bmp.Encode(&bmpBuf, createCheckerboardImage(8, 4, 2))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		frames int
+	}{
+		{"png", pngBuf.Bytes(), "png", 1},
+		{"bmp", bmpBuf.Bytes(), "bmp", 1},
+		{"gif", createAnimatedGIF(8, 4, []bool{true, false, true}), "gif", 3},
+		{"tiff", createBilevelTIFF(8, 4, []bool{true, false}), "tiff", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Inspect(tt.data)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if info.Format != tt.format || info.Frames != tt.frames {
+				t.Errorf("Inspect() = %s with %d frames, want %s with %d", info.Format, info.Frames, tt.format, tt.frames)
+			}
+			if info.Width != 8 || info.Height != 4 {
+				t.Errorf("Inspect() size = %dx%d, want 8x4", info.Width, info.Height)
+			}
+		})
+	}
+
+	if _, err := Inspect([]byte("not an image")); err == nil {
+		t.Error("Inspect() should reject unknown content")
+	}
+}
+
+func TestProcessFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		frame    int
+		expected int // Expected value of every cell
+	}{
+		{"gif first frame", createAnimatedGIF(8, 8, []bool{true, false}), 0, 0},
+		{"gif second frame", createAnimatedGIF(8, 8, []bool{true, false}), 1, 1},
+		{"tiff first page", createBilevelTIFF(8, 8, []bool{false, true, false}), 0, 1},
+		{"tiff second page", createBilevelTIFF(8, 8, []bool{false, true, false}), 1, 0},
+		{"tiff third page", createBilevelTIFF(8, 8, []bool{false, true, false}), 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(8, 8, TwoColor)
+			p.Frame = tt.frame
+			matrix, err := p.Process(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			for y := range matrix {
+				for x := range matrix[y] {
+					if matrix[y][x] != tt.expected {
+						t.Fatalf("matrix[%d][%d] = %d, want %d", y, x, matrix[y][x], tt.expected)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessFrameOutOfRange(t *testing.T) {
+	var pngBuf bytes.Buffer
+	png.Encode(&pngBuf, createCheckerboardImage(8, 8, 2))
+
+	inputs := map[string][]byte{
+		"gif":  createAnimatedGIF(8, 8, []bool{true}),
+		"tiff": createBilevelTIFF(8, 8, []bool{true}),
+		"png":  pngBuf.Bytes(),
+	}
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			p := NewProcessor(8, 8, TwoColor)
+			p.Frame = 1
+			if _, err := p.Process(bytes.NewReader(data)); err == nil {
+				t.Error("Process() should reject a frame beyond the last one")
+			}
+		})
+	}
+}
+
+func TestProcessFrameMaxPixels(t *testing.T) {
+	// A later TIFF page is checked by its own header, not the first page's
+	tiffData := createBilevelTIFF(8, 8, []bool{true, false})
+	offsets, err := tiffPageOffsets(tiffData)
+	if err != nil {
+		t.Fatalf("tiffPageOffsets() error = %v", err)
+	}
+	binary.LittleEndian.PutUint32(tiffData[offsets[1]+2+8:], 4000) // Width of page 2
+
+	tests := []struct {
+		name      string
+		data      []byte
+		frame     int
+		maxPixels int
+		wantErr   bool
+	}{
+		{"tiff first page within limit", tiffData, 0, 64, false},
+		{"tiff later page over limit", tiffData, 1, 64, true},
+		{"gif frame within limit", createAnimatedGIF(8, 8, []bool{true, false, true}), 1, 2 * 64, false},
+		{"gif frames to draw over limit", createAnimatedGIF(8, 8, []bool{true, false, true}), 2, 2 * 64, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(8, 8, TwoColor)
+			p.Frame = tt.frame
+			p.MaxPixels = tt.maxPixels
+			_, err := p.Process(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("Process() error = %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestGIFFrameEnds(t *testing.T) {
+	data := createAnimatedGIF(8, 4, []bool{true, false, true})
+	ends, err := gifFrameEnds(data)
+	if err != nil {
+		t.Fatalf("gifFrameEnds() error = %v", err)
+	}
+	if len(ends) != 3 {
+		t.Fatalf("gifFrameEnds() = %d frames, want 3", len(ends))
+	}
+
+	// Cutting the file after a frame leaves a valid GIF of the frames before it
+	cut := append(append([]byte(nil), data[:ends[1]]...), gifTrailer)
+	anim, err := gif.DecodeAll(bytes.NewReader(cut))
+	if err != nil {
+		t.Fatalf("DecodeAll() of the cut file error = %v", err)
+	}
+	if len(anim.Image) != 2 {
+		t.Errorf("cut file has %d frames, want 2", len(anim.Image))
+	}
+
+	if _, err := gifFrameEnds(data[:ends[0]-3]); err == nil {
+		t.Error("gifFrameEnds() should reject a truncated file")
+	}
+}
+
+func TestProcessBMP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, createCheckerboardImage(16, 16, 2)); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	matrix, err := NewProcessor(8, 8, TwoColor).Process(&buf)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(matrix) != 8 || len(matrix[0]) != 8 {
+		t.Errorf("Matrix size = %dx%d, want 8x8", len(matrix[0]), len(matrix))
+	}
+}
+
+func TestProcessMaxPixels(t *testing.T) {
+	var pngBuf bytes.Buffer
+	png.Encode(&pngBuf, createCheckerboardImage(64, 64, 2))
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="1000"><rect width="10" height="1000"/></svg>`)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		maxPixels int
+		wantErr   bool
+	}{
+		{"png within limit", pngBuf.Bytes(), 64 * 64, false},
+		{"png over limit", pngBuf.Bytes(), 64*64 - 1, true},
+		{"no limit", pngBuf.Bytes(), 0, false},
+		{"svg raster over limit", svg, 1000, true},
+		{"svg raster within limit", svg, 8 * 800, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(8, 0, TwoColor)
+			p.MaxPixels = tt.maxPixels
+			_, err := p.Process(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("Process() error = %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestProcessMaxCells(t *testing.T) {
+	// A narrow, tall image resamples to far more picks than it has pixels
+	var pngBuf bytes.Buffer
+	png.Encode(&pngBuf, createCheckerboardImage(2, 200, 1))
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="1000"><rect width="10" height="1000"/></svg>`)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		maxCells int
+		sett     Sett
+		wantErr  bool
+	}{
+		{"within limit", pngBuf.Bytes(), 8 * 800, Sett{}, false},
+		{"over limit", pngBuf.Bytes(), 8*800 - 1, Sett{}, true},
+		{"picks per row over limit", pngBuf.Bytes(), 8 * 800, Sett{PicksPerRow: 2, EndsPerCm: 8, PicksPerCm: 16}, true},
+		{"svg over limit", svg, 8*800 - 1, Sett{}, true},
+		{"no limit", pngBuf.Bytes(), 0, Sett{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(8, 0, TwoColor)
+			p.MaxCells = tt.maxCells
+			p.Sett = tt.sett
+			_, err := p.Process(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("Process() error = %v, want ErrImageTooLarge", err)
+			}
+		})
+	}
+}
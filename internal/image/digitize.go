@@ -0,0 +1,648 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+// Anchor selects the physical feature used to correct the perspective of a scanned card
+type Anchor string
+
+const (
+	// AnchorOutline uses the four corners of the card outline
+	AnchorOutline Anchor = "outline"
+
+	// AnchorPegHoles refines the outline using the four peg holes near the card corners
+	AnchorPegHoles Anchor = "pegs"
+)
+
+// ValidateAnchor checks if the anchor is valid
+func ValidateAnchor(anchor string) error {
+	if anchor != string(AnchorOutline) && anchor != string(AnchorPegHoles) {
+		return fmt.Errorf("invalid anchor: %s (must be 'outline' or 'pegs')", anchor)
+	}
+	return nil
+}
+
+// CardLayout describes where the hole grid sits on a physical card
+// All measurements are in millimeters from the top-left corner of the card
+type CardLayout struct {
+	Columns    int     // Number of hole columns
+	Rows       int     // Number of hole rows
+	Width      float64 // Card width
+	Height     float64 // Card height
+	OriginX    float64 // Center of the first hole (column 0)
+	OriginY    float64 // Center of the first hole (row 0)
+	Spacing    float64 // Distance between hole centers
+	HoleRadius float64 // Radius of a punched hole
+	PegInset   float64 // Distance of the peg hole centers from each card edge (0 = no peg holes)
+	PegRadius  float64 // Radius of a peg hole
+}
+
+// LayoutForCard returns the layout of a card printed by the SVG exporter at 100% scale
+// The exporter draws no peg holes, so the layout has none; cards cut to another pattern, or
+// punched with peg holes, need their own measurements
+func LayoutForCard(dims punchcard.CardDimensions) CardLayout {
+	return CardLayout{
+		Columns:    dims.Width,
+		Rows:       dims.Height,
+		Width:      float64(dims.Width)*punchcard.HoleSpacing + 2*punchcard.CardPadding,
+		Height:     float64(dims.Height)*punchcard.HoleSpacing + 2*punchcard.CardPadding + punchcard.TextHeight*2,
+		OriginX:    punchcard.CardPadding,
+		OriginY:    punchcard.CardPadding + punchcard.TextHeight,
+		Spacing:    punchcard.HoleSpacing,
+		HoleRadius: punchcard.HoleRadius,
+		PegRadius:  punchcard.HoleRadius,
+	}
+}
+
+// Validate checks that the hole grid, and the peg holes when there are any, fit on the card
+func (l CardLayout) Validate() error {
+	// NaN and infinity would pass the comparisons below
+	for _, v := range []float64{l.Width, l.Height, l.OriginX, l.OriginY, l.Spacing, l.HoleRadius, l.PegInset, l.PegRadius} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("invalid card layout: %g mm is not a measurement", v)
+		}
+	}
+	if l.Columns <= 0 || l.Rows <= 0 {
+		return fmt.Errorf("invalid card layout: %dx%d holes", l.Columns, l.Rows)
+	}
+	if l.HoleRadius <= 0 || 2*l.HoleRadius >= l.Spacing {
+		return fmt.Errorf("invalid card layout: holes of radius %.1f mm do not fit %.1f mm apart", l.HoleRadius, l.Spacing)
+	}
+	right := l.OriginX + float64(l.Columns-1)*l.Spacing + l.HoleRadius
+	bottom := l.OriginY + float64(l.Rows-1)*l.Spacing + l.HoleRadius
+	if l.OriginX < l.HoleRadius || l.OriginY < l.HoleRadius || right > l.Width || bottom > l.Height {
+		return fmt.Errorf("invalid card layout: %dx%d holes %.1f mm apart from (%.1f, %.1f) mm do not fit on a %.1f x %.1f mm card",
+			l.Columns, l.Rows, l.Spacing, l.OriginX, l.OriginY, l.Width, l.Height)
+	}
+	if l.PegInset < 0 || (l.PegInset > 0 && (l.PegRadius <= 0 || l.PegRadius >= l.PegInset ||
+		2*l.PegInset >= math.Min(l.Width, l.Height))) {
+		return fmt.Errorf("invalid card layout: peg holes of radius %.1f mm do not fit %.1f mm from the card edges", l.PegRadius, l.PegInset)
+	}
+	return nil
+}
+
+// Digitizer reads the hole pattern of a physical card from a flatbed scan or photo
+type Digitizer struct {
+	Layout CardLayout
+	Anchor Anchor
+
+	// HolesLight should be set when punched holes appear lighter than the card,
+	// e.g. when the card is photographed against a light box
+	HolesLight bool
+
+	// UncertainBelow is the confidence below which a hole is reported for manual checking
+	UncertainBelow float64
+
+	// MaxPixels is the largest scan accepted, in pixels (0 = no limit)
+	MaxPixels int
+}
+
+// NewDigitizer creates a digitizer for the given card type using the outline anchor
+func NewDigitizer(cardType punchcard.CardType) *Digitizer {
+	return &Digitizer{
+		Layout:         LayoutForCard(punchcard.GetCardDimensions(cardType)),
+		Anchor:         AnchorOutline,
+		UncertainBelow: 0.5,
+	}
+}
+
+// Point is a position in image pixel coordinates
+type Point struct {
+	X, Y float64
+}
+
+// DigitizeResult holds a digitized card together with per-hole confidence values
+type DigitizeResult struct {
+	Card       *punchcard.Card
+	Confidence [][]float64 // 0 = could go either way, 1 = certain; same shape as Card.Matrix
+	Threshold  float64     // Intensity separating punched from unpunched positions
+	Corners    [4]Point    // Detected card corners: top-left, top-right, bottom-right, bottom-left
+}
+
+// ApplyTransform reorients the digitized card and its confidence values together
+func (r *DigitizeResult) ApplyTransform(t punchcard.Transform) {
+	card := r.Card
+	confidence := make([][]float64, card.Height)
+	for y := range confidence {
+		confidence[y] = make([]float64, card.Width)
+	}
+	for y := 0; y < card.Height; y++ {
+		for x := 0; x < card.Width; x++ {
+			ty, tx := t.MapPosition(y, x, card.Height, card.Width)
+			confidence[ty][tx] = r.Confidence[y][x]
+		}
+	}
+	r.Card = t.Apply([]*punchcard.Card{card})[0]
+	r.Confidence = confidence
+}
+
+// UncertainHole identifies a hole whose classification should be checked by hand
+type UncertainHole struct {
+	Row        int
+	Column     int
+	Punched    bool
+	Confidence float64
+}
+
+// Uncertain returns all holes with a confidence below the given limit
+func (r *DigitizeResult) Uncertain(limit float64) []UncertainHole {
+	var holes []UncertainHole
+	for y, row := range r.Confidence {
+		for x, c := range row {
+			if c < limit {
+				holes = append(holes, UncertainHole{
+					Row:        y + 1,
+					Column:     x + 1,
+					Punched:    r.Card.Matrix[y][x] == 1,
+					Confidence: c,
+				})
+			}
+		}
+	}
+	return holes
+}
+
+// WriteConfidence writes the per-hole confidence values as a text block
+// Each hole is shown as a digit from 0 (uncertain) to 9 (certain), aligned with the card rows
+func (r *DigitizeResult) WriteConfidence(w io.Writer, limit float64) error {
+	fmt.Fprintf(w, "\nConfidence (0 = uncertain, 9 = certain):\n")
+	for _, row := range r.Confidence {
+		for _, c := range row {
+			fmt.Fprintf(w, "%d", int(math.Min(c*10, 9)))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	uncertain := r.Uncertain(limit)
+	fmt.Fprintf(w, "\nUncertain holes (confidence < %.2f): %d\n", limit, len(uncertain))
+	for _, h := range uncertain {
+		state := "not punched"
+		if h.Punched {
+			state = "punched"
+		}
+		_, err := fmt.Fprintf(w, "  row %d, column %d: read as %s (%.2f)\n", h.Row, h.Column, state, h.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Digitize decodes a scan or photo of a single card and reads its hole pattern
+func (d *Digitizer) Digitize(r io.Reader) (*DigitizeResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	img, _, err := decodeFrame(data, 0, d.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+	return d.DigitizeImage(img)
+}
+
+// DigitizeImage reads the hole pattern from an already decoded image
+func (d *Digitizer) DigitizeImage(img image.Image) (*DigitizeResult, error) {
+	if err := d.Layout.Validate(); err != nil {
+		return nil, err
+	}
+	if d.Anchor == AnchorPegHoles && d.Layout.PegInset == 0 {
+		return nil, fmt.Errorf("the card layout has no peg holes to anchor to (give their inset, or anchor to the outline)")
+	}
+
+	plane := newIntensityPlane(img)
+	if plane.width < 16 || plane.height < 16 {
+		return nil, fmt.Errorf("image too small to digitize: %dx%d", plane.width, plane.height)
+	}
+
+	// Separate the card from the scanner background
+	background := plane.borderMedian()
+	threshold := plane.otsuThreshold()
+	mask := plane.foregroundMask(threshold, background < threshold)
+
+	corners, err := findCardCorners(mask, plane.width, plane.height)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := d.Layout
+	cardCorners := [4]Point{{0, 0}, {layout.Width, 0}, {layout.Width, layout.Height}, {0, layout.Height}}
+	h, err := solveHomography(cardCorners, corners)
+	if err != nil {
+		return nil, fmt.Errorf("failed to correct perspective: %w", err)
+	}
+
+	if d.Anchor == AnchorPegHoles {
+		h, corners, err = d.refineWithPegHoles(plane, h, background < threshold, threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Sample the mean intensity inside every hole position
+	samples := make([][]float64, layout.Rows)
+	values := make([]float64, 0, layout.Rows*layout.Columns)
+	for y := 0; y < layout.Rows; y++ {
+		samples[y] = make([]float64, layout.Columns)
+		for x := 0; x < layout.Columns; x++ {
+			cx := layout.OriginX + float64(x)*layout.Spacing
+			cy := layout.OriginY + float64(y)*layout.Spacing
+			samples[y][x] = plane.sampleDisk(h, cx, cy, layout.HoleRadius*0.6)
+			values = append(values, samples[y][x])
+		}
+	}
+
+	// Bare card is sampled midway between diagonally adjacent holes, clear of any grid lines
+	var paper float64
+	gaps := 0
+	for i := 0; i < layout.Rows-1 && i < layout.Columns-1; i++ {
+		cx := layout.OriginX + (float64(i)+0.5)*layout.Spacing
+		cy := layout.OriginY + (float64(i)+0.5)*layout.Spacing
+		paper += plane.sampleDisk(h, cx, cy, layout.Spacing*0.1)
+		gaps++
+	}
+	if gaps > 0 {
+		paper /= float64(gaps)
+	}
+	holeThreshold, separation := classifyLevels(values, paper, d.HolesLight)
+
+	result := &DigitizeResult{
+		Card: &punchcard.Card{
+			Number: 1,
+			Width:  layout.Columns,
+			Height: layout.Rows,
+			Matrix: make([][]int, layout.Rows),
+		},
+		Confidence: make([][]float64, layout.Rows),
+		Threshold:  holeThreshold,
+		Corners:    corners,
+	}
+
+	for y := 0; y < layout.Rows; y++ {
+		result.Card.Matrix[y] = make([]int, layout.Columns)
+		result.Confidence[y] = make([]float64, layout.Columns)
+		for x := 0; x < layout.Columns; x++ {
+			v := samples[y][x]
+			punched := v < holeThreshold
+			if d.HolesLight {
+				punched = v > holeThreshold
+			}
+			if punched {
+				result.Card.Matrix[y][x] = 1
+			}
+			result.Confidence[y][x] = math.Min(math.Abs(v-holeThreshold)/(separation/2), 1)
+		}
+	}
+
+	return result, nil
+}
+
+// refineWithPegHoles locates the four peg holes near the card corners and
+// recomputes the card-to-image mapping from their centers
+// Each must show as one patch of background about the size of a peg hole, clear of the card
+// edge, so that a card without peg holes fails instead of anchoring to stray marks or the edge
+func (d *Digitizer) refineWithPegHoles(plane *intensityPlane, h homography, darkBackground bool, threshold float64) (homography, [4]Point, error) {
+	layout := d.Layout
+	inset := layout.PegInset
+	pegs := [4]Point{
+		{inset, inset},
+		{layout.Width - inset, inset},
+		{layout.Width - inset, layout.Height - inset},
+		{inset, layout.Height - inset},
+	}
+
+	var found [4]Point
+	window := math.Min(inset*0.9, layout.PegRadius*3)
+	step := 0.25 // mm
+	expected := math.Pi * layout.PegRadius * layout.PegRadius / (step * step)
+	for i, peg := range pegs {
+		var sumX, sumY, n float64
+		edge := false
+		for my := peg.Y - window; my <= peg.Y+window; my += step {
+			for mx := peg.X - window; mx <= peg.X+window; mx += step {
+				px, py := h.apply(mx, my)
+				v := plane.at(px, py)
+				// Peg holes show the scanner background through the card
+				if (v < threshold) == darkBackground {
+					sumX += px
+					sumY += py
+					n++
+					edge = edge || math.Max(math.Abs(mx-peg.X), math.Abs(my-peg.Y)) > window-step
+				}
+			}
+		}
+		if n < expected/2 || n > expected*2 || edge {
+			return h, found, fmt.Errorf("peg hole %d not found %.1f mm in from the card corner", i+1, inset)
+		}
+		found[i] = Point{sumX / n, sumY / n}
+	}
+
+	refined, err := solveHomography(pegs, found)
+	if err != nil {
+		return h, found, fmt.Errorf("failed to correct perspective from peg holes: %w", err)
+	}
+
+	var corners [4]Point
+	cardCorners := [4]Point{{0, 0}, {layout.Width, 0}, {layout.Width, layout.Height}, {0, layout.Height}}
+	for i, c := range cardCorners {
+		corners[i].X, corners[i].Y = refined.apply(c.X, c.Y)
+	}
+	return refined, corners, nil
+}
+
+// classifyLevels splits the hole samples into punched and unpunched groups
+// using two-means clustering and returns the threshold and the cluster separation
+func classifyLevels(values []float64, paper float64, holesLight bool) (threshold, separation float64) {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	// A card with (almost) uniform holes has no second cluster; compare against the paper instead
+	const minContrast = 0.15
+	if hi-lo < minContrast {
+		if holesLight {
+			return paper + minContrast, minContrast * 2
+		}
+		return paper - minContrast, minContrast * 2
+	}
+
+	c0, c1 := lo, hi
+	for iter := 0; iter < 20; iter++ {
+		t := (c0 + c1) / 2
+		var s0, s1, n0, n1 float64
+		for _, v := range values {
+			if v < t {
+				s0 += v
+				n0++
+			} else {
+				s1 += v
+				n1++
+			}
+		}
+		if n0 == 0 || n1 == 0 {
+			break
+		}
+		c0, c1 = s0/n0, s1/n1
+	}
+
+	return (c0 + c1) / 2, c1 - c0
+}
+
+// intensityPlane holds image intensities (0 = black, 1 = white) for fast sampling
+type intensityPlane struct {
+	width, height int
+	pix           []float64
+}
+
+func newIntensityPlane(img image.Image) *intensityPlane {
+	bounds := img.Bounds()
+	p := &intensityPlane{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		pix:    make([]float64, bounds.Dx()*bounds.Dy()),
+	}
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			p.pix[y*p.width+x] = GetPixelIntensity(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return p
+}
+
+// at returns the bilinearly interpolated intensity at a pixel position
+// Positions outside the image return the nearest edge value
+func (p *intensityPlane) at(x, y float64) float64 {
+	x = math.Max(0, math.Min(x, float64(p.width-1)))
+	y = math.Max(0, math.Min(y, float64(p.height-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := x0+1, y0+1
+	if x1 >= p.width {
+		x1 = x0
+	}
+	if y1 >= p.height {
+		y1 = y0
+	}
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := p.pix[y0*p.width+x0]*(1-fx) + p.pix[y0*p.width+x1]*fx
+	bottom := p.pix[y1*p.width+x0]*(1-fx) + p.pix[y1*p.width+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// sampleDisk averages the intensity over a disk given in card coordinates (mm)
+func (p *intensityPlane) sampleDisk(h homography, cx, cy, radius float64) float64 {
+	const steps = 5
+	var sum float64
+	var n int
+	for j := -steps; j <= steps; j++ {
+		for i := -steps; i <= steps; i++ {
+			dx := float64(i) / steps * radius
+			dy := float64(j) / steps * radius
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			px, py := h.apply(cx+dx, cy+dy)
+			sum += p.at(px, py)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// borderMedian returns the median intensity of the outermost pixels,
+// which are assumed to show the scanner background
+func (p *intensityPlane) borderMedian() float64 {
+	var border []float64
+	for x := 0; x < p.width; x++ {
+		border = append(border, p.pix[x], p.pix[(p.height-1)*p.width+x])
+	}
+	for y := 0; y < p.height; y++ {
+		border = append(border, p.pix[y*p.width], p.pix[y*p.width+p.width-1])
+	}
+	sort.Float64s(border)
+	return border[len(border)/2]
+}
+
+// otsuThreshold finds the intensity that best separates the two dominant tones
+func (p *intensityPlane) otsuThreshold() float64 {
+	var hist [256]int
+	for _, v := range p.pix {
+		hist[int(v*255+0.5)]++
+	}
+
+	total := float64(len(p.pix))
+	var sumAll float64
+	for i, c := range hist {
+		sumAll += float64(i * c)
+	}
+
+	var sumB, wB, best float64
+	threshold := 128
+	for i, c := range hist {
+		wB += float64(c)
+		if wB == 0 {
+			continue
+		}
+		wF := total - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(i * c)
+		mB := sumB / wB
+		mF := (sumAll - sumB) / wF
+		between := wB * wF * (mB - mF) * (mB - mF)
+		if between > best {
+			best = between
+			threshold = i
+		}
+	}
+	return (float64(threshold) + 0.5) / 255
+}
+
+// foregroundMask marks the pixels that do not belong to the scanner background
+func (p *intensityPlane) foregroundMask(threshold float64, darkBackground bool) []bool {
+	mask := make([]bool, len(p.pix))
+	for i, v := range p.pix {
+		mask[i] = (v >= threshold) == darkBackground
+	}
+	return mask
+}
+
+// findCardCorners locates the largest foreground region and returns its four
+// extreme corners in the order top-left, top-right, bottom-right, bottom-left
+func findCardCorners(mask []bool, width, height int) ([4]Point, error) {
+	var corners [4]Point
+
+	// Label the largest 4-connected foreground component so specks of dust are ignored
+	label := make([]int32, len(mask))
+	var bestLabel int32
+	bestSize := 0
+	var next int32
+	queue := make([]int, 0, 1024)
+	for start, fg := range mask {
+		if !fg || label[start] != 0 {
+			continue
+		}
+		next++
+		label[start] = next
+		queue = append(queue[:0], start)
+		size := 0
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			size++
+			x, y := i%width, i/width
+			neighbours := [4]int{-1, -1, -1, -1}
+			if x > 0 {
+				neighbours[0] = i - 1
+			}
+			if x < width-1 {
+				neighbours[1] = i + 1
+			}
+			if y > 0 {
+				neighbours[2] = i - width
+			}
+			if y < height-1 {
+				neighbours[3] = i + width
+			}
+			for _, n := range neighbours {
+				if n >= 0 && mask[n] && label[n] == 0 {
+					label[n] = next
+					queue = append(queue, n)
+				}
+			}
+		}
+		if size > bestSize {
+			bestSize = size
+			bestLabel = next
+		}
+	}
+
+	if bestSize < len(mask)/20 {
+		return corners, fmt.Errorf("card outline not found: scan the card on a contrasting background")
+	}
+
+	// Extreme points along the diagonals give the corners of a convex quadrilateral
+	first := true
+	var minSum, maxSum, minDiff, maxDiff float64
+	for i, l := range label {
+		if l != bestLabel {
+			continue
+		}
+		x, y := float64(i%width)+0.5, float64(i/width)+0.5
+		sum, diff := x+y, x-y
+		if first || sum < minSum {
+			minSum, corners[0] = sum, Point{x, y}
+		}
+		if first || diff > maxDiff {
+			maxDiff, corners[1] = diff, Point{x, y}
+		}
+		if first || sum > maxSum {
+			maxSum, corners[2] = sum, Point{x, y}
+		}
+		if first || diff < minDiff {
+			minDiff, corners[3] = diff, Point{x, y}
+		}
+		first = false
+	}
+
+	return corners, nil
+}
+
+// homography is a 3x3 projective transform stored row-major with h[8] = 1
+type homography [9]float64
+
+// apply maps a point through the transform
+func (h homography) apply(x, y float64) (float64, float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+// solveHomography computes the projective transform mapping each src point onto its dst point
+func solveHomography(src, dst [4]Point) (homography, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := src[i].X, src[i].Y
+		u, v := dst[i].X, dst[i].Y
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return homography{}, fmt.Errorf("degenerate corner points")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var h homography
+	for i := 0; i < 8; i++ {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
@@ -0,0 +1,266 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/punchcard"
+)
+
+func TestValidateAnchor(t *testing.T) {
+	tests := []struct {
+		anchor    string
+		wantError bool
+	}{
+		{"outline", false},
+		{"pegs", false},
+		{"corners", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.anchor, func(t *testing.T) {
+			err := ValidateAnchor(tt.anchor)
+			if (err != nil) != tt.wantError {
+				t.Errorf("ValidateAnchor(%q) error = %v, wantError %v", tt.anchor, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSolveHomography(t *testing.T) {
+	src := [4]Point{{0, 0}, {100, 0}, {100, 50}, {0, 50}}
+	dst := [4]Point{{10, 20}, {210, 30}, {205, 130}, {15, 118}}
+
+	h, err := solveHomography(src, dst)
+	if err != nil {
+		t.Fatalf("solveHomography() error = %v", err)
+	}
+
+	for i := range src {
+		x, y := h.apply(src[i].X, src[i].Y)
+		if math.Abs(x-dst[i].X) > 1e-6 || math.Abs(y-dst[i].Y) > 1e-6 {
+			t.Errorf("corner %d mapped to (%.3f, %.3f), want (%.3f, %.3f)", i, x, y, dst[i].X, dst[i].Y)
+		}
+	}
+}
+
+func TestSolveHomographyDegenerate(t *testing.T) {
+	src := [4]Point{{0, 0}, {0, 0}, {0, 0}, {0, 0}}
+	if _, err := solveHomography(src, src); err == nil {
+		t.Error("solveHomography() with identical points should return error")
+	}
+}
+
+func TestDigitizeImage(t *testing.T) {
+	dims := punchcard.GetCardDimensions(punchcard.CardType26x8)
+	layout := LayoutForCard(dims)
+	layout.PegInset, layout.PegRadius = 5, 1.5
+	pattern := createTestPattern(dims.Height, dims.Width)
+
+	tests := []struct {
+		name    string
+		anchor  Anchor
+		corners [4]Point
+	}{
+		{"flat scan", AnchorOutline, [4]Point{{40, 30}, {640, 30}, {640, 360}, {40, 360}}},
+		{"skewed photo", AnchorOutline, [4]Point{{60, 40}, {650, 20}, {630, 380}, {30, 350}}},
+		{"peg holes", AnchorPegHoles, [4]Point{{50, 35}, {645, 25}, {640, 370}, {45, 365}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderTestCard(t, layout, pattern, tt.corners, 700, 420)
+
+			digitizer := NewDigitizer(punchcard.CardType26x8)
+			digitizer.Layout = layout
+			digitizer.Anchor = tt.anchor
+			result, err := digitizer.DigitizeImage(img)
+			if err != nil {
+				t.Fatalf("DigitizeImage() error = %v", err)
+			}
+
+			if err := result.Card.Validate(); err != nil {
+				t.Fatalf("digitized card is invalid: %v", err)
+			}
+
+			for y := 0; y < dims.Height; y++ {
+				for x := 0; x < dims.Width; x++ {
+					if result.Card.Matrix[y][x] != pattern[y][x] {
+						t.Errorf("hole [%d][%d] = %d, want %d (confidence %.2f)",
+							y, x, result.Card.Matrix[y][x], pattern[y][x], result.Confidence[y][x])
+					}
+				}
+			}
+
+			if uncertain := result.Uncertain(0.5); len(uncertain) != 0 {
+				t.Errorf("expected no uncertain holes on a clean render, got %d", len(uncertain))
+			}
+		})
+	}
+}
+
+func TestDigitizeMissingPegHoles(t *testing.T) {
+	dims := punchcard.GetCardDimensions(punchcard.CardType26x8)
+	layout := LayoutForCard(dims)
+	pattern := createTestPattern(dims.Height, dims.Width)
+	img := renderTestCard(t, layout, pattern, [4]Point{{40, 30}, {640, 30}, {640, 360}, {40, 360}}, 700, 420)
+
+	// The exporter's layout has no peg holes to anchor to
+	digitizer := NewDigitizer(punchcard.CardType26x8)
+	digitizer.Anchor = AnchorPegHoles
+	if _, err := digitizer.DigitizeImage(img); err == nil {
+		t.Error("DigitizeImage() anchored to peg holes the layout lacks should fail")
+	}
+
+	// A layout with peg holes fails on a card punched without them
+	digitizer.Layout.PegInset, digitizer.Layout.PegRadius = 5, 1.5
+	if _, err := digitizer.DigitizeImage(img); err == nil || !strings.Contains(err.Error(), "peg hole 1 not found") {
+		t.Errorf("DigitizeImage() of a card without peg holes error = %v, want peg hole 1 not found", err)
+	}
+}
+
+func TestCardLayoutValidate(t *testing.T) {
+	dims := punchcard.GetCardDimensions(punchcard.CardType26x8)
+	tests := []struct {
+		name      string
+		modify    func(l *CardLayout)
+		wantError bool
+	}{
+		{"exporter layout", func(l *CardLayout) {}, false},
+		{"peg holes", func(l *CardLayout) { l.PegInset, l.PegRadius = 5, 1.5 }, false},
+		{"no rows", func(l *CardLayout) { l.Rows = 0 }, true},
+		{"overlapping holes", func(l *CardLayout) { l.HoleRadius = 2.5 }, true},
+		{"grid off the card", func(l *CardLayout) { l.Width = 100 }, true},
+		{"grid past the top", func(l *CardLayout) { l.OriginY = 1 }, true},
+		{"peg wider than its inset", func(l *CardLayout) { l.PegInset, l.PegRadius = 2, 3 }, true},
+		{"negative peg inset", func(l *CardLayout) { l.PegInset = -1 }, true},
+		{"NaN spacing", func(l *CardLayout) { l.Spacing = math.NaN() }, true},
+		{"infinite card", func(l *CardLayout) { l.Width = math.Inf(1) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := LayoutForCard(dims)
+			tt.modify(&layout)
+			if err := layout.Validate(); (err != nil) != tt.wantError {
+				t.Errorf("Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestDigitizeNoCard(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	digitizer := NewDigitizer(punchcard.CardType26x8)
+	if _, err := digitizer.DigitizeImage(img); err == nil {
+		t.Error("DigitizeImage() on a blank image should return error")
+	}
+}
+
+func TestDigitizeInvalidImage(t *testing.T) {
+	digitizer := NewDigitizer(punchcard.CardType26x8)
+	if _, err := digitizer.Digitize(strings.NewReader("not an image")); err == nil {
+		t.Error("Digitize() with invalid data should return error")
+	}
+}
+
+func TestDigitizeResultWriteConfidence(t *testing.T) {
+	result := &DigitizeResult{
+		Card: &punchcard.Card{
+			Number: 1,
+			Width:  3,
+			Height: 1,
+			Matrix: [][]int{{1, 0, 1}},
+		},
+		Confidence: [][]float64{{1.0, 0.2, 0.75}},
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteConfidence(&buf, 0.5); err != nil {
+		t.Fatalf("WriteConfidence() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "\n927\n") {
+		t.Errorf("confidence row missing from output:\n%s", output)
+	}
+	if !strings.Contains(output, "row 1, column 2: read as not punched (0.20)") {
+		t.Errorf("uncertain hole missing from output:\n%s", output)
+	}
+}
+
+// Helper functions
+
+func createTestPattern(rows, cols int) [][]int {
+	pattern := make([][]int, rows)
+	for y := range pattern {
+		pattern[y] = make([]int, cols)
+		for x := range pattern[y] {
+			if (x*3+y*5)%7 < 3 {
+				pattern[y][x] = 1
+			}
+		}
+	}
+	return pattern
+}
+
+// renderTestCard draws a card with the given hole pattern, and its peg holes when the layout has
+// them, onto a dark background, projected so that the card corners land on the given image points
+func renderTestCard(t *testing.T, layout CardLayout, pattern [][]int, corners [4]Point, width, height int) image.Image {
+	t.Helper()
+
+	cardCorners := [4]Point{{0, 0}, {layout.Width, 0}, {layout.Width, layout.Height}, {0, layout.Height}}
+	inverse, err := solveHomography(corners, cardCorners)
+	if err != nil {
+		t.Fatalf("failed to build test projection: %v", err)
+	}
+
+	pegs := [4]Point{
+		{layout.PegInset, layout.PegInset},
+		{layout.Width - layout.PegInset, layout.PegInset},
+		{layout.Width - layout.PegInset, layout.Height - layout.PegInset},
+		{layout.PegInset, layout.Height - layout.PegInset},
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			mx, my := inverse.apply(float64(px)+0.5, float64(py)+0.5)
+			shade := uint8(40) // Scanner background
+			if mx >= 0 && mx <= layout.Width && my >= 0 && my <= layout.Height {
+				shade = 230 // Card stock
+				col := int(math.Round((mx - layout.OriginX) / layout.Spacing))
+				row := int(math.Round((my - layout.OriginY) / layout.Spacing))
+				if col >= 0 && col < layout.Columns && row >= 0 && row < layout.Rows && pattern[row][col] == 1 {
+					hx := layout.OriginX + float64(col)*layout.Spacing
+					hy := layout.OriginY + float64(row)*layout.Spacing
+					if math.Hypot(mx-hx, my-hy) <= layout.HoleRadius {
+						shade = 40
+					}
+				}
+				for _, peg := range pegs {
+					if layout.PegInset > 0 && math.Hypot(mx-peg.X, my-peg.Y) <= layout.PegRadius {
+						shade = 40
+					}
+				}
+			}
+			img.SetGray(px, py, color.Gray{Y: shade})
+		}
+	}
+
+	// Round-trip through PNG to make sure the decoder path is exercised too
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test card: %v", err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode test card: %v", err)
+	}
+	return decoded
+}
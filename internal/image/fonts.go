@@ -0,0 +1,290 @@
+package image
+
+// Embedded bitmap fonts
+//
+// Each glyph is a list of rows with '#' for ink and '.' for ground. Glyphs are laid out
+// proportionally by default: empty columns at either side are trimmed.
+
+// font5x7Glyphs covers printable ASCII and common Nordic and German letters
+var font5x7Glyphs = map[rune][]string{
+	' ':  {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'!':  {"..#..", "..#..", "..#..", "..#..", "..#..", ".....", "..#.."},
+	'"':  {".#.#.", ".#.#.", ".#.#.", ".....", ".....", ".....", "....."},
+	'#':  {".#.#.", ".#.#.", "#####", ".#.#.", "#####", ".#.#.", ".#.#."},
+	'$':  {"..#..", ".####", "#.#..", ".###.", "..#.#", "####.", "..#.."},
+	'%':  {"##...", "##..#", "...#.", "..#..", ".#...", "#..##", "...##"},
+	'&':  {".##..", "#..#.", "#.#..", ".#...", "#.#.#", "#..#.", ".##.#"},
+	'\'': {"..#..", "..#..", ".....", ".....", ".....", ".....", "....."},
+	'(':  {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')':  {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'*':  {".....", "..#..", "#.#.#", ".###.", "#.#.#", "..#..", "....."},
+	'+':  {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	',':  {".....", ".....", ".....", ".....", ".##..", "..#..", ".#..."},
+	'-':  {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'.':  {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	'/':  {".....", "....#", "...#.", "..#..", ".#...", "#....", "....."},
+	'0':  {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1':  {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2':  {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3':  {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4':  {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5':  {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6':  {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7':  {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8':  {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9':  {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	':':  {".....", ".##..", ".##..", ".....", ".##..", ".##..", "....."},
+	';':  {".....", ".##..", ".##..", ".....", ".##..", "..#..", ".#..."},
+	'<':  {"...#.", "..#..", ".#...", "#....", ".#...", "..#..", "...#."},
+	'=':  {".....", ".....", "#####", ".....", "#####", ".....", "....."},
+	'>':  {".#...", "..#..", "...#.", "....#", "...#.", "..#..", ".#..."},
+	'?':  {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+	'@':  {".###.", "#...#", "....#", ".##.#", "#.#.#", "#.#.#", ".###."},
+	'A':  {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B':  {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C':  {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D':  {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E':  {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F':  {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G':  {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H':  {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I':  {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J':  {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K':  {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L':  {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M':  {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N':  {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O':  {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P':  {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q':  {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R':  {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S':  {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T':  {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U':  {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V':  {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W':  {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X':  {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y':  {"#...#", "#...#", "#...#", ".#.#.", "..#..", "..#..", "..#.."},
+	'Z':  {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'[':  {".###.", ".#...", ".#...", ".#...", ".#...", ".#...", ".###."},
+	'\\': {".....", "#....", ".#...", "..#..", "...#.", "....#", "....."},
+	']':  {".###.", "...#.", "...#.", "...#.", "...#.", "...#.", ".###."},
+	'^':  {"..#..", ".#.#.", "#...#", ".....", ".....", ".....", "....."},
+	'_':  {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'`':  {".#...", "..#..", "...#.", ".....", ".....", ".....", "....."},
+	'a':  {".....", ".....", ".###.", "....#", ".####", "#...#", ".####"},
+	'b':  {"#....", "#....", "#.##.", "##..#", "#...#", "#...#", "####."},
+	'c':  {".....", ".....", ".###.", "#....", "#....", "#...#", ".###."},
+	'd':  {"....#", "....#", ".##.#", "#..##", "#...#", "#...#", ".####"},
+	'e':  {".....", ".....", ".###.", "#...#", "#####", "#....", ".###."},
+	'f':  {"..##.", ".#..#", ".#...", "###..", ".#...", ".#...", ".#..."},
+	'g':  {".....", ".####", "#...#", "#...#", ".####", "....#", ".###."},
+	'h':  {"#....", "#....", "#.##.", "##..#", "#...#", "#...#", "#...#"},
+	'i':  {"..#..", ".....", ".##..", "..#..", "..#..", "..#..", ".###."},
+	'j':  {"...#.", ".....", "..##.", "...#.", "...#.", "#..#.", ".##.."},
+	'k':  {"#....", "#....", "#..#.", "#.#..", "##...", "#.#..", "#..#."},
+	'l':  {".##..", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'm':  {".....", ".....", "##.#.", "#.#.#", "#.#.#", "#...#", "#...#"},
+	'n':  {".....", ".....", "#.##.", "##..#", "#...#", "#...#", "#...#"},
+	'o':  {".....", ".....", ".###.", "#...#", "#...#", "#...#", ".###."},
+	'p':  {".....", ".....", "####.", "#...#", "####.", "#....", "#...."},
+	'q':  {".....", ".....", ".##.#", "#..##", ".####", "....#", "....#"},
+	'r':  {".....", ".....", "#.##.", "##..#", "#....", "#....", "#...."},
+	's':  {".....", ".....", ".###.", "#....", ".###.", "....#", "####."},
+	't':  {".#...", ".#...", "###..", ".#...", ".#...", ".#..#", "..##."},
+	'u':  {".....", ".....", "#...#", "#...#", "#...#", "#..##", ".##.#"},
+	'v':  {".....", ".....", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'w':  {".....", ".....", "#...#", "#...#", "#.#.#", "#.#.#", ".#.#."},
+	'x':  {".....", ".....", "#...#", ".#.#.", "..#..", ".#.#.", "#...#"},
+	'y':  {".....", ".....", "#...#", "#...#", ".####", "....#", ".###."},
+	'z':  {".....", ".....", "#####", "...#.", "..#..", ".#...", "#####"},
+	'{':  {"...#.", "..#..", "..#..", ".#...", "..#..", "..#..", "...#."},
+	'|':  {"..#..", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'}':  {".#...", "..#..", "..#..", "...#.", "..#..", "..#..", ".#..."},
+	'~':  {".....", ".....", ".#...", "#.#.#", "...#.", ".....", "....."},
+	'Å':  {"..#..", ".....", ".###.", "#...#", "#####", "#...#", "#...#"},
+	'Ä':  {"#...#", ".....", ".###.", "#...#", "#####", "#...#", "#...#"},
+	'Ö':  {"#...#", ".###.", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'Ü':  {"#...#", ".....", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'É':  {"...#.", "..#..", "#####", "#....", "####.", "#....", "#####"},
+	'å':  {"..#..", ".....", ".###.", "....#", ".####", "#...#", ".####"},
+	'ä':  {".#.#.", ".....", ".###.", "....#", ".####", "#...#", ".####"},
+	'ö':  {".#.#.", ".....", ".###.", "#...#", "#...#", "#...#", ".###."},
+	'ü':  {".#.#.", ".....", "#...#", "#...#", "#...#", "#..##", ".##.#"},
+	'é':  {"...#.", "..#..", ".###.", "#...#", "#####", "#....", ".###."},
+}
+
+// font3x5Glyphs covers capitals, digits and common punctuation; lowercase is drawn as capitals
+var font3x5Glyphs = map[rune][]string{
+	' ':  {"...", "...", "...", "...", "..."},
+	'!':  {".#.", ".#.", ".#.", "...", ".#."},
+	'"':  {"#.#", "#.#", "...", "...", "..."},
+	'#':  {"#.#", "###", "#.#", "###", "#.#"},
+	'%':  {"#.#", "..#", ".#.", "#..", "#.#"},
+	'&':  {".#.", "#.#", ".#.", "#.#", ".##"},
+	'\'': {".#.", ".#.", "...", "...", "..."},
+	'(':  {".#.", "#..", "#..", "#..", ".#."},
+	')':  {".#.", "..#", "..#", "..#", ".#."},
+	'*':  {"...", "#.#", ".#.", "#.#", "..."},
+	'+':  {"...", ".#.", "###", ".#.", "..."},
+	',':  {"...", "...", "...", ".#.", "#.."},
+	'-':  {"...", "...", "###", "...", "..."},
+	'.':  {"...", "...", "...", "...", ".#."},
+	'/':  {"..#", "..#", ".#.", "#..", "#.."},
+	'0':  {"###", "#.#", "#.#", "#.#", "###"},
+	'1':  {".#.", "##.", ".#.", ".#.", "###"},
+	'2':  {"##.", "..#", ".#.", "#..", "###"},
+	'3':  {"##.", "..#", ".#.", "..#", "##."},
+	'4':  {"#.#", "#.#", "###", "..#", "..#"},
+	'5':  {"###", "#..", "##.", "..#", "##."},
+	'6':  {".##", "#..", "###", "#.#", "###"},
+	'7':  {"###", "..#", ".#.", ".#.", ".#."},
+	'8':  {"###", "#.#", "###", "#.#", "###"},
+	'9':  {"###", "#.#", "###", "..#", "##."},
+	':':  {"...", ".#.", "...", ".#.", "..."},
+	';':  {"...", ".#.", "...", ".#.", "#.."},
+	'<':  {"..#", ".#.", "#..", ".#.", "..#"},
+	'=':  {"...", "###", "...", "###", "..."},
+	'>':  {"#..", ".#.", "..#", ".#.", "#.."},
+	'?':  {"##.", "..#", ".#.", "...", ".#."},
+	'@':  {"###", "#.#", "###", "#..", ".##"},
+	'A':  {".#.", "#.#", "###", "#.#", "#.#"},
+	'B':  {"##.", "#.#", "##.", "#.#", "##."},
+	'C':  {".##", "#..", "#..", "#..", ".##"},
+	'D':  {"##.", "#.#", "#.#", "#.#", "##."},
+	'E':  {"###", "#..", "##.", "#..", "###"},
+	'F':  {"###", "#..", "##.", "#..", "#.."},
+	'G':  {".##", "#..", "#.#", "#.#", ".##"},
+	'H':  {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I':  {"###", ".#.", ".#.", ".#.", "###"},
+	'J':  {"..#", "..#", "..#", "#.#", ".#."},
+	'K':  {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L':  {"#..", "#..", "#..", "#..", "###"},
+	'M':  {"#.#", "###", "###", "#.#", "#.#"},
+	'N':  {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O':  {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P':  {"##.", "#.#", "##.", "#..", "#.."},
+	'Q':  {".#.", "#.#", "#.#", "##.", ".##"},
+	'R':  {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S':  {".##", "#..", ".#.", "..#", "##."},
+	'T':  {"###", ".#.", ".#.", ".#.", ".#."},
+	'U':  {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V':  {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W':  {"#.#", "#.#", "###", "###", "#.#"},
+	'X':  {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y':  {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z':  {"###", "..#", ".#.", "#..", "###"},
+	'[':  {"##.", "#..", "#..", "#..", "##."},
+	']':  {".##", "..#", "..#", "..#", ".##"},
+	'_':  {"...", "...", "...", "...", "###"},
+}
+
+// font6x13Glyphs is the 7x13 face of the public domain X11 misc-fixed fonts, its glyphs 6 columns
+// wide, with the Nordic and German letters of the 5x7 font added
+var font6x13Glyphs = map[rune][]string{
+	' ':  {"......", "......", "......", "......", "......", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'!':  {"......", "......", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "......", "...#..", "......", "......"},
+	'"':  {"......", "......", "..#.#.", "..#.#.", "..#.#.", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'#':  {"......", "......", "......", "..#.#.", "..#.#.", ".#####", "..#.#.", ".#####", "..#.#.", "..#.#.", "......", "......", "......"},
+	'$':  {"......", "......", "......", "...#..", "..####", ".#.#..", "..###.", "...#.#", ".####.", "...#..", "......", "......", "......"},
+	'%':  {"......", "......", ".#...#", "#.#..#", ".#..#.", "...#..", "...#..", "..#...", ".#..#.", "#..#.#", "#...#.", "......", "......"},
+	'&':  {"......", "......", "......", "......", ".##...", "#..#..", "#..#..", ".##...", "#..#.#", "#...#.", ".###.#", "......", "......"},
+	'\'': {"......", "......", "...#..", "...#..", "...#..", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'(':  {"......", "......", "....#.", "...#..", "...#..", "..#...", "..#...", "..#...", "...#..", "...#..", "....#.", "......", "......"},
+	')':  {"......", "......", "..#...", "...#..", "...#..", "....#.", "....#.", "....#.", "...#..", "...#..", "..#...", "......", "......"},
+	'*':  {"......", "......", "......", "......", ".#..#.", "..##..", "######", "..##..", ".#..#.", "......", "......", "......", "......"},
+	'+':  {"......", "......", "......", "......", "...#..", "...#..", ".#####", "...#..", "...#..", "......", "......", "......", "......"},
+	',':  {"......", "......", "......", "......", "......", "......", "......", "......", "......", "..###.", "..##..", ".#....", "......"},
+	'-':  {"......", "......", "......", "......", "......", "......", ".#####", "......", "......", "......", "......", "......", "......"},
+	'.':  {"......", "......", "......", "......", "......", "......", "......", "......", "......", "...#..", "..###.", "...#..", "......"},
+	'/':  {"......", "......", ".....#", ".....#", "....#.", "....#.", "...#..", "..#...", "..#...", ".#....", ".#....", "......", "......"},
+	'0':  {"......", "......", "..##..", ".#..#.", "#....#", "#....#", "#....#", "#....#", "#....#", ".#..#.", "..##..", "......", "......"},
+	'1':  {"......", "......", "...#..", "..##..", ".#.#..", "...#..", "...#..", "...#..", "...#..", "...#..", ".#####", "......", "......"},
+	'2':  {"......", "......", ".####.", "#....#", "#....#", ".....#", "....#.", "..##..", ".#....", "#.....", "######", "......", "......"},
+	'3':  {"......", "......", "######", ".....#", "....#.", "...#..", "..###.", ".....#", ".....#", "#....#", ".####.", "......", "......"},
+	'4':  {"......", "......", "....#.", "...##.", "..#.#.", ".#..#.", "#...#.", "#...#.", "######", "....#.", "....#.", "......", "......"},
+	'5':  {"......", "......", "######", "#.....", "#.....", "#.###.", "##...#", ".....#", ".....#", "#....#", ".####.", "......", "......"},
+	'6':  {"......", "......", "..###.", ".#....", "#.....", "#.....", "#.###.", "##...#", "#....#", "#....#", ".####.", "......", "......"},
+	'7':  {"......", "......", "######", ".....#", "....#.", "...#..", "...#..", "..#...", "..#...", ".#....", ".#....", "......", "......"},
+	'8':  {"......", "......", ".####.", "#....#", "#....#", "#....#", ".####.", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'9':  {"......", "......", ".####.", "#....#", "#....#", "#...##", ".###.#", ".....#", ".....#", "....#.", ".###..", "......", "......"},
+	':':  {"......", "......", "......", "......", "...#..", "..###.", "...#..", "......", "......", "...#..", "..###.", "...#..", "......"},
+	';':  {"......", "......", "......", "......", "...#..", "..###.", "...#..", "......", "......", "..###.", "..##..", ".#....", "......"},
+	'<':  {"......", "......", ".....#", "....#.", "...#..", "..#...", ".#....", "..#...", "...#..", "....#.", ".....#", "......", "......"},
+	'=':  {"......", "......", "......", "......", "......", "######", "......", "......", "######", "......", "......", "......", "......"},
+	'>':  {"......", "......", ".#....", "..#...", "...#..", "....#.", ".....#", "....#.", "...#..", "..#...", ".#....", "......", "......"},
+	'?':  {"......", "......", ".####.", "#....#", "#....#", ".....#", "....#.", "...#..", "...#..", "......", "...#..", "......", "......"},
+	'@':  {"......", "......", ".####.", "#....#", "#....#", "#..###", "#.#..#", "#.#.##", "#..#.#", "#.....", ".####.", "......", "......"},
+	'A':  {"......", "......", "..##..", ".#..#.", "#....#", "#....#", "#....#", "######", "#....#", "#....#", "#....#", "......", "......"},
+	'B':  {"......", "......", "#####.", ".#...#", ".#...#", ".#...#", ".####.", ".#...#", ".#...#", ".#...#", "#####.", "......", "......"},
+	'C':  {"......", "......", ".####.", "#....#", "#.....", "#.....", "#.....", "#.....", "#.....", "#....#", ".####.", "......", "......"},
+	'D':  {"......", "......", "#####.", ".#...#", ".#...#", ".#...#", ".#...#", ".#...#", ".#...#", ".#...#", "#####.", "......", "......"},
+	'E':  {"......", "......", "######", "#.....", "#.....", "#.....", "####..", "#.....", "#.....", "#.....", "######", "......", "......"},
+	'F':  {"......", "......", "######", "#.....", "#.....", "#.....", "####..", "#.....", "#.....", "#.....", "#.....", "......", "......"},
+	'G':  {"......", "......", ".####.", "#....#", "#.....", "#.....", "#.....", "#..###", "#....#", "#...##", ".###.#", "......", "......"},
+	'H':  {"......", "......", "#....#", "#....#", "#....#", "#....#", "######", "#....#", "#....#", "#....#", "#....#", "......", "......"},
+	'I':  {"......", "......", ".#####", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", ".#####", "......", "......"},
+	'J':  {"......", "......", "...###", "....#.", "....#.", "....#.", "....#.", "....#.", "....#.", "#...#.", ".###..", "......", "......"},
+	'K':  {"......", "......", "#....#", "#...#.", "#..#..", "#.#...", "##....", "#.#...", "#..#..", "#...#.", "#....#", "......", "......"},
+	'L':  {"......", "......", "#.....", "#.....", "#.....", "#.....", "#.....", "#.....", "#.....", "#.....", "######", "......", "......"},
+	'M':  {"......", "......", "#....#", "##..##", "##..##", "#.##.#", "#.##.#", "#....#", "#....#", "#....#", "#....#", "......", "......"},
+	'N':  {"......", "......", "#....#", "#....#", "##...#", "#.#..#", "#..#.#", "#...##", "#....#", "#....#", "#....#", "......", "......"},
+	'O':  {"......", "......", ".####.", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'P':  {"......", "......", "#####.", "#....#", "#....#", "#....#", "#####.", "#.....", "#.....", "#.....", "#.....", "......", "......"},
+	'Q':  {"......", "......", ".####.", "#....#", "#....#", "#....#", "#....#", "#....#", "#.#..#", "#..#.#", ".####.", ".....#", "......"},
+	'R':  {"......", "......", "#####.", "#....#", "#....#", "#....#", "#####.", "#.#...", "#..#..", "#...#.", "#....#", "......", "......"},
+	'S':  {"......", "......", ".####.", "#....#", "#.....", "#.....", ".####.", ".....#", ".....#", "#....#", ".####.", "......", "......"},
+	'T':  {"......", "......", ".#####", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "......", "......"},
+	'U':  {"......", "......", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'V':  {"......", "......", "#....#", "#....#", "#....#", ".#..#.", ".#..#.", ".#..#.", "..##..", "..##..", "..##..", "......", "......"},
+	'W':  {"......", "......", "#....#", "#....#", "#....#", "#....#", "#.##.#", "#.##.#", "##..##", "##..##", "#....#", "......", "......"},
+	'X':  {"......", "......", "#....#", "#....#", ".#..#.", ".#..#.", "..##..", ".#..#.", ".#..#.", "#....#", "#....#", "......", "......"},
+	'Y':  {"......", "......", ".#...#", ".#...#", "..#.#.", "..#.#.", "...#..", "...#..", "...#..", "...#..", "...#..", "......", "......"},
+	'Z':  {"......", "......", "######", ".....#", "....#.", "...#..", "..##..", "..#...", ".#....", "#.....", "######", "......", "......"},
+	'[':  {"......", ".####.", ".#....", ".#....", ".#....", ".#....", ".#....", ".#....", ".#....", ".#....", ".#....", ".####.", "......"},
+	'\\': {"......", "......", ".#....", ".#....", "..#...", "..#...", "...#..", "....#.", "....#.", ".....#", ".....#", "......", "......"},
+	']':  {"......", ".####.", "....#.", "....#.", "....#.", "....#.", "....#.", "....#.", "....#.", "....#.", "....#.", ".####.", "......"},
+	'^':  {"......", "......", "...#..", "..#.#.", ".#...#", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'_':  {"......", "......", "......", "......", "......", "......", "......", "......", "......", "......", "......", "######", "......"},
+	'`':  {"......", "..#...", "...#..", "......", "......", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'a':  {"......", "......", "......", "......", "......", ".####.", ".....#", ".#####", "#....#", "#...##", ".###.#", "......", "......"},
+	'b':  {"......", "......", "#.....", "#.....", "#.....", "#.###.", "##...#", "#....#", "#....#", "##...#", "#.###.", "......", "......"},
+	'c':  {"......", "......", "......", "......", "......", ".####.", "#....#", "#.....", "#.....", "#....#", ".####.", "......", "......"},
+	'd':  {"......", "......", ".....#", ".....#", ".....#", ".###.#", "#...##", "#....#", "#....#", "#...##", ".###.#", "......", "......"},
+	'e':  {"......", "......", "......", "......", "......", ".####.", "#....#", "######", "#.....", "#....#", ".####.", "......", "......"},
+	'f':  {"......", "......", "..###.", ".#...#", ".#....", ".#....", "####..", ".#....", ".#....", ".#....", ".#....", "......", "......"},
+	'g':  {"......", "......", "......", "......", "......", ".###.#", "#...#.", "#...#.", ".###..", "#.....", ".####.", "#....#", ".####."},
+	'h':  {"......", "......", "#.....", "#.....", "#.....", "#.###.", "##...#", "#....#", "#....#", "#....#", "#....#", "......", "......"},
+	'i':  {"......", "......", "......", "...#..", "......", "..##..", "...#..", "...#..", "...#..", "...#..", ".#####", "......", "......"},
+	'j':  {"......", "......", "......", ".....#", "......", "....##", ".....#", ".....#", ".....#", ".....#", ".#...#", ".#...#", "..###."},
+	'k':  {"......", "......", "#.....", "#.....", "#.....", "#...#.", "#..#..", "###...", "#..#..", "#...#.", "#....#", "......", "......"},
+	'l':  {"......", "......", "..##..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", ".#####", "......", "......"},
+	'm':  {"......", "......", "......", "......", "......", ".##.#.", ".#.#.#", ".#.#.#", ".#.#.#", ".#.#.#", ".#...#", "......", "......"},
+	'n':  {"......", "......", "......", "......", "......", "#.###.", "##...#", "#....#", "#....#", "#....#", "#....#", "......", "......"},
+	'o':  {"......", "......", "......", "......", "......", ".####.", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'p':  {"......", "......", "......", "......", "......", "#.###.", "##...#", "#....#", "##...#", "#.###.", "#.....", "#.....", "#....."},
+	'q':  {"......", "......", "......", "......", "......", ".###.#", "#...##", "#....#", "#...##", ".###.#", ".....#", ".....#", ".....#"},
+	'r':  {"......", "......", "......", "......", "......", "#.###.", ".#...#", ".#....", ".#....", ".#....", ".#....", "......", "......"},
+	's':  {"......", "......", "......", "......", "......", ".####.", "#....#", ".##...", "...##.", "#....#", ".####.", "......", "......"},
+	't':  {"......", "......", "......", ".#....", ".#....", "####..", ".#....", ".#....", ".#....", ".#...#", "..###.", "......", "......"},
+	'u':  {"......", "......", "......", "......", "......", "#....#", "#....#", "#....#", "#....#", "#...##", ".###.#", "......", "......"},
+	'v':  {"......", "......", "......", "......", "......", ".#...#", ".#...#", ".#...#", "..#.#.", "..#.#.", "...#..", "......", "......"},
+	'w':  {"......", "......", "......", "......", "......", ".#...#", ".#...#", ".#.#.#", ".#.#.#", ".#.#.#", "..#.#.", "......", "......"},
+	'x':  {"......", "......", "......", "......", "......", "#....#", ".#..#.", "..##..", "..##..", ".#..#.", "#....#", "......", "......"},
+	'y':  {"......", "......", "......", "......", "......", "#....#", "#....#", "#....#", "#...##", ".###.#", ".....#", "#....#", ".####."},
+	'z':  {"......", "......", "......", "......", "......", "######", "....#.", "...#..", "..#...", ".#....", "######", "......", "......"},
+	'{':  {"......", "...###", "..#...", "..#...", "..#...", "...#..", ".##...", "...#..", "..#...", "..#...", "..#...", "...###", "......"},
+	'|':  {"......", "......", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "...#..", "......", "......"},
+	'}':  {"......", ".###..", "....#.", "....#.", "....#.", "...#..", "....##", "...#..", "....#.", "....#.", "....#.", ".###..", "......"},
+	'~':  {"......", "......", "..#..#", ".#.#.#", ".#..#.", "......", "......", "......", "......", "......", "......", "......", "......"},
+	'Å':  {"..##..", ".#..#.", "..##..", ".#..#.", "#....#", "#....#", "######", "#....#", "#....#", "#....#", "#....#", "......", "......"},
+	'Ä':  {".#..#.", "......", "..##..", ".#..#.", "#....#", "#....#", "#....#", "######", "#....#", "#....#", "#....#", "......", "......"},
+	'Ö':  {".#..#.", "......", ".####.", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'Ü':  {".#..#.", "......", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'É':  {"....#.", "...#..", "######", "#.....", "#.....", "#.....", "####..", "#.....", "#.....", "#.....", "######", "......", "......"},
+	'å':  {"......", "..##..", ".#..#.", "..##..", "......", ".####.", ".....#", ".#####", "#....#", "#...##", ".###.#", "......", "......"},
+	'ä':  {"......", "......", "......", ".#..#.", "......", ".####.", ".....#", ".#####", "#....#", "#...##", ".###.#", "......", "......"},
+	'ö':  {"......", "......", "......", ".#..#.", "......", ".####.", "#....#", "#....#", "#....#", "#....#", ".####.", "......", "......"},
+	'ü':  {"......", "......", "......", ".#..#.", "......", "#....#", "#....#", "#....#", "#....#", "#...##", ".###.#", "......", "......"},
+	'é':  {"......", "......", "....#.", "...#..", "......", ".####.", "#....#", "######", "#.....", "#....#", ".####.", "......", "......"},
+}
@@ -0,0 +1,432 @@
+package image
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Alignment defines how lines of text are placed horizontally
+type Alignment string
+
+const (
+	AlignLeft   Alignment = "left"
+	AlignCenter Alignment = "center"
+	AlignRight  Alignment = "right"
+)
+
+// ValidateAlignment checks if the alignment is supported
+func ValidateAlignment(align string) error {
+	switch Alignment(align) {
+	case AlignLeft, AlignCenter, AlignRight:
+		return nil
+	default:
+		return fmt.Errorf("invalid alignment: %s (must be 'left', 'center', or 'right')", align)
+	}
+}
+
+// Font is an embedded bitmap font
+// Each font has one native height; lettering of another height scales its glyphs (see Lettering.Height)
+type Font struct {
+	Name        string
+	Height      int // Native glyph height in pixels
+	SpaceWidth  int // Width of a space in native pixels
+	glyphs      map[rune][]string
+	capitalOnly bool // Lowercase letters are drawn as capitals
+	bold        bool // Every stroke is doubled one column to the right, so glyphs are one column wider
+}
+
+// Embedded fonts by name; 5x7-bold is the 5x7 face with doubled strokes, not a face of its own
+var fonts = map[string]*Font{
+	"3x5":      {Name: "3x5", Height: 5, SpaceWidth: 2, glyphs: font3x5Glyphs, capitalOnly: true},
+	"5x7":      {Name: "5x7", Height: 7, SpaceWidth: 3, glyphs: font5x7Glyphs},
+	"5x7-bold": {Name: "5x7-bold", Height: 7, SpaceWidth: 3, glyphs: font5x7Glyphs, bold: true},
+	"6x13":     {Name: "6x13", Height: 13, SpaceWidth: 4, glyphs: font6x13Glyphs},
+}
+
+// DefaultFont is the name of the font used when none is chosen
+const DefaultFont = "5x7"
+
+// Lettering height limits in pixels (picks)
+const (
+	MinLetteringHeight = 5
+	MaxLetteringHeight = 32
+)
+
+// Lettering text limits, which bound the size of the rendered bitmap and of the chain woven from it
+const (
+	MaxLetteringRunes = 256 // Characters, counting line breaks
+	MaxLetteringLines = 16
+)
+
+// FontByName returns an embedded font
+func FontByName(name string) (*Font, error) {
+	if f, ok := fonts[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid font: %s (must be one of %s)", name, strings.Join(FontNames(), ", "))
+}
+
+// FontNames returns the names of the embedded fonts
+func FontNames() []string {
+	names := make([]string, 0, len(fonts))
+	for name := range fonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// glyph returns the bitmap of a rune at native size, falling back to a plain letter or '?'
+func (f *Font) glyph(r rune) [][]int {
+	rows, ok := f.lookup(r)
+	if !ok {
+		rows, _ = f.lookup('?')
+	}
+
+	bitmap := make([][]int, len(rows))
+	for y, row := range rows {
+		width := len(row)
+		if f.bold {
+			width++
+		}
+		bitmap[y] = make([]int, width)
+		for x, c := range row {
+			if c == '#' {
+				bitmap[y][x] = 1
+				if f.bold {
+					bitmap[y][x+1] = 1
+				}
+			}
+		}
+	}
+	return bitmap
+}
+
+// lookup finds the glyph for a rune, trying capitals and letters without diacritics
+func (f *Font) lookup(r rune) ([]string, bool) {
+	candidates := []rune{r}
+	if base, ok := plainLetters[r]; ok {
+		candidates = append(candidates, base)
+	}
+	for _, c := range candidates {
+		if f.capitalOnly {
+			c = unicode.ToUpper(c)
+		}
+		if rows, ok := f.glyphs[c]; ok {
+			return rows, true
+		}
+		if rows, ok := f.glyphs[unicode.ToUpper(c)]; ok && unicode.IsLetter(c) {
+			return rows, true
+		}
+	}
+	return nil, false
+}
+
+// plainLetters maps accented letters to the letter drawn when a font lacks them
+var plainLetters = map[rune]rune{
+	'Å': 'A', 'Ä': 'A', 'À': 'A', 'Á': 'A', 'Â': 'A', 'Æ': 'A',
+	'å': 'a', 'ä': 'a', 'à': 'a', 'á': 'a', 'â': 'a', 'æ': 'a',
+	'Ö': 'O', 'Ø': 'O', 'Ó': 'O', 'Ò': 'O', 'Ô': 'O',
+	'ö': 'o', 'ø': 'o', 'ó': 'o', 'ò': 'o', 'ô': 'o',
+	'Ü': 'U', 'Ú': 'U', 'Ù': 'U', 'ü': 'u', 'ú': 'u', 'ù': 'u',
+	'É': 'E', 'È': 'E', 'Ê': 'E', 'Ë': 'E', 'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'Ç': 'C', 'ç': 'c', 'Ñ': 'N', 'ñ': 'n', 'ß': 's',
+}
+
+// Lettering lays out text with an embedded bitmap font
+// The fonts are 5, 7 and 13 pixels tall: any other Height enlarges or shrinks their glyphs by
+// nearest-neighbour sampling, which keeps strokes even only at whole multiples of the native height
+type Lettering struct {
+	Font          string    // Font name (see FontNames)
+	Height        int       // Letter height in pixels, 5-32 (0 = the font's native height); see below
+	Align         Alignment // Horizontal alignment of the lines
+	LetterSpacing int       // Extra columns between letters (may be negative)
+	LineSpacing   int       // Extra rows between lines (may be negative)
+	Monospace     bool      // Keep every glyph at full width instead of trimming empty columns
+	Outline       bool      // Draw hollow letters with a one-pixel contour around each glyph
+}
+
+// NewLettering creates a lettering layout with default settings
+func NewLettering() *Lettering {
+	return &Lettering{
+		Font:  DefaultFont,
+		Align: AlignLeft,
+	}
+}
+
+// Validate checks that the lettering settings are usable
+func (l *Lettering) Validate() error {
+	if _, err := FontByName(l.Font); err != nil {
+		return err
+	}
+	if l.Height != 0 && (l.Height < MinLetteringHeight || l.Height > MaxLetteringHeight) {
+		return fmt.Errorf("invalid height: %d (must be between %d and %d)", l.Height, MinLetteringHeight, MaxLetteringHeight)
+	}
+	if err := ValidateAlignment(string(l.Align)); err != nil {
+		return err
+	}
+	if l.LetterSpacing < -2 || l.LetterSpacing > 32 {
+		return fmt.Errorf("invalid letter spacing: %d (must be between -2 and 32)", l.LetterSpacing)
+	}
+	if l.LineSpacing < -2 || l.LineSpacing > 32 {
+		return fmt.Errorf("invalid line spacing: %d (must be between -2 and 32)", l.LineSpacing)
+	}
+	return nil
+}
+
+// TextBitmap is rendered lettering
+// Ink holds 1 for punched cells; Mask marks the cells covered by the lettering, which
+// includes the hollow insides of outlined glyphs, so compositing knocks out the image there
+type TextBitmap struct {
+	Width  int
+	Height int
+	Ink    [][]int
+	Mask   [][]bool
+	Align  Alignment
+}
+
+// Render lays out text; lines are separated by newlines
+func (l *Lettering) Render(text string) (*TextBitmap, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	font, _ := FontByName(l.Font)
+
+	height := l.Height
+	if height == 0 {
+		height = font.Height
+	}
+	scale := float64(height) / float64(font.Height)
+	unit := int(math.Max(1, math.Round(scale))) // One native pixel at this scale
+
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("no text to render")
+	}
+	if n := utf8.RuneCountInString(text); n > MaxLetteringRunes {
+		return nil, fmt.Errorf("text too long: %d characters (must be at most %d)", n, MaxLetteringRunes)
+	}
+	if n := strings.Count(text, "\n") + 1; n > MaxLetteringLines {
+		return nil, fmt.Errorf("text too long: %d lines (must be at most %d)", n, MaxLetteringLines)
+	}
+
+	// Lay out each line as a list of scaled glyph bitmaps
+	var lines [][][]int
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, l.layoutLine(font, line, height, scale, unit))
+	}
+
+	lineGap := unit + l.LineSpacing
+	if lineGap < 0 {
+		lineGap = 0
+	}
+	width := 0
+	for _, line := range lines {
+		if len(line) > 0 && len(line[0]) > width {
+			width = len(line[0])
+		}
+	}
+	total := len(lines)*height + (len(lines)-1)*lineGap
+	if width == 0 {
+		return nil, fmt.Errorf("no text to render")
+	}
+
+	ink := make([][]int, total)
+	for y := range ink {
+		ink[y] = make([]int, width)
+	}
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		offset := 0
+		switch l.Align {
+		case AlignCenter:
+			offset = (width - len(line[0])) / 2
+		case AlignRight:
+			offset = width - len(line[0])
+		}
+		top := i * (height + lineGap)
+		for y, row := range line {
+			copy(ink[top+y][offset:], row)
+		}
+	}
+
+	bitmap := &TextBitmap{Width: width, Height: total, Ink: ink, Align: l.Align}
+	if l.Outline {
+		bitmap = bitmap.outlined()
+	} else {
+		bitmap.Mask = make([][]bool, total)
+		for y := range ink {
+			bitmap.Mask[y] = make([]bool, width)
+			for x, v := range ink[y] {
+				bitmap.Mask[y][x] = v == 1
+			}
+		}
+	}
+	return bitmap, nil
+}
+
+// layoutLine renders one line at the target height; the result has height rows
+func (l *Lettering) layoutLine(font *Font, line string, height int, scale float64, unit int) [][]int {
+	rows := make([][]int, height)
+	gap := unit + l.LetterSpacing
+	if gap < 0 {
+		gap = 0
+	}
+
+	// Trailing spaces do not widen the line
+	first := true
+	for _, r := range strings.TrimRight(line, " \t") {
+		var glyph [][]int
+		if r == ' ' || r == '\t' {
+			width := int(math.Max(1, math.Round(float64(font.SpaceWidth)*scale)))
+			glyph = make([][]int, height)
+			for y := range glyph {
+				glyph[y] = make([]int, width)
+			}
+		} else {
+			glyph = scaleGlyph(l.trim(font.glyph(r)), height, scale)
+		}
+
+		for y := range rows {
+			if !first {
+				rows[y] = append(rows[y], make([]int, gap)...)
+			}
+			rows[y] = append(rows[y], glyph[y]...)
+		}
+		first = false
+	}
+
+	if len(rows) > 0 && len(rows[0]) == 0 {
+		return nil
+	}
+	return rows
+}
+
+// trim removes empty columns at the sides of a glyph unless the layout is monospaced
+func (l *Lettering) trim(glyph [][]int) [][]int {
+	if l.Monospace || len(glyph) == 0 {
+		return glyph
+	}
+	left, right := 0, len(glyph[0])
+	for left < right && columnEmpty(glyph, left) {
+		left++
+	}
+	for right > left && columnEmpty(glyph, right-1) {
+		right--
+	}
+	if left == right {
+		return glyph
+	}
+	trimmed := make([][]int, len(glyph))
+	for y := range glyph {
+		trimmed[y] = glyph[y][left:right]
+	}
+	return trimmed
+}
+
+// scaleGlyph resizes a glyph with nearest-neighbour sampling, repeating or dropping whole rows and columns
+func scaleGlyph(glyph [][]int, height int, scale float64) [][]int {
+	srcHeight := len(glyph)
+	srcWidth := len(glyph[0])
+	width := int(math.Max(1, math.Round(float64(srcWidth)*scale)))
+
+	scaled := make([][]int, height)
+	for y := range scaled {
+		scaled[y] = make([]int, width)
+		sy := y * srcHeight / height
+		for x := range scaled[y] {
+			scaled[y][x] = glyph[sy][x*srcWidth/width]
+		}
+	}
+	return scaled
+}
+
+// columnEmpty reports whether a column has no ink
+func columnEmpty(rows [][]int, x int) bool {
+	for _, row := range rows {
+		if x < len(row) && row[x] == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// outlined returns hollow lettering: a one-pixel contour around the glyphs, with the glyphs
+// themselves left as ground; the bitmap grows by one pixel on every side
+func (b *TextBitmap) outlined() *TextBitmap {
+	width, height := b.Width+2, b.Height+2
+	inked := func(x, y int) bool {
+		x, y = x-1, y-1
+		return y >= 0 && y < b.Height && x >= 0 && x < b.Width && b.Ink[y][x] == 1
+	}
+
+	result := &TextBitmap{Width: width, Height: height, Align: b.Align}
+	result.Ink = make([][]int, height)
+	result.Mask = make([][]bool, height)
+	for y := 0; y < height; y++ {
+		result.Ink[y] = make([]int, width)
+		result.Mask[y] = make([]bool, width)
+		for x := 0; x < width; x++ {
+			if inked(x, y) {
+				result.Mask[y][x] = true
+				continue
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if inked(x+dx, y+dy) {
+						result.Ink[y][x] = 1
+						result.Mask[y][x] = true
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Matrix places the lettering on its own in a matrix of the given width, aligned as rendered
+// The result can be passed straight to the punchcard generator
+func (b *TextBitmap) Matrix(width int) ([][]int, error) {
+	if b.Width > width {
+		return nil, fmt.Errorf("text is %d hooks wide, more than the %d available", b.Width, width)
+	}
+
+	x := 0
+	switch b.Align {
+	case AlignCenter:
+		x = (width - b.Width) / 2
+	case AlignRight:
+		x = width - b.Width
+	}
+
+	matrix := make([][]int, b.Height)
+	for y := range matrix {
+		matrix[y] = make([]int, width)
+	}
+	b.CompositeInto(matrix, x, 0)
+	return matrix, nil
+}
+
+// CompositeInto draws the lettering into a matrix with its top-left corner at (x, y)
+// Cells under the mask take the lettering's value; parts outside the matrix are clipped
+func (b *TextBitmap) CompositeInto(matrix [][]int, x, y int) {
+	for ty := 0; ty < b.Height; ty++ {
+		my := y + ty
+		if my < 0 || my >= len(matrix) {
+			continue
+		}
+		for tx := 0; tx < b.Width; tx++ {
+			mx := x + tx
+			if mx < 0 || mx >= len(matrix[my]) || !b.Mask[ty][tx] {
+				continue
+			}
+			matrix[my][mx] = b.Ink[ty][tx]
+		}
+	}
+}
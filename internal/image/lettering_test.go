@@ -0,0 +1,250 @@
+package image
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func renderText(t *testing.T, l *Lettering, text string) *TextBitmap {
+	t.Helper()
+	bitmap, err := l.Render(text)
+	if err != nil {
+		t.Fatalf("Render(%q) error = %v", text, err)
+	}
+	return bitmap
+}
+
+func TestLetteringValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(l *Lettering)
+		wantErr bool
+	}{
+		{"defaults", func(l *Lettering) {}, false},
+		{"bold font", func(l *Lettering) { l.Font = "5x7-bold" }, false},
+		{"unknown font", func(l *Lettering) { l.Font = "comic" }, true},
+		{"minimum height", func(l *Lettering) { l.Height = 5 }, false},
+		{"maximum height", func(l *Lettering) { l.Height = 32 }, false},
+		{"height too small", func(l *Lettering) { l.Height = 4 }, true},
+		{"height too large", func(l *Lettering) { l.Height = 33 }, true},
+		{"invalid alignment", func(l *Lettering) { l.Align = "justify" }, true},
+		{"letter spacing too tight", func(l *Lettering) { l.LetterSpacing = -3 }, true},
+		{"line spacing too wide", func(l *Lettering) { l.LineSpacing = 33 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLettering()
+			tt.modify(l)
+			if err := l.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLetteringLayout(t *testing.T) {
+	t.Run("proportional", func(t *testing.T) {
+		// H is 5 wide, I trims to 3, with a one-column gap
+		bitmap := renderText(t, NewLettering(), "HI")
+		if bitmap.Width != 9 || bitmap.Height != 7 {
+			t.Errorf("size = %dx%d, want 9x7", bitmap.Width, bitmap.Height)
+		}
+		if bitmap.Ink[3][0] != 1 || bitmap.Ink[3][4] != 1 || bitmap.Ink[3][5] != 0 {
+			t.Error("H crossbar or gap drawn in the wrong place")
+		}
+	})
+
+	t.Run("monospace", func(t *testing.T) {
+		l := NewLettering()
+		l.Monospace = true
+		if bitmap := renderText(t, l, "HI"); bitmap.Width != 11 {
+			t.Errorf("width = %d, want 11", bitmap.Width)
+		}
+	})
+
+	t.Run("letter spacing", func(t *testing.T) {
+		l := NewLettering()
+		l.LetterSpacing = 2
+		if bitmap := renderText(t, l, "HI"); bitmap.Width != 11 {
+			t.Errorf("width = %d, want 11", bitmap.Width)
+		}
+	})
+
+	t.Run("scaled", func(t *testing.T) {
+		l := NewLettering()
+		l.Height = 14
+		bitmap := renderText(t, l, "HI")
+		if bitmap.Width != 18 || bitmap.Height != 14 {
+			t.Errorf("size = %dx%d, want 18x14", bitmap.Width, bitmap.Height)
+		}
+	})
+
+	t.Run("bold", func(t *testing.T) {
+		l := NewLettering()
+		l.Font = "5x7-bold"
+		bitmap := renderText(t, l, "I")
+		if bitmap.Width != 4 {
+			t.Errorf("width = %d, want 4", bitmap.Width)
+		}
+	})
+
+	t.Run("large font", func(t *testing.T) {
+		// Drawn at its native 13 picks, g reaching into the descender rows below H
+		l := NewLettering()
+		l.Font = "6x13"
+		bitmap := renderText(t, l, "Hg")
+		if bitmap.Height != 13 {
+			t.Errorf("height = %d, want 13", bitmap.Height)
+		}
+		if bitmap.Ink[2][0] != 1 || bitmap.Ink[11][0] != 0 || bitmap.Ink[12][bitmap.Width-2] != 1 {
+			t.Error("6x13 letters drawn in the wrong rows")
+		}
+	})
+
+	t.Run("trailing spaces", func(t *testing.T) {
+		a := renderText(t, NewLettering(), "HI")
+		b := renderText(t, NewLettering(), "HI   ")
+		if a.Width != b.Width {
+			t.Errorf("trailing spaces widened the text from %d to %d", a.Width, b.Width)
+		}
+	})
+
+	t.Run("empty text", func(t *testing.T) {
+		if _, err := NewLettering().Render(" \n "); err == nil {
+			t.Error("expected an error for blank text")
+		}
+	})
+
+	t.Run("text too long", func(t *testing.T) {
+		if _, err := NewLettering().Render(strings.Repeat("A", MaxLetteringRunes+1)); err == nil {
+			t.Error("expected an error for too many characters")
+		}
+		if _, err := NewLettering().Render(strings.Repeat("A\n", MaxLetteringLines) + "A"); err == nil {
+			t.Error("expected an error for too many lines")
+		}
+		if _, err := NewLettering().Render(strings.Repeat("A\n", MaxLetteringLines-1) + "A"); err != nil {
+			t.Errorf("Render() of %d lines error = %v", MaxLetteringLines, err)
+		}
+	})
+}
+
+func TestFontGlyphSizes(t *testing.T) {
+	for _, name := range FontNames() {
+		font, _ := FontByName(name)
+		for r, rows := range font.glyphs {
+			if len(rows) != font.Height {
+				t.Errorf("%s glyph %q has %d rows, want %d", name, r, len(rows), font.Height)
+				continue
+			}
+			for _, row := range rows {
+				if len(row) != len(rows[0]) {
+					t.Errorf("%s glyph %q has rows of different widths", name, r)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestLetteringFallbacks(t *testing.T) {
+	small := NewLettering()
+	small.Font = "3x5"
+
+	if !reflect.DeepEqual(renderText(t, small, "hi").Ink, renderText(t, small, "HI").Ink) {
+		t.Error("3x5 lowercase should be drawn as capitals")
+	}
+	if !reflect.DeepEqual(renderText(t, small, "Ä").Ink, renderText(t, small, "A").Ink) {
+		t.Error("missing accented letters should fall back to the plain letter")
+	}
+	if !reflect.DeepEqual(renderText(t, NewLettering(), "ø").Ink, renderText(t, NewLettering(), "o").Ink) {
+		t.Error("ø should fall back to o in the 5x7 font")
+	}
+	if !reflect.DeepEqual(renderText(t, NewLettering(), "☃").Ink, renderText(t, NewLettering(), "?").Ink) {
+		t.Error("unknown characters should be drawn as ?")
+	}
+}
+
+func TestLetteringMultiLine(t *testing.T) {
+	l := NewLettering()
+	l.Align = AlignCenter
+	bitmap := renderText(t, l, "HI\nI")
+
+	// Two 7-row lines with a one-row gap
+	if bitmap.Width != 9 || bitmap.Height != 15 {
+		t.Fatalf("size = %dx%d, want 9x15", bitmap.Width, bitmap.Height)
+	}
+	// The lone I (3 wide) is centred under the 9-wide first line
+	if bitmap.Ink[8][2] != 0 || bitmap.Ink[8][3] != 1 || bitmap.Ink[8][5] != 1 || bitmap.Ink[8][6] != 0 {
+		t.Errorf("second line not centred: %v", bitmap.Ink[8])
+	}
+}
+
+func TestLetteringOutline(t *testing.T) {
+	l := NewLettering()
+	l.Outline = true
+	plain := renderText(t, NewLettering(), "I")
+	outlined := renderText(t, l, "I")
+
+	if outlined.Width != plain.Width+2 || outlined.Height != plain.Height+2 {
+		t.Fatalf("size = %dx%d, want %dx%d", outlined.Width, outlined.Height, plain.Width+2, plain.Height+2)
+	}
+	for y := 0; y < plain.Height; y++ {
+		for x := 0; x < plain.Width; x++ {
+			if plain.Ink[y][x] == 1 && (outlined.Ink[y+1][x+1] != 0 || !outlined.Mask[y+1][x+1]) {
+				t.Fatalf("glyph cell (%d,%d) should be hollow and masked", x, y)
+			}
+		}
+	}
+	if outlined.Ink[0][1] != 1 {
+		t.Error("contour should surround the glyph")
+	}
+	if outlined.Mask[0][0] && outlined.Ink[0][0] == 0 {
+		t.Error("corner outside the contour should not be masked")
+	}
+}
+
+func TestTextBitmapMatrix(t *testing.T) {
+	l := NewLettering()
+	l.Align = AlignRight
+	bitmap := renderText(t, l, "I")
+
+	matrix, err := bitmap.Matrix(8)
+	if err != nil {
+		t.Fatalf("Matrix() error = %v", err)
+	}
+	if len(matrix) != 7 || len(matrix[0]) != 8 {
+		t.Fatalf("matrix size = %dx%d, want 8x7", len(matrix[0]), len(matrix))
+	}
+	if matrix[0][4] != 0 || matrix[0][5] != 1 || matrix[0][7] != 1 {
+		t.Errorf("right-aligned row = %v", matrix[0])
+	}
+
+	if _, err := bitmap.Matrix(2); err == nil {
+		t.Error("expected an error when the text is wider than the matrix")
+	}
+}
+
+func TestTextBitmapCompositeInto(t *testing.T) {
+	l := NewLettering()
+	l.Outline = true
+	bitmap := renderText(t, l, "I")
+
+	matrix := make([][]int, 10)
+	for y := range matrix {
+		matrix[y] = []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	}
+	bitmap.CompositeInto(matrix, 2, 1)
+
+	// Hollow glyph cells are knocked out of the dark background
+	if matrix[2][4] != 0 {
+		t.Error("glyph cell should be knocked out")
+	}
+	if matrix[0][0] != 1 || matrix[9][9] != 1 {
+		t.Error("cells outside the lettering should be untouched")
+	}
+
+	// Placement partly outside the matrix is clipped
+	bitmap.CompositeInto(matrix, -3, 8)
+}
@@ -0,0 +1,295 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultChannelWeights are the standard luminosity weights used by RGBToGray
+var DefaultChannelWeights = [3]float64{0.299, 0.587, 0.114}
+
+// Preprocess holds the tonal adjustments applied to the grayscale image before dithering
+// The steps run in a fixed order: auto-levels, black/white points, gamma,
+// brightness/contrast, blur, unsharp mask and finally invert.
+// Spatial steps (blur, sharpen) work on the resized image, so their radii are
+// measured in hooks and picks rather than source pixels.
+type Preprocess struct {
+	ChannelWeights [3]float64 `json:"channelWeights"`       // Red, green and blue weights for the grayscale conversion
+	AutoLevels     bool       `json:"autoLevels"`           // Stretch the darkest and lightest 0.5% to full black and white
+	BlackPoint     float64    `json:"blackPoint"`           // Input level (0-1) mapped to black
+	WhitePoint     *float64   `json:"whitePoint,omitempty"` // Input level (0-1) mapped to white (nil = 1)
+	Gamma          float64    `json:"gamma"`                // Midtone gamma; above 1 lightens, below 1 darkens
+	Brightness     float64    `json:"brightness"`           // Added to every level (-1 to 1)
+	Contrast       float64    `json:"contrast"`             // Contrast around mid-gray (-1 to 1)
+	BlurRadius     float64    `json:"blurRadius"`           // Gaussian blur sigma in pixels (0 = off)
+	SharpenAmount  float64    `json:"sharpenAmount"`        // Unsharp mask strength (0 = off)
+	SharpenRadius  float64    `json:"sharpenRadius"`        // Unsharp mask sigma in pixels
+	Invert         bool       `json:"invert"`               // Swap light and dark
+}
+
+// DefaultPreprocess returns a pre-processing chain that leaves the image unchanged
+func DefaultPreprocess() Preprocess {
+	return Preprocess{
+		ChannelWeights: DefaultChannelWeights,
+		BlackPoint:     0,
+		Gamma:          1,
+		SharpenRadius:  1,
+	}
+}
+
+// normalized fills in defaults for zero values so a zero Preprocess behaves like DefaultPreprocess
+func (p Preprocess) normalized() Preprocess {
+	if p.ChannelWeights == [3]float64{} {
+		p.ChannelWeights = DefaultChannelWeights
+	}
+	if p.WhitePoint != nil && *p.WhitePoint == 1 {
+		p.WhitePoint = nil
+	}
+	if p.Gamma <= 0 {
+		p.Gamma = 1
+	}
+	if p.SharpenRadius <= 0 {
+		p.SharpenRadius = 1
+	}
+	return p
+}
+
+// White returns the input level mapped to white
+func (p Preprocess) White() float64 {
+	if p.WhitePoint == nil {
+		return 1
+	}
+	return *p.WhitePoint
+}
+
+// IsDefault reports whether the chain leaves the image unchanged
+func (p Preprocess) IsDefault() bool {
+	return p.normalized() == DefaultPreprocess()
+}
+
+// Validate checks that all adjustments are within their allowed ranges
+func (p Preprocess) Validate() error {
+	// Ranges are checked as !(in range) so that NaN, which fails every comparison, is rejected
+	for i, w := range p.ChannelWeights {
+		if !(w >= 0 && w <= math.MaxFloat64) {
+			return fmt.Errorf("invalid channel weight %d: %g (must be a number, not negative)", i+1, w)
+		}
+	}
+	white := p.White()
+	if !(p.BlackPoint >= 0 && p.BlackPoint <= 1 && white >= 0 && white <= 1) {
+		return fmt.Errorf("invalid black/white point: %g/%g (must be between 0 and 1)", p.BlackPoint, white)
+	}
+	if white <= p.BlackPoint {
+		return fmt.Errorf("invalid black/white point: white point %g must be above black point %g", white, p.BlackPoint)
+	}
+	if p.Gamma != 0 && !(p.Gamma >= 0.1 && p.Gamma <= 10) {
+		return fmt.Errorf("invalid gamma: %g (must be between 0.1 and 10)", p.Gamma)
+	}
+	if !(p.Brightness >= -1 && p.Brightness <= 1) {
+		return fmt.Errorf("invalid brightness: %g (must be between -1 and 1)", p.Brightness)
+	}
+	if !(p.Contrast >= -1 && p.Contrast <= 1) {
+		return fmt.Errorf("invalid contrast: %g (must be between -1 and 1)", p.Contrast)
+	}
+	if !(p.BlurRadius >= 0 && p.BlurRadius <= 50) {
+		return fmt.Errorf("invalid blur radius: %g (must be between 0 and 50)", p.BlurRadius)
+	}
+	if !(p.SharpenAmount >= 0 && p.SharpenAmount <= 10) {
+		return fmt.Errorf("invalid sharpen amount: %g (must be between 0 and 10)", p.SharpenAmount)
+	}
+	if !(p.SharpenRadius >= 0 && p.SharpenRadius <= 50) {
+		return fmt.Errorf("invalid sharpen radius: %g (must be between 0 and 50)", p.SharpenRadius)
+	}
+	return nil
+}
+
+// Describe returns a short human-readable summary of the active steps
+func (p Preprocess) Describe() string {
+	p = p.normalized()
+	var steps []string
+	if p.ChannelWeights != DefaultChannelWeights {
+		steps = append(steps, fmt.Sprintf("channels R%.2f G%.2f B%.2f", p.ChannelWeights[0], p.ChannelWeights[1], p.ChannelWeights[2]))
+	}
+	if p.AutoLevels {
+		steps = append(steps, "auto-levels")
+	}
+	if p.BlackPoint != 0 || p.White() != 1 {
+		steps = append(steps, fmt.Sprintf("levels %d-%d", int(p.BlackPoint*255+0.5), int(p.White()*255+0.5)))
+	}
+	if p.Gamma != 1 {
+		steps = append(steps, fmt.Sprintf("gamma %.2f", p.Gamma))
+	}
+	if p.Brightness != 0 {
+		steps = append(steps, fmt.Sprintf("brightness %+.0f%%", p.Brightness*100))
+	}
+	if p.Contrast != 0 {
+		steps = append(steps, fmt.Sprintf("contrast %+.0f%%", p.Contrast*100))
+	}
+	if p.BlurRadius > 0 {
+		steps = append(steps, fmt.Sprintf("blur %.1fpx", p.BlurRadius))
+	}
+	if p.SharpenAmount > 0 {
+		steps = append(steps, fmt.Sprintf("sharpen %.1f@%.1fpx", p.SharpenAmount, p.SharpenRadius))
+	}
+	if p.Invert {
+		steps = append(steps, "invert")
+	}
+	if len(steps) == 0 {
+		return "none"
+	}
+	return strings.Join(steps, ", ")
+}
+
+// toGrayscaleWeighted converts an image to grayscale using custom channel weights
+// The weights are normalized so that white stays white
+func toGrayscaleWeighted(img image.Image, weights [3]float64) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+
+	sum := weights[0] + weights[1] + weights[2]
+	if sum <= 0 {
+		weights, sum = DefaultChannelWeights, 1
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			v := (weights[0]*float64(r) + weights[1]*float64(g) + weights[2]*float64(b)) / sum / 257
+			gray.SetGray(x, y, color.Gray{Y: uint8(math.Min(math.Round(v), 255))})
+		}
+	}
+
+	return gray
+}
+
+// apply runs the tonal and spatial steps on a grayscale image
+func (p Preprocess) apply(img *image.Gray) *image.Gray {
+	p = p.normalized()
+	if p == DefaultPreprocess() {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	plane := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			plane[y*width+x] = float64(img.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y) / 255
+		}
+	}
+
+	if p.AutoLevels {
+		lo, hi := percentiles(plane, 0.005, 0.995)
+		levels(plane, lo, hi)
+	}
+	if p.BlackPoint != 0 || p.White() != 1 {
+		levels(plane, p.BlackPoint, p.White())
+	}
+	if p.Gamma != 1 {
+		for i, v := range plane {
+			plane[i] = math.Pow(v, 1/p.Gamma)
+		}
+	}
+	if p.Brightness != 0 || p.Contrast != 0 {
+		for i, v := range plane {
+			plane[i] = clamp01((v-0.5)*(1+p.Contrast) + 0.5 + p.Brightness)
+		}
+	}
+	if p.BlurRadius > 0 {
+		plane = gaussianBlur(plane, width, height, p.BlurRadius)
+	}
+	if p.SharpenAmount > 0 {
+		blurred := gaussianBlur(plane, width, height, p.SharpenRadius)
+		for i, v := range plane {
+			plane[i] = clamp01(v + p.SharpenAmount*(v-blurred[i]))
+		}
+	}
+	if p.Invert {
+		for i, v := range plane {
+			plane[i] = 1 - v
+		}
+	}
+
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	for i, v := range plane {
+		dst.Pix[(i/width)*dst.Stride+i%width] = uint8(math.Round(clamp01(v) * 255))
+	}
+	return dst
+}
+
+// levels maps lo to black and hi to white, clipping values outside the range
+func levels(plane []float64, lo, hi float64) {
+	if hi <= lo {
+		return
+	}
+	for i, v := range plane {
+		plane[i] = clamp01((v - lo) / (hi - lo))
+	}
+}
+
+// percentiles returns the values below which the given fractions of the plane fall
+func percentiles(plane []float64, low, high float64) (float64, float64) {
+	sorted := make([]float64, len(plane))
+	copy(sorted, plane)
+	sort.Float64s(sorted)
+	last := len(sorted) - 1
+	return sorted[int(low*float64(last))], sorted[int(high*float64(last))]
+}
+
+// gaussianBlur applies a separable Gaussian blur with the given sigma
+// Edges are handled by repeating the border pixels
+func gaussianBlur(plane []float64, width, height int, sigma float64) []float64 {
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	clampIndex := func(i, n int) int {
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+
+	tmp := make([]float64, len(plane))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var v float64
+			for k, w := range kernel {
+				v += w * plane[y*width+clampIndex(x+k-radius, width)]
+			}
+			tmp[y*width+x] = v
+		}
+	}
+
+	out := make([]float64, len(plane))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var v float64
+			for k, w := range kernel {
+				v += w * tmp[clampIndex(y+k-radius, height)*width+x]
+			}
+			out[y*width+x] = v
+		}
+	}
+	return out
+}
+
+// clamp01 limits a value to the 0-1 range
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,172 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func createGradientImage(width int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, 1))
+	for x := 0; x < width; x++ {
+		img.SetGray(x, 0, color.Gray{Y: uint8(x * 255 / (width - 1))})
+	}
+	return img
+}
+
+func TestPreprocessDefault(t *testing.T) {
+	if !DefaultPreprocess().IsDefault() {
+		t.Error("DefaultPreprocess() should be the default")
+	}
+	if !(Preprocess{}).IsDefault() {
+		t.Error("zero Preprocess should behave like the default")
+	}
+	if got := DefaultPreprocess().Describe(); got != "none" {
+		t.Errorf("Describe() = %q, want 'none'", got)
+	}
+
+	img := createGradientImage(16)
+	if out := DefaultPreprocess().apply(img); out != img {
+		t.Error("default chain should return the image unchanged")
+	}
+}
+
+// level returns a pointer to an input level, for the white point
+func level(v float64) *float64 {
+	return &v
+}
+
+func TestPreprocessValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(p *Preprocess)
+		wantError bool
+	}{
+		{"default", func(p *Preprocess) {}, false},
+		{"negative weight", func(p *Preprocess) { p.ChannelWeights[0] = -1 }, true},
+		{"white below black", func(p *Preprocess) { p.BlackPoint, p.WhitePoint = 0.6, level(0.4) }, true},
+		{"white point of 0", func(p *Preprocess) { p.WhitePoint = level(0) }, true},
+		{"black point with the white point unset", func(p *Preprocess) { p.BlackPoint = 0.2 }, false},
+		{"NaN white point", func(p *Preprocess) { p.WhitePoint = level(math.NaN()) }, true},
+		{"NaN gamma", func(p *Preprocess) { p.Gamma = math.NaN() }, true},
+		{"NaN brightness", func(p *Preprocess) { p.Brightness = math.NaN() }, true},
+		{"infinite weight", func(p *Preprocess) { p.ChannelWeights[1] = math.Inf(1) }, true},
+		{"gamma too high", func(p *Preprocess) { p.Gamma = 20 }, true},
+		{"brightness out of range", func(p *Preprocess) { p.Brightness = 2 }, true},
+		{"contrast out of range", func(p *Preprocess) { p.Contrast = -2 }, true},
+		{"negative blur", func(p *Preprocess) { p.BlurRadius = -1 }, true},
+		{"valid adjustments", func(p *Preprocess) {
+			p.Gamma, p.Brightness, p.Contrast, p.SharpenAmount = 1.8, 0.1, 0.3, 1
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPreprocess()
+			tt.modify(&p)
+			if err := p.Validate(); (err != nil) != tt.wantError {
+				t.Errorf("Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPreprocessSteps(t *testing.T) {
+	img := createGradientImage(11)
+
+	tests := []struct {
+		name   string
+		modify func(p *Preprocess)
+		check  func(t *testing.T, out *image.Gray)
+	}{
+		{"invert", func(p *Preprocess) { p.Invert = true }, func(t *testing.T, out *image.Gray) {
+			if out.GrayAt(0, 0).Y != 255 || out.GrayAt(10, 0).Y != 0 {
+				t.Errorf("invert should swap black and white, got %d and %d", out.GrayAt(0, 0).Y, out.GrayAt(10, 0).Y)
+			}
+		}},
+		{"levels", func(p *Preprocess) { p.BlackPoint, p.WhitePoint = 0.2, level(0.8) }, func(t *testing.T, out *image.Gray) {
+			if out.GrayAt(2, 0).Y != 0 || out.GrayAt(8, 0).Y != 255 {
+				t.Errorf("levels should clip to the black and white points, got %d and %d", out.GrayAt(2, 0).Y, out.GrayAt(8, 0).Y)
+			}
+		}},
+		{"gamma", func(p *Preprocess) { p.Gamma = 2 }, func(t *testing.T, out *image.Gray) {
+			if out.GrayAt(5, 0).Y <= img.GrayAt(5, 0).Y {
+				t.Errorf("gamma above 1 should lighten midtones, got %d", out.GrayAt(5, 0).Y)
+			}
+		}},
+		{"brightness", func(p *Preprocess) { p.Brightness = -0.2 }, func(t *testing.T, out *image.Gray) {
+			if out.GrayAt(5, 0).Y >= img.GrayAt(5, 0).Y {
+				t.Errorf("negative brightness should darken, got %d", out.GrayAt(5, 0).Y)
+			}
+		}},
+		{"contrast", func(p *Preprocess) { p.Contrast = 0.5 }, func(t *testing.T, out *image.Gray) {
+			if out.GrayAt(2, 0).Y >= img.GrayAt(2, 0).Y || out.GrayAt(8, 0).Y <= img.GrayAt(8, 0).Y {
+				t.Error("contrast should push levels away from mid-gray")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPreprocess()
+			tt.modify(&p)
+			tt.check(t, p.apply(img))
+		})
+	}
+}
+
+func TestPreprocessAutoLevels(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 1))
+	for x := 0; x < 10; x++ {
+		img.SetGray(x, 0, color.Gray{Y: uint8(100 + x*5)})
+	}
+
+	p := DefaultPreprocess()
+	p.AutoLevels = true
+	out := p.apply(img)
+
+	if out.GrayAt(0, 0).Y != 0 || out.GrayAt(9, 0).Y != 255 {
+		t.Errorf("auto-levels should stretch to full range, got %d-%d", out.GrayAt(0, 0).Y, out.GrayAt(9, 0).Y)
+	}
+}
+
+func TestPreprocessBlurAndSharpen(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 9, 1))
+	img.SetGray(4, 0, color.Gray{Y: 255})
+
+	blur := DefaultPreprocess()
+	blur.BlurRadius = 1
+	blurred := blur.apply(img)
+	if blurred.GrayAt(4, 0).Y >= 255 || blurred.GrayAt(3, 0).Y == 0 {
+		t.Error("blur should spread the bright pixel to its neighbours")
+	}
+
+	sharpen := DefaultPreprocess()
+	sharpen.SharpenAmount = 2
+	edge := createGradientImage(9)
+	sharpened := sharpen.apply(edge)
+	if sharpened.GrayAt(0, 0).Y != 0 || sharpened.GrayAt(8, 0).Y != 255 {
+		t.Error("sharpen should keep the extremes clipped to black and white")
+	}
+}
+
+func TestToGrayscaleWeighted(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	gray := toGrayscaleWeighted(img, [3]float64{1, 0, 0})
+	if gray.GrayAt(0, 0).Y != 255 {
+		t.Errorf("red-only weights should map red to white, got %d", gray.GrayAt(0, 0).Y)
+	}
+	if gray.GrayAt(1, 0).Y != 255 {
+		t.Errorf("white should stay white, got %d", gray.GrayAt(1, 0).Y)
+	}
+
+	p := NewProcessor(2, 1, TwoColor)
+	p.Preprocess.ChannelWeights = [3]float64{0, 0, 1}
+	if got := p.grayscale(img).GrayAt(0, 0).Y; got != 0 {
+		t.Errorf("blue-only weights should map red to black, got %d", got)
+	}
+}
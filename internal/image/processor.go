@@ -4,10 +4,9 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
 	"math"
+	"time"
 )
 
 // ColorMode defines the number of color variations supported
@@ -21,41 +20,202 @@ const (
 
 // Processor handles image processing for punchcard conversion
 type Processor struct {
-	Width     int
-	Height    int
-	ColorMode ColorMode
+	Width      int
+	Height     int
+	ColorMode  ColorMode
+	Preprocess Preprocess      // Tonal adjustments applied before dithering
+	Sett       Sett            // Cloth density used to derive the pick count when Height is 0
+	Alpha      AlphaMode       // How transparent pixels are converted
+	Background color.Color     // Background for AlphaBackground (white when nil)
+	Ground     GroundStructure // Structure for AlphaStructure (plain weave when nil)
+	Frame      int             // GIF frame or TIFF page to decode, counted from 0
+	Antialias  bool            // Anti-alias edges when rasterising SVG input
+	MaxPixels  int             // Largest source image or SVG raster accepted, in pixels (0 = no limit)
+	MaxCells   int             // Largest matrix produced, in hooks x picks (0 = no limit)
+	Tile       TileMode        // Vertical repeat for chains woven as an endless loop (none by default)
+	TileRows   int             // Rows rolled or blended by Tile (0 = half the height to roll, an eighth to blend)
+
+	// Observe, when set, is called with the time taken by each stage: decode, resize and dither
+	Observe func(stage string, elapsed time.Duration)
 }
 
+// Processing stages reported to Processor.Observe
+const (
+	StageDecode = "decode"
+	StageResize = "resize"
+	StageDither = "dither"
+)
+
 // NewProcessor creates a new image processor
 // For Jacquard looms: width typically represents the number of needles (8 for simplified version)
 // height represents the number of rows in the image
 func NewProcessor(width, height int, mode ColorMode) *Processor {
 	return &Processor{
-		Width:     width,
-		Height:    height,
-		ColorMode: mode,
+		Width:      width,
+		Height:     height,
+		ColorMode:  mode,
+		Preprocess: DefaultPreprocess(),
+		Alpha:      AlphaBackground,
+		Background: color.White,
+		Antialias:  true,
 	}
 }
 
 // Process converts an uploaded image to a binary matrix suitable for punchcard generation
 // Uses Floyd-Steinberg dithering for better visual quality with limited colors
 func (p *Processor) Process(r io.Reader) ([][]int, error) {
-	// Decode the image
-	img, _, err := image.Decode(r)
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	start := time.Now()
+
+	// Vector input is rasterised straight at the target size; raster input is
+	// decoded, picking the requested GIF frame or TIFF page
+	var img image.Image
+	height := p.Height
+	if IsSVG(data) {
+		if p.Frame > 0 {
+			return nil, fmt.Errorf("%w: %d (svg images have a single frame)", ErrInvalidFrame, p.Frame+1)
+		}
+		docWidth, docHeight, err := svgSize(data)
+		if err != nil {
+			return nil, err
+		}
+		if height == 0 {
+			height = p.Sett.Rows(p.Width, int(math.Round(docWidth)), int(math.Round(docHeight)))
+		}
+		if height == 0 {
+			height = int(math.Max(1, math.Round(float64(p.Width)*docHeight/docWidth)))
+		}
+		if err := p.checkCells(height); err != nil {
+			return nil, err
+		}
+		if err := checkPixels(p.Width, height, p.MaxPixels); err != nil {
+			return nil, err
+		}
+		img, err = rasterizeSVG(data, p.Width, height, p.Antialias)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		img, _, err = decodeFrame(data, p.Frame, p.MaxPixels)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Flatten transparency before the grayscale conversion
+	// Outside background mode, transparent areas are flattened onto white and replaced after dithering
+	var mask *image.Gray
+	if hasTransparency(img) {
+		background := p.Background
+		if p.Alpha != "" && p.Alpha != AlphaBackground {
+			mask = alphaMask(img)
+			background = color.White
+		}
+		if background == nil {
+			background = color.White
+		}
+		img = composite(img, background)
 	}
 
+	start = p.observe(StageDecode, start)
+
 	// Convert to grayscale and resize
-	grayImg := toGrayscale(img)
-	resized := resize(grayImg, p.Width, p.Height)
+	// Without a fixed height the pick count comes from the sett, or from the aspect ratio
+	grayImg := p.grayscale(img)
+	if height == 0 {
+		height = p.Sett.Rows(p.Width, grayImg.Bounds().Dx(), grayImg.Bounds().Dy())
+	}
+	if height == 0 {
+		height = int(float64(p.Width) * (float64(grayImg.Bounds().Dy()) / float64(grayImg.Bounds().Dx())))
+		if height == 0 {
+			height = 1
+		}
+	}
+	if err := p.checkCells(height); err != nil {
+		return nil, err
+	}
+	resized := resize(grayImg, p.Width, height)
+
+	// Apply the pre-processing chain at the target resolution, then make it tile for endless chains
+	resized = p.Preprocess.apply(resized)
+	resized = tile(resized, p.Tile, p.TileRows)
+	start = p.observe(StageResize, start)
 
 	// Apply dithering based on color mode
 	dithered := p.applyDithering(resized)
 
+	// Weave each image row over several picks
+	dithered = repeatRows(dithered, p.Sett.picksPerRow())
+
+	// Replace transparent areas with ground or the ground structure
+	if mask != nil {
+		transparent := repeatRows(transparentCells(tile(resize(mask, p.Width, height), p.Tile, p.TileRows)), p.Sett.picksPerRow())
+		fillTransparent(dithered, transparent, p.groundStructure())
+	}
+	p.observe(StageDither, start)
+
 	return dithered, nil
 }
 
+// checkCells rejects a matrix of the given image rows, each woven over its picks, when it would
+// be larger than MaxCells, before anything that size is allocated
+func (p *Processor) checkCells(rows int) error {
+	picks := int64(rows) * int64(p.Sett.picksPerRow())
+	if p.MaxCells > 0 && int64(p.Width)*picks > int64(p.MaxCells) {
+		return fmt.Errorf("%w: %d hooks x %d picks (limit is %d hooks x picks)", ErrImageTooLarge, p.Width, picks, p.MaxCells)
+	}
+	return nil
+}
+
+// observe reports the time since start for a stage and returns the start of the next stage
+func (p *Processor) observe(stage string, start time.Time) time.Time {
+	now := time.Now()
+	if p.Observe != nil {
+		p.Observe(stage, now.Sub(start))
+	}
+	return now
+}
+
+// groundStructure returns the structure used for transparent areas, or nil for plain ground
+func (p *Processor) groundStructure() GroundStructure {
+	if p.Alpha != AlphaStructure {
+		return nil
+	}
+	if p.Ground != nil {
+		return p.Ground
+	}
+	return defaultGround
+}
+
+// DescribeAlpha returns a human-readable description of the alpha handling
+func (p *Processor) DescribeAlpha() string {
+	switch p.Alpha {
+	case AlphaGround:
+		return "transparent areas woven as ground (no lift)"
+	case AlphaStructure:
+		return "transparent areas filled with a ground structure"
+	default:
+		background := p.Background
+		if background == nil {
+			background = color.White
+		}
+		r, g, b, _ := color.RGBAModel.Convert(background).RGBA()
+		return fmt.Sprintf("composited onto #%02x%02x%02x", r>>8, g>>8, b>>8)
+	}
+}
+
+// grayscale converts an image to grayscale using the configured channel weights
+func (p *Processor) grayscale(img image.Image) *image.Gray {
+	weights := p.Preprocess.normalized().ChannelWeights
+	if weights == DefaultChannelWeights {
+		return toGrayscale(img)
+	}
+	return toGrayscaleWeighted(img, weights)
+}
+
 // toGrayscale converts an image to grayscale
 func toGrayscale(img image.Image) *image.Gray {
 	bounds := img.Bounds()
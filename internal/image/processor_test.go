@@ -5,7 +5,9 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewProcessor(t *testing.T) {
@@ -281,3 +283,25 @@ func BenchmarkDithering(b *testing.B) {
 		processor.applyDithering(img)
 	}
 }
+
+func TestProcessObserveStages(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, createCheckerboardImage(32, 32, 4))
+
+	var stages []string
+	p := NewProcessor(16, 0, TwoColor)
+	p.Observe = func(stage string, elapsed time.Duration) {
+		if elapsed < 0 {
+			t.Errorf("stage %s took %v", stage, elapsed)
+		}
+		stages = append(stages, stage)
+	}
+	if _, err := p.Process(&buf); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := []string{StageDecode, StageResize, StageDither}
+	if strings.Join(stages, ",") != strings.Join(want, ",") {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+}
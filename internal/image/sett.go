@@ -0,0 +1,150 @@
+package image
+
+import (
+	"fmt"
+	"math"
+)
+
+// Sett describes the thread density of the woven cloth
+// Without a sett the pick count follows the pixel aspect ratio, which stretches the
+// woven image whenever the cloth has a different number of ends and picks per cm.
+// The warp is given either as EndsPerCm or as a target WovenWidthCm (ends/cm is then
+// hooks / width). PicksPerCm defaults to the ends/cm of a balanced cloth, and
+// WovenHeightCm, when set, replaces the height implied by the image aspect ratio; both need
+// the warp. PicksPerRow also works on its own, sharing the picks of the aspect ratio between rows.
+type Sett struct {
+	EndsPerCm     float64 `json:"endsPerCm,omitempty"`     // Warp ends per cm (one hook per end)
+	PicksPerCm    float64 `json:"picksPerCm,omitempty"`    // Weft picks per cm
+	WovenWidthCm  float64 `json:"wovenWidthCm,omitempty"`  // Target woven width in cm
+	WovenHeightCm float64 `json:"wovenHeightCm,omitempty"` // Target woven height in cm
+	PicksPerRow   int     `json:"picksPerRow,omitempty"`   // Cards woven from each image row (0 or 1 = one card per row)
+}
+
+// Validate checks that the sett values are within their allowed ranges
+// Ranges are checked as !(in range) so that NaN, which fails every comparison, is rejected
+func (s Sett) Validate() error {
+	if !(s.EndsPerCm >= 0 && s.EndsPerCm <= 200) {
+		return fmt.Errorf("invalid ends per cm: %g (must be between 0 and 200)", s.EndsPerCm)
+	}
+	if !(s.PicksPerCm >= 0 && s.PicksPerCm <= 200) {
+		return fmt.Errorf("invalid picks per cm: %g (must be between 0 and 200)", s.PicksPerCm)
+	}
+	if !(s.WovenWidthCm >= 0 && s.WovenWidthCm <= 1000) {
+		return fmt.Errorf("invalid woven width: %g cm (must be between 0 and 1000)", s.WovenWidthCm)
+	}
+	if !(s.WovenHeightCm >= 0 && s.WovenHeightCm <= 1000) {
+		return fmt.Errorf("invalid woven height: %g cm (must be between 0 and 1000)", s.WovenHeightCm)
+	}
+	if s.PicksPerRow < 0 || s.PicksPerRow > 64 {
+		return fmt.Errorf("invalid picks per row: %d (must be between 1 and 64, or 0 for one)", s.PicksPerRow)
+	}
+	if s.EndsPerCm > 0 && s.WovenWidthCm > 0 {
+		return fmt.Errorf("invalid sett: give either ends per cm or a woven width, not both")
+	}
+	if !s.IsSet() && (s.PicksPerCm > 0 || s.WovenHeightCm > 0) {
+		return fmt.Errorf("invalid sett: picks per cm and a woven height need ends per cm or a woven width")
+	}
+	return nil
+}
+
+// IsSet reports whether the sett gives a warp density, so picks can be derived from it
+func (s Sett) IsSet() bool {
+	return s.EndsPerCm > 0 || s.WovenWidthCm > 0
+}
+
+// endsPerCm returns the warp density for the given number of hooks, or 0 when unknown
+func (s Sett) endsPerCm(ends int) float64 {
+	if s.EndsPerCm > 0 {
+		return s.EndsPerCm
+	}
+	if s.WovenWidthCm > 0 {
+		return float64(ends) / s.WovenWidthCm
+	}
+	return 0
+}
+
+// WeftDensity returns the picks per cm for the given number of hooks, defaulting to a balanced cloth
+func (s Sett) WeftDensity(ends int) float64 {
+	if s.PicksPerCm > 0 {
+		return s.PicksPerCm
+	}
+	return s.endsPerCm(ends)
+}
+
+// picksPerRow returns how many cards are woven from each image row
+func (s Sett) picksPerRow() int {
+	if s.PicksPerRow < 1 {
+		return 1
+	}
+	return s.PicksPerRow
+}
+
+// maxSettRows bounds the rows derived from a sett, so the conversion to int cannot overflow;
+// Processor.MaxCells decides whether that many rows are accepted
+const maxSettRows = math.MaxInt32
+
+// Rows returns the number of image rows to resample a srcWidth x srcHeight image to,
+// or 0 when the sett is not set and the pixel aspect ratio should be used
+// Without a warp density but with several picks per row, the picks of the aspect ratio are
+// shared between the rows, so weaving each row over several picks does not stretch the image.
+// A dense sett or a long woven height can ask for far more rows than the image has; the result is
+// only capped at maxSettRows, and Process refuses it when it is over MaxCells
+func (s Sett) Rows(ends, srcWidth, srcHeight int) int {
+	epc := s.endsPerCm(ends)
+	if ends <= 0 || srcWidth <= 0 {
+		return 0
+	}
+	if epc <= 0 {
+		if s.picksPerRow() == 1 {
+			return 0
+		}
+		return boundRows(float64(ends) * float64(srcHeight) / float64(srcWidth) / float64(s.picksPerRow()))
+	}
+
+	wovenHeight := s.WovenHeightCm
+	if wovenHeight <= 0 {
+		wovenWidth := float64(ends) / epc
+		wovenHeight = wovenWidth * float64(srcHeight) / float64(srcWidth)
+	}
+
+	picks := wovenHeight * s.WeftDensity(ends)
+	return boundRows(picks / float64(s.picksPerRow()))
+}
+
+// boundRows rounds a row count to between 1 and maxSettRows
+func boundRows(rows float64) int {
+	rows = math.Round(rows)
+	if !(rows >= 1) {
+		return 1
+	}
+	if rows > maxSettRows {
+		return maxSettRows
+	}
+	return int(rows)
+}
+
+// WovenSize returns the woven width and height in cm for the given number of ends and picks
+// The result is zero when the sett does not give a warp density
+func (s Sett) WovenSize(ends, picks int) (float64, float64) {
+	epc := s.endsPerCm(ends)
+	if epc <= 0 {
+		return 0, 0
+	}
+	return float64(ends) / epc, float64(picks) / s.WeftDensity(ends)
+}
+
+// repeatRows repeats each row of the matrix n times
+func repeatRows(matrix [][]int, n int) [][]int {
+	if n <= 1 {
+		return matrix
+	}
+	result := make([][]int, 0, len(matrix)*n)
+	for _, row := range matrix {
+		for i := 0; i < n; i++ {
+			copied := make([]int, len(row))
+			copy(copied, row)
+			result = append(result, copied)
+		}
+	}
+	return result
+}
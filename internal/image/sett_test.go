@@ -0,0 +1,141 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestSettValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		sett      Sett
+		wantError bool
+	}{
+		{"empty", Sett{}, false},
+		{"ends and picks", Sett{EndsPerCm: 20, PicksPerCm: 30}, false},
+		{"woven size", Sett{WovenWidthCm: 10, WovenHeightCm: 15}, false},
+		{"negative ends", Sett{EndsPerCm: -1}, true},
+		{"too many picks", Sett{PicksPerCm: 500}, true},
+		{"negative picks per row", Sett{PicksPerRow: -2}, true},
+		{"ends and width", Sett{EndsPerCm: 20, WovenWidthCm: 10}, true},
+		{"picks without warp", Sett{PicksPerCm: 30}, true},
+		{"woven height without warp", Sett{WovenHeightCm: 15}, true},
+		{"picks per row without warp", Sett{PicksPerRow: 4}, false},
+		{"NaN ends", Sett{EndsPerCm: math.NaN()}, true},
+		{"NaN woven height", Sett{EndsPerCm: 20, WovenHeightCm: math.NaN()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sett.Validate(); (err != nil) != tt.wantError {
+				t.Errorf("Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSettRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		sett     Sett
+		expected int
+	}{
+		// 200 ends at 20/cm = 10 cm wide; a square image weaves 10 cm high
+		{"no sett", Sett{}, 0},
+		{"balanced", Sett{EndsPerCm: 20}, 200},
+		{"weft faced", Sett{EndsPerCm: 20, PicksPerCm: 40}, 400},
+		{"picks per row", Sett{EndsPerCm: 20, PicksPerCm: 40, PicksPerRow: 4}, 100},
+		{"picks per row without warp", Sett{PicksPerRow: 4}, 50},
+		{"woven width", Sett{WovenWidthCm: 5, PicksPerCm: 10}, 50},
+		{"woven height", Sett{EndsPerCm: 20, PicksPerCm: 30, WovenHeightCm: 2}, 60},
+		{"unvalidated", Sett{EndsPerCm: 1e300, WovenHeightCm: 1e300}, maxSettRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sett.Rows(200, 100, 100); got != tt.expected {
+				t.Errorf("Rows() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSettWovenSize(t *testing.T) {
+	width, height := Sett{EndsPerCm: 20, PicksPerCm: 40}.WovenSize(208, 400)
+	if math.Abs(width-10.4) > 1e-9 || math.Abs(height-10) > 1e-9 {
+		t.Errorf("WovenSize() = %g x %g, want 10.4 x 10", width, height)
+	}
+
+	width, height = Sett{}.WovenSize(208, 400)
+	if width != 0 || height != 0 {
+		t.Errorf("WovenSize() without sett = %g x %g, want 0 x 0", width, height)
+	}
+}
+
+func TestProcessWithSett(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createCheckerboardImage(16, 16, 2)); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	processor := NewProcessor(8, 0, TwoColor)
+	processor.Sett = Sett{EndsPerCm: 4, PicksPerCm: 6, PicksPerRow: 3}
+	matrix, err := processor.Process(&buf)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// 8 ends at 4/cm = 2 cm square, 12 picks woven as 4 rows of 3
+	if len(matrix) != 12 {
+		t.Fatalf("Matrix height = %d, want 12", len(matrix))
+	}
+	for y := 0; y < len(matrix); y += 3 {
+		for i := 1; i < 3; i++ {
+			for x := range matrix[y] {
+				if matrix[y+i][x] != matrix[y][x] {
+					t.Fatalf("row %d should repeat row %d", y+i, y)
+				}
+			}
+		}
+	}
+}
+
+func TestProcessPicksPerRowWithoutSett(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createCheckerboardImage(16, 16, 2)); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	// A square image keeps its 8 picks, woven as 4 rows of 2 instead of stretched to 16
+	processor := NewProcessor(8, 0, TwoColor)
+	processor.Sett = Sett{PicksPerRow: 2}
+	matrix, err := processor.Process(&buf)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(matrix) != 8 {
+		t.Fatalf("Matrix height = %d, want 8", len(matrix))
+	}
+}
+
+func TestProcessSettMaxCells(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createCheckerboardImage(10, 10, 2)); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	// 10 m woven at 200 picks per cm is 200000 picks, whatever the size of the image
+	sett := Sett{EndsPerCm: 200, WovenHeightCm: 1000}
+	if rows := sett.Rows(208, 10, 10); rows != 200000 {
+		t.Fatalf("Rows() = %d, want 200000", rows)
+	}
+	processor := NewProcessor(208, 0, TwoColor)
+	processor.Sett = sett
+	processor.MaxCells = 16000000
+	if _, err := processor.Process(&buf); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Process() error = %v, want ErrImageTooLarge", err)
+	}
+}
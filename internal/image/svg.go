@@ -0,0 +1,1272 @@
+package image
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SVG input
+//
+// Vector designs are rasterised straight at the target hook width instead of being
+// decoded from pixels, so edges land exactly on hooks. The supported subset is:
+//   - elements: svg, g, a, path, rect, circle, ellipse, line, polyline, polygon
+//   - paint: fill, stroke, stroke-width, stroke-linecap, fill-rule, opacity,
+//     fill-opacity, stroke-opacity, color/currentColor, as attributes or in style=""
+//   - transform: matrix, translate, scale, rotate, skewX, skewY
+//   - the root viewBox with the default xMidYMid meet or preserveAspectRatio="none"
+//
+// Text, images, <use>, clipping, masks and filters are ignored. Gradients and patterns
+// are painted with their fallback colour, or black when none is given. Stroke joins are
+// always round, and group opacity is multiplied into the children.
+
+// affine is a 2D affine transform: x' = a*x + c*y + e, y' = b*x + d*y + f
+type affine [6]float64
+
+var identityAffine = affine{1, 0, 0, 1, 0, 0}
+
+// mul returns the transform applying o first and then m
+func (m affine) mul(o affine) affine {
+	return affine{
+		m[0]*o[0] + m[2]*o[1],
+		m[1]*o[0] + m[3]*o[1],
+		m[0]*o[2] + m[2]*o[3],
+		m[1]*o[2] + m[3]*o[3],
+		m[0]*o[4] + m[2]*o[5] + m[4],
+		m[1]*o[4] + m[3]*o[5] + m[5],
+	}
+}
+
+// apply transforms a point
+func (m affine) apply(p Point) Point {
+	return Point{X: m[0]*p.X + m[2]*p.Y + m[4], Y: m[1]*p.X + m[3]*p.Y + m[5]}
+}
+
+// scale returns the average scale factor, used to pick flattening tolerances
+func (m affine) scale() float64 {
+	s := math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+	if s == 0 {
+		return 1
+	}
+	return s
+}
+
+// subpath is a flattened run of points, closed by "z" or by the shape itself
+type subpath struct {
+	points []Point
+	closed bool
+}
+
+// svgPaint is a fill or stroke paint
+type svgPaint struct {
+	none    bool
+	current bool // currentColor
+	rgb     [3]float64
+}
+
+// svgStyle holds the inherited presentation properties
+type svgStyle struct {
+	fill          svgPaint
+	stroke        svgPaint
+	color         [3]float64
+	fillOpacity   float64
+	strokeOpacity float64
+	opacity       float64
+	strokeWidth   float64
+	lineCap       string
+	evenOdd       bool
+	hidden        bool
+	ctm           affine
+}
+
+// IsSVG reports whether the content looks like an SVG document
+func IsSVG(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(trimmed) == 0 || trimmed[0] != '<' {
+		return false
+	}
+	head := trimmed
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
+// svgSize returns the document size in px, taken from width/height or else the viewBox
+func svgSize(data []byte) (float64, float64, error) {
+	root, err := svgRoot(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	attrs := attrMap(root)
+	viewBox, hasViewBox := parseViewBox(attrs["viewBox"])
+
+	width, widthOK := parseAbsoluteLength(attrs["width"])
+	height, heightOK := parseAbsoluteLength(attrs["height"])
+	switch {
+	case widthOK && heightOK:
+	case hasViewBox && widthOK:
+		height = width * viewBox[3] / viewBox[2]
+	case hasViewBox && heightOK:
+		width = height * viewBox[2] / viewBox[3]
+	case hasViewBox:
+		width, height = viewBox[2], viewBox[3]
+	default:
+		// The SVG default viewport size
+		width, height = 300, 150
+	}
+
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid SVG size: %gx%g", width, height)
+	}
+	return width, height, nil
+}
+
+// svgRoot returns the root <svg> element
+func svgRoot(data []byte) (xml.StartElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("failed to parse SVG: no <svg> element")
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local != "svg" {
+				return xml.StartElement{}, fmt.Errorf("failed to parse SVG: root element is <%s>", start.Name.Local)
+			}
+			return start, nil
+		}
+	}
+}
+
+// rasterizeSVG renders an SVG document into a width x height image with a transparent background
+// The document viewport is stretched to fill the image, so a sett-derived height keeps its aspect in cloth
+func rasterizeSVG(data []byte, width, height int, antialias bool) (*image.RGBA, error) {
+	docWidth, docHeight, err := svgSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid raster size: %dx%d", width, height)
+	}
+
+	canvas := newSVGCanvas(width, height, antialias)
+	viewport := affine{float64(width) / docWidth, 0, 0, float64(height) / docHeight, 0, 0}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	var stack []svgStyle
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SVG: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := attrMap(t)
+
+			var parent svgStyle
+			if len(stack) == 0 {
+				parent = defaultSVGStyle()
+				parent.ctm = viewport.mul(viewBoxTransform(attrs, docWidth, docHeight))
+			} else {
+				parent = stack[len(stack)-1]
+			}
+
+			if !svgElementDrawable(t.Name.Local) {
+				if err := decoder.Skip(); err != nil {
+					return nil, fmt.Errorf("failed to parse SVG: %w", err)
+				}
+				continue
+			}
+
+			style := parent.inherit(attrs)
+			if t.Name.Local == "svg" && len(stack) > 0 {
+				// Nested viewports are placed at their x/y offset
+				x, _ := parseLength(attrs["x"], 0)
+				y, _ := parseLength(attrs["y"], 0)
+				style.ctm = style.ctm.mul(affine{1, 0, 0, 1, x, y})
+			}
+			if !style.hidden {
+				canvas.drawShape(t.Name.Local, attrs, style)
+			}
+			stack = append(stack, style)
+
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return canvas.image(), nil
+}
+
+// svgElementDrawable reports whether an element is rendered (or may contain rendered children)
+func svgElementDrawable(name string) bool {
+	switch name {
+	case "svg", "g", "a", "switch", "path", "rect", "circle", "ellipse", "line", "polyline", "polygon":
+		return true
+	default:
+		return false
+	}
+}
+
+// viewBoxTransform maps the root viewBox onto the document viewport
+func viewBoxTransform(attrs map[string]string, docWidth, docHeight float64) affine {
+	viewBox, ok := parseViewBox(attrs["viewBox"])
+	if !ok {
+		return identityAffine
+	}
+
+	sx := docWidth / viewBox[2]
+	sy := docHeight / viewBox[3]
+	if strings.TrimSpace(attrs["preserveAspectRatio"]) == "none" {
+		return affine{sx, 0, 0, sy, -viewBox[0] * sx, -viewBox[1] * sy}
+	}
+
+	// xMidYMid meet: uniform scale, centred
+	s := math.Min(sx, sy)
+	tx := (docWidth-viewBox[2]*s)/2 - viewBox[0]*s
+	ty := (docHeight-viewBox[3]*s)/2 - viewBox[1]*s
+	return affine{s, 0, 0, s, tx, ty}
+}
+
+// defaultSVGStyle returns the initial property values
+func defaultSVGStyle() svgStyle {
+	return svgStyle{
+		fill:          svgPaint{},
+		stroke:        svgPaint{none: true},
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		strokeWidth:   1,
+		lineCap:       "butt",
+		ctm:           identityAffine,
+	}
+}
+
+// inherit returns the style of a child element with the given attributes
+func (s svgStyle) inherit(attrs map[string]string) svgStyle {
+	props := make(map[string]string)
+	for _, key := range []string{"fill", "stroke", "color", "fill-opacity", "stroke-opacity", "opacity",
+		"stroke-width", "stroke-linecap", "fill-rule", "display", "visibility"} {
+		if v, ok := attrs[key]; ok {
+			props[key] = v
+		}
+	}
+	// The style attribute overrides presentation attributes
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		if i := strings.IndexByte(decl, ':'); i > 0 {
+			props[strings.TrimSpace(decl[:i])] = strings.TrimSpace(decl[i+1:])
+		}
+	}
+
+	// Opacity is not inherited in SVG; multiplying it into the children approximates group opacity
+	if v, ok := parseNumber(props["opacity"]); ok {
+		s.opacity *= clamp01(v)
+	}
+	if v, ok := props["color"]; ok {
+		if p, ok := parsePaint(v); ok && !p.none && !p.current {
+			s.color = p.rgb
+		}
+	}
+	if v, ok := props["fill"]; ok {
+		if p, ok := parsePaint(v); ok {
+			s.fill = p
+		}
+	}
+	if v, ok := props["stroke"]; ok {
+		if p, ok := parsePaint(v); ok {
+			s.stroke = p
+		}
+	}
+	if v, ok := parseNumber(props["fill-opacity"]); ok {
+		s.fillOpacity = clamp01(v)
+	}
+	if v, ok := parseNumber(props["stroke-opacity"]); ok {
+		s.strokeOpacity = clamp01(v)
+	}
+	if v, ok := parseLength(props["stroke-width"], 0); ok && v >= 0 {
+		s.strokeWidth = v
+	}
+	switch props["stroke-linecap"] {
+	case "butt", "round", "square":
+		s.lineCap = props["stroke-linecap"]
+	}
+	switch props["fill-rule"] {
+	case "evenodd":
+		s.evenOdd = true
+	case "nonzero":
+		s.evenOdd = false
+	}
+	if props["display"] == "none" || props["visibility"] == "hidden" {
+		s.hidden = true
+	} else if props["visibility"] == "visible" {
+		s.hidden = false
+	}
+
+	if t, ok := attrs["transform"]; ok {
+		s.ctm = s.ctm.mul(parseTransformList(t))
+	}
+	return s
+}
+
+// paintColor resolves currentColor
+func (s svgStyle) paintColor(p svgPaint) [3]float64 {
+	if p.current {
+		return s.color
+	}
+	return p.rgb
+}
+
+// svgCanvas accumulates shapes into a premultiplied RGBA float buffer
+type svgCanvas struct {
+	width, height int
+	antialias     bool
+	pix           []float64 // 4 values per pixel, premultiplied
+	coverage      []float64
+}
+
+func newSVGCanvas(width, height int, antialias bool) *svgCanvas {
+	return &svgCanvas{
+		width:     width,
+		height:    height,
+		antialias: antialias,
+		pix:       make([]float64, width*height*4),
+		coverage:  make([]float64, width*height),
+	}
+}
+
+// drawShape fills and strokes one element
+func (c *svgCanvas) drawShape(name string, attrs map[string]string, style svgStyle) {
+	tolerance := 0.05 / style.ctm.scale()
+	paths := shapeSubpaths(name, attrs, tolerance)
+	if len(paths) == 0 {
+		return
+	}
+
+	// Lines have no interior; open polylines and paths are filled as if closed
+	if name != "line" && !style.fill.none && style.fillOpacity > 0 {
+		c.fill(transformSubpaths(paths, style.ctm), style.evenOdd, style.paintColor(style.fill), style.fillOpacity*style.opacity)
+	}
+	if !style.stroke.none && style.strokeWidth > 0 && style.strokeOpacity > 0 {
+		outline := strokeOutline(paths, style.strokeWidth/2, style.lineCap, tolerance)
+		c.fill(transformSubpaths(outline, style.ctm), false, style.paintColor(style.stroke), style.strokeOpacity*style.opacity)
+	}
+}
+
+// fill composites a polygon set with the given fill rule, colour and opacity
+func (c *svgCanvas) fill(paths []subpath, evenOdd bool, rgb [3]float64, opacity float64) {
+	if opacity <= 0 {
+		return
+	}
+	for i := range c.coverage {
+		c.coverage[i] = 0
+	}
+	c.rasterize(paths, evenOdd)
+
+	for i, cov := range c.coverage {
+		if cov <= 0 {
+			continue
+		}
+		a := math.Min(cov, 1) * opacity
+		p := c.pix[i*4 : i*4+4]
+		p[0] = rgb[0]*a + p[0]*(1-a)
+		p[1] = rgb[1]*a + p[1]*(1-a)
+		p[2] = rgb[2]*a + p[2]*(1-a)
+		p[3] = a + p[3]*(1-a)
+	}
+}
+
+// edge is a polygon edge oriented top to bottom
+type edge struct {
+	x0, y0, x1, y1 float64
+	dir            int
+}
+
+// crossing is where an edge crosses a scanline
+type crossing struct {
+	x   float64
+	dir int
+}
+
+// rasterize computes pixel coverage with a scanline fill
+// With anti-aliasing each pixel row is sampled on 4 sub-scanlines with exact horizontal
+// coverage; without it a pixel is either in or out depending on its centre
+func (c *svgCanvas) rasterize(paths []subpath, evenOdd bool) {
+	var edges []edge
+	for _, sp := range paths {
+		n := len(sp.points)
+		for i := 0; i < n; i++ {
+			a, b := sp.points[i], sp.points[(i+1)%n]
+			if a.Y == b.Y {
+				continue
+			}
+			if a.Y < b.Y {
+				edges = append(edges, edge{a.X, a.Y, b.X, b.Y, 1})
+			} else {
+				edges = append(edges, edge{b.X, b.Y, a.X, a.Y, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+
+	samples := 1
+	if c.antialias {
+		samples = 4
+	}
+	weight := 1 / float64(samples)
+
+	var crossings []crossing
+	for py := 0; py < c.height; py++ {
+		for s := 0; s < samples; s++ {
+			sy := float64(py) + (float64(s)+0.5)/float64(samples)
+
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if sy < e.y0 || sy >= e.y1 {
+					continue
+				}
+				x := e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0)
+				if math.IsInf(x, 0) || math.IsNaN(x) {
+					// Huge coordinates can still overflow once transformed
+					continue
+				}
+				crossings = append(crossings, crossing{x, e.dir})
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+			winding := 0
+			for i := 0; i+1 < len(crossings); i++ {
+				winding += crossings[i].dir
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside {
+					c.span(py, crossings[i].x, crossings[i+1].x, weight)
+				}
+			}
+		}
+	}
+}
+
+// span adds the coverage of [x0, x1) on one sub-scanline of pixel row py
+func (c *svgCanvas) span(py int, x0, x1, weight float64) {
+	row := c.coverage[py*c.width : (py+1)*c.width]
+	x0 = math.Max(0, x0)
+	x1 = math.Min(float64(c.width), x1)
+	if !(x0 < x1) {
+		return
+	}
+	if !c.antialias {
+		start := int(math.Ceil(x0 - 0.5))
+		end := int(math.Ceil(x1 - 0.5))
+		for px := start; px < end; px++ {
+			row[px] = 1
+		}
+		return
+	}
+
+	for px := int(x0); px < c.width && float64(px) < x1; px++ {
+		overlap := math.Min(x1, float64(px+1)) - math.Max(x0, float64(px))
+		if overlap > 0 {
+			row[px] += overlap * weight
+		}
+	}
+}
+
+// image converts the canvas to an RGBA image
+func (c *svgCanvas) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	for i := 0; i < c.width*c.height; i++ {
+		for k := 0; k < 4; k++ {
+			img.Pix[i*4+k] = uint8(math.Round(clamp01(c.pix[i*4+k]) * 255))
+		}
+	}
+	return img
+}
+
+// transformSubpaths maps user-space subpaths to device space
+func transformSubpaths(paths []subpath, m affine) []subpath {
+	result := make([]subpath, len(paths))
+	for i, sp := range paths {
+		points := make([]Point, len(sp.points))
+		for j, p := range sp.points {
+			points[j] = m.apply(p)
+		}
+		result[i] = subpath{points: points, closed: sp.closed}
+	}
+	return result
+}
+
+// strokeOutline builds polygons covering the stroke of the subpaths, all wound the same way
+// so they can be filled together with the nonzero rule
+func strokeOutline(paths []subpath, halfWidth float64, lineCap string, tolerance float64) []subpath {
+	var outline []subpath
+	add := func(points []Point) {
+		if signedArea(points) < 0 {
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+		outline = append(outline, subpath{points: points, closed: true})
+	}
+
+	for _, sp := range paths {
+		points := dedupePoints(sp.points)
+		if sp.closed && len(points) > 1 {
+			points = append(points, points[0])
+		}
+		if len(points) < 2 {
+			if len(points) == 1 && lineCap == "round" {
+				add(circlePolygon(points[0], halfWidth, tolerance))
+			}
+			continue
+		}
+
+		last := len(points) - 1
+		for i := 0; i < last; i++ {
+			a, b := points[i], points[i+1]
+			dx, dy := b.X-a.X, b.Y-a.Y
+			length := math.Hypot(dx, dy)
+			ux, uy := dx/length, dy/length
+			nx, ny := -uy*halfWidth, ux*halfWidth
+
+			// Square caps extend the first and last segments
+			if lineCap == "square" && !sp.closed {
+				if i == 0 {
+					a = Point{X: a.X - ux*halfWidth, Y: a.Y - uy*halfWidth}
+				}
+				if i == last-1 {
+					b = Point{X: b.X + ux*halfWidth, Y: b.Y + uy*halfWidth}
+				}
+			}
+
+			add([]Point{
+				{X: a.X + nx, Y: a.Y + ny},
+				{X: b.X + nx, Y: b.Y + ny},
+				{X: b.X - nx, Y: b.Y - ny},
+				{X: a.X - nx, Y: a.Y - ny},
+			})
+		}
+
+		// Round joins at every interior vertex, and round caps at the ends
+		for i, p := range points {
+			isEnd := !sp.closed && (i == 0 || i == last)
+			if isEnd && lineCap != "round" || sp.closed && i == last {
+				continue
+			}
+			add(circlePolygon(p, halfWidth, tolerance))
+		}
+	}
+
+	return outline
+}
+
+// dedupePoints drops consecutive duplicate points
+func dedupePoints(points []Point) []Point {
+	result := make([]Point, 0, len(points))
+	for _, p := range points {
+		if len(result) > 0 && result[len(result)-1] == p {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// signedArea returns the signed area of a polygon
+func signedArea(points []Point) float64 {
+	var area float64
+	for i := range points {
+		a, b := points[i], points[(i+1)%len(points)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
+
+// circlePolygon approximates a circle
+func circlePolygon(center Point, r, tolerance float64) []Point {
+	return ellipsePoints(center.X, center.Y, r, r, tolerance)
+}
+
+// ellipsePoints approximates an axis-aligned ellipse
+func ellipsePoints(cx, cy, rx, ry, tolerance float64) []Point {
+	n := arcSegments(2*math.Pi, math.Max(rx, ry), tolerance)
+	points := make([]Point, n)
+	for i := range points {
+		theta := 2 * math.Pi * float64(i) / float64(n)
+		points[i] = Point{X: cx + rx*math.Cos(theta), Y: cy + ry*math.Sin(theta)}
+	}
+	return points
+}
+
+// arcSegments returns how many chords approximate an arc within the tolerance
+func arcSegments(sweep, radius, tolerance float64) int {
+	if radius <= tolerance {
+		return 4
+	}
+	step := 2 * math.Acos(1-tolerance/radius)
+	n := int(math.Ceil(math.Abs(sweep) / step))
+	if n < 4 {
+		n = 4
+	}
+	if n > 512 {
+		n = 512
+	}
+	return n
+}
+
+// shapeSubpaths converts a shape element to flattened subpaths in user space
+func shapeSubpaths(name string, attrs map[string]string, tolerance float64) []subpath {
+	num := func(key string) float64 {
+		v, _ := parseLength(attrs[key], 0)
+		return v
+	}
+
+	switch name {
+	case "path":
+		return parsePathData(attrs["d"], tolerance)
+
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, rxOK := parseLength(attrs["rx"], 0)
+		ry, ryOK := parseLength(attrs["ry"], 0)
+		if !rxOK {
+			rx = ry
+		}
+		if !ryOK {
+			ry = rx
+		}
+		rx, ry = math.Min(math.Max(rx, 0), w/2), math.Min(math.Max(ry, 0), h/2)
+		if rx == 0 || ry == 0 {
+			return []subpath{{points: []Point{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}}, closed: true}}
+		}
+		var points []Point
+		corners := []struct{ cx, cy, start float64 }{
+			{x + w - rx, y + ry, -math.Pi / 2},
+			{x + w - rx, y + h - ry, 0},
+			{x + rx, y + h - ry, math.Pi / 2},
+			{x + rx, y + ry, math.Pi},
+		}
+		for _, corner := range corners {
+			n := arcSegments(math.Pi/2, math.Max(rx, ry), tolerance)
+			for i := 0; i <= n; i++ {
+				theta := corner.start + math.Pi/2*float64(i)/float64(n)
+				points = append(points, Point{X: corner.cx + rx*math.Cos(theta), Y: corner.cy + ry*math.Sin(theta)})
+			}
+		}
+		return []subpath{{points: points, closed: true}}
+
+	case "circle":
+		r := num("r")
+		if r <= 0 {
+			return nil
+		}
+		return []subpath{{points: ellipsePoints(num("cx"), num("cy"), r, r, tolerance), closed: true}}
+
+	case "ellipse":
+		rx, ry := num("rx"), num("ry")
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		return []subpath{{points: ellipsePoints(num("cx"), num("cy"), rx, ry, tolerance), closed: true}}
+
+	case "line":
+		return []subpath{{points: []Point{{X: num("x1"), Y: num("y1")}, {X: num("x2"), Y: num("y2")}}}}
+
+	case "polyline", "polygon":
+		values := parseNumberList(attrs["points"])
+		var points []Point
+		for i := 0; i+1 < len(values); i += 2 {
+			points = append(points, Point{X: values[i], Y: values[i+1]})
+		}
+		if len(points) < 2 {
+			return nil
+		}
+		return []subpath{{points: points, closed: name == "polygon"}}
+	}
+
+	return nil
+}
+
+// pathScanner tokenizes path data
+type pathScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *pathScanner) skipSeparators() {
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+// command returns the next command letter, if the next token is one
+func (sc *pathScanner) command() (byte, bool) {
+	sc.skipSeparators()
+	if sc.pos < len(sc.s) {
+		c := sc.s[sc.pos]
+		if (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') && c != 'e' && c != 'E' {
+			sc.pos++
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// hasNumber reports whether a number follows
+func (sc *pathScanner) hasNumber() bool {
+	sc.skipSeparators()
+	if sc.pos >= len(sc.s) {
+		return false
+	}
+	c := sc.s[sc.pos]
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.'
+}
+
+// number reads a number; "1.5.5" is two numbers and "-1-2" is two numbers
+func (sc *pathScanner) number() (float64, bool) {
+	if !sc.hasNumber() {
+		return 0, false
+	}
+	start := sc.pos
+	if sc.s[sc.pos] == '-' || sc.s[sc.pos] == '+' {
+		sc.pos++
+	}
+	seenDot, seenDigit := false, false
+	for sc.pos < len(sc.s) {
+		c := sc.s[sc.pos]
+		switch {
+		case c >= '0' && c <= '9':
+			seenDigit = true
+		case c == '.' && !seenDot:
+			seenDot = true
+		case (c == 'e' || c == 'E') && seenDigit:
+			sc.pos++
+			if sc.pos < len(sc.s) && (sc.s[sc.pos] == '-' || sc.s[sc.pos] == '+') {
+				sc.pos++
+			}
+			for sc.pos < len(sc.s) && sc.s[sc.pos] >= '0' && sc.s[sc.pos] <= '9' {
+				sc.pos++
+			}
+			return parseFloat(sc.s[start:sc.pos])
+		default:
+			return parseFloat(sc.s[start:sc.pos])
+		}
+		sc.pos++
+	}
+	return parseFloat(sc.s[start:sc.pos])
+}
+
+// flag reads an arc flag, which may be written without a separator ("a1 1 0 11 5 5")
+func (sc *pathScanner) flag() (bool, bool) {
+	sc.skipSeparators()
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '0' || sc.s[sc.pos] == '1') {
+		sc.pos++
+		return sc.s[sc.pos-1] == '1', true
+	}
+	return false, false
+}
+
+// numbers reads n numbers
+func (sc *pathScanner) numbers(n int) ([]float64, bool) {
+	values := make([]float64, n)
+	for i := range values {
+		v, ok := sc.number()
+		if !ok {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// parsePathData flattens SVG path data into subpaths
+// Parsing stops at the first error, keeping what was drawn so far, as SVG renderers do
+func parsePathData(d string, tolerance float64) []subpath {
+	sc := &pathScanner{s: d}
+	var paths []subpath
+	var current []Point
+	var cur, start, lastCtrl Point
+	var lastCmd byte
+
+	flush := func(closed bool) {
+		if len(current) > 0 {
+			paths = append(paths, subpath{points: current, closed: closed})
+		}
+		current = nil
+	}
+	lineTo := func(p Point) {
+		if len(current) == 0 {
+			current = append(current, cur)
+		}
+		current = append(current, p)
+		cur = p
+	}
+
+	var cmd byte
+	for {
+		if c, ok := sc.command(); ok {
+			cmd = c
+		} else if cmd == 0 || !sc.hasNumber() {
+			break
+		}
+
+		rel := cmd >= 'a'
+		offset := func(p Point) Point {
+			if rel {
+				return Point{X: cur.X + p.X, Y: cur.Y + p.Y}
+			}
+			return p
+		}
+
+		switch cmd | 0x20 {
+		case 'm':
+			v, ok := sc.numbers(2)
+			if !ok {
+				flush(false)
+				return paths
+			}
+			flush(false)
+			cur = offset(Point{X: v[0], Y: v[1]})
+			start = cur
+			current = []Point{cur}
+			// Further pairs after a moveto are linetos
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+			lastCmd = 'm'
+			continue
+
+		case 'z':
+			flush(true)
+			cur = start
+			lastCmd = 'z'
+			cmd = 0
+			continue
+
+		case 'l':
+			v, ok := sc.numbers(2)
+			if !ok {
+				flush(false)
+				return paths
+			}
+			lineTo(offset(Point{X: v[0], Y: v[1]}))
+
+		case 'h':
+			v, ok := sc.number()
+			if !ok {
+				flush(false)
+				return paths
+			}
+			if rel {
+				v += cur.X
+			}
+			lineTo(Point{X: v, Y: cur.Y})
+
+		case 'v':
+			v, ok := sc.number()
+			if !ok {
+				flush(false)
+				return paths
+			}
+			if rel {
+				v += cur.Y
+			}
+			lineTo(Point{X: cur.X, Y: v})
+
+		case 'c', 's':
+			var c1 Point
+			var rest []float64
+			if cmd|0x20 == 'c' {
+				v, ok := sc.numbers(6)
+				if !ok {
+					flush(false)
+					return paths
+				}
+				c1 = offset(Point{X: v[0], Y: v[1]})
+				rest = v[2:]
+			} else {
+				v, ok := sc.numbers(4)
+				if !ok {
+					flush(false)
+					return paths
+				}
+				c1 = cur
+				if lastCmd == 'c' || lastCmd == 's' {
+					c1 = Point{X: 2*cur.X - lastCtrl.X, Y: 2*cur.Y - lastCtrl.Y}
+				}
+				rest = v
+			}
+			c2 := offset(Point{X: rest[0], Y: rest[1]})
+			end := offset(Point{X: rest[2], Y: rest[3]})
+			for _, p := range flattenCubic(cur, c1, c2, end, tolerance) {
+				lineTo(p)
+			}
+			lastCtrl = c2
+
+		case 'q', 't':
+			var ctrl Point
+			var end Point
+			if cmd|0x20 == 'q' {
+				v, ok := sc.numbers(4)
+				if !ok {
+					flush(false)
+					return paths
+				}
+				ctrl = offset(Point{X: v[0], Y: v[1]})
+				end = offset(Point{X: v[2], Y: v[3]})
+			} else {
+				v, ok := sc.numbers(2)
+				if !ok {
+					flush(false)
+					return paths
+				}
+				ctrl = cur
+				if lastCmd == 'q' || lastCmd == 't' {
+					ctrl = Point{X: 2*cur.X - lastCtrl.X, Y: 2*cur.Y - lastCtrl.Y}
+				}
+				end = offset(Point{X: v[0], Y: v[1]})
+			}
+			// Elevate the quadratic to a cubic
+			c1 := Point{X: cur.X + 2.0/3*(ctrl.X-cur.X), Y: cur.Y + 2.0/3*(ctrl.Y-cur.Y)}
+			c2 := Point{X: end.X + 2.0/3*(ctrl.X-end.X), Y: end.Y + 2.0/3*(ctrl.Y-end.Y)}
+			for _, p := range flattenCubic(cur, c1, c2, end, tolerance) {
+				lineTo(p)
+			}
+			lastCtrl = ctrl
+
+		case 'a':
+			radii, ok := sc.numbers(3)
+			large, ok1 := sc.flag()
+			sweep, ok2 := sc.flag()
+			end, ok3 := sc.numbers(2)
+			if !ok || !ok1 || !ok2 || !ok3 {
+				flush(false)
+				return paths
+			}
+			for _, p := range flattenArc(cur, radii[0], radii[1], radii[2], large, sweep, offset(Point{X: end[0], Y: end[1]}), tolerance) {
+				lineTo(p)
+			}
+
+		default:
+			flush(false)
+			return paths
+		}
+		lastCmd = cmd | 0x20
+	}
+
+	flush(false)
+	return paths
+}
+
+// flattenCubic approximates a cubic Bézier with line segments, excluding the start point
+func flattenCubic(p0, p1, p2, p3 Point, tolerance float64) []Point {
+	length := math.Hypot(p1.X-p0.X, p1.Y-p0.Y) + math.Hypot(p2.X-p1.X, p2.Y-p1.Y) + math.Hypot(p3.X-p2.X, p3.Y-p2.Y)
+	n := int(math.Ceil(math.Sqrt(length / tolerance)))
+	if n < 1 {
+		n = 1
+	}
+	if n > 256 {
+		n = 256
+	}
+
+	points := make([]Point, n)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		mt := 1 - t
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		points[i-1] = Point{
+			X: a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+			Y: a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+		}
+	}
+	return points
+}
+
+// flattenArc approximates an elliptical arc with line segments, excluding the start point
+// It follows the endpoint-to-centre conversion in the SVG implementation notes
+func flattenArc(from Point, rx, ry, rotation float64, large, sweep bool, to Point, tolerance float64) []Point {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if from == to {
+		return nil
+	}
+	if rx == 0 || ry == 0 {
+		return []Point{to}
+	}
+
+	phi := rotation * math.Pi / 180
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (from.X-to.X)/2, (from.Y-to.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// Scale up radii that are too small to reach the end point
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (from.X+to.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (from.Y+to.Y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta1 := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	n := arcSegments(delta, math.Max(rx, ry), tolerance)
+	points := make([]Point, n)
+	for i := 1; i <= n; i++ {
+		theta := theta1 + delta*float64(i)/float64(n)
+		ex, ey := rx*math.Cos(theta), ry*math.Sin(theta)
+		points[i-1] = Point{X: cosPhi*ex - sinPhi*ey + cx, Y: sinPhi*ex + cosPhi*ey + cy}
+	}
+	points[n-1] = to
+	return points
+}
+
+// parseTransformList parses a transform attribute
+func parseTransformList(s string) affine {
+	m := identityAffine
+	for {
+		open := strings.IndexByte(s, '(')
+		close := strings.IndexByte(s, ')')
+		if open < 0 || close < open {
+			return m
+		}
+		name := strings.TrimSpace(strings.Trim(s[:open], " \t\r\n,"))
+		args := parseNumberList(s[open+1 : close])
+		s = s[close+1:]
+
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+
+		var t affine
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				continue
+			}
+			t = affine{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case "translate":
+			t = affine{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = affine{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			cos, sin := math.Cos(a), math.Sin(a)
+			t = affine{1, 0, 0, 1, cx, cy}.mul(affine{cos, sin, -sin, cos, 0, 0}).mul(affine{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = affine{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = affine{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.mul(t)
+	}
+}
+
+// parseNumberList parses a list of numbers separated by whitespace and/or commas
+func parseNumberList(s string) []float64 {
+	sc := &pathScanner{s: s}
+	var values []float64
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return values
+		}
+		values = append(values, v)
+	}
+}
+
+// parseViewBox parses a viewBox attribute
+func parseViewBox(s string) ([4]float64, bool) {
+	values := parseNumberList(s)
+	if len(values) != 4 || values[2] <= 0 || values[3] <= 0 {
+		return [4]float64{}, false
+	}
+	return [4]float64{values[0], values[1], values[2], values[3]}, true
+}
+
+// parseNumber parses a plain number
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	return parseFloat(s)
+}
+
+// parseFloat parses a finite number; ParseFloat also accepts "Inf" and "NaN", which would
+// leave the rasteriser with coordinates it cannot fill
+func parseFloat(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && !math.IsInf(v, 0) && !math.IsNaN(v)
+}
+
+// Length units in px (CSS 96 dpi)
+var svgUnits = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"mm": 96 / 25.4,
+	"cm": 96 / 2.54,
+	"in": 96,
+}
+
+// parseLength parses a length with an optional unit; percentages are taken of ref
+func parseLength(s string, ref float64) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, ok := parseNumber(strings.TrimSuffix(s, "%"))
+		return v * ref / 100, ok && ref > 0
+	}
+	for unit, scale := range svgUnits {
+		if unit != "" && strings.HasSuffix(s, unit) {
+			v, ok := parseNumber(strings.TrimSuffix(s, unit))
+			return v * scale, ok
+		}
+	}
+	return parseNumber(s)
+}
+
+// parseAbsoluteLength parses a positive length that is not a percentage
+func parseAbsoluteLength(s string) (float64, bool) {
+	if strings.HasSuffix(strings.TrimSpace(s), "%") {
+		return 0, false
+	}
+	v, ok := parseLength(s, 0)
+	return v, ok && v > 0
+}
+
+// Named colours supported in paint values
+var svgNamedColors = map[string][3]uint8{
+	"black":   {0, 0, 0},
+	"white":   {255, 255, 255},
+	"gray":    {128, 128, 128},
+	"grey":    {128, 128, 128},
+	"silver":  {192, 192, 192},
+	"red":     {255, 0, 0},
+	"maroon":  {128, 0, 0},
+	"green":   {0, 128, 0},
+	"lime":    {0, 255, 0},
+	"blue":    {0, 0, 255},
+	"navy":    {0, 0, 128},
+	"yellow":  {255, 255, 0},
+	"olive":   {128, 128, 0},
+	"aqua":    {0, 255, 255},
+	"cyan":    {0, 255, 255},
+	"teal":    {0, 128, 128},
+	"fuchsia": {255, 0, 255},
+	"magenta": {255, 0, 255},
+	"purple":  {128, 0, 128},
+	"orange":  {255, 165, 0},
+}
+
+// parsePaint parses a fill or stroke value; ok is false for values that should be ignored
+func parsePaint(s string) (svgPaint, bool) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "none" || lower == "transparent":
+		return svgPaint{none: true}, true
+	case lower == "currentcolor":
+		return svgPaint{current: true}, true
+	case strings.HasPrefix(lower, "url("):
+		// Gradients and patterns fall back to the colour after the reference, or black
+		if end := strings.IndexByte(s, ')'); end >= 0 {
+			if fallback, ok := parsePaint(s[end+1:]); ok {
+				return fallback, true
+			}
+		}
+		return svgPaint{}, true
+	case strings.HasPrefix(lower, "#"):
+		c, err := ParseHexColor(s)
+		if err != nil {
+			return svgPaint{}, false
+		}
+		return rgbPaint(c.R, c.G, c.B), true
+	case strings.HasPrefix(lower, "rgb(") && strings.HasSuffix(lower, ")"):
+		parts := strings.Split(lower[4:len(lower)-1], ",")
+		if len(parts) != 3 {
+			return svgPaint{}, false
+		}
+		var rgb [3]uint8
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			scale := 1.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = strings.TrimSuffix(part, "%"), 2.55
+			}
+			v, ok := parseNumber(part)
+			if !ok {
+				return svgPaint{}, false
+			}
+			rgb[i] = uint8(math.Max(0, math.Min(255, math.Round(v*scale))))
+		}
+		return rgbPaint(rgb[0], rgb[1], rgb[2]), true
+	}
+
+	if c, ok := svgNamedColors[lower]; ok {
+		return rgbPaint(c[0], c[1], c[2]), true
+	}
+	return svgPaint{}, false
+}
+
+// rgbPaint builds a solid paint from 8-bit channels
+func rgbPaint(r, g, b uint8) svgPaint {
+	return svgPaint{rgb: [3]float64{float64(r) / 255, float64(g) / 255, float64(b) / 255}}
+}
+
+// attrMap collects the attributes of an element by local name
+func attrMap(start xml.StartElement) map[string]string {
+	attrs := make(map[string]string, len(start.Attr))
+	for _, a := range start.Attr {
+		attrs[a.Name.Local] = a.Value
+	}
+	return attrs
+}
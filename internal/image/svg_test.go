@@ -0,0 +1,291 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"testing"
+)
+
+// svgDoc wraps shapes in an SVG document of the given size
+func svgDoc(width, height int, body string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">%s</svg>`, width, height, body))
+}
+
+// alphaAt returns the alpha of a rendered pixel as 0-1
+func alphaAt(img *image.RGBA, x, y int) float64 {
+	return float64(img.RGBAAt(x, y).A) / 255
+}
+
+// coveredArea sums the alpha of all pixels
+func coveredArea(img *image.RGBA) float64 {
+	var area float64
+	for i := 3; i < len(img.Pix); i += 4 {
+		area += float64(img.Pix[i]) / 255
+	}
+	return area
+}
+
+func TestIsSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"plain", `<svg xmlns="http://www.w3.org/2000/svg"/>`, true},
+		{"xml declaration", "\ufeff  <?xml version=\"1.0\"?>\n<!-- logo -->\n<svg/>", true},
+		{"html", `<html><body></body></html>`, false},
+		{"png", "\x89PNG\r\n", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSVG([]byte(tt.data)); got != tt.expected {
+				t.Errorf("IsSVG() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSVGSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		root          string
+		width, height float64
+	}{
+		{"width and height", `<svg width="200" height="100">`, 200, 100},
+		{"units", `<svg width="10mm" height="1in">`, 96 / 2.54, 96},
+		{"viewBox only", `<svg viewBox="0 0 40 30">`, 40, 30},
+		{"width and viewBox", `<svg width="80" viewBox="0 0 40 30">`, 80, 60},
+		{"percentages", `<svg width="100%" height="100%" viewBox="0 0 40 30">`, 40, 30},
+		{"default", `<svg>`, 300, 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, err := svgSize([]byte(tt.root + "</svg>"))
+			if err != nil {
+				t.Fatalf("svgSize() error = %v", err)
+			}
+			if math.Abs(width-tt.width) > 1e-9 || math.Abs(height-tt.height) > 1e-9 {
+				t.Errorf("svgSize() = %gx%g, want %gx%g", width, height, tt.width, tt.height)
+			}
+		})
+	}
+
+	if _, _, err := svgSize([]byte(`<html></html>`)); err == nil {
+		t.Error("svgSize() should reject documents without an <svg> root")
+	}
+}
+
+func TestRasterizeSVGShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		area float64 // Expected covered area in pixels
+	}{
+		{"rect", `<rect x="2" y="2" width="10" height="5"/>`, 50},
+		{"circle", `<circle cx="10" cy="10" r="6"/>`, math.Pi * 36},
+		{"ellipse", `<ellipse cx="10" cy="10" rx="8" ry="4"/>`, math.Pi * 32},
+		{"polygon", `<polygon points="0,0 20,0 0,20"/>`, 200},
+		{"path", `<path d="M2 2 h10 v10 h-10 z"/>`, 100},
+		{"relative path", `<path d="m2,2 l10,0 0,10 -10,0z"/>`, 100},
+		{"cubic", `<path d="M0 10 C0 -3.3333 20 -3.3333 20 10 Z"/>`, 160},
+		{"arc", `<path d="M4 10 A6 6 0 0 1 16 10 A6 6 0 0 1 4 10 Z"/>`, math.Pi * 36},
+		{"rounded rect", `<rect x="0" y="0" width="20" height="20" rx="5"/>`, 400 - (4-math.Pi)*25},
+		{"stroke", `<line x1="0" y1="10" x2="20" y2="10" stroke="black" stroke-width="4"/>`, 80},
+		{"fill none", `<rect width="20" height="20" fill="none"/>`, 0},
+		{"display none", `<g style="display: none"><rect width="20" height="20"/></g>`, 0},
+		{"ignored elements", `<defs><rect id="r" width="20" height="20"/></defs><text>hi</text>`, 0},
+		{"transform", `<g transform="translate(5,5) scale(2)"><rect width="4" height="3"/></g>`, 48},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := rasterizeSVG(svgDoc(20, 20, tt.body), 20, 20, true)
+			if err != nil {
+				t.Fatalf("rasterizeSVG() error = %v", err)
+			}
+			if got := coveredArea(img); math.Abs(got-tt.area) > math.Max(1, tt.area*0.02) {
+				t.Errorf("covered area = %.2f, want %.2f", got, tt.area)
+			}
+		})
+	}
+}
+
+func TestRasterizeSVGFillRule(t *testing.T) {
+	// Two squares wound the same way: nonzero fills the hole, evenodd leaves it open
+	ring := `M0 0 H20 V20 H0 Z M5 5 H15 V15 H5 Z`
+
+	nonzero, _ := rasterizeSVG(svgDoc(20, 20, `<path d="`+ring+`"/>`), 20, 20, false)
+	if alphaAt(nonzero, 10, 10) != 1 {
+		t.Error("nonzero fill should cover the inner square")
+	}
+
+	evenodd, _ := rasterizeSVG(svgDoc(20, 20, `<path fill-rule="evenodd" d="`+ring+`"/>`), 20, 20, false)
+	if alphaAt(evenodd, 10, 10) != 0 || alphaAt(evenodd, 2, 2) != 1 {
+		t.Error("evenodd fill should leave the inner square empty")
+	}
+}
+
+func TestRasterizeSVGAntialias(t *testing.T) {
+	doc := svgDoc(10, 10, `<rect x="0" y="0" width="4.5" height="10"/>`)
+
+	aliased, _ := rasterizeSVG(doc, 10, 10, false)
+	for x := 0; x < 10; x++ {
+		if a := alphaAt(aliased, x, 5); a != 0 && a != 1 {
+			t.Fatalf("without anti-aliasing pixel %d has alpha %g", x, a)
+		}
+	}
+
+	smooth, _ := rasterizeSVG(doc, 10, 10, true)
+	if a := alphaAt(smooth, 4, 5); math.Abs(a-0.5) > 0.01 {
+		t.Errorf("anti-aliased edge pixel alpha = %g, want 0.5", a)
+	}
+}
+
+func TestRasterizeSVGNonFinite(t *testing.T) {
+	// Non-finite numbers, given or reached by a transform, once left a span loop running forever
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Inf width", `<rect x="0" y="0" width="Inf" height="5"/>`},
+		{"NaN coordinate", `<rect x="NaN" y="0" width="5" height="5"/>`},
+		{"Inf in points", `<polygon points="0,0 Inf,0 0,5"/>`},
+		{"Inf in path data", `<path d="M0 0 H+Inf V5 H0 Z"/>`},
+		{"overflowing transform", `<rect width="1e300" height="5" transform="scale(1e300)"/>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, antialias := range []bool{false, true} {
+				if _, err := rasterizeSVG(svgDoc(10, 10, tt.body), 10, 10, antialias); err != nil {
+					t.Errorf("rasterizeSVG() error = %v", err)
+				}
+			}
+		})
+	}
+
+	for _, s := range []string{"Inf", "-inf", "NaN", "1e999"} {
+		if _, ok := parseNumber(s); ok {
+			t.Errorf("parseNumber(%q) should fail", s)
+		}
+		if _, ok := parseLength(s+"px", 10); ok {
+			t.Errorf("parseLength(%q) should fail", s+"px")
+		}
+	}
+}
+
+func TestRasterizeSVGViewBox(t *testing.T) {
+	// The viewBox maps 0-10 user units onto a 20 px wide raster
+	doc := []byte(`<svg viewBox="0 0 10 5"><rect x="5" width="5" height="5" fill="#fff" stroke="none"/></svg>`)
+	img, err := rasterizeSVG(doc, 20, 10, false)
+	if err != nil {
+		t.Fatalf("rasterizeSVG() error = %v", err)
+	}
+	if alphaAt(img, 5, 5) != 0 || alphaAt(img, 15, 5) != 1 {
+		t.Error("rect should cover the right half of the raster")
+	}
+	if c := img.RGBAAt(15, 5); c.R != 255 || c.G != 255 || c.B != 255 {
+		t.Errorf("fill colour = %v, want white", c)
+	}
+}
+
+func TestParsePathDataNumbers(t *testing.T) {
+	// Compact number syntax: implicit separators before signs and second decimal points
+	paths := parsePathData("M0,0L10-5.5.5.5z", 0.1)
+	if len(paths) != 1 || !paths[0].closed {
+		t.Fatalf("parsePathData() = %v, want one closed subpath", paths)
+	}
+	want := []Point{{X: 0, Y: 0}, {X: 10, Y: -5.5}, {X: 0.5, Y: 0.5}}
+	got := paths[0].points
+	if len(got) != len(want) {
+		t.Fatalf("points = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("point %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseTransformList(t *testing.T) {
+	m := parseTransformList("translate(10 20) rotate(90) scale(2, 3)")
+	p := m.apply(Point{X: 1, Y: 1})
+	// scale -> (2, 3), rotate 90 -> (-3, 2), translate -> (7, 22)
+	if math.Abs(p.X-7) > 1e-9 || math.Abs(p.Y-22) > 1e-9 {
+		t.Errorf("transformed point = %v, want (7, 22)", p)
+	}
+}
+
+func TestParsePaint(t *testing.T) {
+	tests := []struct {
+		input string
+		rgb   [3]float64
+		none  bool
+		ok    bool
+	}{
+		{"#ff0000", [3]float64{1, 0, 0}, false, true},
+		{"rgb(0, 255, 0)", [3]float64{0, 1, 0}, false, true},
+		{"rgb(0%, 0%, 100%)", [3]float64{0, 0, 1}, false, true},
+		{"white", [3]float64{1, 1, 1}, false, true},
+		{"none", [3]float64{}, true, true},
+		{"url(#grad) #fff", [3]float64{1, 1, 1}, false, true},
+		{"url(#grad)", [3]float64{}, false, true},
+		{"notacolour", [3]float64{}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			paint, ok := parsePaint(tt.input)
+			if ok != tt.ok || paint.none != tt.none || paint.rgb != tt.rgb {
+				t.Errorf("parsePaint() = %+v, %v; want rgb %v none %v ok %v", paint, ok, tt.rgb, tt.none, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProcessSVG(t *testing.T) {
+	// A black square in the middle of a transparent 16x16 document
+	doc := svgDoc(16, 16, `<rect x="4" y="4" width="8" height="8"/>`)
+
+	processor := NewProcessor(8, 0, TwoColor)
+	processor.Antialias = false
+	matrix, err := processor.Process(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(matrix) != 8 || len(matrix[0]) != 8 {
+		t.Fatalf("Matrix size = %dx%d, want 8x8", len(matrix[0]), len(matrix))
+	}
+	for y := range matrix {
+		for x := range matrix[y] {
+			want := 0
+			if x >= 2 && x < 6 && y >= 2 && y < 6 {
+				want = 1
+			}
+			if matrix[y][x] != want {
+				t.Fatalf("matrix[%d][%d] = %d, want %d", y, x, matrix[y][x], want)
+			}
+		}
+	}
+
+	// The sett sets the pick count: 8 ends at 4/cm is 2 cm, woven at 8 picks/cm is 16 picks
+	processor.Sett = Sett{EndsPerCm: 4, PicksPerCm: 8}
+	matrix, err = processor.Process(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(matrix) != 16 {
+		t.Errorf("Matrix height with sett = %d, want 16", len(matrix))
+	}
+
+	processor.Frame = 1
+	if _, err := processor.Process(bytes.NewReader(doc)); err == nil {
+		t.Error("Process() should reject frames beyond the first for SVG")
+	}
+}
@@ -0,0 +1,92 @@
+package image
+
+import (
+	"fmt"
+	"image"
+)
+
+// TileMode makes an image repeat vertically, for chains laced into an endless loop where the
+// last pick is followed by the first
+type TileMode string
+
+const (
+	TileNone   TileMode = ""       // The image is woven as it is
+	TileOffset TileMode = "offset" // Roll the image down, so rows that were neighbours meet at the join
+	TileBlend  TileMode = "blend"  // Crossfade the bottom rows into the top ones, shortening the image
+	TileMirror TileMode = "mirror" // Follow the image by its mirror image, doubling its length
+)
+
+// ValidateTileMode checks if the tile mode is supported
+func ValidateTileMode(mode string) error {
+	switch TileMode(mode) {
+	case TileNone, TileOffset, TileBlend, TileMirror:
+		return nil
+	default:
+		return fmt.Errorf("invalid tile mode: %s (must be 'offset', 'blend', or 'mirror')", mode)
+	}
+}
+
+// tileRows returns the rows a tile mode rolls or blends in an image of the given height:
+// rows when set, otherwise half the height for offset and an eighth for blend
+func tileRows(mode TileMode, rows, height int) int {
+	if rows <= 0 {
+		switch mode {
+		case TileOffset:
+			rows = height / 2
+		case TileBlend:
+			rows = height / 8
+		}
+	}
+	if mode == TileBlend && rows > height/2 {
+		rows = height / 2
+	}
+	return rows % height
+}
+
+// tile makes a grayscale image repeat vertically
+// Offset moves the last rows to the top, so the seam of the image leaves the join for the middle;
+// blend fades the last rows into the first and drops them, so the new last row leads into the first;
+// mirror appends the image upside down, so each end of the chain meets its own first or last row
+func tile(img *image.Gray, mode TileMode, rows int) *image.Gray {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if mode == TileNone || height < 2 {
+		return img
+	}
+	row := func(y int) []uint8 {
+		start := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		return img.Pix[start : start+width]
+	}
+
+	n := tileRows(mode, rows, height)
+	var out *image.Gray
+	switch mode {
+	case TileOffset:
+		out = image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			copy(out.Pix[y*out.Stride:], row((y-n+height)%height))
+		}
+	case TileBlend:
+		out = image.NewGray(image.Rect(0, 0, width, height-n))
+		for y := 0; y < height-n; y++ {
+			copy(out.Pix[y*out.Stride:], row(y))
+		}
+		// The first n rows shade from the dropped last rows into their own
+		for y := 0; y < n; y++ {
+			t := (float64(y) + 0.5) / float64(n)
+			top, bottom := row(y), row(height-n+y)
+			for x := 0; x < width; x++ {
+				out.Pix[y*out.Stride+x] = uint8(float64(bottom[x])*(1-t) + float64(top[x])*t + 0.5)
+			}
+		}
+	case TileMirror:
+		out = image.NewGray(image.Rect(0, 0, width, 2*height))
+		for y := 0; y < height; y++ {
+			copy(out.Pix[y*out.Stride:], row(y))
+			copy(out.Pix[(2*height-1-y)*out.Stride:], row(y))
+		}
+	default:
+		return img
+	}
+	return out
+}
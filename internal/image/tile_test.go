@@ -0,0 +1,66 @@
+package image
+
+import (
+	"image"
+	"testing"
+)
+
+// rowsImage returns a one-pixel-wide image with the given row values
+func rowsImage(values ...uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 1, len(values)))
+	copy(img.Pix, values)
+	return img
+}
+
+func TestTile(t *testing.T) {
+	tests := []struct {
+		name string
+		mode TileMode
+		rows int
+		want []uint8
+	}{
+		{"none", TileNone, 0, []uint8{0, 40, 80, 120}},
+		{"offset half", TileOffset, 0, []uint8{80, 120, 0, 40}},
+		{"offset one row", TileOffset, 1, []uint8{120, 0, 40, 80}},
+		{"blend two rows", TileBlend, 2, []uint8{60, 60}},
+		{"mirror", TileMirror, 0, []uint8{0, 40, 80, 120, 120, 80, 40, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tile(rowsImage(0, 40, 80, 120), tt.mode, tt.rows)
+			if string(got.Pix) != string(tt.want) {
+				t.Errorf("tile() = %v, want %v", got.Pix, tt.want)
+			}
+		})
+	}
+}
+
+func TestTileBlendIsSeamless(t *testing.T) {
+	// A ramp has its largest step at the join; blending leaves every step small
+	values := make([]uint8, 64)
+	for y := range values {
+		values[y] = uint8(y * 4)
+	}
+	got := tile(rowsImage(values...), TileBlend, 16)
+	if got.Bounds().Dy() != 48 {
+		t.Fatalf("tile() height = %d, want 48", got.Bounds().Dy())
+	}
+	for y := range got.Pix {
+		next := got.Pix[(y+1)%len(got.Pix)]
+		if d := int(next) - int(got.Pix[y]); d > 16 || d < -16 {
+			t.Errorf("rows %d and %d differ by %d", y, (y+1)%len(got.Pix), d)
+		}
+	}
+}
+
+func TestValidateTileMode(t *testing.T) {
+	for _, mode := range []string{"", "offset", "blend", "mirror"} {
+		if err := ValidateTileMode(mode); err != nil {
+			t.Errorf("ValidateTileMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := ValidateTileMode("wrap"); err == nil {
+		t.Error("ValidateTileMode(\"wrap\") should fail")
+	}
+}
@@ -0,0 +1,160 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token-bucket limiter with one bucket per client key
+// Each bucket holds up to Burst tokens and refills at Rate tokens per second;
+// a request takes one token and is refused when the bucket is empty.
+type RateLimiter struct {
+	Rate  float64 // Tokens added per second
+	Burst int     // Bucket size: requests a client may make at once
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate requests per second per client, in bursts of up to burst
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the client's bucket
+// When the bucket is empty it returns false and how long until the next token is available
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweepIdle(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweepIdle drops buckets that have refilled completely, at most once a minute
+// A full bucket behaves exactly like a new one, so forgetting it changes nothing
+func (l *RateLimiter) sweepIdle(now time.Time) {
+	if now.Sub(l.sweep) < time.Minute {
+		return
+	}
+	l.sweep = now
+	full := time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Clients returns the number of clients currently tracked
+func (l *RateLimiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// ErrQueueTimeout is returned when no slot became free within the queue timeout
+var ErrQueueTimeout = errors.New("timed out waiting for a free slot")
+
+// Pool bounds how many requests run at once, queueing the rest for up to QueueTimeout
+type Pool struct {
+	QueueTimeout time.Duration
+
+	slots   chan struct{}
+	mu      sync.Mutex
+	waiting int
+}
+
+// NewPool creates a pool running at most size requests at once
+func NewPool(size int, queueTimeout time.Duration) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		QueueTimeout: queueTimeout,
+		slots:        make(chan struct{}, size),
+	}
+}
+
+// Acquire waits for a free slot and returns the function that releases it
+// It fails with ErrQueueTimeout after QueueTimeout, or with the context's error when it is cancelled first
+func (p *Pool) Acquire(ctx context.Context) (func(), error) {
+	release := func() { <-p.slots }
+
+	// Take a free slot without queueing
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	p.mu.Lock()
+	p.waiting++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(p.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Size returns the number of slots
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// InUse returns the number of requests currently running
+func (p *Pool) InUse() int {
+	return len(p.slots)
+}
+
+// Waiting returns the number of requests queued for a slot
+func (p *Pool) Waiting() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.waiting
+}
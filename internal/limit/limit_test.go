@@ -0,0 +1,123 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(rate float64, burst int) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(rate, burst)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	l, clock := newTestLimiter(2, 3) // 2 requests per second, bursts of 3
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms", wait)
+	}
+
+	clock.advance(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("request after refilling one token was refused")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("bucket should be empty again")
+	}
+}
+
+func TestRateLimiterSeparateClients(t *testing.T) {
+	l, _ := newTestLimiter(1, 1)
+
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("first request from a was refused")
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("client b should have its own bucket")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("second request from a should be refused")
+	}
+}
+
+func TestRateLimiterSweepsIdleClients(t *testing.T) {
+	l, clock := newTestLimiter(1, 5)
+	l.Allow("a")
+	l.Allow("b")
+
+	clock.advance(2 * time.Minute)
+	l.Allow("c")
+	if n := l.Clients(); n != 1 {
+		t.Errorf("Clients() = %d after the idle buckets refilled, want 1", n)
+	}
+}
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	p := NewPool(2, 20*time.Millisecond)
+	ctx := context.Background()
+
+	release1, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := p.Acquire(ctx); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if p.InUse() != 2 {
+		t.Errorf("InUse() = %d, want 2", p.InUse())
+	}
+
+	if _, err := p.Acquire(ctx); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire() on a full pool = %v, want ErrQueueTimeout", err)
+	}
+
+	// A queued request gets the slot as soon as it is released
+	done := make(chan error)
+	go func() {
+		_, err := p.Acquire(ctx)
+		done <- err
+	}()
+	for p.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	release1()
+	if err := <-done; err != nil {
+		t.Errorf("queued Acquire() error = %v", err)
+	}
+	if p.Waiting() != 0 {
+		t.Errorf("Waiting() = %d, want 0", p.Waiting())
+	}
+}
+
+func TestPoolCancelledContext(t *testing.T) {
+	p := NewPool(1, time.Minute)
+	p.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level string
+
+const (
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warn"
+	LevelError Level = "error"
+)
+
+// Logger writes JSON log entries with a fixed set of fields added to every entry
+// Fields are given as alternating keys and values, e.g. Info("generated", "cards", 12)
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+// output is the destination shared by a logger and the loggers derived from it
+type output struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// New creates a logger writing to w
+func New(w io.Writer) *Logger {
+	return &Logger{out: &output{w: w, now: time.Now}}
+}
+
+var defaultLogger = New(os.Stderr)
+
+// Default returns the logger used when a context carries none
+func Default() *Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces the default logger
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// With returns a logger that adds the given fields to every entry
+func (l *Logger) With(fields ...interface{}) *Logger {
+	combined := make([]interface{}, 0, len(l.fields)+len(fields))
+	combined = append(combined, l.fields...)
+	combined = append(combined, fields...)
+	return &Logger{out: l.out, fields: combined}
+}
+
+// Info logs an informational entry
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.log(LevelInfo, msg, fields)
+}
+
+// Warn logs an entry about a recoverable problem
+func (l *Logger) Warn(msg string, fields ...interface{}) {
+	l.log(LevelWarn, msg, fields)
+}
+
+// Error logs an entry about a failure
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.log(LevelError, msg, fields)
+}
+
+// log writes one entry: time, level and msg first, then the logger's fields and the entry's fields
+func (l *Logger) log(level Level, msg string, fields []interface{}) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeField(&b, "time", l.out.now().UTC().Format(time.RFC3339Nano))
+	b.WriteByte(',')
+	writeField(&b, "level", string(level))
+	b.WriteByte(',')
+	writeField(&b, "msg", msg)
+
+	all := append(append([]interface{}{}, l.fields...), fields...)
+	for i := 0; i < len(all); i += 2 {
+		key, ok := all[i].(string)
+		if !ok {
+			key = fmt.Sprint(all[i])
+		}
+		var value interface{} = "(missing)"
+		if i+1 < len(all) {
+			value = all[i+1]
+		}
+		b.WriteByte(',')
+		writeField(&b, key, value)
+	}
+	b.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+// writeField writes "key":value, encoding errors, durations and stringers as text
+func writeField(b *bytes.Buffer, key string, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		value = v.String()
+	}
+
+	encodedKey, _ := json.Marshal(key)
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(encodedKey)
+	b.WriteByte(':')
+	b.Write(encoded)
+}
+
+// Writer returns an io.Writer that logs each line written to it as an info entry
+// It lets log.SetOutput route the standard logger through the structured log
+func (l *Logger) Writer() io.Writer {
+	return lineWriter{l}
+}
+
+type lineWriter struct {
+	l *Logger
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		w.l.Info(string(line))
+	}
+	return len(p), nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by the context, or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return Default()
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(buf *bytes.Buffer) *Logger {
+	l := New(buf)
+	l.out.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return l
+}
+
+func TestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := newTestLogger(&buf).With("request_id", "abc123")
+
+	l.Error("export failed", "format", "pdf", "error", errors.New("no cards"), "duration", 1500*time.Millisecond, "cards", 3)
+
+	want := `{"time":"2024-03-01T12:00:00Z","level":"error","msg":"export failed","request_id":"abc123",` +
+		`"format":"pdf","error":"no cards","duration":"1.5s","cards":3}` + "\n"
+	if buf.String() != want {
+		t.Errorf("entry = %s\nwant    %s", buf.String(), want)
+	}
+}
+
+func TestLoggerOddFields(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(&buf).Info("odd", "key")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("entry is not valid JSON: %v", err)
+	}
+	if entry["key"] != "(missing)" {
+		t.Errorf("key = %v, want (missing)", entry["key"])
+	}
+}
+
+func TestLoggerWithDoesNotShareFields(t *testing.T) {
+	var buf bytes.Buffer
+	base := newTestLogger(&buf).With("a", 1)
+	first := base.With("b", 2)
+	base.With("c", 3).Info("second")
+	first.Info("first")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if strings.Contains(lines[0], `"b"`) || strings.Contains(lines[1], `"c"`) {
+		t.Errorf("derived loggers share fields:\n%s", buf.String())
+	}
+}
+
+func TestStandardLoggerWriter(t *testing.T) {
+	var buf bytes.Buffer
+	std := log.New(newTestLogger(&buf).Writer(), "", 0)
+	std.Printf("Starting on %s", ":8080")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("entry is not valid JSON: %v", err)
+	}
+	if entry["msg"] != "Starting on :8080" || entry["level"] != "info" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestContext(t *testing.T) {
+	var buf bytes.Buffer
+	l := newTestLogger(&buf)
+
+	if FromContext(context.Background()) != Default() {
+		t.Error("FromContext() without a logger should return the default logger")
+	}
+	if FromContext(NewContext(context.Background(), l)) != l {
+		t.Error("FromContext() should return the logger stored in the context")
+	}
+}
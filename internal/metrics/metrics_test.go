@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("loom_conversions_total", "Conversions by format.", "format", "card_type")
+	c.Inc("svg", "26x8")
+	c.Inc("svg", "26x8")
+	c.Add(3, "pdf", "50x12")
+
+	if v := c.Value("svg", "26x8"); v != 2 {
+		t.Errorf("Value(svg, 26x8) = %g, want 2", v)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	want := `# HELP loom_conversions_total Conversions by format.
+# TYPE loom_conversions_total counter
+loom_conversions_total{format="pdf",card_type="50x12"} 3
+loom_conversions_total{format="svg",card_type="26x8"} 2
+`
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("loom_cards_total", "Cards.").Add(12)
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "\nloom_cards_total 12\n") {
+		t.Errorf("output =\n%s", buf.String())
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	inFlight := 2
+	r.NewGaugeFunc("loom_in_flight", "Requests running.", func() float64 { return float64(inFlight) })
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	inFlight = 0
+	r.WriteText(&buf)
+	want := `# HELP loom_in_flight Requests running.
+# TYPE loom_in_flight gauge
+loom_in_flight 2
+# HELP loom_in_flight Requests running.
+# TYPE loom_in_flight gauge
+loom_in_flight 0
+`
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("loom_stage_duration_seconds", "Stage durations.", []float64{1, 0.1}, "stage")
+	h.Observe(0.05, "decode")
+	h.Observe(0.1, "decode")
+	h.Observe(0.5, "decode")
+	h.Observe(3, "decode")
+
+	if n := h.Count("decode"); n != 4 {
+		t.Errorf("Count(decode) = %d, want 4", n)
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	want := `# HELP loom_stage_duration_seconds Stage durations.
+# TYPE loom_stage_duration_seconds histogram
+loom_stage_duration_seconds_bucket{stage="decode",le="0.1"} 2
+loom_stage_duration_seconds_bucket{stage="decode",le="1"} 3
+loom_stage_duration_seconds_bucket{stage="decode",le="+Inf"} 4
+loom_stage_duration_seconds_sum{stage="decode"} 3.65
+loom_stage_duration_seconds_count{stage="decode"} 4
+`
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("loom_errors_total", "Errors.", "class").Inc("say \"hi\"\\\n\x01")
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), `loom_errors_total{class="say \"hi\"\\\n?"} 1`) {
+		t.Errorf("output =\n%s", buf.String())
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("loom_a_total", "A.", "x")
+
+	for name, f := range map[string]func(){
+		"duplicate name":     func() { r.NewCounter("loom_a_total", "A again.") },
+		"wrong label count":  func() { c.Inc() },
+		"negative increment": func() { c.Add(-1, "x") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("loom_a_total", "A.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "loom_a_total 1") {
+		t.Errorf("body =\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package punchcard
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// Gray levels used when drawing pages (0 = black, 1 = white)
+const (
+	inkBlack     = 0.0
+	inkGray      = 0.5
+	inkLightGray = 0.8
+)
+
+// textAnchor is the horizontal alignment of text relative to its position
+type textAnchor int
+
+const (
+	anchorStart textAnchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas draws a page in millimetres with the origin at the top left
+// It lets the imposition render the same page to SVG and PDF
+type canvas interface {
+	line(x1, y1, x2, y2, width, gray float64)
+	rect(x, y, w, h, width, gray float64)
+	circle(cx, cy, r float64, filled bool, width, gray float64)
+	text(x, y, size float64, anchor textAnchor, gray float64, s string)
+}
+
+// svgCanvas writes SVG elements with a millimetre viewBox
+type svgCanvas struct {
+	w io.Writer
+}
+
+// newSVGCanvas writes the SVG header for a page of the given size and returns its canvas
+func newSVGCanvas(w io.Writer, widthMm, heightMm float64, title string) *svgCanvas {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%.2fmm" height="%.2fmm" viewBox="0 0 %.2f %.2f">`+"\n",
+		widthMm, heightMm, widthMm, heightMm)
+	fmt.Fprintf(w, "  <title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(w, `  <rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	return &svgCanvas{w: w}
+}
+
+// close ends the SVG document
+func (c *svgCanvas) close() {
+	fmt.Fprintf(c.w, "</svg>\n")
+}
+
+// svgGray formats a gray level as an SVG colour
+func svgGray(gray float64) string {
+	v := int(gray*255 + 0.5)
+	return fmt.Sprintf("rgb(%d,%d,%d)", v, v, v)
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(c.w, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"/>`+"\n",
+		x1, y1, x2, y2, svgGray(gray), width)
+}
+
+func (c *svgCanvas) rect(x, y, w, h, width, gray float64) {
+	fmt.Fprintf(c.w, `  <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="none" stroke="%s" stroke-width="%.2f"/>`+"\n",
+		x, y, w, h, svgGray(gray), width)
+}
+
+func (c *svgCanvas) circle(cx, cy, r float64, filled bool, width, gray float64) {
+	if filled {
+		fmt.Fprintf(c.w, `  <circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s"/>`+"\n", cx, cy, r, svgGray(gray))
+		return
+	}
+	fmt.Fprintf(c.w, `  <circle cx="%.2f" cy="%.2f" r="%.2f" fill="none" stroke="%s" stroke-width="%.2f"/>`+"\n",
+		cx, cy, r, svgGray(gray), width)
+}
+
+func (c *svgCanvas) text(x, y, size float64, anchor textAnchor, gray float64, s string) {
+	anchors := map[textAnchor]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+	fmt.Fprintf(c.w, `  <text x="%.2f" y="%.2f" font-family="monospace" font-size="%.2f" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, size, anchors[anchor], svgGray(gray), html.EscapeString(s))
+}
@@ -0,0 +1,65 @@
+package punchcard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRangeOutOfBounds is reported when a card range starts after the last card
+var ErrRangeOutOfBounds = errors.New("range out of bounds")
+
+// CardRange selects cards by their 1-based position in a set, both ends included
+// A To of 0 runs to the last card
+type CardRange struct {
+	From int
+	To   int
+}
+
+// PageRange returns the cards on a 1-based page of perPage cards
+func PageRange(page, perPage int) CardRange {
+	return CardRange{From: (page-1)*perPage + 1, To: page * perPage}
+}
+
+// Validate checks that the range is well formed, without knowing the size of the set
+func (r CardRange) Validate() error {
+	if r.From < 1 {
+		return fmt.Errorf("invalid range start: %d (must be 1 or more)", r.From)
+	}
+	if r.To != 0 && r.To < r.From {
+		return fmt.Errorf("invalid range: %d-%d (end must not be before start)", r.From, r.To)
+	}
+	return nil
+}
+
+// Select returns the cards in the range, which is cut short at the last card
+// Cards keep their numbers, so labels still show their place in the whole set
+func (r CardRange) Select(cards []*Card) ([]*Card, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	if r.From > len(cards) {
+		return nil, fmt.Errorf("%w: starts at card %d but there are only %d cards", ErrRangeOutOfBounds, r.From, len(cards))
+	}
+	to := r.To
+	if to == 0 || to > len(cards) {
+		to = len(cards)
+	}
+	return cards[r.From-1 : to], nil
+}
+
+// Bounds returns the first and last card positions the range selects from a set of total cards
+func (r CardRange) Bounds(total int) (int, int) {
+	to := r.To
+	if to == 0 || to > total {
+		to = total
+	}
+	return r.From, to
+}
+
+// String formats the range as "from-to", or "from-" when it runs to the last card
+func (r CardRange) String() string {
+	if r.To == 0 {
+		return fmt.Sprintf("%d-", r.From)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
@@ -0,0 +1,69 @@
+package punchcard
+
+import (
+	"errors"
+	"testing"
+)
+
+func createRangeTestCards(n int) []*Card {
+	cards := make([]*Card, n)
+	for i := range cards {
+		cards[i] = &Card{Number: i + 1, Width: 2, Height: 1, Matrix: [][]int{{1, 0}}}
+	}
+	return cards
+}
+
+func TestCardRangeSelect(t *testing.T) {
+	cards := createRangeTestCards(10)
+
+	tests := []struct {
+		name      string
+		r         CardRange
+		wantFirst int
+		wantLast  int
+	}{
+		{"single card", CardRange{From: 4, To: 4}, 4, 4},
+		{"middle", CardRange{From: 3, To: 6}, 3, 6},
+		{"open end", CardRange{From: 8}, 8, 10},
+		{"end past the last card", CardRange{From: 9, To: 20}, 9, 10},
+		{"first page", PageRange(1, 3), 1, 3},
+		{"last partial page", PageRange(4, 3), 10, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.r.Select(cards)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if got[0].Number != tt.wantFirst || got[len(got)-1].Number != tt.wantLast {
+				t.Errorf("Select() = cards %d-%d, want %d-%d", got[0].Number, got[len(got)-1].Number, tt.wantFirst, tt.wantLast)
+			}
+			if first, last := tt.r.Bounds(len(cards)); first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("Bounds() = %d, %d, want %d, %d", first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestCardRangeInvalid(t *testing.T) {
+	cards := createRangeTestCards(5)
+
+	if _, err := (CardRange{From: 6}).Select(cards); !errors.Is(err, ErrRangeOutOfBounds) {
+		t.Errorf("Select() past the last card error = %v, want ErrRangeOutOfBounds", err)
+	}
+	for _, r := range []CardRange{{From: 0}, {From: -1, To: 2}, {From: 4, To: 2}} {
+		if _, err := r.Select(cards); err == nil || errors.Is(err, ErrRangeOutOfBounds) {
+			t.Errorf("Select(%v) error = %v, want a validation error", r, err)
+		}
+	}
+}
+
+func TestCardRangeString(t *testing.T) {
+	if s := (CardRange{From: 3, To: 7}).String(); s != "3-7" {
+		t.Errorf("String() = %q, want 3-7", s)
+	}
+	if s := (CardRange{From: 3}).String(); s != "3-" {
+		t.Errorf("String() = %q, want 3-", s)
+	}
+}
@@ -0,0 +1,124 @@
+package punchcard
+
+import "fmt"
+
+// Limits on the chain layout, keeping generated chains to a size that can be punched
+const (
+	MaxBorderCards   = 1000 // Most header, footer or separator cards
+	MaxDesignRepeats = 100  // Most repeats of the design in one chain
+)
+
+// DesignWidth returns the hooks left for the design between the selvedges, over all sections
+func (g *Generator) DesignWidth() int {
+	return g.hooks() - g.LeftSelvedge - g.RightSelvedge
+}
+
+// hooks returns the hooks of a pick over all sections
+func (g *Generator) hooks() int {
+	return g.sections() * g.Dimensions.Width * g.Dimensions.Height
+}
+
+// ValidateLayout checks the section, selvedge, border and repeat options of a generator
+func (g *Generator) ValidateLayout() error {
+	if g.CardsPerRow < 1 || g.CardsPerRow > MaxSections {
+		return fmt.Errorf("invalid cards per row: %d (must be 1 to %d)", g.CardsPerRow, MaxSections)
+	}
+	hooks := g.hooks()
+	if g.LeftSelvedge < 0 || g.RightSelvedge < 0 {
+		return fmt.Errorf("invalid selvedge: %d/%d hooks (must not be negative)", g.LeftSelvedge, g.RightSelvedge)
+	}
+	if g.DesignWidth() < 1 {
+		return fmt.Errorf("invalid selvedge: %d/%d hooks (must leave some of the %d hooks for the design)", g.LeftSelvedge, g.RightSelvedge, hooks)
+	}
+
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"header cards", g.HeaderCards},
+		{"footer cards", g.FooterCards},
+		{"separator cards", g.SeparatorCards},
+	}
+	for _, c := range counts {
+		if c.value < 0 || c.value > MaxBorderCards {
+			return fmt.Errorf("invalid %s: %d (must be 0 to %d)", c.name, c.value, MaxBorderCards)
+		}
+	}
+	if g.Repeats < 0 || g.Repeats > MaxDesignRepeats {
+		return fmt.Errorf("invalid repeats: %d (must be at most %d)", g.Repeats, MaxDesignRepeats)
+	}
+	return nil
+}
+
+// chainPicks returns the picks of the chain woven from a design of the given rows
+func (g *Generator) chainPicks(designRows int) int {
+	repeats := g.Repeats
+	if repeats < 1 {
+		repeats = 1
+	}
+	return g.HeaderCards + repeats*designRows + (repeats-1)*g.SeparatorCards + g.FooterCards
+}
+
+// hasLayout reports whether the generator adds anything around the design
+func (g *Generator) hasLayout() bool {
+	return g.LeftSelvedge > 0 || g.RightSelvedge > 0 || g.HeaderCards > 0 || g.FooterCards > 0 || g.Repeats > 1
+}
+
+// layout builds the picks of the chain from the design rows: header cards, the design repeated
+// with separator cards between the repeats, then footer cards, each with the selvedges at its sides
+// (the first hooks of the first section and the last hooks of the last)
+// Header, footer and separator cards fill the design hooks with the border weave, and the design
+// its figure and ground with the damask weaves; every weave is tiled by hook and pick of the whole
+// chain, so the selvedges, borders and damask interlace continuously
+func (g *Generator) layout(design [][]int) [][]int {
+	if !g.hasLayout() && g.Damask == nil {
+		return design
+	}
+	hooks := g.hooks()
+	left, right := g.LeftSelvedge, hooks-g.RightSelvedge
+	repeats := g.Repeats
+	if repeats < 1 {
+		repeats = 1
+	}
+
+	var picks [][]int
+	border := func(cards int) {
+		for i := 0; i < cards; i++ {
+			row := make([]int, hooks)
+			for x := left; x < right; x++ {
+				row[x] = weaveLift(g.BorderWeave, x, len(picks))
+			}
+			picks = append(picks, row)
+		}
+	}
+
+	border(g.HeaderCards)
+	for repeat := 0; repeat < repeats; repeat++ {
+		if repeat > 0 {
+			border(g.SeparatorCards)
+		}
+		for _, designRow := range design {
+			row := make([]int, hooks)
+			for x, v := range designRow {
+				row[left+x] = g.Damask.Lift(v, left+x, len(picks))
+			}
+			picks = append(picks, row)
+		}
+	}
+	border(g.FooterCards)
+
+	for y, row := range picks {
+		for x := 0; x < left; x++ {
+			row[x] = weaveLift(g.SelvedgeWeave, x, y)
+		}
+		for x := right; x < hooks; x++ {
+			row[x] = weaveLift(g.SelvedgeWeave, x, y)
+		}
+	}
+	return picks
+}
+
+// weaveLift returns the lift of a weave at hook x and pick y, plain weave when it is nil
+func weaveLift(w *Weave, x, y int) int {
+	return orPlain(w).Lift(x, y)
+}
@@ -0,0 +1,196 @@
+package punchcard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerateWithSelvedges(t *testing.T) {
+	g := NewGenerator()
+	g.LeftSelvedge, g.RightSelvedge = 4, 2
+	if got := g.DesignWidth(); got != 202 {
+		t.Fatalf("DesignWidth() = %d, want 202", got)
+	}
+
+	matrix := createTestMatrix(3, 202)
+	cards, err := g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("Generate() = %d cards, want 3", len(cards))
+	}
+	for y, card := range cards {
+		for hook := 0; hook < 208; hook++ {
+			got := card.Matrix[hook/26][hook%26]
+			want := 1 - (hook+y)%2 // Plain selvedges
+			if hook >= 4 && hook < 206 {
+				want = matrix[y][hook-4]
+			}
+			if got != want {
+				t.Errorf("card %d hook %d = %d, want %d", y+1, hook+1, got, want)
+			}
+		}
+	}
+
+	if _, err := g.Generate(createTestMatrix(3, 208)); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Generate() of a full-width design error = %v, want ErrWidthMismatch", err)
+	}
+}
+
+func TestGenerateMaxCells(t *testing.T) {
+	// 3 header + 2 x 4 design + 1 separator + 2 footer = 14 picks of 208 hooks
+	g := NewGenerator()
+	g.HeaderCards, g.FooterCards, g.SeparatorCards, g.Repeats = 3, 2, 1, 2
+	g.MaxCells = 14 * 208
+	if cards, err := g.Generate(createTestMatrix(4, 208)); err != nil || len(cards) != 14 {
+		t.Fatalf("Generate() = %d cards, %v, want 14", len(cards), err)
+	}
+
+	g.MaxCells--
+	if _, err := g.Generate(createTestMatrix(4, 208)); !errors.Is(err, ErrChainTooLarge) {
+		t.Errorf("Generate() over the limit error = %v, want ErrChainTooLarge", err)
+	}
+}
+
+func TestGenerateWithBorders(t *testing.T) {
+	twill, _ := WeaveByName("twill-2/2")
+	g := NewGenerator()
+	g.LeftSelvedge, g.RightSelvedge = 2, 2
+	g.HeaderCards, g.FooterCards, g.SeparatorCards, g.Repeats = 3, 2, 1, 2
+	g.BorderWeave = twill
+
+	solid := make([][]int, 4)
+	for y := range solid {
+		solid[y] = make([]int, 204)
+		for x := range solid[y] {
+			solid[y][x] = 1
+		}
+	}
+	cards, err := g.Generate(solid)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// 3 header + 4 design + 1 separator + 4 design + 2 footer
+	if len(cards) != 14 {
+		t.Fatalf("Generate() = %d cards, want 14", len(cards))
+	}
+	borders := map[int]bool{0: true, 1: true, 2: true, 7: true, 12: true, 13: true}
+	for y, card := range cards {
+		if card.Number != y+1 {
+			t.Errorf("card %d has number %d", y+1, card.Number)
+		}
+		hook := 10
+		want := 1
+		if borders[y] {
+			want = twill.Lift(hook, y)
+		}
+		if got := card.Matrix[0][hook]; got != want {
+			t.Errorf("card %d hook %d = %d, want %d", y+1, hook+1, got, want)
+		}
+		if got := card.Matrix[0][0]; got != 1-y%2 {
+			t.Errorf("card %d selvedge = %d, want plain", y+1, got)
+		}
+	}
+}
+
+func TestGenerateSelvedgesAcrossSections(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 2
+	g.LeftSelvedge, g.RightSelvedge = 2, 3
+
+	solid := make([][]int, 2)
+	for y := range solid {
+		solid[y] = make([]int, g.DesignWidth())
+		for x := range solid[y] {
+			solid[y][x] = 1
+		}
+	}
+	cards, err := g.Generate(solid)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// The selvedges are the outer hooks of the cloth: the first of section A and the last of B
+	a, b := cards[1], cards[3]
+	if a.Section != "A" || b.Section != "B" {
+		t.Fatalf("cards 2 and 4 are in sections %q and %q, want A and B", a.Section, b.Section)
+	}
+	for hook := 0; hook < 208; hook++ {
+		row, col := hook/26, hook%26
+		// Plain selvedges on pick 2 lift every other hook of the chain, from its second
+		wantA, wantB := 1, 1
+		if hook < 2 {
+			wantA = hook % 2
+		}
+		if hook >= 205 {
+			wantB = (208 + hook) % 2
+		}
+		if got := a.Matrix[row][col]; got != wantA {
+			t.Errorf("card A-2 hook %d = %d, want %d", hook+1, got, wantA)
+		}
+		if got := b.Matrix[row][col]; got != wantB {
+			t.Errorf("card B-2 hook %d = %d, want %d", hook+1, got, wantB)
+		}
+	}
+}
+
+func TestGenerateDamaskAlignedWithChain(t *testing.T) {
+	twill, _ := WeaveByName("twill-2/2")
+	g := NewGenerator()
+	g.LeftSelvedge, g.RightSelvedge = 3, 1
+	g.HeaderCards, g.SeparatorCards, g.Repeats = 3, 1, 2
+	g.Damask = &Damask{Figure: twill}
+
+	solid := make([][]int, 5)
+	for y := range solid {
+		solid[y] = make([]int, 204)
+		for x := range solid[y] {
+			solid[y][x] = 1
+		}
+	}
+	cards, err := g.Generate(solid)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// The design picks of both repeats follow the twill by chain hook and pick, not design column and row
+	for _, pick := range []int{3, 4, 5, 6, 7, 9, 10, 11, 12, 13} {
+		for hook := 3; hook < 207; hook++ {
+			if got, want := cards[pick].Matrix[hook/26][hook%26], twill.Lift(hook, pick); got != want {
+				t.Fatalf("pick %d hook %d = %d, want %d", pick+1, hook+1, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(g *Generator)
+	}{
+		{"no cards per row", func(g *Generator) { g.CardsPerRow = 0 }},
+		{"negative selvedge", func(g *Generator) { g.LeftSelvedge = -1 }},
+		{"no design hooks", func(g *Generator) { g.LeftSelvedge, g.RightSelvedge = 104, 104 }},
+		{"negative header", func(g *Generator) { g.HeaderCards = -1 }},
+		{"too many footer cards", func(g *Generator) { g.FooterCards = MaxBorderCards + 1 }},
+		{"too many repeats", func(g *Generator) { g.Repeats = MaxDesignRepeats + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator()
+			tt.apply(g)
+			if err := g.ValidateLayout(); err == nil {
+				t.Error("ValidateLayout() should fail")
+			}
+			if _, err := g.Generate(createTestMatrix(1, g.DesignWidth())); err == nil {
+				t.Error("Generate() should fail")
+			}
+		})
+	}
+
+	if err := NewGenerator().ValidateLayout(); err != nil {
+		t.Errorf("ValidateLayout() of the defaults error = %v", err)
+	}
+}
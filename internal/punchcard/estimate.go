@@ -0,0 +1,244 @@
+package punchcard
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+)
+
+// LoomProfile describes the loom and card stock a job is produced on
+type LoomProfile struct {
+	PicksPerMinute  float64 `json:"picksPerMinute"`  // Weaving speed
+	PicksPerCm      float64 `json:"picksPerCm"`      // Weft density of the cloth
+	CardWidthMm     float64 `json:"cardWidthMm"`     // Physical card size across the chain
+	CardHeightMm    float64 `json:"cardHeightMm"`    // Physical card size along the chain
+	CardThicknessMm float64 `json:"cardThicknessMm"` // Thickness of the card stock
+	CardGSM         float64 `json:"cardGsm"`         // Grammage of the card stock in g/m²
+	LacingPerCardCm float64 `json:"lacingPerCardCm"` // Lacing thread used to join each card to the next
+	SheetWidthMm    float64 `json:"sheetWidthMm"`    // Size of the board sheets blanks are cut from
+	SheetHeightMm   float64 `json:"sheetHeightMm"`
+	SparePercent    float64 `json:"sparePercent"` // Extra blanks allowed for mispunches
+}
+
+// DefaultLoomProfile returns a hand Jacquard profile with cards sized as the exporters draw them
+func DefaultLoomProfile(dims CardDimensions) LoomProfile {
+	return LoomProfile{
+		PicksPerMinute:  40,
+		PicksPerCm:      20,
+		CardWidthMm:     float64(dims.Width)*HoleSpacing + 2*CardPadding,
+		CardHeightMm:    float64(dims.Height)*HoleSpacing + 2*CardPadding + TextHeight*2,
+		CardThicknessMm: 0.3,
+		CardGSM:         300,
+		LacingPerCardCm: 30,
+		SheetWidthMm:    700,
+		SheetHeightMm:   1000,
+		SparePercent:    5,
+	}
+}
+
+// Validate checks that the profile describes a workable loom and card stock
+func (p LoomProfile) Validate() error {
+	positive := []struct {
+		name  string
+		value float64
+	}{
+		{"picks per minute", p.PicksPerMinute},
+		{"picks per cm", p.PicksPerCm},
+		{"card width", p.CardWidthMm},
+		{"card height", p.CardHeightMm},
+		{"card thickness", p.CardThicknessMm},
+		{"card grammage", p.CardGSM},
+		{"sheet width", p.SheetWidthMm},
+		{"sheet height", p.SheetHeightMm},
+	}
+	// NaN fails every comparison and infinity passes them, so both are checked for explicitly
+	for _, f := range positive {
+		if !(f.value > 0) || math.IsInf(f.value, 0) {
+			return fmt.Errorf("invalid %s: %g (must be a number greater than 0)", f.name, f.value)
+		}
+	}
+	if !(p.LacingPerCardCm >= 0) || math.IsInf(p.LacingPerCardCm, 0) {
+		return fmt.Errorf("invalid lacing per card: %g (must be a number, not negative)", p.LacingPerCardCm)
+	}
+	if !(p.SparePercent >= 0 && p.SparePercent <= 100) {
+		return fmt.Errorf("invalid spare allowance: %g (must be between 0 and 100)", p.SparePercent)
+	}
+	if p.BlanksPerSheet() == 0 {
+		return fmt.Errorf("invalid sheet size: %gx%g mm is smaller than a %gx%g mm card",
+			p.SheetWidthMm, p.SheetHeightMm, p.CardWidthMm, p.CardHeightMm)
+	}
+	return nil
+}
+
+// BlanksPerSheet returns how many card blanks can be cut from one sheet, in the better orientation
+func (p LoomProfile) BlanksPerSheet() int {
+	if p.CardWidthMm <= 0 || p.CardHeightMm <= 0 {
+		return 0
+	}
+	upright := int(p.SheetWidthMm/p.CardWidthMm) * int(p.SheetHeightMm/p.CardHeightMm)
+	turned := int(p.SheetWidthMm/p.CardHeightMm) * int(p.SheetHeightMm/p.CardWidthMm)
+	if turned > upright {
+		return turned
+	}
+	return upright
+}
+
+// ProductionEstimate is the material and time needed to punch, lace and weave a card set
+type ProductionEstimate struct {
+	Profile        LoomProfile `json:"profile"`
+	Cards          int         `json:"cards"`              // Cards in the chain, or in all chains of a sectioned design
+	Sections       int         `json:"sections,omitempty"` // Parallel chains of a sectioned design, woven together
+	Repeats        int         `json:"repeats"`            // Times the chain is woven
+	SpareCards     int         `json:"spareCards"`         // Extra blanks for mispunches
+	CardBlanks     int         `json:"cardBlanks"`         // Cards plus spares
+	BlanksPerSheet int         `json:"blanksPerSheet"`     // Blanks cut from one sheet
+	Sheets         int         `json:"sheets"`             // Sheets of board to buy
+	ChainLengthM   float64     `json:"chainLengthM"`       // Length of the laced chain (each chain) laid out flat
+	ChainStackCm   float64     `json:"chainStackCm"`       // Height of the chain (each chain) folded into a stack
+	ChainWeightKg  float64     `json:"chainWeightKg"`      // Weight of all the laced cards
+	LacingThreadM  float64     `json:"lacingThreadM"`      // Lacing thread for all the cards
+	TotalPicks     int         `json:"totalPicks"`         // Picks woven over all repeats
+	WeavingMinutes float64     `json:"weavingMinutes"`     // Weaving time at the profile's speed
+	WeavingTime    string      `json:"weavingTime"`        // Weaving time for display
+	WovenLengthCm  float64     `json:"wovenLengthCm"`      // Length of cloth woven
+}
+
+// EstimateProduction works out the materials and weaving time for a card set
+// Each card is one pick, except in a design split into sections, where a pick takes one card of
+// every section; repeats is the number of times the chain is woven (at least 1)
+func EstimateProduction(cards []*Card, profile LoomProfile, repeats int) (*ProductionEstimate, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if repeats < 1 {
+		return nil, fmt.Errorf("invalid repeats: %d (must be 1 or more)", repeats)
+	}
+
+	n := len(cards)
+	chains := SplitSections(cards)
+	picks := 0
+	for _, chain := range chains {
+		if len(chain.Cards) > picks {
+			picks = len(chain.Cards)
+		}
+	}
+	estimate := &ProductionEstimate{
+		Profile:        profile,
+		Cards:          n,
+		Repeats:        repeats,
+		SpareCards:     int(math.Ceil(float64(n) * profile.SparePercent / 100)),
+		BlanksPerSheet: profile.BlanksPerSheet(),
+		TotalPicks:     picks * repeats,
+	}
+	if len(chains) > 1 {
+		estimate.Sections = len(chains)
+	}
+	estimate.CardBlanks = n + estimate.SpareCards
+	estimate.Sheets = (estimate.CardBlanks + estimate.BlanksPerSheet - 1) / estimate.BlanksPerSheet
+
+	cardAreaM2 := profile.CardWidthMm * profile.CardHeightMm / 1e6
+	estimate.ChainLengthM = float64(picks) * profile.CardHeightMm / 1000
+	estimate.ChainStackCm = float64(picks) * profile.CardThicknessMm / 10
+	estimate.ChainWeightKg = float64(n) * cardAreaM2 * profile.CardGSM / 1000
+	estimate.LacingThreadM = float64(n) * profile.LacingPerCardCm / 100
+
+	estimate.WeavingMinutes = float64(estimate.TotalPicks) / profile.PicksPerMinute
+	estimate.WeavingTime = formatMinutes(estimate.WeavingMinutes)
+	estimate.WovenLengthCm = float64(estimate.TotalPicks) / profile.PicksPerCm
+
+	return estimate, nil
+}
+
+// formatMinutes formats a duration in minutes as hours and minutes
+func formatMinutes(minutes float64) string {
+	total := int(math.Ceil(minutes))
+	if total < 60 {
+		return fmt.Sprintf("%d min", total)
+	}
+	return fmt.Sprintf("%d h %02d min", total/60, total%60)
+}
+
+// EstimateReportExporter writes a production estimate as a printable HTML page
+type EstimateReportExporter struct {
+	Title   string
+	Profile LoomProfile
+	Repeats int
+}
+
+// NewEstimateReportExporter creates a report exporter for the given loom profile
+func NewEstimateReportExporter(profile LoomProfile) *EstimateReportExporter {
+	return &EstimateReportExporter{
+		Profile: profile,
+		Repeats: 1,
+	}
+}
+
+// ExportCards estimates the production of a card set and writes the report
+func (e *EstimateReportExporter) ExportCards(cards []*Card, w io.Writer) error {
+	if len(cards) == 0 {
+		return fmt.Errorf("no cards to export")
+	}
+	estimate, err := EstimateProduction(cards, e.Profile, e.Repeats)
+	if err != nil {
+		return err
+	}
+
+	title := e.Title
+	if title == "" {
+		title = "Untitled Pattern"
+	}
+	return estimateReportTemplate.Execute(w, struct {
+		Title    string
+		CardType string
+		*ProductionEstimate
+	}{title, fmt.Sprintf("%dx%d", cards[0].Width, cards[0].Height), estimate})
+}
+
+var estimateReportTemplate = template.Must(template.New("estimate").Funcs(template.FuncMap{
+	"f1": func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"f2": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>Production Estimate: {{.Title}}</title>
+<style>
+  body { font-family: sans-serif; max-width: 180mm; margin: 15mm auto; color: #222; }
+  h1 { font-size: 1.4em; margin-bottom: 0; }
+  h2 { font-size: 1.1em; margin-top: 1.5em; border-bottom: 1px solid #999; }
+  table { border-collapse: collapse; width: 100%; }
+  td { padding: 3px 6px; border-bottom: 1px solid #ddd; }
+  td.value { text-align: right; font-variant-numeric: tabular-nums; }
+  @media print { body { margin: 0 auto; } }
+</style>
+</head>
+<body>
+<h1>Production Estimate</h1>
+<p>{{.Title}} &middot; {{.Cards}} cards ({{.CardType}}){{if gt .Repeats 1}} &middot; woven {{.Repeats}} times{{end}}</p>
+
+<h2>Card Stock</h2>
+<table>
+  <tr><td>Cards in the chain</td><td class="value">{{.Cards}}</td></tr>
+  <tr><td>Spare blanks ({{f1 .Profile.SparePercent}}%)</td><td class="value">{{.SpareCards}}</td></tr>
+  <tr><td>Card blanks ({{f1 .Profile.CardWidthMm}} &times; {{f1 .Profile.CardHeightMm}} mm)</td><td class="value">{{.CardBlanks}}</td></tr>
+  <tr><td>Sheets ({{f1 .Profile.SheetWidthMm}} &times; {{f1 .Profile.SheetHeightMm}} mm, {{.BlanksPerSheet}} blanks each)</td><td class="value">{{.Sheets}}</td></tr>
+</table>
+
+<h2>Chain</h2>
+<table>
+  <tr><td>Chain length</td><td class="value">{{f2 .ChainLengthM}} m</td></tr>
+  <tr><td>Folded stack height ({{f2 .Profile.CardThicknessMm}} mm cards)</td><td class="value">{{f1 .ChainStackCm}} cm</td></tr>
+  <tr><td>Chain weight ({{f1 .Profile.CardGSM}} g/m&sup2;)</td><td class="value">{{f2 .ChainWeightKg}} kg</td></tr>
+  <tr><td>Lacing thread ({{f1 .Profile.LacingPerCardCm}} cm per card)</td><td class="value">{{f1 .LacingThreadM}} m</td></tr>
+</table>
+
+<h2>Weaving</h2>
+<table>
+  <tr><td>Total picks</td><td class="value">{{.TotalPicks}}</td></tr>
+  <tr><td>Weaving time ({{f1 .Profile.PicksPerMinute}} picks/min)</td><td class="value">{{.WeavingTime}}</td></tr>
+  <tr><td>Woven length ({{f1 .Profile.PicksPerCm}} picks/cm)</td><td class="value">{{f1 .WovenLengthCm}} cm</td></tr>
+</table>
+</body>
+</html>
+`))
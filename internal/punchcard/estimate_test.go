@@ -0,0 +1,184 @@
+package punchcard
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testProfile() LoomProfile {
+	return LoomProfile{
+		PicksPerMinute:  50,
+		PicksPerCm:      25,
+		CardWidthMm:     150,
+		CardHeightMm:    80,
+		CardThicknessMm: 0.5,
+		CardGSM:         250,
+		LacingPerCardCm: 40,
+		SheetWidthMm:    500,
+		SheetHeightMm:   700,
+		SparePercent:    10,
+	}
+}
+
+func TestDefaultLoomProfile(t *testing.T) {
+	for _, cardType := range []CardType{CardType26x8, CardType50x12} {
+		profile := DefaultLoomProfile(GetCardDimensions(cardType))
+		if err := profile.Validate(); err != nil {
+			t.Errorf("DefaultLoomProfile(%s) is invalid: %v", cardType, err)
+		}
+	}
+
+	profile := DefaultLoomProfile(GetCardDimensions(CardType26x8))
+	if profile.CardWidthMm != 150 || profile.CardHeightMm != 76 {
+		t.Errorf("26x8 card size = %gx%g mm, want 150x76", profile.CardWidthMm, profile.CardHeightMm)
+	}
+}
+
+func TestLoomProfileValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *LoomProfile)
+	}{
+		{"zero speed", func(p *LoomProfile) { p.PicksPerMinute = 0 }},
+		{"negative thickness", func(p *LoomProfile) { p.CardThicknessMm = -1 }},
+		{"negative lacing", func(p *LoomProfile) { p.LacingPerCardCm = -5 }},
+		{"spare over 100%", func(p *LoomProfile) { p.SparePercent = 150 }},
+		{"sheet smaller than card", func(p *LoomProfile) { p.SheetWidthMm, p.SheetHeightMm = 100, 100 }},
+		{"NaN picks per cm", func(p *LoomProfile) { p.PicksPerCm = math.NaN() }},
+		{"infinite speed", func(p *LoomProfile) { p.PicksPerMinute = math.Inf(1) }},
+		{"NaN spare", func(p *LoomProfile) { p.SparePercent = math.NaN() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := testProfile()
+			tt.modify(&profile)
+			if err := profile.Validate(); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestBlanksPerSheet(t *testing.T) {
+	profile := testProfile()
+	// Upright: 3 x 8 = 24; turned: 6 x 4 = 24
+	if n := profile.BlanksPerSheet(); n != 24 {
+		t.Errorf("BlanksPerSheet() = %d, want 24", n)
+	}
+
+	profile.SheetWidthMm, profile.SheetHeightMm = 160, 500
+	// Upright: 1 x 6 = 6; turned: 2 x 3 = 6
+	if n := profile.BlanksPerSheet(); n != 6 {
+		t.Errorf("BlanksPerSheet() = %d, want 6", n)
+	}
+}
+
+func TestEstimateProduction(t *testing.T) {
+	matrix := createTestMatrix(100, CardWidth*CardHeight)
+	cards, err := NewGenerator().Generate(matrix)
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	estimate, err := EstimateProduction(cards, testProfile(), 3)
+	if err != nil {
+		t.Fatalf("EstimateProduction() error = %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"SpareCards", float64(estimate.SpareCards), 10},
+		{"CardBlanks", float64(estimate.CardBlanks), 110},
+		{"Sheets", float64(estimate.Sheets), 5},
+		{"ChainLengthM", estimate.ChainLengthM, 8},
+		{"ChainStackCm", estimate.ChainStackCm, 5},
+		{"ChainWeightKg", estimate.ChainWeightKg, 0.3},
+		{"LacingThreadM", estimate.LacingThreadM, 40},
+		{"TotalPicks", float64(estimate.TotalPicks), 300},
+		{"WeavingMinutes", estimate.WeavingMinutes, 6},
+		{"WovenLengthCm", estimate.WovenLengthCm, 12},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %g, want %g", c.name, c.got, c.want)
+		}
+	}
+	if estimate.WeavingTime != "6 min" {
+		t.Errorf("WeavingTime = %q, want %q", estimate.WeavingTime, "6 min")
+	}
+
+	if _, err := EstimateProduction(cards, testProfile(), 0); err == nil {
+		t.Error("expected an error for zero repeats")
+	}
+}
+
+func TestEstimateProductionSections(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 2
+	cards, err := g.Generate(createTestMatrix(10, 2*CardWidth*CardHeight))
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	estimate, err := EstimateProduction(cards, testProfile(), 1)
+	if err != nil {
+		t.Fatalf("EstimateProduction() error = %v", err)
+	}
+	// Every card is punched, but a pick takes one card of each section
+	if estimate.Cards != 20 || estimate.Sections != 2 {
+		t.Errorf("Cards, Sections = %d, %d, want 20, 2", estimate.Cards, estimate.Sections)
+	}
+	if estimate.TotalPicks != 10 {
+		t.Errorf("TotalPicks = %d, want 10", estimate.TotalPicks)
+	}
+	if math.Abs(estimate.WovenLengthCm-0.4) > 1e-9 {
+		t.Errorf("WovenLengthCm = %g, want 0.4", estimate.WovenLengthCm)
+	}
+	if math.Abs(estimate.ChainLengthM-0.8) > 1e-9 {
+		t.Errorf("ChainLengthM = %g, want 0.8", estimate.ChainLengthM)
+	}
+}
+
+func TestFormatMinutes(t *testing.T) {
+	tests := map[float64]string{
+		0:     "0 min",
+		12.2:  "13 min",
+		60:    "1 h 00 min",
+		125.5: "2 h 06 min",
+	}
+	for minutes, want := range tests {
+		if got := formatMinutes(minutes); got != want {
+			t.Errorf("formatMinutes(%g) = %q, want %q", minutes, got, want)
+		}
+	}
+}
+
+func TestEstimateReportExporter(t *testing.T) {
+	cards, err := NewGenerator().Generate(createTestMatrix(10, CardWidth*CardHeight))
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	exporter := NewEstimateReportExporter(testProfile())
+	exporter.Title = "Roses <Border>"
+	var buf bytes.Buffer
+	if err := exporter.ExportCards(cards, &buf); err != nil {
+		t.Fatalf("ExportCards() error = %v", err)
+	}
+
+	report := buf.String()
+	for _, want := range []string{"<!DOCTYPE html>", "Production Estimate", "Roses &lt;Border&gt;", "Total picks", "10 cards (26x8)"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+
+	if err := exporter.ExportCards(nil, &buf); err == nil {
+		t.Error("expected an error for an empty card set")
+	}
+}
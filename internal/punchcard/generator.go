@@ -1,9 +1,16 @@
 package punchcard
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrWidthMismatch is reported when a row or image is not as wide as its cards require
+var ErrWidthMismatch = errors.New("incorrect width")
+
+// ErrChainTooLarge is reported when a chain would have more hooks x picks than Generator.MaxCells
+var ErrChainTooLarge = errors.New("chain too large")
+
 // CardType represents different loom card specifications
 type CardType string
 
@@ -41,6 +48,16 @@ func ValidateCardType(cardType string) error {
 	return nil
 }
 
+// CardTypeForDimensions returns the card type with the given dimensions
+func CardTypeForDimensions(dims CardDimensions) (CardType, error) {
+	for _, cardType := range []CardType{CardType26x8, CardType50x12} {
+		if GetCardDimensions(cardType) == dims {
+			return cardType, nil
+		}
+	}
+	return "", fmt.Errorf("no card type with dimensions %dx%d", dims.Width, dims.Height)
+}
+
 // Legacy constants for backward compatibility
 const (
 	// CardWidth represents the number of columns in a standard Jacquard punchcard
@@ -55,16 +72,29 @@ const (
 
 // Card represents a single Jacquard punchcard
 type Card struct {
-	Number int       // Sequential number for ordering
-	Matrix [][]int   // Binary matrix: 1 = hole punched, 0 = no hole
-	Width  int       // Number of columns (typically 8)
-	Height int       // Number of rows (typically 26)
+	Number  int       // Sequential number for ordering
+	Matrix  [][]int   // Binary matrix: 1 = hole punched, 0 = no hole
+	Width   int       // Number of columns (typically 8)
+	Height  int       // Number of rows (typically 26)
+	Section string    // Chain of a design split across several, e.g. "A" (empty for a single chain)
 }
 
 // Generator creates punchcards from binary image data
 type Generator struct {
-	CardsPerRow int            // How many cards wide the pattern is (usually 1 for standard looms)
+	CardsPerRow int            // How many cards wide the pattern is, each a section with its own chain (usually 1)
 	Dimensions  CardDimensions // Card dimensions (width and height)
+	Damask      *Damask        // Weaves filling the figure and ground (nil = every pixel is one lift)
+	MaxCells    int            // Largest chain generated, in hooks x picks over all sections (0 = no limit)
+
+	// Chain layout (see chain.go): the design fills the hooks between the selvedges
+	LeftSelvedge   int    // Hooks reserved as the left selvedge, the first hooks of the first section
+	RightSelvedge  int    // Hooks reserved as the right selvedge, the last hooks of the last section
+	SelvedgeWeave  *Weave // Structure of the selvedges (nil = plain)
+	HeaderCards    int    // Cards woven before the design
+	FooterCards    int    // Cards woven after the design
+	SeparatorCards int    // Cards woven between repeats of the design
+	Repeats        int    // Times the design is woven in the chain (0 = once)
+	BorderWeave    *Weave // Structure of header, footer and separator cards (nil = plain)
 }
 
 // NewGenerator creates a new punchcard generator with default 26x8 card type
@@ -91,43 +121,72 @@ func (g *Generator) Generate(matrix [][]int) ([]*Card, error) {
 		return nil, fmt.Errorf("empty matrix provided")
 	}
 
+	if err := g.ValidateLayout(); err != nil {
+		return nil, err
+	}
+
 	imageWidth := len(matrix[0])
-	imageHeight := len(matrix)
 
-	// Expected width is Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600)
-	expectedWidth := g.Dimensions.Width * g.Dimensions.Height
+	// Expected width is Width * Height (e.g., 26 * 8 = 208 or 50 * 12 = 600) for each card of a row,
+	// less any selvedges
+	expectedWidth := g.DesignWidth()
 	if imageWidth != expectedWidth {
-		return nil, fmt.Errorf("image width (%d) does not match expected width (%d = %d x %d)",
-			imageWidth, expectedWidth, g.Dimensions.Width, g.Dimensions.Height)
+		hooks := fmt.Sprintf("%d x %d", g.Dimensions.Width, g.Dimensions.Height)
+		if g.sections() > 1 {
+			hooks = fmt.Sprintf("%d cards of %s", g.sections(), hooks)
+		}
+		if selvedges := g.LeftSelvedge + g.RightSelvedge; selvedges > 0 {
+			hooks += fmt.Sprintf(" less %d selvedge hooks", selvedges)
+		}
+		return nil, fmt.Errorf("%w: image width (%d) does not match expected width (%d = %s)",
+			ErrWidthMismatch, imageWidth, expectedWidth, hooks)
+	}
+
+	// Refuse chains too large to hold before laying them out; the cards follow from the picks
+	if picks := g.chainPicks(len(matrix)); g.MaxCells > 0 && int64(picks)*int64(g.hooks()) > int64(g.MaxCells) {
+		return nil, fmt.Errorf("%w: %d picks of %d hooks in %d cards (limit is %d hooks x picks)",
+			ErrChainTooLarge, picks, g.hooks(), picks*g.sections(), g.MaxCells)
 	}
 
-	// Each row of the image becomes one card
-	numCards := imageHeight
-	cards := make([]*Card, numCards)
+	// Place the design between the selvedges, with any header, separator and footer cards,
+	// and fill its figure and ground with their weaves
+	matrix = g.layout(matrix)
+
+	// Each row of the image becomes one card in every section, the sections one after another
+	numCards := len(matrix)
+	sections := g.sections()
+	hooks := g.Dimensions.Width * g.Dimensions.Height
+	cards := make([]*Card, 0, numCards*sections)
 
 	// Convert each row into a card
-	for cardNum := 0; cardNum < numCards; cardNum++ {
-		// Get the source row (e.g., 208 or 600 pixels)
-		sourceRow := matrix[cardNum]
-
-		// Create the card matrix (Width columns x Height rows)
-		cardMatrix := make([][]int, g.Dimensions.Height)
-
-		// Reshape the pixel row into a Width x Height grid
-		// We fill the grid row by row (left to right, top to bottom)
-		for row := 0; row < g.Dimensions.Height; row++ {
-			cardMatrix[row] = make([]int, g.Dimensions.Width)
-			for col := 0; col < g.Dimensions.Width; col++ {
-				pixelIndex := row*g.Dimensions.Width + col
-				cardMatrix[row][col] = sourceRow[pixelIndex]
+	for section := 0; section < sections; section++ {
+		for cardNum := 0; cardNum < numCards; cardNum++ {
+			// Get the source row (e.g., 208 or 600 pixels) of the section
+			sourceRow := matrix[cardNum][section*hooks : (section+1)*hooks]
+
+			// Create the card matrix (Width columns x Height rows)
+			cardMatrix := make([][]int, g.Dimensions.Height)
+
+			// Reshape the pixel row into a Width x Height grid
+			// We fill the grid row by row (left to right, top to bottom)
+			for row := 0; row < g.Dimensions.Height; row++ {
+				cardMatrix[row] = make([]int, g.Dimensions.Width)
+				for col := 0; col < g.Dimensions.Width; col++ {
+					pixelIndex := row*g.Dimensions.Width + col
+					cardMatrix[row][col] = sourceRow[pixelIndex]
+				}
 			}
-		}
 
-		cards[cardNum] = &Card{
-			Number: cardNum + 1, // 1-indexed for user display
-			Matrix: cardMatrix,
-			Width:  g.Dimensions.Width,
-			Height: g.Dimensions.Height,
+			card := &Card{
+				Number: cardNum + 1, // 1-indexed for user display
+				Matrix: cardMatrix,
+				Width:  g.Dimensions.Width,
+				Height: g.Dimensions.Height,
+			}
+			if sections > 1 {
+				card.Section = SectionName(section)
+			}
+			cards = append(cards, card)
 		}
 	}
 
@@ -139,8 +198,8 @@ func (c *Card) GetCardInfo() string {
 	holes := c.CountHoles()
 	density := float64(holes) / float64(c.Width*c.Height) * 100
 
-	return fmt.Sprintf("Card #%d: %dx%d, %d holes (%.1f%% density)",
-		c.Number, c.Width, c.Height, holes, density)
+	return fmt.Sprintf("Card #%s: %dx%d, %d holes (%.1f%% density)",
+		c.Label(), c.Width, c.Height, holes, density)
 }
 
 // CountHoles returns the number of punched holes in the card
@@ -175,7 +234,7 @@ func (c *Card) IsHolePunched(x, y int) bool {
 // GetBinaryString returns a string representation of the card in binary form
 // Useful for debugging and verification
 func (c *Card) GetBinaryString() string {
-	result := fmt.Sprintf("Card #%d:\n", c.Number)
+	result := fmt.Sprintf("Card #%s:\n", c.Label())
 	for y := 0; y < c.Height; y++ {
 		for x := 0; x < c.Width; x++ {
 			if c.Matrix[y][x] == 1 {
@@ -201,7 +260,7 @@ func (c *Card) Validate() error {
 
 	for y, row := range c.Matrix {
 		if len(row) != c.Width {
-			return fmt.Errorf("row %d width (%d) does not match card width (%d)", y, len(row), c.Width)
+			return fmt.Errorf("%w: row %d width (%d) does not match card width (%d)", ErrWidthMismatch, y, len(row), c.Width)
 		}
 
 		// Validate binary values
@@ -218,10 +277,11 @@ func (c *Card) Validate() error {
 // Clone creates a deep copy of the card
 func (c *Card) Clone() *Card {
 	clone := &Card{
-		Number: c.Number,
-		Width:  c.Width,
-		Height: c.Height,
-		Matrix: make([][]int, c.Height),
+		Number:  c.Number,
+		Width:   c.Width,
+		Height:  c.Height,
+		Matrix:  make([][]int, c.Height),
+		Section: c.Section,
 	}
 
 	for y := 0; y < c.Height; y++ {
@@ -244,12 +304,12 @@ func (c *Card) Invert() {
 
 // GetMetadata returns metadata about the card set
 type Metadata struct {
-	TotalCards    int
-	CardWidth     int
-	CardHeight    int
-	TotalRows     int
-	HolesPerCard  []int
-	AverageDensity float64
+	TotalCards     int     `json:"totalCards"`
+	CardWidth      int     `json:"cardWidth"`
+	CardHeight     int     `json:"cardHeight"`
+	TotalRows      int     `json:"totalRows"`
+	HolesPerCard   []int   `json:"holesPerCard"`
+	AverageDensity float64 `json:"averageDensity"`
 }
 
 // GenerateMetadata creates metadata for a set of cards
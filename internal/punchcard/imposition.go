@@ -0,0 +1,320 @@
+package punchcard
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+)
+
+// Imposition spacing in millimetres
+const (
+	PageMargin      = 10.0 // Unprinted border, also holding the registration marks
+	HeaderHeight    = 8.0  // Band below the top margin for the page and card-range header
+	RulerHeight     = 14.0 // Band above the bottom margin for the calibration ruler
+	CardGap         = 8.0  // Space between cards, holding the crop marks
+	CropMarkLength  = 3.0  // Length of each crop mark
+	CropMarkOffset  = 1.0  // Distance between a crop mark and the card edge
+	RulerLength     = 100  // Length of the calibration ruler in mm
+	RegistrationDia = 6.0  // Diameter of a registration mark
+)
+
+// PageLayout is the grid of cards on an imposed page
+type PageLayout struct {
+	PageWidth  float64 // Page width in mm, after choosing the orientation
+	PageHeight float64 // Page height in mm
+	Landscape  bool
+	Columns    int
+	Rows       int
+	CardWidth  float64 // Card width in mm
+	CardHeight float64 // Card height in mm
+	OriginX    float64 // Left edge of the first card
+	OriginY    float64 // Top edge of the first card
+}
+
+// CardsPerPage returns the number of cards on each page
+func (l PageLayout) CardsPerPage() int {
+	return l.Columns * l.Rows
+}
+
+// cardPosition returns the top-left corner of the card in the given slot
+func (l PageLayout) cardPosition(slot int) (float64, float64) {
+	col, row := slot%l.Columns, slot/l.Columns
+	return l.OriginX + float64(col)*(l.CardWidth+CardGap), l.OriginY + float64(row)*(l.CardHeight+CardGap)
+}
+
+// CardSize returns the printed size of a card in mm, as drawn by the exporters
+func CardSize(dims CardDimensions) (float64, float64) {
+	return float64(dims.Width)*HoleSpacing + 2*CardPadding,
+		float64(dims.Height)*HoleSpacing + 2*CardPadding + TextHeight*2
+}
+
+// LayoutPage tiles cards of the given dimensions on a page, choosing the orientation that fits more
+func LayoutPage(pageSize PDFPageSize, dims CardDimensions) (PageLayout, error) {
+	cardWidth, cardHeight := CardSize(dims)
+
+	best := PageLayout{}
+	for _, landscape := range []bool{false, true} {
+		pageWidth, pageHeight := pageSize.Width, pageSize.Height
+		if landscape {
+			pageWidth, pageHeight = pageHeight, pageWidth
+		}
+		usableWidth := pageWidth - 2*PageMargin
+		usableHeight := pageHeight - 2*PageMargin - HeaderHeight - RulerHeight
+
+		columns := int((usableWidth + CardGap) / (cardWidth + CardGap))
+		rows := int((usableHeight + CardGap) / (cardHeight + CardGap))
+		if columns*rows <= best.CardsPerPage() {
+			continue
+		}
+
+		// Centre the grid in the usable area
+		gridWidth := float64(columns)*(cardWidth+CardGap) - CardGap
+		gridHeight := float64(rows)*(cardHeight+CardGap) - CardGap
+		best = PageLayout{
+			PageWidth:  pageWidth,
+			PageHeight: pageHeight,
+			Landscape:  landscape,
+			Columns:    columns,
+			Rows:       rows,
+			CardWidth:  cardWidth,
+			CardHeight: cardHeight,
+			OriginX:    PageMargin + (usableWidth-gridWidth)/2,
+			OriginY:    PageMargin + HeaderHeight + (usableHeight-gridHeight)/2,
+		}
+	}
+
+	if best.CardsPerPage() == 0 {
+		return best, fmt.Errorf("a %.0fx%.0f mm card does not fit on a %.0fx%.0f mm page",
+			cardWidth, cardHeight, pageSize.Width, pageSize.Height)
+	}
+	return best, nil
+}
+
+// ImpositionExporter tiles cards onto printable pages with crop marks, registration marks,
+// a page header and a calibration ruler
+type ImpositionExporter struct {
+	PageSize    string    // "A4", "A3" or "Letter"
+	Title       string    // Pattern title
+	ShowGrid    bool      // Whether to draw the hole grid
+	ShowNumbers bool      // Whether to label each card
+	Transform   Transform // Orientation applied to the cards, noted in the header
+	TotalCards  int       // Cards in the whole set, when exporting part of it (0 = the cards given)
+}
+
+// NewImpositionExporter creates an imposition exporter with default settings
+func NewImpositionExporter() *ImpositionExporter {
+	return &ImpositionExporter{
+		PageSize:    "A4",
+		ShowGrid:    true,
+		ShowNumbers: true,
+	}
+}
+
+// ValidatePageSize checks if the page size is supported
+func ValidatePageSize(name string) error {
+	switch name {
+	case "A4", "A3", "Letter":
+		return nil
+	default:
+		return fmt.Errorf("invalid page size: %s (must be 'A4', 'A3', or 'Letter')", name)
+	}
+}
+
+// Layout returns the page layout for the cards
+func (e *ImpositionExporter) Layout(cards []*Card) (PageLayout, error) {
+	if len(cards) == 0 {
+		return PageLayout{}, fmt.Errorf("no cards to export")
+	}
+	if err := ValidatePageSize(e.PageSize); err != nil {
+		return PageLayout{}, err
+	}
+	return LayoutPage(GetPageSize(e.PageSize), CardDimensions{Width: cards[0].Width, Height: cards[0].Height})
+}
+
+// pageCount returns the number of pages needed for the cards
+func pageCount(cards []*Card, layout PageLayout) int {
+	return (len(cards) + layout.CardsPerPage() - 1) / layout.CardsPerPage()
+}
+
+// ExportPDF writes the imposed pages as a PDF document
+func (e *ImpositionExporter) ExportPDF(cards []*Card, w io.Writer) error {
+	layout, err := e.Layout(cards)
+	if err != nil {
+		return err
+	}
+
+	metadata := GetDefaultMetadata(len(cards))
+	if e.Title != "" {
+		metadata.Title = e.Title
+	}
+	doc := newPDFDocument(metadata)
+	for page := 0; page < pageCount(cards, layout); page++ {
+		e.drawPage(doc.addPage(layout.PageWidth, layout.PageHeight), cards, layout, page)
+	}
+	return doc.write(w)
+}
+
+// ExportSVGZip writes each imposed page as an SVG file in a ZIP archive
+func (e *ImpositionExporter) ExportSVGZip(cards []*Card, w io.Writer) error {
+	layout, err := e.Layout(cards)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for page := 0; page < pageCount(cards, layout); page++ {
+		f, err := archive.Create(fmt.Sprintf("page-%03d.svg", page+1))
+		if err != nil {
+			return err
+		}
+		c := newSVGCanvas(f, layout.PageWidth, layout.PageHeight, e.pageHeader(cards, layout, page))
+		e.drawPage(c, cards, layout, page)
+		c.close()
+	}
+	return archive.Close()
+}
+
+// pageHeader returns the title, page number and card range of a page
+func (e *ImpositionExporter) pageHeader(cards []*Card, layout PageLayout, page int) string {
+	first := page * layout.CardsPerPage()
+	last := first + layout.CardsPerPage() - 1
+	if last >= len(cards) {
+		last = len(cards) - 1
+	}
+
+	title := e.Title
+	if title == "" {
+		title = "Untitled Pattern"
+	}
+	section := ""
+	if cards[first].Section != "" {
+		section = "section " + cards[first].Section + ", "
+	}
+	header := fmt.Sprintf("%s - page %d of %d - %scards %d-%d of %d",
+		title, page+1, pageCount(cards, layout), section, cards[first].Number, cards[last].Number, e.totalCards(cards))
+	if !e.Transform.IsIdentity() {
+		header += fmt.Sprintf(" (transform: %s)", e.Transform)
+	}
+	return header
+}
+
+// totalCards returns the size of the set the cards belong to
+func (e *ImpositionExporter) totalCards(cards []*Card) int {
+	if e.TotalCards > len(cards) {
+		return e.TotalCards
+	}
+	return len(cards)
+}
+
+// drawPage draws one imposed page
+func (e *ImpositionExporter) drawPage(c canvas, cards []*Card, layout PageLayout, page int) {
+	c.text(PageMargin, PageMargin+HeaderHeight*0.5, 3.5, anchorStart, inkBlack, e.pageHeader(cards, layout, page))
+
+	first := page * layout.CardsPerPage()
+	for slot := 0; slot < layout.CardsPerPage() && first+slot < len(cards); slot++ {
+		x, y := layout.cardPosition(slot)
+		e.drawCard(c, cards[first+slot], x, y, e.totalCards(cards))
+		drawCropMarks(c, x, y, layout.CardWidth, layout.CardHeight)
+	}
+
+	drawRegistrationMarks(c, layout.PageWidth, layout.PageHeight)
+	drawRuler(c, PageMargin, layout.PageHeight-PageMargin-RulerHeight)
+}
+
+// drawCard draws a card template with its top-left corner at (x, y), laid out as the SVG export
+func (e *ImpositionExporter) drawCard(c canvas, card *Card, x, y float64, total int) {
+	width, height := CardSize(CardDimensions{Width: card.Width, Height: card.Height})
+	c.rect(x, y, width, height, 0.2, inkLightGray)
+
+	if e.ShowNumbers {
+		label := fmt.Sprintf("Card #%s/%d", card.Label(), total)
+		if e.Title != "" {
+			label = fmt.Sprintf("%s #%s/%d", e.Title, card.Label(), total)
+		}
+		c.text(x+width/2, y+TextHeight*0.8, TextHeight*0.6, anchorMiddle, inkBlack, label)
+	}
+
+	startX := x + CardPadding
+	startY := y + CardPadding + TextHeight
+	if e.ShowGrid {
+		endX := startX + float64(card.Width-1)*HoleSpacing
+		endY := startY + float64(card.Height-1)*HoleSpacing
+		for col := 0; col < card.Width; col++ {
+			cx := startX + float64(col)*HoleSpacing
+			c.line(cx, startY, cx, endY, 0.1, inkLightGray)
+		}
+		for row := 0; row < card.Height; row++ {
+			cy := startY + float64(row)*HoleSpacing
+			c.line(startX, cy, endX, cy, 0.1, inkLightGray)
+		}
+	}
+
+	for row := 0; row < card.Height; row++ {
+		for col := 0; col < card.Width; col++ {
+			cx := startX + float64(col)*HoleSpacing
+			cy := startY + float64(row)*HoleSpacing
+			if card.Matrix[row][col] == 1 {
+				c.circle(cx, cy, HoleRadius, true, 0, inkBlack)
+			} else {
+				c.circle(cx, cy, HoleRadius*0.3, false, 0.15, inkLightGray)
+			}
+		}
+	}
+
+	if e.ShowNumbers {
+		info := fmt.Sprintf("%dx%d | %d holes | Card %s", card.Width, card.Height, card.CountHoles(), card.Label())
+		c.text(x+width/2, y+height-TextHeight*0.3, TextHeight*0.5, anchorMiddle, inkGray, info)
+	}
+}
+
+// drawCropMarks draws short cut lines outside each corner of a card
+func drawCropMarks(c canvas, x, y, width, height float64) {
+	for _, corner := range [][2]float64{{x, y}, {x + width, y}, {x, y + height}, {x + width, y + height}} {
+		cx, cy := corner[0], corner[1]
+		dx, dy := -1.0, -1.0
+		if cx > x {
+			dx = 1
+		}
+		if cy > y {
+			dy = 1
+		}
+		c.line(cx+dx*CropMarkOffset, cy, cx+dx*(CropMarkOffset+CropMarkLength), cy, 0.2, inkBlack)
+		c.line(cx, cy+dy*CropMarkOffset, cx, cy+dy*(CropMarkOffset+CropMarkLength), 0.2, inkBlack)
+	}
+}
+
+// drawRegistrationMarks draws a target in the middle of each page margin
+func drawRegistrationMarks(c canvas, pageWidth, pageHeight float64) {
+	r := RegistrationDia / 2
+	centre := PageMargin / 2
+	for _, p := range [][2]float64{
+		{pageWidth / 2, centre},
+		{pageWidth / 2, pageHeight - centre},
+		{centre, pageHeight / 2},
+		{pageWidth - centre, pageHeight / 2},
+	} {
+		c.circle(p[0], p[1], r*0.6, false, 0.2, inkBlack)
+		c.line(p[0]-r, p[1], p[0]+r, p[1], 0.2, inkBlack)
+		c.line(p[0], p[1]-r, p[0], p[1]+r, 0.2, inkBlack)
+	}
+}
+
+// drawRuler draws a 100 mm calibration ruler with its top-left corner at (x, y)
+// Printed at 100% scale, the ruler measures exactly 100 mm
+func drawRuler(c canvas, x, y float64) {
+	base := y + 7
+	c.line(x, base, x+RulerLength, base, 0.2, inkBlack)
+	for mm := 0; mm <= RulerLength; mm++ {
+		tick := 1.5
+		switch {
+		case mm%10 == 0:
+			tick = 4
+			c.text(x+float64(mm), base-4.5, 2.5, anchorMiddle, inkBlack, fmt.Sprintf("%d", mm/10))
+		case mm%5 == 0:
+			tick = 2.5
+		}
+		c.line(x+float64(mm), base, x+float64(mm), base-tick, 0.15, inkBlack)
+	}
+	c.text(x, base+5, 2.8, anchorStart, inkGray,
+		fmt.Sprintf("Calibration: this ruler is %d mm (cm marks) when printed at 100%% / actual size", RulerLength))
+}
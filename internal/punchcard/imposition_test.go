@@ -0,0 +1,204 @@
+package punchcard
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func generateCards(t *testing.T, cardType CardType, n int) []*Card {
+	t.Helper()
+	dims := GetCardDimensions(cardType)
+	cards, err := NewGeneratorWithType(cardType).Generate(createTestMatrix(n, dims.Width*dims.Height))
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+	return cards
+}
+
+func TestLayoutPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      PDFPageSize
+		cardType  CardType
+		want      int
+		landscape bool
+	}{
+		{"26x8 on A4", PageSizeA4, CardType26x8, 3, false},
+		{"26x8 on A3", PageSizeA3, CardType26x8, 6, true},
+		{"50x12 on A4 turns the page", PageSizeA4, CardType50x12, 1, true},
+		{"50x12 on A3", PageSizeA3, CardType50x12, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := LayoutPage(tt.page, GetCardDimensions(tt.cardType))
+			if err != nil {
+				t.Fatalf("LayoutPage() error = %v", err)
+			}
+			if layout.CardsPerPage() != tt.want || layout.Landscape != tt.landscape {
+				t.Errorf("layout = %d cards (landscape %v), want %d (landscape %v)",
+					layout.CardsPerPage(), layout.Landscape, tt.want, tt.landscape)
+			}
+
+			// Every card stays inside the margins and its crop marks on the page
+			last := layout.CardsPerPage() - 1
+			x, y := layout.cardPosition(last)
+			if layout.OriginX < PageMargin-1e-9 ||
+				layout.OriginX-CropMarkOffset-CropMarkLength < 0 ||
+				x+layout.CardWidth > layout.PageWidth-PageMargin+1e-9 ||
+				y+layout.CardHeight > layout.PageHeight-PageMargin-RulerHeight+1e-9 {
+				t.Errorf("cards overflow the printable area: %+v", layout)
+			}
+		})
+	}
+
+	if _, err := LayoutPage(PDFPageSize{Width: 100, Height: 100}, GetCardDimensions(CardType26x8)); err == nil {
+		t.Error("expected an error when the card is larger than the page")
+	}
+}
+
+func TestCalculateCardsPerPage(t *testing.T) {
+	if n := CalculateCardsPerPage(PageSizeA4, GetCardDimensions(CardType26x8)); n != 3 {
+		t.Errorf("CalculateCardsPerPage(A4, 26x8) = %d, want 3", n)
+	}
+	if n := CalculateCardsPerPage(PageSizeA4, GetCardDimensions(CardType50x12)); n != 1 {
+		t.Errorf("CalculateCardsPerPage(A4, 50x12) = %d, want 1", n)
+	}
+	if n := CalculateCardsPerPage(PDFPageSize{Width: 50, Height: 50}, GetCardDimensions(CardType26x8)); n != 0 {
+		t.Errorf("CalculateCardsPerPage(tiny page) = %d, want 0", n)
+	}
+}
+
+func TestImpositionSVGZip(t *testing.T) {
+	cards := generateCards(t, CardType26x8, 7)
+	exporter := NewImpositionExporter()
+	exporter.Title = "Roses"
+
+	var buf bytes.Buffer
+	if err := exporter.ExportSVGZip(cards, &buf); err != nil {
+		t.Fatalf("ExportSVGZip() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a ZIP archive: %v", err)
+	}
+	// 7 cards at 3 per A4 page
+	if len(archive.File) != 3 {
+		t.Fatalf("pages = %d, want 3", len(archive.File))
+	}
+	if archive.File[0].Name != "page-001.svg" {
+		t.Errorf("first page name = %q", archive.File[0].Name)
+	}
+
+	f, err := archive.File[2].Open()
+	if err != nil {
+		t.Fatalf("Failed to open page: %v", err)
+	}
+	page, _ := io.ReadAll(f)
+	f.Close()
+	for _, want := range []string{`width="210.00mm"`, "Roses - page 3 of 3 - cards 7-7 of 7", "Calibration", "Roses #7/7"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("last page does not contain %q", want)
+		}
+	}
+}
+
+func TestImpositionPartOfSet(t *testing.T) {
+	cards, _ := CardRange{From: 5, To: 6}.Select(generateCards(t, CardType26x8, 7))
+	exporter := NewImpositionExporter()
+	exporter.Title = "Roses"
+	exporter.TotalCards = 7
+
+	var buf bytes.Buffer
+	if err := exporter.ExportSVGZip(cards, &buf); err != nil {
+		t.Fatalf("ExportSVGZip() error = %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a ZIP archive: %v", err)
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatalf("Failed to open page: %v", err)
+	}
+	page, _ := io.ReadAll(f)
+	f.Close()
+	for _, want := range []string{"Roses - page 1 of 1 - cards 5-6 of 7", "Roses #6/7"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+func TestImpositionRejectsUnknownPageSize(t *testing.T) {
+	exporter := NewImpositionExporter()
+	exporter.PageSize = "B5"
+	if err := exporter.ExportPDF(generateCards(t, CardType26x8, 1), io.Discard); err == nil {
+		t.Error("expected an error for an unsupported page size")
+	}
+}
+
+func TestPDFExporter(t *testing.T) {
+	cards := generateCards(t, CardType26x8, 4)
+	exporter := NewPDFExporter()
+	exporter.Title = "Roses (border)"
+
+	var buf bytes.Buffer
+	if err := exporter.ExportCards(cards, &buf); err != nil {
+		t.Fatalf("ExportCards() error = %v", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("output is not a complete PDF file")
+	}
+	if !strings.Contains(pdf, "/Count 2") {
+		t.Error("4 cards at 3 per page should give 2 pages")
+	}
+	if !strings.Contains(pdf, `(Roses \(border\) - page 1 of 2`) {
+		t.Error("page header should be written with escaped parentheses")
+	}
+
+	// The cross-reference table points at each object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(pdf[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := strconv.Itoa(i+1) + " 0 obj"; !strings.HasPrefix(pdf[offset:], want) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, pdf[offset:offset+8], want)
+		}
+	}
+
+	if err := exporter.ExportCards(nil, &buf); err == nil {
+		t.Error("expected an error for an empty card set")
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := map[string]string{
+		"plain":     "(plain)",
+		`a(b)\c`:    `(a\(b\)\\c)`,
+		"Väv":       `(V\344v)`,
+		"snow ☃":    "(snow ?)",
+		"tab\there": "(tab here)",
+		"4–9":       `(4\2269)`,
+	}
+	for in, want := range tests {
+		if got := pdfString(in); got != want {
+			t.Errorf("pdfString(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package punchcard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Card set JSON schema
+//
+// The JSON format is the machine-readable counterpart of the text format. A document
+// written by JSONExporter looks like this:
+//
+//	{
+//	  "schema": "loom-punchcards/card-set",
+//	  "version": 1,
+//	  "title": "Roses_Pattern",
+//	  "cardType": "26x8",
+//	  "settings": {
+//	    "colorMode": 2,
+//	    "source": "roses.png",
+//	    "options": {"transform": "none"}
+//	  },
+//	  "metadata": {
+//	    "totalCards": 2,
+//	    "cardWidth": 26,
+//	    "cardHeight": 8,
+//	    "totalRows": 16,
+//	    "holesPerCard": [104, 96],
+//	    "averageDensity": 48.1
+//	  },
+//	  "encoding": "bits",
+//	  "cards": [
+//	    {"number": 1, "rows": ["10101010101010101010101010", ...]},
+//	    ...
+//	  ]
+//	}
+//
+// Fields:
+//   - schema: always "loom-punchcards/card-set"
+//   - version: schema version; readers reject documents newer than JSONSchemaVersion
+//   - title: pattern title (may be empty)
+//   - cardType: one of the supported card types ("26x8", "50x12")
+//   - settings: how the cards were generated; optional when importing
+//   - metadata: derived from the cards on export and ignored on import
+//   - encoding: "bits" or "base64" (defaults to "bits" when omitted)
+//   - cards: one entry per card in chain order, each with Height rows
+//
+// With "bits" encoding every row is a string of Width characters, '1' for a punched hole
+// and '0' for no hole. With "base64" encoding every row is packed into bytes, most
+// significant bit first (hook 1 is the top bit of the first byte), padded with zero bits
+// to a whole byte and encoded with standard base64.
+
+const (
+	// JSONSchemaID identifies card set documents
+	JSONSchemaID = "loom-punchcards/card-set"
+
+	// JSONSchemaVersion is the schema version written by JSONExporter
+	JSONSchemaVersion = 1
+
+	// RowEncodingBits encodes each row as a string of '0' and '1' characters
+	RowEncodingBits = "bits"
+
+	// RowEncodingBase64 encodes each row as a base64 bitset
+	RowEncodingBase64 = "base64"
+)
+
+// GenerationSettings records how a card set was generated
+type GenerationSettings struct {
+	ColorMode int               `json:"colorMode,omitempty"` // 2, 4, or 8 (0 when not generated from an image)
+	Source    string            `json:"source,omitempty"`    // Original file name
+	Options   map[string]string `json:"options,omitempty"`   // Additional generation options
+}
+
+// SettingsOptionTransform is the settings option recording the applied Transform
+const SettingsOptionTransform = "transform"
+
+// transform returns the transform recorded in the settings, or the identity when absent or invalid
+func (s *GenerationSettings) transform() Transform {
+	if s == nil {
+		return Transform{}
+	}
+	t, _ := ParseTransform(s.Options[SettingsOptionTransform])
+	return t
+}
+
+// SetOption records an additional generation option
+func (s *GenerationSettings) SetOption(key, value string) {
+	if s.Options == nil {
+		s.Options = make(map[string]string)
+	}
+	s.Options[key] = value
+}
+
+// CardSetJSON is the top-level JSON document for a card set
+type CardSetJSON struct {
+	Schema   string              `json:"schema"`
+	Version  int                 `json:"version"`
+	Title    string              `json:"title"`
+	CardType CardType            `json:"cardType"`
+	Settings *GenerationSettings `json:"settings,omitempty"`
+	Metadata *Metadata           `json:"metadata,omitempty"`
+	Encoding string              `json:"encoding"`
+	Cards    []CardJSON          `json:"cards"`
+}
+
+// CardJSON is the JSON representation of a single card
+type CardJSON struct {
+	Number  int      `json:"number"`
+	Section string   `json:"section,omitempty"` // Section of a design split across several chains
+	Rows    []string `json:"rows"`
+}
+
+// JSONExporter handles exporting punchcards to the versioned JSON format
+type JSONExporter struct {
+	Title    string              // Pattern title
+	Settings *GenerationSettings // Optional generation settings
+	Encoding string              // RowEncodingBits or RowEncodingBase64
+	Indent   bool                // Pretty-print the output
+}
+
+// NewJSONExporter creates a new JSON exporter with default settings
+func NewJSONExporter() *JSONExporter {
+	return &JSONExporter{
+		Encoding: RowEncodingBits,
+		Indent:   true,
+	}
+}
+
+// SetTitle sets the title of the card set
+func (e *JSONExporter) SetTitle(title string) {
+	e.Title = title
+}
+
+// ExportCards exports multiple cards to JSON format
+func (e *JSONExporter) ExportCards(cards []*Card, w io.Writer) error {
+	doc, err := e.Document(cards)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	if e.Indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(doc)
+}
+
+// Document builds the JSON document for a set of cards without encoding it
+func (e *JSONExporter) Document(cards []*Card) (*CardSetJSON, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no cards to export")
+	}
+
+	encoding := e.Encoding
+	if encoding == "" {
+		encoding = RowEncodingBits
+	}
+	if encoding != RowEncodingBits && encoding != RowEncodingBase64 {
+		return nil, fmt.Errorf("invalid row encoding: %s (must be 'bits' or 'base64')", encoding)
+	}
+
+	cardType, err := CardTypeForDimensions(CardDimensions{Width: cards[0].Width, Height: cards[0].Height})
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &CardSetJSON{
+		Schema:   JSONSchemaID,
+		Version:  JSONSchemaVersion,
+		Title:    e.Title,
+		CardType: cardType,
+		Settings: e.Settings,
+		Metadata: GenerateMetadata(cards),
+		Encoding: encoding,
+		Cards:    make([]CardJSON, len(cards)),
+	}
+
+	for i, card := range cards {
+		if err := card.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid card %d: %w", i+1, err)
+		}
+		rows := make([]string, card.Height)
+		for y, row := range card.Matrix {
+			if encoding == RowEncodingBase64 {
+				rows[y] = encodeRowBase64(row)
+			} else {
+				rows[y] = encodeRowBits(row)
+			}
+		}
+		doc.Cards[i] = CardJSON{Number: card.Number, Section: card.Section, Rows: rows}
+	}
+
+	return doc, nil
+}
+
+// JSONParser handles parsing the JSON format back into cards
+type JSONParser struct{}
+
+// NewJSONParser creates a new JSON parser
+func NewJSONParser() *JSONParser {
+	return &JSONParser{}
+}
+
+// Parse parses a JSON card set document
+func (p *JSONParser) Parse(content []byte) (*ParseResult, error) {
+	var doc CardSetJSON
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON card set: %w", err)
+	}
+
+	if doc.Schema != JSONSchemaID {
+		return nil, fmt.Errorf("unsupported schema %q (expected %q)", doc.Schema, JSONSchemaID)
+	}
+	if doc.Version < 1 || doc.Version > JSONSchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d (supported: 1-%d)", doc.Version, JSONSchemaVersion)
+	}
+	if err := ValidateCardType(string(doc.CardType)); err != nil {
+		return nil, err
+	}
+	if len(doc.Cards) == 0 {
+		return nil, fmt.Errorf("card set contains no cards")
+	}
+
+	encoding := doc.Encoding
+	if encoding == "" {
+		encoding = RowEncodingBits
+	}
+	if encoding != RowEncodingBits && encoding != RowEncodingBase64 {
+		return nil, fmt.Errorf("invalid row encoding: %s (must be 'bits' or 'base64')", encoding)
+	}
+
+	dims := GetCardDimensions(doc.CardType)
+	result := &ParseResult{
+		Title:        doc.Title,
+		CardType:     doc.CardType,
+		Settings:     doc.Settings,
+		Transform:    doc.Settings.transform(),
+		Cards:        make([]*Card, 0, len(doc.Cards)),
+		TotalCards:   len(doc.Cards),
+		HolesPerCard: dims.Width * dims.Height,
+	}
+
+	numbers := map[string]int{}
+	for i, cj := range doc.Cards {
+		if len(cj.Rows) != dims.Height {
+			return nil, fmt.Errorf("card %d has %d rows, expected %d", i+1, len(cj.Rows), dims.Height)
+		}
+
+		matrix := make([][]int, dims.Height)
+		for y, row := range cj.Rows {
+			var err error
+			if encoding == RowEncodingBase64 {
+				matrix[y], err = decodeRowBase64(row, dims.Width)
+			} else {
+				matrix[y], err = decodeRowBits(row, dims.Width)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("card %d row %d: %w", i+1, y+1, err)
+			}
+		}
+
+		// Cards are renumbered in document order within their section, like the text parser does
+		numbers[cj.Section]++
+		card := &Card{
+			Number:  numbers[cj.Section],
+			Matrix:  matrix,
+			Width:   dims.Width,
+			Height:  dims.Height,
+			Section: cj.Section,
+		}
+		if err := card.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid card %d: %w", i+1, err)
+		}
+		result.Cards = append(result.Cards, card)
+	}
+
+	return result, nil
+}
+
+// ParseCardSet parses either the JSON or the text card set format, detected from the content
+func ParseCardSet(content []byte) (*ParseResult, error) {
+	if IsJSONCardSet(content) {
+		return NewJSONParser().Parse(content)
+	}
+	return NewTextParser().Parse(string(content))
+}
+
+// IsJSONCardSet reports whether the content looks like a JSON document
+func IsJSONCardSet(content []byte) bool {
+	trimmed := bytes.TrimLeft(content, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// encodeRowBits encodes a row as a string of '0' and '1' characters
+func encodeRowBits(row []int) string {
+	var sb strings.Builder
+	sb.Grow(len(row))
+	for _, v := range row {
+		if v == 1 {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	return sb.String()
+}
+
+// decodeRowBits decodes a row written by encodeRowBits
+func decodeRowBits(s string, width int) ([]int, error) {
+	if len(s) != width {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrWidthMismatch, width, len(s))
+	}
+	row := make([]int, width)
+	for i, c := range s {
+		switch c {
+		case '1':
+			row[i] = 1
+		case '0':
+		default:
+			return nil, fmt.Errorf("invalid character '%c' at column %d (expected 0 or 1)", c, i+1)
+		}
+	}
+	return row, nil
+}
+
+// encodeRowBase64 packs a row into bytes (most significant bit first) and encodes it
+func encodeRowBase64(row []int) string {
+	packed := make([]byte, (len(row)+7)/8)
+	for i, v := range row {
+		if v == 1 {
+			packed[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(packed)
+}
+
+// decodeRowBase64 decodes a row written by encodeRowBase64
+func decodeRowBase64(s string, width int) ([]int, error) {
+	packed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(packed) != (width+7)/8 {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrWidthMismatch, (width+7)/8, len(packed))
+	}
+	row := make([]int, width)
+	for i := range row {
+		if packed[i/8]&(0x80>>(i%8)) != 0 {
+			row[i] = 1
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,180 @@
+package punchcard
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONExporter_ExportCards(t *testing.T) {
+	cards := []*Card{createTestCard(1), createTestCard(2)}
+
+	exporter := NewJSONExporter()
+	exporter.SetTitle("Test Pattern")
+	exporter.Settings = &GenerationSettings{ColorMode: 4, Source: "test.png"}
+
+	var buf bytes.Buffer
+	if err := exporter.ExportCards(cards, &buf); err != nil {
+		t.Fatalf("ExportCards failed: %v", err)
+	}
+
+	var doc CardSetJSON
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+
+	if doc.Schema != JSONSchemaID {
+		t.Errorf("Schema = %q, want %q", doc.Schema, JSONSchemaID)
+	}
+	if doc.Version != JSONSchemaVersion {
+		t.Errorf("Version = %d, want %d", doc.Version, JSONSchemaVersion)
+	}
+	if doc.Title != "Test Pattern" {
+		t.Errorf("Title = %q, want 'Test Pattern'", doc.Title)
+	}
+	if doc.CardType != CardType26x8 {
+		t.Errorf("CardType = %q, want %q", doc.CardType, CardType26x8)
+	}
+	if doc.Settings == nil || doc.Settings.ColorMode != 4 || doc.Settings.Source != "test.png" {
+		t.Errorf("Settings not exported correctly: %+v", doc.Settings)
+	}
+	if doc.Metadata == nil || doc.Metadata.TotalCards != 2 {
+		t.Errorf("Metadata not exported correctly: %+v", doc.Metadata)
+	}
+	if len(doc.Cards) != 2 {
+		t.Fatalf("expected 2 cards, got %d", len(doc.Cards))
+	}
+	if len(doc.Cards[0].Rows) != CardHeight || len(doc.Cards[0].Rows[0]) != CardWidth {
+		t.Errorf("card rows have wrong shape: %d rows of %d", len(doc.Cards[0].Rows), len(doc.Cards[0].Rows[0]))
+	}
+}
+
+func TestJSONExporter_ExportCardsEmpty(t *testing.T) {
+	exporter := NewJSONExporter()
+	var buf bytes.Buffer
+	if err := exporter.ExportCards([]*Card{}, &buf); err == nil {
+		t.Error("ExportCards with no cards should return error")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	generator := NewGeneratorWithType(CardType50x12)
+	dims := generator.Dimensions
+	cards, err := generator.Generate(createTestMatrix(3, dims.Width*dims.Height))
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	for _, encoding := range []string{RowEncodingBits, RowEncodingBase64} {
+		t.Run(encoding, func(t *testing.T) {
+			exporter := NewJSONExporter()
+			exporter.SetTitle("Round Trip")
+			exporter.Encoding = encoding
+
+			var buf bytes.Buffer
+			if err := exporter.ExportCards(cards, &buf); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+
+			result, err := NewJSONParser().Parse(buf.Bytes())
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			if result.Title != "Round Trip" {
+				t.Errorf("Title = %q, want 'Round Trip'", result.Title)
+			}
+			if result.CardType != CardType50x12 {
+				t.Errorf("CardType = %q, want %q", result.CardType, CardType50x12)
+			}
+			if len(result.Cards) != len(cards) {
+				t.Fatalf("Card count mismatch: expected %d, got %d", len(cards), len(result.Cards))
+			}
+			for i := range cards {
+				for y := 0; y < dims.Height; y++ {
+					for x := 0; x < dims.Width; x++ {
+						if cards[i].Matrix[y][x] != result.Cards[i].Matrix[y][x] {
+							t.Fatalf("Card %d [%d][%d]: expected %d, got %d",
+								i+1, y, x, cards[i].Matrix[y][x], result.Cards[i].Matrix[y][x])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestJSONParser_ParseInvalid(t *testing.T) {
+	validRow := `"` + strings.Repeat("0", CardWidth) + `"`
+	rows := strings.TrimSuffix(strings.Repeat(validRow+",", CardHeight), ",")
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not json", `{"schema":`},
+		{"wrong schema", `{"schema":"other","version":1,"cardType":"26x8","cards":[{"number":1,"rows":[` + rows + `]}]}`},
+		{"future version", `{"schema":"loom-punchcards/card-set","version":99,"cardType":"26x8","cards":[{"number":1,"rows":[` + rows + `]}]}`},
+		{"invalid card type", `{"schema":"loom-punchcards/card-set","version":1,"cardType":"10x10","cards":[{"number":1,"rows":[` + rows + `]}]}`},
+		{"no cards", `{"schema":"loom-punchcards/card-set","version":1,"cardType":"26x8","cards":[]}`},
+		{"too few rows", `{"schema":"loom-punchcards/card-set","version":1,"cardType":"26x8","cards":[{"number":1,"rows":[` + validRow + `]}]}`},
+		{"invalid bit", `{"schema":"loom-punchcards/card-set","version":1,"cardType":"26x8","cards":[{"number":1,"rows":[` +
+			strings.Replace(rows, "0", "x", 1) + `]}]}`},
+		{"invalid encoding", `{"schema":"loom-punchcards/card-set","version":1,"cardType":"26x8","encoding":"hex","cards":[{"number":1,"rows":[` + rows + `]}]}`},
+	}
+
+	parser := NewJSONParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parser.Parse([]byte(tt.input)); err == nil {
+				t.Errorf("Expected parse to fail for %s, but it succeeded", tt.name)
+			}
+		})
+	}
+}
+
+func TestParseCardSet(t *testing.T) {
+	cards := []*Card{createTestCard(1)}
+
+	var textBuf, jsonBuf bytes.Buffer
+	if err := NewTextExporter().ExportCards(cards, &textBuf); err != nil {
+		t.Fatalf("text export failed: %v", err)
+	}
+	if err := NewJSONExporter().ExportCards(cards, &jsonBuf); err != nil {
+		t.Fatalf("JSON export failed: %v", err)
+	}
+
+	for name, content := range map[string][]byte{"text": textBuf.Bytes(), "json": jsonBuf.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			result, err := ParseCardSet(content)
+			if err != nil {
+				t.Fatalf("ParseCardSet failed: %v", err)
+			}
+			if len(result.Cards) != 1 {
+				t.Errorf("expected 1 card, got %d", len(result.Cards))
+			}
+			if result.CardType != CardType26x8 {
+				t.Errorf("CardType = %q, want %q", result.CardType, CardType26x8)
+			}
+		})
+	}
+}
+
+func TestEncodeRowBase64(t *testing.T) {
+	row := []int{1, 0, 0, 0, 0, 0, 0, 1, 1}
+	encoded := encodeRowBase64(row)
+	if encoded != "gYA=" {
+		t.Errorf("encodeRowBase64() = %q, want %q", encoded, "gYA=")
+	}
+
+	decoded, err := decodeRowBase64(encoded, len(row))
+	if err != nil {
+		t.Fatalf("decodeRowBase64() error = %v", err)
+	}
+	for i := range row {
+		if decoded[i] != row[i] {
+			t.Errorf("bit %d = %d, want %d", i, decoded[i], row[i])
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package punchcard
+
+import "fmt"
+
+// JoinReport describes the join of an endless chain, where the last card is laced to the first
+type JoinReport struct {
+	Cards         int      `json:"cards"`            // Cards in the loop
+	LongestFloat  int      `json:"longestFloat"`     // Longest warp float anywhere in the loop, counted around the join
+	ChainFloat    int      `json:"chainFloat"`       // Longest warp float that does not cross the join
+	JoinFloat     int      `json:"joinFloat"`        // Longest warp float crossing the join (0 when none does)
+	JoinFloatHook int      `json:"joinFloatHook"`    // Hook of the longest float crossing the join, counted from 1
+	JoinChange    float64  `json:"joinChangePct"`    // Percentage of hooks changing from the last card to the first
+	AverageChange float64  `json:"averageChangePct"` // Mean percentage of hooks changing between neighbouring cards
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// Seamless reports whether the join raised no warnings
+func (r *JoinReport) Seamless() bool {
+	return len(r.Warnings) == 0
+}
+
+// CheckJoin checks an endless chain for floats and discontinuities at its join
+// It warns when the join makes a warp float longer than any inside the chain, or than maxFloat
+// picks when that is set, and when the join changes markedly more hooks than other cards do:
+// over twice the average and at least 10 points more
+func CheckJoin(cards []*Card, maxFloat int) *JoinReport {
+	report := &JoinReport{Cards: len(cards)}
+	n := len(cards)
+	if n == 0 {
+		return report
+	}
+	hooks := cards[0].Width * cards[0].Height
+	lift := func(card *Card, hook int) int {
+		return card.Matrix[hook/card.Width][hook%card.Width]
+	}
+
+	// Warp floats: the runs at either end of a hook join into one around the loop
+	report.ChainFloat, _ = LongestFloats(cards)
+	for hook := 0; hook < hooks; hook++ {
+		first, last := lift(cards[0], hook), lift(cards[n-1], hook)
+		if first != last {
+			continue
+		}
+		lead := 1
+		for lead < n && lift(cards[lead], hook) == first {
+			lead++
+		}
+		if lead == n {
+			continue // A hook that never changes floats the whole chain, join or not
+		}
+		trail := 0
+		for lift(cards[n-1-trail], hook) == first {
+			trail++
+		}
+		if float := lead + trail; float > report.JoinFloat {
+			report.JoinFloat, report.JoinFloatHook = float, hook+1
+		}
+	}
+	report.LongestFloat = report.ChainFloat
+	if report.JoinFloat > report.LongestFloat {
+		report.LongestFloat = report.JoinFloat
+	}
+
+	// Continuity: the share of hooks changing at the join against the rest of the chain
+	changed := func(a, b *Card) int {
+		count := 0
+		for hook := 0; hook < hooks; hook++ {
+			if lift(a, hook) != lift(b, hook) {
+				count++
+			}
+		}
+		return count
+	}
+	report.JoinChange = percent(changed(cards[n-1], cards[0]), hooks)
+	if n > 1 {
+		total := 0
+		for i := 1; i < n; i++ {
+			total += changed(cards[i-1], cards[i])
+		}
+		report.AverageChange = percent(total, hooks*(n-1))
+	}
+
+	if report.JoinFloat > report.ChainFloat {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"the join makes a warp float of %d picks on hook %d, longer than any in the chain (%d)",
+			report.JoinFloat, report.JoinFloatHook, report.ChainFloat))
+	}
+	if maxFloat > 0 && report.JoinFloat > maxFloat {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"the join makes a warp float of %d picks on hook %d, over the limit of %d",
+			report.JoinFloat, report.JoinFloatHook, maxFloat))
+	}
+	if n > 1 && report.JoinChange > 2*report.AverageChange && report.JoinChange-report.AverageChange >= 10 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"the join changes %.1f%% of hooks, against %.1f%% between other cards",
+			report.JoinChange, report.AverageChange))
+	}
+	return report
+}
+
+// CheckLoop checks the join of an endless chain made by the generator
+// Besides CheckJoin, it warns when the chain is not a whole number of repeats of a weave tiled over
+// it, the selvedge, border and damask weaves, which breaks the structure at the join
+func (g *Generator) CheckLoop(cards []*Card, maxFloat int) *JoinReport {
+	report := CheckJoin(cards, maxFloat)
+	repeats := g.Repeats
+	if repeats < 1 {
+		repeats = 1
+	}
+
+	var weaves []*Weave
+	if g.LeftSelvedge+g.RightSelvedge > 0 {
+		weaves = append(weaves, orPlain(g.SelvedgeWeave))
+	}
+	if g.HeaderCards+g.FooterCards > 0 || (repeats > 1 && g.SeparatorCards > 0) {
+		weaves = append(weaves, orPlain(g.BorderWeave))
+	}
+	if g.Damask != nil {
+		weaves = append(weaves, g.Damask.Figure, g.Damask.Ground)
+	}
+
+	seen := map[string]bool{}
+	for _, w := range weaves {
+		if w == nil || seen[w.String()] || len(cards)%w.Picks() == 0 {
+			continue
+		}
+		seen[w.String()] = true
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"the chain of %d cards is not a whole number of %s repeats (%d picks), so the weave breaks at the join",
+			len(cards), w.Name, w.Picks()))
+	}
+	return report
+}
+
+// orPlain returns a weave, or the plain weave the generator uses in its place when it is nil
+func orPlain(w *Weave) *Weave {
+	if w == nil {
+		return plainWeave
+	}
+	return w
+}
+
+// plainWeave is the weave of selvedges and borders when none is chosen
+var plainWeave, _ = WeaveByName("plain")
+
+// percent returns part as a percentage of whole, or 0 when whole is 0
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+// JoinCards returns the last n and first n cards of a chain, the cards either side of its join
+// When the chain has 2n cards or fewer, it is returned whole
+func JoinCards(cards []*Card, n int) []*Card {
+	if n < 1 || 2*n >= len(cards) {
+		return cards
+	}
+	join := make([]*Card, 0, 2*n)
+	join = append(join, cards[len(cards)-n:]...)
+	return append(join, cards[:n]...)
+}
@@ -0,0 +1,117 @@
+package punchcard
+
+import (
+	"strings"
+	"testing"
+)
+
+// stripeCards generates cards from rows that are all 1 or all 0
+func stripeCards(t *testing.T, g *Generator, rows ...int) []*Card {
+	t.Helper()
+	matrix := make([][]int, len(rows))
+	for y, v := range rows {
+		matrix[y] = make([]int, g.DesignWidth())
+		for x := range matrix[y] {
+			matrix[y][x] = v
+		}
+	}
+	cards, err := g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	return cards
+}
+
+func TestCheckJoinFloats(t *testing.T) {
+	// Two raised picks at each end meet at the join as a float of four, longer than the three inside
+	cards := stripeCards(t, NewGenerator(), 1, 1, 0, 0, 0, 1, 0, 1, 1)
+	report := CheckJoin(cards, 0)
+	if report.ChainFloat != 3 || report.JoinFloat != 4 || report.LongestFloat != 4 || report.JoinFloatHook != 1 {
+		t.Errorf("CheckJoin() floats = chain %d, join %d on hook %d, longest %d, want 3, 4 on hook 1, 4",
+			report.ChainFloat, report.JoinFloat, report.JoinFloatHook, report.LongestFloat)
+	}
+	if report.Seamless() || !strings.Contains(report.Warnings[0], "longer than any in the chain") {
+		t.Errorf("CheckJoin() warnings = %q, want a float warning", report.Warnings)
+	}
+
+	// A limit on floats warns even when the chain has longer ones
+	cards = stripeCards(t, NewGenerator(), 1, 0, 0, 0, 0, 0, 1)
+	if report := CheckJoin(cards, 1); len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "over the limit of 1") {
+		t.Errorf("CheckJoin() warnings = %q, want a limit warning", report.Warnings)
+	}
+}
+
+func TestCheckJoinContinuity(t *testing.T) {
+	// A chain that changes slowly, then jumps back at the join
+	g := NewGenerator()
+	matrix := make([][]int, 8)
+	for y := range matrix {
+		matrix[y] = make([]int, 208)
+		for x := 0; x < y*26; x++ {
+			matrix[y][x] = 1
+		}
+	}
+	cards, err := g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	report := CheckJoin(cards, 0)
+	if report.JoinChange != 87.5 || report.AverageChange != 12.5 {
+		t.Errorf("CheckJoin() change = %.1f%% at the join, %.1f%% on average, want 87.5%%, 12.5%%",
+			report.JoinChange, report.AverageChange)
+	}
+	if report.Seamless() {
+		t.Error("CheckJoin() should warn about the discontinuity")
+	}
+
+	// Alternating cards change every hook everywhere, so the join is no worse than the rest
+	if report := CheckJoin(stripeCards(t, g, 1, 0, 1, 0), 0); !report.Seamless() {
+		t.Errorf("CheckJoin() of alternating cards warnings = %q", report.Warnings)
+	}
+}
+
+func TestCheckLoopWeaveRepeats(t *testing.T) {
+	satin, _ := WeaveByName("satin-5")
+	g := NewGenerator()
+	g.LeftSelvedge, g.RightSelvedge = 2, 2
+	g.Damask = &Damask{Figure: satin}
+
+	// Five design picks hold whole satin repeats, but an odd chain breaks the plain selvedge
+	report := g.CheckLoop(stripeCards(t, g, 1, 1, 1, 1, 1), 0)
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "plain repeats") {
+		t.Errorf("CheckLoop() warnings = %q, want the selvedge warning", report.Warnings)
+	}
+
+	// Five plain header cards make the chain whole plain and satin repeats again
+	g.HeaderCards = 5
+	report = g.CheckLoop(stripeCards(t, g, 1, 1, 1, 1, 1), 0)
+	for _, w := range report.Warnings {
+		if strings.Contains(w, "repeats") {
+			t.Errorf("CheckLoop() of whole repeats warns %q", w)
+		}
+	}
+}
+
+func TestJoinCards(t *testing.T) {
+	cards := createRangeTestCards(10)
+	join := JoinCards(cards, 2)
+	var numbers []int
+	for _, c := range join {
+		numbers = append(numbers, c.Number)
+	}
+	if len(numbers) != 4 || numbers[0] != 9 || numbers[1] != 10 || numbers[2] != 1 || numbers[3] != 2 {
+		t.Errorf("JoinCards() = cards %v, want 9 10 1 2", numbers)
+	}
+	if got := JoinCards(cards, 5); len(got) != 10 {
+		t.Errorf("JoinCards() of a short chain = %d cards, want all 10", len(got))
+	}
+}
+
+func TestCheckJoinIgnoresConstantHooks(t *testing.T) {
+	// Hooks that never change float the whole chain, which is no fault of the join
+	report := CheckJoin(stripeCards(t, NewGenerator(), 1, 1, 1), 2)
+	if report.JoinFloat != 0 || report.ChainFloat != 3 || !report.Seamless() {
+		t.Errorf("CheckJoin() = join float %d, chain float %d, warnings %q, want 0, 3, none",
+			report.JoinFloat, report.ChainFloat, report.Warnings)
+	}
+}
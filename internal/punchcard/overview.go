@@ -0,0 +1,140 @@
+package punchcard
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// OverviewGutter is the width in pixels of the ruler left of the design in an overview
+const OverviewGutter = 8
+
+// Overview palette indexes
+const (
+	overviewPaper = iota
+	overviewHole
+	overviewMarkedPaper
+	overviewMarkedHole
+	overviewGutter
+	overviewTick
+	overviewMajorTick
+)
+
+var overviewPalette = color.Palette{
+	overviewPaper:       color.RGBA{0xff, 0xff, 0xff, 0xff},
+	overviewHole:        color.RGBA{0x1a, 0x1a, 0x1a, 0xff},
+	overviewMarkedPaper: color.RGBA{0xd6, 0xe2, 0xf5, 0xff},
+	overviewMarkedHole:  color.RGBA{0x2a, 0x4a, 0x8a, 0xff},
+	overviewGutter:      color.RGBA{0xf0, 0xf0, 0xf0, 0xff},
+	overviewTick:        color.RGBA{0x9a, 0x9a, 0x9a, 0xff},
+	overviewMajorTick:   color.RGBA{0x33, 0x33, 0x33, 0xff},
+}
+
+// OverviewExporter renders a whole card set as one compact bitmap
+// Each card is a row of pixels holding its holes in reading order, so the rows together show
+// the woven design; a ruler on the left marks every card boundary, with a long mark and a faint
+// line across the design at the start of every MarkEvery cards
+type OverviewExporter struct {
+	Scale     int // Pixels per hole, across and down
+	MarkEvery int // Cards between long marks (0 = no long marks)
+	MaxPixels int // Largest image rendered; the scale is reduced to fit (0 = no limit)
+}
+
+// NewOverviewExporter creates an overview exporter with default settings
+func NewOverviewExporter() *OverviewExporter {
+	return &OverviewExporter{
+		Scale:     2,
+		MarkEvery: 10,
+		MaxPixels: 16000000,
+	}
+}
+
+// fitScale returns the largest scale up to Scale that keeps an overview within MaxPixels
+func (e *OverviewExporter) fitScale(cards []*Card) (int, error) {
+	holes := cards[0].Width * cards[0].Height
+	scale := e.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	for ; scale >= 1; scale-- {
+		pixels := (OverviewGutter + holes*scale) * len(cards) * scale
+		if e.MaxPixels == 0 || pixels <= e.MaxPixels {
+			return scale, nil
+		}
+	}
+	return 0, fmt.Errorf("overview of %d cards is larger than %d pixels", len(cards), e.MaxPixels)
+}
+
+// Render draws the overview of the cards
+// Card i (counted from 0) occupies the rows from i*RowHeight down, where RowHeight is the image
+// height divided by the number of cards
+func (e *OverviewExporter) Render(cards []*Card) (*image.Paletted, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no cards to export")
+	}
+	for i, card := range cards {
+		if err := card.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid card %d: %w", i+1, err)
+		}
+		if card.Width != cards[0].Width || card.Height != cards[0].Height {
+			return nil, fmt.Errorf("card %d is %dx%d but card 1 is %dx%d", card.Number, card.Width, card.Height, cards[0].Width, cards[0].Height)
+		}
+	}
+
+	scale, err := e.fitScale(cards)
+	if err != nil {
+		return nil, err
+	}
+	holes := cards[0].Width * cards[0].Height
+	img := image.NewPaletted(image.Rect(0, 0, OverviewGutter+holes*scale, len(cards)*scale), overviewPalette)
+
+	for i, card := range cards {
+		top := i * scale
+		major := e.MarkEvery > 0 && (card.Number-1)%e.MarkEvery == 0
+
+		for dy := 0; dy < scale; dy++ {
+			y := top + dy
+			// A faint line across the design marks the start of a group, when a card is tall enough to keep its holes visible
+			marked := major && dy == 0 && scale > 1
+
+			for x := 0; x < OverviewGutter; x++ {
+				img.SetColorIndex(x, y, overviewGutter)
+			}
+			switch {
+			case dy == 0 && major:
+				for x := 0; x < OverviewGutter; x++ {
+					img.SetColorIndex(x, y, overviewMajorTick)
+				}
+			case dy == 0 && scale > 1:
+				for x := OverviewGutter / 2; x < OverviewGutter; x++ {
+					img.SetColorIndex(x, y, overviewTick)
+				}
+			}
+
+			for hole := 0; hole < holes; hole++ {
+				index := uint8(overviewPaper)
+				if card.Matrix[hole/card.Width][hole%card.Width] == 1 {
+					index = overviewHole
+				}
+				if marked {
+					index += overviewMarkedPaper
+				}
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(OverviewGutter+hole*scale+dx, y, index)
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// ExportPNG writes the overview of the cards as a PNG image
+func (e *OverviewExporter) ExportPNG(cards []*Card, w io.Writer) error {
+	img, err := e.Render(cards)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,85 @@
+package punchcard
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestOverviewRender(t *testing.T) {
+	cards := createTransformTestCards() // Two 3x2 cards
+	e := NewOverviewExporter()
+	e.Scale = 3
+	e.MarkEvery = 2
+
+	img, err := e.Render(cards)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != OverviewGutter+6*3 || h != 2*3 {
+		t.Fatalf("size = %dx%d, want %dx%d", w, h, OverviewGutter+6*3, 6)
+	}
+
+	// Card 1 starts a group: its first row is marked across the design
+	if got := img.ColorIndexAt(OverviewGutter, 0); got != overviewMarkedHole {
+		t.Errorf("marked hole index = %d, want %d", got, overviewMarkedHole)
+	}
+	if got := img.ColorIndexAt(OverviewGutter, 1); got != overviewHole {
+		t.Errorf("hole index = %d, want %d", got, overviewHole)
+	}
+	if got := img.ColorIndexAt(0, 0); got != overviewMajorTick {
+		t.Errorf("ruler at card 1 = %d, want a long mark", got)
+	}
+
+	// Card 2 has holes at positions 4 and 5 (its second row), and a short mark
+	if got := img.ColorIndexAt(OverviewGutter+4*3, 4); got != overviewHole {
+		t.Errorf("card 2 hole index = %d, want %d", got, overviewHole)
+	}
+	if got := img.ColorIndexAt(OverviewGutter, 4); got != overviewPaper {
+		t.Errorf("card 2 paper index = %d, want %d", got, overviewPaper)
+	}
+	if got := img.ColorIndexAt(0, 3); got != overviewGutter {
+		t.Errorf("ruler left half at card 2 = %d, want gutter", got)
+	}
+	if got := img.ColorIndexAt(OverviewGutter-1, 3); got != overviewTick {
+		t.Errorf("ruler right half at card 2 = %d, want a short mark", got)
+	}
+}
+
+func TestOverviewScaleFitsMaxPixels(t *testing.T) {
+	cards := createTransformTestCards()
+	e := NewOverviewExporter()
+	e.Scale = 4
+	e.MaxPixels = (OverviewGutter + 6*2) * 2 * 2 // Fits scale 2, not 3
+
+	img, err := e.Render(cards)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if h := img.Bounds().Dy(); h != 4 {
+		t.Errorf("height = %d, want the scale reduced to 2 (4 px)", h)
+	}
+
+	e.MaxPixels = 10
+	if _, err := e.Render(cards); err == nil {
+		t.Error("Render() should fail when even scale 1 is too large")
+	}
+}
+
+func TestOverviewExportPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewOverviewExporter().ExportPNG(createTransformTestCards(), &buf); err != nil {
+		t.Fatalf("ExportPNG() error = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("output is not a PNG: %v", err)
+	}
+	if h := img.Bounds().Dy(); h != 4 {
+		t.Errorf("height = %d, want 4", h)
+	}
+
+	if err := NewOverviewExporter().ExportPNG(nil, &buf); err == nil {
+		t.Error("ExportPNG() with no cards should fail")
+	}
+}
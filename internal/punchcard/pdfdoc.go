@@ -0,0 +1,182 @@
+package punchcard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mmToPoint converts millimetres to PDF points (1/72 inch)
+const mmToPoint = 72 / 25.4
+
+// courierAdvance is the advance width of every Courier glyph as a fraction of the font size
+const courierAdvance = 0.6
+
+// bezierCircle is the control point distance that approximates a quarter circle with a cubic curve
+const bezierCircle = 0.5523
+
+// pdfDocument is a minimal PDF 1.4 writer for vector pages drawn with the built-in Courier font
+type pdfDocument struct {
+	metadata *PDFMetadata
+	pages    []*pdfPage
+}
+
+// pdfPage is one page of a pdfDocument and draws its content stream as a canvas
+type pdfPage struct {
+	widthMm  float64
+	heightMm float64
+	content  bytes.Buffer
+}
+
+// newPDFDocument creates an empty document with the given metadata
+func newPDFDocument(metadata *PDFMetadata) *pdfDocument {
+	return &pdfDocument{metadata: metadata}
+}
+
+// addPage appends a page of the given size in millimetres
+func (d *pdfDocument) addPage(widthMm, heightMm float64) *pdfPage {
+	page := &pdfPage{widthMm: widthMm, heightMm: heightMm}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// write serialises the document with its cross-reference table
+func (d *pdfDocument) write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		return fmt.Errorf("no pages to write")
+	}
+
+	// Objects 1-4 are the catalog, page tree, font and info; each page then takes two objects
+	var out bytes.Buffer
+	offsets := []int{0}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets)-1, body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object(d.info())
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			page.widthMm*mmToPoint, page.heightMm*mmToPoint, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets))
+	for _, offset := range offsets[1:] {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets), xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// info returns the document information dictionary
+func (d *pdfDocument) info() string {
+	m := d.metadata
+	if m == nil {
+		return "<< >>"
+	}
+	return fmt.Sprintf("<< /Title %s /Author %s /Subject %s /Creator %s /Producer %s /Keywords %s >>",
+		pdfString(m.Title), pdfString(m.Author), pdfString(m.Subject), pdfString(m.Creator),
+		pdfString(m.Producer), pdfString(strings.Join(m.Keywords, ", ")))
+}
+
+// winAnsiPunctuation maps the typographic characters WinAnsi adds to Latin-1 to their codes
+var winAnsiPunctuation = map[rune]byte{
+	'•': 0x95, // Bullet
+	'–': 0x96, // En dash
+	'—': 0x97, // Em dash
+}
+
+// pdfString encodes text as a PDF literal string in WinAnsi (Latin-1) encoding
+// Characters outside Latin-1 and winAnsiPunctuation are replaced with '?'
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 128:
+			b.WriteRune(r)
+		case r >= 160 && r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsiPunctuation[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsiPunctuation[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// x converts a horizontal position in millimetres to points
+func (p *pdfPage) x(mm float64) float64 {
+	return mm * mmToPoint
+}
+
+// y converts a vertical position measured down from the top in millimetres to PDF points
+func (p *pdfPage) y(mm float64) float64 {
+	return (p.heightMm - mm) * mmToPoint
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "%.3f G %.3f w %.2f %.2f m %.2f %.2f l S\n",
+		gray, width*mmToPoint, p.x(x1), p.y(y1), p.x(x2), p.y(y2))
+}
+
+func (p *pdfPage) rect(x, y, w, h, width, gray float64) {
+	fmt.Fprintf(&p.content, "%.3f G %.3f w %.2f %.2f %.2f %.2f re S\n",
+		gray, width*mmToPoint, p.x(x), p.y(y+h), w*mmToPoint, h*mmToPoint)
+}
+
+func (p *pdfPage) circle(cx, cy, r float64, filled bool, width, gray float64) {
+	x, y, rr := p.x(cx), p.y(cy), r*mmToPoint
+	k := rr * bezierCircle
+
+	if filled {
+		fmt.Fprintf(&p.content, "%.3f g\n", gray)
+	} else {
+		fmt.Fprintf(&p.content, "%.3f G %.3f w\n", gray, width*mmToPoint)
+	}
+	fmt.Fprintf(&p.content, "%.2f %.2f m\n", x+rr, y)
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x+rr, y+k, x+k, y+rr, x, y+rr)
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x-k, y+rr, x-rr, y+k, x-rr, y)
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x-rr, y-k, x-k, y-rr, x, y-rr)
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x+k, y-rr, x+rr, y-k, x+rr, y)
+	if filled {
+		p.content.WriteString("f\n")
+	} else {
+		p.content.WriteString("S\n")
+	}
+}
+
+func (p *pdfPage) text(x, y, size float64, anchor textAnchor, gray float64, s string) {
+	sizePt := size * mmToPoint
+	width := float64(len([]rune(s))) * courierAdvance * sizePt
+	left := p.x(x)
+	switch anchor {
+	case anchorMiddle:
+		left -= width / 2
+	case anchorEnd:
+		left -= width
+	}
+	fmt.Fprintf(&p.content, "BT %.3f g /F1 %.2f Tf %.2f %.2f Td %s Tj ET\n", gray, sizePt, left, p.y(y), pdfString(s))
+}
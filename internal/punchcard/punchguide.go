@@ -0,0 +1,343 @@
+package punchcard
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Punching guide PDF layout in millimetres
+const (
+	GuideFontSize   = 3.2 // Text size of the guide lines
+	GuideLineHeight = 5.0 // Distance between guide lines
+	GuideIndent     = 6.0 // Indent of the row lines under their card
+	GuideBoxSize    = 3.0 // Side of a progress checkbox
+)
+
+// HoleRun is a run of consecutive punched columns in a card row, numbered from 1
+type HoleRun struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Holes returns the number of holes in the run
+func (r HoleRun) Holes() int {
+	return r.To - r.From + 1
+}
+
+// String formats the run as "4–9", or "14" for a single hole
+func (r HoleRun) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("%d", r.From)
+	}
+	return fmt.Sprintf("%d–%d", r.From, r.To)
+}
+
+// HoleRuns run-length compresses a card row into the runs of punched columns
+func HoleRuns(row []int) []HoleRun {
+	var runs []HoleRun
+	for x := 0; x < len(row); x++ {
+		if row[x] != 1 {
+			continue
+		}
+		start := x
+		for x+1 < len(row) && row[x+1] == 1 {
+			x++
+		}
+		runs = append(runs, HoleRun{From: start + 1, To: x + 1})
+	}
+	return runs
+}
+
+// GuideRow lists the holes to punch in one row of a card
+type GuideRow struct {
+	Row   int       // Row number, starting at 1 from the top
+	Runs  []HoleRun // Punched columns
+	Holes int       // Holes in the row
+}
+
+// Columns returns the runs as a list such as "4–9, 14, 20–22", or "none"
+func (r GuideRow) Columns() string {
+	if len(r.Runs) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(r.Runs))
+	for i, run := range r.Runs {
+		parts[i] = run.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GuideCard is the punching guide for one card
+type GuideCard struct {
+	Number int
+	Label  string // Number with any section, e.g. "A-17"
+	Rows   []GuideRow
+	Holes  int // Holes on the card, equal to CountHoles
+}
+
+// NewGuideCard builds the punching guide for a card
+func NewGuideCard(card *Card) GuideCard {
+	guide := GuideCard{Number: card.Number, Label: card.Label(), Rows: make([]GuideRow, card.Height)}
+	for y := 0; y < card.Height; y++ {
+		row := GuideRow{Row: y + 1, Runs: HoleRuns(card.Matrix[y])}
+		for _, run := range row.Runs {
+			row.Holes += run.Holes()
+		}
+		guide.Rows[y] = row
+		guide.Holes += row.Holes
+	}
+	return guide
+}
+
+// PunchingGuideExporter writes row-by-row punching instructions for punching cards by hand
+// Each card and row has a progress checkbox, and the hole totals let the punched card be checked
+type PunchingGuideExporter struct {
+	Title     string    // Pattern title
+	PageSize  string    // "A4", "A3" or "Letter" for the PDF guide
+	Transform Transform // Orientation applied to the cards, noted in the header
+}
+
+// NewPunchingGuideExporter creates a punching guide exporter with default settings
+func NewPunchingGuideExporter() *PunchingGuideExporter {
+	return &PunchingGuideExporter{
+		PageSize: "A4",
+	}
+}
+
+// punchingGuide holds everything the guide formats print
+type punchingGuide struct {
+	Title     string
+	CardType  string
+	Transform string
+	Width     int
+	Height    int
+	Holes     int
+	Cards     []GuideCard
+}
+
+// guide validates the cards and builds their punching guide
+func (e *PunchingGuideExporter) guide(cards []*Card) (*punchingGuide, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no cards to export")
+	}
+
+	title := e.Title
+	if title == "" {
+		title = "Untitled Pattern"
+	}
+	guide := &punchingGuide{
+		Title:    title,
+		CardType: fmt.Sprintf("%dx%d", cards[0].Width, cards[0].Height),
+		Width:    cards[0].Width,
+		Height:   cards[0].Height,
+		Cards:    make([]GuideCard, len(cards)),
+	}
+	if !e.Transform.IsIdentity() {
+		guide.Transform = e.Transform.String()
+	}
+
+	for i, card := range cards {
+		if err := card.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid card %d: %w", i+1, err)
+		}
+		guide.Cards[i] = NewGuideCard(card)
+		guide.Holes += guide.Cards[i].Holes
+	}
+	return guide, nil
+}
+
+// numbering explains how rows and columns are counted
+func (g *punchingGuide) numbering() string {
+	return fmt.Sprintf("Columns are numbered 1-%d from the left and rows 1-%d from the top", g.Width, g.Height)
+}
+
+// ExportText writes the punching guide as plain text with [ ] checkboxes
+func (e *PunchingGuideExporter) ExportText(cards []*Card, w io.Writer) error {
+	guide, err := e.guide(cards)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Punching Guide: %s\n", guide.Title)
+	fmt.Fprintf(w, "Cards: %d (%s)\n", len(guide.Cards), guide.CardType)
+	fmt.Fprintf(w, "Holes: %d\n", guide.Holes)
+	if guide.Transform != "" {
+		fmt.Fprintf(w, "Transform: %s\n", guide.Transform)
+	}
+	fmt.Fprintf(w, "%s.\n", guide.numbering())
+	fmt.Fprintf(w, "Tick each row once punched, then count the card's holes against its total.\n")
+
+	for _, card := range guide.Cards {
+		fmt.Fprintf(w, "\n[ ] Card %s of %d: %d holes\n", card.Label, len(guide.Cards), card.Holes)
+		for _, row := range card.Rows {
+			fmt.Fprintf(w, "    [ ] row %d: %s (%d)\n", row.Row, row.Columns(), row.Holes)
+		}
+	}
+	return nil
+}
+
+// ExportHTML writes the punching guide as a printable HTML page with checkboxes
+func (e *PunchingGuideExporter) ExportHTML(cards []*Card, w io.Writer) error {
+	guide, err := e.guide(cards)
+	if err != nil {
+		return err
+	}
+	return punchingGuideTemplate.Execute(w, struct {
+		*punchingGuide
+		Numbering string
+	}{guide, guide.numbering()})
+}
+
+// guideLine is one line of the PDF guide
+type guideLine struct {
+	indent float64
+	box    bool
+	text   string
+}
+
+// ExportPDF writes the punching guide as a PDF document, keeping each card on one page where it fits
+func (e *PunchingGuideExporter) ExportPDF(cards []*Card, w io.Writer) error {
+	guide, err := e.guide(cards)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePageSize(e.PageSize); err != nil {
+		return err
+	}
+	pageSize := GetPageSize(e.PageSize)
+
+	// Lay the lines out on pages first, so each header can give the page count
+	top := PageMargin + HeaderHeight + GuideLineHeight
+	bottom := pageSize.Height - PageMargin
+	linesPerPage := int((bottom - top) / GuideLineHeight)
+	textWidth := pageSize.Width - 2*PageMargin
+
+	pages := [][]guideLine{{}}
+	for _, card := range guide.Cards {
+		block := guideBlock(guide, card, textWidth)
+		current := pages[len(pages)-1]
+		if len(current) > 0 && len(current)+len(block) > linesPerPage {
+			pages = append(pages, nil)
+		}
+		for _, line := range block {
+			if len(pages[len(pages)-1]) >= linesPerPage {
+				pages = append(pages, nil)
+			}
+			pages[len(pages)-1] = append(pages[len(pages)-1], line)
+		}
+	}
+
+	metadata := GetDefaultMetadata(len(cards))
+	metadata.Title = "Punching Guide: " + guide.Title
+	doc := newPDFDocument(metadata)
+	for i, lines := range pages {
+		page := doc.addPage(pageSize.Width, pageSize.Height)
+
+		header := fmt.Sprintf("%s - punching guide - page %d of %d - %d cards, %d holes",
+			guide.Title, i+1, len(pages), len(guide.Cards), guide.Holes)
+		if guide.Transform != "" {
+			header += fmt.Sprintf(" (transform: %s)", guide.Transform)
+		}
+		page.text(PageMargin, PageMargin+HeaderHeight*0.5, 3.5, anchorStart, inkBlack, header)
+		page.text(PageMargin, PageMargin+HeaderHeight, 2.8, anchorStart, inkGray, guide.numbering())
+
+		for j, line := range lines {
+			base := top + float64(j+1)*GuideLineHeight
+			x := PageMargin + line.indent
+			if line.box {
+				page.rect(x, base-GuideBoxSize, GuideBoxSize, GuideBoxSize, 0.2, inkBlack)
+			}
+			page.text(x+GuideBoxSize+1.5, base, GuideFontSize, anchorStart, inkBlack, line.text)
+		}
+	}
+	return doc.write(w)
+}
+
+// guideBlock returns the PDF lines for one card, wrapping long column lists to the text width
+func guideBlock(guide *punchingGuide, card GuideCard, textWidth float64) []guideLine {
+	lines := []guideLine{{
+		box:  true,
+		text: fmt.Sprintf("Card %s of %d: %d holes", card.Label, len(guide.Cards), card.Holes),
+	}}
+
+	textStart := GuideIndent + GuideBoxSize + 1.5
+	maxChars := int((textWidth - textStart) / (courierAdvance * GuideFontSize))
+	for _, row := range card.Rows {
+		prefix := fmt.Sprintf("row %2d: ", row.Row)
+		for i, text := range wrapColumns(row, maxChars-len(prefix)) {
+			line := guideLine{indent: GuideIndent, text: strings.Repeat(" ", len(prefix)) + text}
+			if i == 0 {
+				line.box = true
+				line.text = prefix + text
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// A blank line separates the cards
+	return append(lines, guideLine{})
+}
+
+// wrapColumns splits a row's column list into lines of at most width characters,
+// breaking after the commas and ending with the row's hole count
+func wrapColumns(row GuideRow, width int) []string {
+	items := strings.Split(row.Columns(), ", ")
+	for i := 0; i < len(items)-1; i++ {
+		items[i] += ","
+	}
+	items[len(items)-1] += fmt.Sprintf(" (%d)", row.Holes)
+
+	var lines []string
+	current := ""
+	for _, item := range items {
+		switch {
+		case current == "":
+			current = item
+		case len([]rune(current))+1+len([]rune(item)) > width:
+			lines = append(lines, current)
+			current = item
+		default:
+			current += " " + item
+		}
+	}
+	return append(lines, current)
+}
+
+var punchingGuideTemplate = template.Must(template.New("guide").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>Punching Guide: {{.Title}}</title>
+<style>
+  body { font-family: sans-serif; max-width: 180mm; margin: 15mm auto; color: #222; }
+  h1 { font-size: 1.4em; margin-bottom: 0; }
+  .card { break-inside: avoid; margin-top: 1.2em; }
+  h2 { font-size: 1.1em; margin: 0; border-bottom: 1px solid #999; }
+  table { border-collapse: collapse; width: 100%; }
+  td { padding: 2px 6px; border-bottom: 1px solid #ddd; vertical-align: top; }
+  td.row { white-space: nowrap; width: 4em; }
+  td.columns { font-family: monospace; font-size: 1.1em; }
+  td.count { text-align: right; width: 3em; color: #666; font-variant-numeric: tabular-nums; }
+  input { width: 1.1em; height: 1.1em; margin: 0 0.4em 0 0; vertical-align: middle; }
+  @media print { body { margin: 0 auto; } }
+</style>
+</head>
+<body>
+<h1>Punching Guide</h1>
+<p>{{.Title}} &middot; {{len .Cards}} cards ({{.CardType}}) &middot; {{.Holes}} holes{{if .Transform}} &middot; transform: {{.Transform}}{{end}}</p>
+<p>{{.Numbering}}. Tick each row once punched, then count the card's holes against its total.</p>
+{{$total := len .Cards}}{{range .Cards}}
+<div class="card">
+<h2><label><input type="checkbox"> Card {{.Label}} of {{$total}}: {{.Holes}} holes</label></h2>
+<table>
+{{- range .Rows}}
+  <tr><td class="row"><label><input type="checkbox"> row {{.Row}}</label></td><td class="columns">{{.Columns}}</td><td class="count">{{.Holes}}</td></tr>
+{{- end}}
+</table>
+</div>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,139 @@
+package punchcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// guideTestCard returns a 26x8 card with the given rows, leaving the others blank
+func guideTestCard(rows map[int]string) *Card {
+	card := &Card{Number: 1, Width: CardWidth, Height: CardHeight, Matrix: make([][]int, CardHeight)}
+	for y := range card.Matrix {
+		card.Matrix[y] = make([]int, CardWidth)
+		for x, c := range rows[y] {
+			if c == '#' {
+				card.Matrix[y][x] = 1
+			}
+		}
+	}
+	return card
+}
+
+func TestHoleRuns(t *testing.T) {
+	tests := []struct {
+		row  string
+		want string
+	}{
+		{"...######....#.....###....", "4–9, 14, 20–22"},
+		{"#........................#", "1, 26"},
+		{"##########################", "1–26"},
+		{"..........................", "none"},
+	}
+
+	for _, tt := range tests {
+		card := guideTestCard(map[int]string{0: tt.row})
+		row := NewGuideCard(card).Rows[0]
+		if got := row.Columns(); got != tt.want {
+			t.Errorf("Columns(%q) = %q, want %q", tt.row, got, tt.want)
+		}
+		if row.Holes != strings.Count(tt.row, "#") {
+			t.Errorf("Holes(%q) = %d, want %d", tt.row, row.Holes, strings.Count(tt.row, "#"))
+		}
+	}
+}
+
+func TestGuideCardMatchesCountHoles(t *testing.T) {
+	for _, card := range generateCards(t, CardType50x12, 5) {
+		if guide := NewGuideCard(card); guide.Holes != card.CountHoles() {
+			t.Errorf("card %d: guide total %d, CountHoles %d", card.Number, guide.Holes, card.CountHoles())
+		}
+	}
+}
+
+func TestPunchingGuideText(t *testing.T) {
+	card := guideTestCard(map[int]string{2: "...######....#.....###...."})
+	exporter := NewPunchingGuideExporter()
+	exporter.Title = "Sampler"
+
+	var buf bytes.Buffer
+	if err := exporter.ExportText([]*Card{card}, &buf); err != nil {
+		t.Fatalf("ExportText() error = %v", err)
+	}
+	text := buf.String()
+
+	for _, want := range []string{
+		"Punching Guide: Sampler",
+		"Holes: 10",
+		"[ ] Card 1 of 1: 10 holes",
+		"    [ ] row 3: 4–9, 14, 20–22 (10)",
+		"    [ ] row 8: none (0)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("guide does not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestPunchingGuideHTML(t *testing.T) {
+	exporter := NewPunchingGuideExporter()
+	exporter.Title = "<Roses>"
+
+	var buf bytes.Buffer
+	if err := exporter.ExportHTML(generateCards(t, CardType26x8, 3), &buf); err != nil {
+		t.Fatalf("ExportHTML() error = %v", err)
+	}
+	html := buf.String()
+
+	if !strings.Contains(html, "&lt;Roses&gt;") {
+		t.Error("title should be escaped")
+	}
+	// One checkbox per card and per row
+	if got, want := strings.Count(html, `type="checkbox"`), 3*(1+CardHeight); got != want {
+		t.Errorf("checkboxes = %d, want %d", got, want)
+	}
+}
+
+func TestPunchingGuidePDF(t *testing.T) {
+	exporter := NewPunchingGuideExporter()
+	exporter.Title = "Roses"
+
+	var buf bytes.Buffer
+	if err := exporter.ExportPDF(generateCards(t, CardType50x12, 10), &buf); err != nil {
+		t.Fatalf("ExportPDF() error = %v", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("output is not a complete PDF file")
+	}
+	if !strings.Contains(pdf, "(Roses - punching guide - page 1 of ") {
+		t.Error("missing page header")
+	}
+	if !strings.Contains(pdf, "(Card 10 of 10: ") {
+		t.Error("missing last card")
+	}
+
+	exporter.PageSize = "B5"
+	if err := exporter.ExportPDF(generateCards(t, CardType26x8, 1), &buf); err == nil {
+		t.Error("expected an error for an unsupported page size")
+	}
+}
+
+func TestWrapColumns(t *testing.T) {
+	card := guideTestCard(map[int]string{0: "#.#.#.#.#.#.#.#.#.#.#.#.#."})
+	row := NewGuideCard(card).Rows[0]
+
+	lines := wrapColumns(row, 12)
+	if len(lines) < 2 {
+		t.Fatalf("expected the column list to wrap, got %q", lines)
+	}
+	for _, line := range lines {
+		if len(line) > 12 {
+			t.Errorf("line %q is longer than 12 characters", line)
+		}
+	}
+	if joined := strings.Join(lines, " "); joined != row.Columns()+" (13)" {
+		t.Errorf("wrapped lines %q do not rejoin to the column list", joined)
+	}
+}
@@ -0,0 +1,109 @@
+package punchcard
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxSections is the most cards a row of a design may span, each woven by its own chain
+const MaxSections = 26
+
+// Section is one of the synchronised chains of a design wider than one card, woven on its own
+// head or card section; card N of every section is laced for the same pick
+type Section struct {
+	Name  string // "A", "B", ... (empty for a single chain)
+	Cards []*Card
+}
+
+// SectionName returns the name of a section by its 0-based position: A, B, C, ...
+func SectionName(i int) string {
+	return string(rune('A' + i))
+}
+
+// sections returns how many sections a generator splits each row into
+func (g *Generator) sections() int {
+	if g.CardsPerRow < 1 {
+		return 1
+	}
+	return g.CardsPerRow
+}
+
+// Label returns the number a card is labelled with: its number, after its section if it has one
+// (e.g. "17" or "A-17")
+func (c *Card) Label() string {
+	if c.Section == "" {
+		return strconv.Itoa(c.Number)
+	}
+	return c.Section + "-" + strconv.Itoa(c.Number)
+}
+
+// SplitSections groups cards into their sections, in the order the sections first appear
+// Cards without a section make one unnamed section
+func SplitSections(cards []*Card) []*Section {
+	var sections []*Section
+	index := map[string]*Section{}
+	for _, card := range cards {
+		s, ok := index[card.Section]
+		if !ok {
+			s = &Section{Name: card.Section}
+			index[card.Section] = s
+			sections = append(sections, s)
+		}
+		s.Cards = append(s.Cards, card)
+	}
+	return sections
+}
+
+// JoinSections returns the cards of sections one section after another
+func JoinSections(sections []*Section) []*Card {
+	var cards []*Card
+	for _, s := range sections {
+		cards = append(cards, s.Cards...)
+	}
+	return cards
+}
+
+// FindSection returns the section with the given name, ignoring case
+func FindSection(sections []*Section, name string) (*Section, error) {
+	names := make([]string, len(sections))
+	for i, s := range sections {
+		if strings.EqualFold(s.Name, name) {
+			return s, nil
+		}
+		names[i] = s.Name
+	}
+	if len(sections) == 1 && sections[0].Name == "" {
+		return nil, fmt.Errorf("invalid section: %s (the cards are not split into sections)", name)
+	}
+	return nil, fmt.Errorf("invalid section: %s (must be one of %s)", name, strings.Join(names, ", "))
+}
+
+// SectionFilename returns the file name of a section's export, the section name added to a
+// file name, e.g. "punchcards-B.svg"
+func SectionFilename(filename, section string) string {
+	if section == "" {
+		return filename
+	}
+	ext := path.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + section + ext
+}
+
+// WriteSectionArchive writes a ZIP archive holding one file per section, each exported by
+// export and named with SectionFilename
+func WriteSectionArchive(w io.Writer, sections []*Section, filename string, export func(s *Section, w io.Writer) error) error {
+	archive := zip.NewWriter(w)
+	for _, s := range sections {
+		f, err := archive.Create(SectionFilename(filename, s.Name))
+		if err != nil {
+			return err
+		}
+		if err := export(s, f); err != nil {
+			return fmt.Errorf("section %s: %w", s.Name, err)
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,181 @@
+package punchcard
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSections(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 2
+	if got := g.DesignWidth(); got != 416 {
+		t.Fatalf("DesignWidth() = %d, want 416", got)
+	}
+
+	matrix := createTestMatrix(3, 416)
+	cards, err := g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(cards) != 6 {
+		t.Fatalf("Generate() = %d cards, want 6", len(cards))
+	}
+
+	for i, card := range cards {
+		section, y := i/3, i%3
+		wantLabel := fmt.Sprintf("%s-%d", SectionName(section), y+1)
+		if card.Label() != wantLabel {
+			t.Errorf("card %d Label() = %q, want %q", i, card.Label(), wantLabel)
+		}
+		for hook := 0; hook < 208; hook++ {
+			if got, want := card.Matrix[hook/26][hook%26], matrix[y][section*208+hook]; got != want {
+				t.Fatalf("card %s hook %d = %d, want %d", card.Label(), hook+1, got, want)
+			}
+		}
+	}
+
+	if _, err := g.Generate(createTestMatrix(3, 208)); err == nil {
+		t.Error("Generate() of a one-card-wide design with 2 cards per row should fail")
+	}
+
+	g.CardsPerRow = MaxSections + 1
+	if err := g.ValidateLayout(); err == nil {
+		t.Errorf("ValidateLayout() with %d cards per row should fail", g.CardsPerRow)
+	}
+}
+
+func TestCardLabel(t *testing.T) {
+	card := createTestCard(17)
+	if got := card.Label(); got != "17" {
+		t.Errorf("Label() = %q, want %q", got, "17")
+	}
+	card.Section = "B"
+	if got := card.Label(); got != "B-17" {
+		t.Errorf("Label() = %q, want %q", got, "B-17")
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 3
+	cards, err := g.Generate(createTestMatrix(2, 624))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	sections := SplitSections(cards)
+	if len(sections) != 3 {
+		t.Fatalf("SplitSections() = %d sections, want 3", len(sections))
+	}
+	for i, s := range sections {
+		if s.Name != SectionName(i) || len(s.Cards) != 2 {
+			t.Errorf("section %d = %s with %d cards, want %s with 2", i, s.Name, len(s.Cards), SectionName(i))
+		}
+	}
+	if joined := JoinSections(sections); len(joined) != len(cards) || joined[2] != cards[2] {
+		t.Error("JoinSections() did not restore the cards in order")
+	}
+
+	s, err := FindSection(sections, "b")
+	if err != nil || s.Name != "B" {
+		t.Errorf("FindSection(b) = %v, %v, want section B", s, err)
+	}
+	if _, err := FindSection(sections, "D"); err == nil {
+		t.Error("FindSection(D) should fail")
+	}
+
+	single := SplitSections([]*Card{createTestCard(1), createTestCard(2)})
+	if len(single) != 1 || single[0].Name != "" {
+		t.Errorf("SplitSections() of unsectioned cards = %d sections, want 1 unnamed", len(single))
+	}
+}
+
+func TestSectionFilename(t *testing.T) {
+	tests := []struct {
+		filename, section, want string
+	}{
+		{"punchcards.svg", "B", "punchcards-B.svg"},
+		{"punchcards.txt", "", "punchcards.txt"},
+		{"punchcards", "A", "punchcards-A"},
+	}
+	for _, tt := range tests {
+		if got := SectionFilename(tt.filename, tt.section); got != tt.want {
+			t.Errorf("SectionFilename(%q, %q) = %q, want %q", tt.filename, tt.section, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSectionArchive(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 2
+	cards, err := g.Generate(createTestMatrix(2, 416))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteSectionArchive(&buf, SplitSections(cards), "punchcards.txt", func(s *Section, w io.Writer) error {
+		return NewTextExporter().ExportCards(s.Cards, w)
+	})
+	if err != nil {
+		t.Fatalf("WriteSectionArchive() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	if len(archive.File) != 2 {
+		t.Fatalf("archive holds %d files, want 2", len(archive.File))
+	}
+	for i, f := range archive.File {
+		name := SectionName(i)
+		if want := "punchcards-" + name + ".txt"; f.Name != want {
+			t.Errorf("file %d = %s, want %s", i, f.Name, want)
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		if !strings.Contains(string(content), "Card "+name+"-2:") {
+			t.Errorf("%s does not hold card %s-2", f.Name, name)
+		}
+
+		// Section labels survive a round trip through the text format
+		result, err := NewTextParser().Parse(string(content))
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", f.Name, err)
+		}
+		if got := result.Cards[1].Label(); got != name+"-2" {
+			t.Errorf("parsed card Label() = %q, want %q", got, name+"-2")
+		}
+	}
+}
+
+func TestTransformApplySections(t *testing.T) {
+	g := NewGenerator()
+	g.CardsPerRow = 2
+	cards, err := g.Generate(createTestMatrix(3, 416))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// Each section is reversed on its own and renumbered from 1
+	reversed := Transform{ReverseOrder: true}.Apply(cards)
+	for i, card := range reversed {
+		section, y := i/3, i%3
+		original := cards[section*3+2-y]
+		if want := fmt.Sprintf("%s-%d", SectionName(section), y+1); card.Label() != want {
+			t.Errorf("reversed card %d Label() = %q, want %q", i, card.Label(), want)
+		}
+		if fmt.Sprint(card.Matrix) != fmt.Sprint(original.Matrix) {
+			t.Errorf("reversed card %s holds the wrong pick, want that of %s", card.Label(), original.Label())
+		}
+	}
+}
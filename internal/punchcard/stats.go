@@ -0,0 +1,195 @@
+package punchcard
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// DensityBuckets is the number of 10%-wide buckets in the density histogram
+const DensityBuckets = 10
+
+// HeaviestPickCount is the number of heaviest picks listed in the statistics
+const HeaviestPickCount = 5
+
+// Statistics describes how a card set works the loom
+// Each card is one pick; hooks are numbered from 1 in reading order across the card
+type Statistics struct {
+	TotalCards       int             `json:"totalCards"`
+	Hooks            int             `json:"hooks"`
+	TotalLifts       int             `json:"totalLifts"`
+	HookLifts        []int           `json:"hookLifts"`        // Lifts per hook over the whole set
+	UnusedHooks      []int           `json:"unusedHooks"`      // Hooks that are never lifted
+	BusiestHook      int             `json:"busiestHook"`      // Hook with the most lifts
+	BusiestHookLifts int             `json:"busiestHookLifts"` // Lifts of the busiest hook
+	HookLiftSpread   float64         `json:"hookLiftSpread"`   // Standard deviation of hook lifts as a percentage of the mean
+	MinLifts         int             `json:"minLifts"`         // Fewest hooks lifted on a pick
+	MinLiftsCard     int             `json:"minLiftsCard"`
+	MaxLifts         int             `json:"maxLifts"` // Most hooks lifted on a pick
+	MaxLiftsCard     int             `json:"maxLiftsCard"`
+	AverageLifts     float64         `json:"averageLifts"`
+	HeaviestPicks    []PickLifts     `json:"heaviestPicks"` // Picks with the most lifts, heaviest first
+	UniqueCards      int             `json:"uniqueCards"`
+	IdenticalRuns    []CardRun       `json:"identicalRuns"` // Runs of two or more identical consecutive cards
+	LongestRun       int             `json:"longestRun"`
+	DensityHistogram []DensityBucket `json:"densityHistogram"`
+}
+
+// PickLifts is the number of hooks lifted by one card
+type PickLifts struct {
+	Card  int `json:"card"`
+	Lifts int `json:"lifts"`
+}
+
+// CardRun is a run of identical consecutive cards
+type CardRun struct {
+	First  int `json:"first"` // Number of the first card in the run
+	Last   int `json:"last"`  // Number of the last card in the run
+	Length int `json:"length"`
+}
+
+// DensityBucket counts the cards whose hole density falls in [From, To) percent
+// The last bucket also includes 100%
+type DensityBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Cards int `json:"cards"`
+}
+
+// GenerateStatistics analyses the hook usage of a card set
+func GenerateStatistics(cards []*Card) *Statistics {
+	stats := &Statistics{
+		HookLifts:        []int{},
+		UnusedHooks:      []int{},
+		HeaviestPicks:    []PickLifts{},
+		IdenticalRuns:    []CardRun{},
+		DensityHistogram: make([]DensityBucket, DensityBuckets),
+	}
+	for i := range stats.DensityHistogram {
+		stats.DensityHistogram[i] = DensityBucket{From: i * 100 / DensityBuckets, To: (i + 1) * 100 / DensityBuckets}
+	}
+	if len(cards) == 0 {
+		return stats
+	}
+
+	width := cards[0].Width
+	hooks := width * cards[0].Height
+	stats.TotalCards = len(cards)
+	stats.Hooks = hooks
+	stats.HookLifts = make([]int, hooks)
+	stats.MinLifts = -1
+
+	picks := make([]PickLifts, len(cards))
+	unique := make(map[string]bool)
+	runStart := 0
+
+	for i, card := range cards {
+		lifts := 0
+		for y := 0; y < card.Height; y++ {
+			for x := 0; x < card.Width; x++ {
+				if card.Matrix[y][x] != 1 {
+					continue
+				}
+				lifts++
+				if hook := y*width + x; hook < hooks {
+					stats.HookLifts[hook]++
+				}
+			}
+		}
+		picks[i] = PickLifts{Card: card.Number, Lifts: lifts}
+		stats.TotalLifts += lifts
+
+		if stats.MinLifts < 0 || lifts < stats.MinLifts {
+			stats.MinLifts, stats.MinLiftsCard = lifts, card.Number
+		}
+		if lifts > stats.MaxLifts || stats.MaxLiftsCard == 0 {
+			stats.MaxLifts, stats.MaxLiftsCard = lifts, card.Number
+		}
+
+		if cardHoles := card.Width * card.Height; cardHoles > 0 {
+			bucket := lifts * DensityBuckets / cardHoles
+			if bucket >= DensityBuckets {
+				bucket = DensityBuckets - 1
+			}
+			stats.DensityHistogram[bucket].Cards++
+		}
+
+		unique[holeKey(card)] = true
+
+		// Close the run of identical cards when this card differs from the previous one
+		if i > 0 && !sameHoles(cards[i-1], card) {
+			stats.addRun(cards, runStart, i-1)
+			runStart = i
+		}
+	}
+	stats.addRun(cards, runStart, len(cards)-1)
+
+	stats.UniqueCards = len(unique)
+	stats.AverageLifts = float64(stats.TotalLifts) / float64(len(cards))
+
+	// Hook usage and balance
+	mean := float64(stats.TotalLifts) / float64(hooks)
+	variance := 0.0
+	for hook, lifts := range stats.HookLifts {
+		if lifts == 0 {
+			stats.UnusedHooks = append(stats.UnusedHooks, hook+1)
+		}
+		if lifts > stats.BusiestHookLifts {
+			stats.BusiestHook, stats.BusiestHookLifts = hook+1, lifts
+		}
+		d := float64(lifts) - mean
+		variance += d * d
+	}
+	if mean > 0 {
+		stats.HookLiftSpread = math.Sqrt(variance/float64(hooks)) / mean * 100
+	}
+
+	// Heaviest picks first, keeping card order for ties
+	sort.SliceStable(picks, func(i, j int) bool { return picks[i].Lifts > picks[j].Lifts })
+	n := HeaviestPickCount
+	if n > len(picks) {
+		n = len(picks)
+	}
+	stats.HeaviestPicks = picks[:n]
+
+	return stats
+}
+
+// addRun records cards[first..last] as a run when it has more than one card
+func (s *Statistics) addRun(cards []*Card, first, last int) {
+	length := last - first + 1
+	if length < 2 {
+		return
+	}
+	s.IdenticalRuns = append(s.IdenticalRuns, CardRun{First: cards[first].Number, Last: cards[last].Number, Length: length})
+	if length > s.LongestRun {
+		s.LongestRun = length
+	}
+}
+
+// holeKey returns a string identifying a card's size and hole pattern
+func holeKey(c *Card) string {
+	var b strings.Builder
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			b.WriteByte(byte('0' + c.Matrix[y][x]))
+		}
+		b.WriteByte('/')
+	}
+	return b.String()
+}
+
+// sameHoles reports whether two cards have the same size and hole pattern
+func sameHoles(a, b *Card) bool {
+	if a.Width != b.Width || a.Height != b.Height {
+		return false
+	}
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			if a.Matrix[y][x] != b.Matrix[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
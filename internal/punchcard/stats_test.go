@@ -0,0 +1,108 @@
+package punchcard
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cardFromRows builds a small card from rows of '0' and '1'
+func cardFromRows(number int, rows ...string) *Card {
+	card := &Card{Number: number, Width: len(rows[0]), Height: len(rows), Matrix: make([][]int, len(rows))}
+	for y, row := range rows {
+		card.Matrix[y] = make([]int, len(row))
+		for x, c := range row {
+			if c == '1' {
+				card.Matrix[y][x] = 1
+			}
+		}
+	}
+	return card
+}
+
+func TestGenerateStatistics(t *testing.T) {
+	cards := []*Card{
+		cardFromRows(1, "10", "00"),
+		cardFromRows(2, "10", "00"),
+		cardFromRows(3, "10", "00"),
+		cardFromRows(4, "11", "10"),
+		cardFromRows(5, "00", "00"),
+		cardFromRows(6, "00", "00"),
+	}
+	stats := GenerateStatistics(cards)
+
+	if stats.TotalCards != 6 || stats.Hooks != 4 || stats.TotalLifts != 6 {
+		t.Errorf("totals = %d cards, %d hooks, %d lifts; want 6, 4, 6", stats.TotalCards, stats.Hooks, stats.TotalLifts)
+	}
+	if !reflect.DeepEqual(stats.HookLifts, []int{4, 1, 1, 0}) {
+		t.Errorf("HookLifts = %v, want [4 1 1 0]", stats.HookLifts)
+	}
+	if !reflect.DeepEqual(stats.UnusedHooks, []int{4}) {
+		t.Errorf("UnusedHooks = %v, want [4]", stats.UnusedHooks)
+	}
+	if stats.BusiestHook != 1 || stats.BusiestHookLifts != 4 {
+		t.Errorf("busiest hook = %d with %d lifts, want 1 with 4", stats.BusiestHook, stats.BusiestHookLifts)
+	}
+	if stats.MaxLifts != 3 || stats.MaxLiftsCard != 4 {
+		t.Errorf("max = %d on card %d, want 3 on card 4", stats.MaxLifts, stats.MaxLiftsCard)
+	}
+	if stats.MinLifts != 0 || stats.MinLiftsCard != 5 {
+		t.Errorf("min = %d on card %d, want 0 on card 5", stats.MinLifts, stats.MinLiftsCard)
+	}
+	if stats.AverageLifts != 1 {
+		t.Errorf("AverageLifts = %f, want 1", stats.AverageLifts)
+	}
+	if stats.UniqueCards != 3 {
+		t.Errorf("UniqueCards = %d, want 3", stats.UniqueCards)
+	}
+
+	wantRuns := []CardRun{{First: 1, Last: 3, Length: 3}, {First: 5, Last: 6, Length: 2}}
+	if !reflect.DeepEqual(stats.IdenticalRuns, wantRuns) {
+		t.Errorf("IdenticalRuns = %v, want %v", stats.IdenticalRuns, wantRuns)
+	}
+	if stats.LongestRun != 3 {
+		t.Errorf("LongestRun = %d, want 3", stats.LongestRun)
+	}
+
+	if stats.HeaviestPicks[0] != (PickLifts{Card: 4, Lifts: 3}) || stats.HeaviestPicks[1].Card != 1 {
+		t.Errorf("HeaviestPicks = %v", stats.HeaviestPicks)
+	}
+	if len(stats.HeaviestPicks) != HeaviestPickCount {
+		t.Errorf("len(HeaviestPicks) = %d, want %d", len(stats.HeaviestPicks), HeaviestPickCount)
+	}
+
+	// 0% x2, 25% x3, 75% x1
+	counts := map[int]int{}
+	total := 0
+	for _, bucket := range stats.DensityHistogram {
+		counts[bucket.From] = bucket.Cards
+		total += bucket.Cards
+	}
+	if counts[0] != 2 || counts[20] != 3 || counts[70] != 1 || total != 6 {
+		t.Errorf("DensityHistogram = %v", stats.DensityHistogram)
+	}
+}
+
+func TestGenerateStatisticsFullCard(t *testing.T) {
+	stats := GenerateStatistics([]*Card{cardFromRows(1, "11", "11")})
+
+	if last := stats.DensityHistogram[DensityBuckets-1]; last.Cards != 1 || last.To != 100 {
+		t.Errorf("full card should be in the last bucket, got %v", last)
+	}
+	if stats.HookLiftSpread != 0 {
+		t.Errorf("HookLiftSpread = %f, want 0 for evenly used hooks", stats.HookLiftSpread)
+	}
+	if len(stats.IdenticalRuns) != 0 {
+		t.Errorf("IdenticalRuns = %v, want none", stats.IdenticalRuns)
+	}
+}
+
+func TestGenerateStatisticsEmpty(t *testing.T) {
+	stats := GenerateStatistics(nil)
+
+	if stats.TotalCards != 0 || len(stats.HookLifts) != 0 {
+		t.Errorf("empty set statistics = %+v", stats)
+	}
+	if len(stats.DensityHistogram) != DensityBuckets {
+		t.Errorf("len(DensityHistogram) = %d, want %d", len(stats.DensityHistogram), DensityBuckets)
+	}
+}
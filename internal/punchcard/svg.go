@@ -17,13 +17,14 @@ const (
 
 // SVGExporter handles exporting punchcards to SVG format
 type SVGExporter struct {
-	ShowGrid      bool    // Whether to show a grid
-	ShowNumbers   bool    // Whether to show card numbers
-	HoleRadius    float64 // Radius of holes in mm
-	HoleSpacing   float64 // Spacing between holes in mm
-	Scale         float64 // Scale factor for the entire card
-	Title         string  // Optional title to display on cards
-	TotalCards    int     // Total number of cards in the series
+	ShowGrid      bool      // Whether to show a grid
+	ShowNumbers   bool      // Whether to show card numbers
+	HoleRadius    float64   // Radius of holes in mm
+	HoleSpacing   float64   // Spacing between holes in mm
+	Scale         float64   // Scale factor for the entire card
+	Title         string    // Optional title to display on cards
+	TotalCards    int       // Total number of cards in the series
+	Transform     Transform // Orientation applied to the cards, recorded in the description
 }
 
 // NewSVGExporter creates a new SVG exporter with default settings
@@ -67,9 +68,9 @@ func (e *SVGExporter) ExportCard(card *Card, w io.Writer) error {
 	fmt.Fprintf(w, "\n")
 
 	// Add title and description
-	fmt.Fprintf(w, `  <title>Jacquard Loom Punchcard #%d</title>`, card.Number)
+	fmt.Fprintf(w, `  <title>Jacquard Loom Punchcard #%s</title>`, card.Label())
 	fmt.Fprintf(w, "\n")
-	fmt.Fprintf(w, `  <desc>%s - For use in Jacquard weaving looms</desc>`, card.GetCardInfo())
+	fmt.Fprintf(w, `  <desc>%s - For use in Jacquard weaving looms%s</desc>`, card.GetCardInfo(), e.transformNote())
 	fmt.Fprintf(w, "\n\n")
 
 	// Background
@@ -83,11 +84,11 @@ func (e *SVGExporter) ExportCard(card *Card, w io.Writer) error {
 
 		// Display title with card number in format "Title_name #1/156"
 		if e.Title != "" && e.TotalCards > 0 {
-			fmt.Fprintf(w, "%s #%d/%d", e.Title, card.Number, e.TotalCards)
+			fmt.Fprintf(w, "%s #%s/%d", e.Title, card.Label(), e.TotalCards)
 		} else if e.TotalCards > 0 {
-			fmt.Fprintf(w, "Card #%d/%d", card.Number, e.TotalCards)
+			fmt.Fprintf(w, "Card #%s/%d", card.Label(), e.TotalCards)
 		} else {
-			fmt.Fprintf(w, "Card #%d", card.Number)
+			fmt.Fprintf(w, "Card #%s", card.Label())
 		}
 
 		fmt.Fprintf(w, "</text>\n")
@@ -126,7 +127,7 @@ func (e *SVGExporter) ExportCard(card *Card, w io.Writer) error {
 		infoY := heightPx - TextHeight*MMToPixel*0.3
 		fmt.Fprintf(w, `  <text x="%.2f" y="%.2f" font-family="monospace" font-size="%.2f" text-anchor="middle" fill="gray">`,
 			widthPx/2, infoY, TextHeight*MMToPixel*0.5)
-		fmt.Fprintf(w, "%dx%d | %d holes | Card %d", card.Width, card.Height, card.CountHoles(), card.Number)
+		fmt.Fprintf(w, "%dx%d | %d holes | Card %s", card.Width, card.Height, card.CountHoles(), card.Label())
 		fmt.Fprintf(w, "</text>\n")
 	}
 
@@ -136,6 +137,14 @@ func (e *SVGExporter) ExportCard(card *Card, w io.Writer) error {
 	return nil
 }
 
+// transformNote returns the description suffix recording the applied transform
+func (e *SVGExporter) transformNote() string {
+	if e.Transform.IsIdentity() {
+		return ""
+	}
+	return fmt.Sprintf(" (transform: %s)", e.Transform)
+}
+
 // drawGrid draws a grid for alignment
 func (e *SVGExporter) drawGrid(w io.Writer, card *Card, widthPx, heightPx float64) {
 	startX := CardPadding * MMToPixel
@@ -194,7 +203,7 @@ func (e *SVGExporter) ExportCards(cards []*Card, w io.Writer) error {
 	// Add title and description
 	fmt.Fprintf(w, `  <title>Jacquard Loom Punchcards (Set of %d)</title>`, len(cards))
 	fmt.Fprintf(w, "\n")
-	fmt.Fprintf(w, `  <desc>Complete set of %d punchcards for Jacquard weaving</desc>`, len(cards))
+	fmt.Fprintf(w, `  <desc>Complete set of %d punchcards for Jacquard weaving%s</desc>`, len(cards), e.transformNote())
 	fmt.Fprintf(w, "\n\n")
 
 	// Background
@@ -205,7 +214,7 @@ func (e *SVGExporter) ExportCards(cards []*Card, w io.Writer) error {
 	for i, card := range cards {
 		offsetY := float64(i) * (cardHeight + cardSpacing) * MMToPixel
 
-		fmt.Fprintf(w, `  <g id="card-%d" transform="translate(0, %.2f)">`, card.Number, offsetY)
+		fmt.Fprintf(w, `  <g id="card-%s" transform="translate(0, %.2f)">`, card.Label(), offsetY)
 		fmt.Fprintf(w, "\n")
 
 		// Render the card content directly (without SVG wrapper)
@@ -229,11 +238,11 @@ func (e *SVGExporter) renderCardContent(w io.Writer, card *Card, widthPx, height
 
 		// Display title with card number in format "Title_name #1/156"
 		if e.Title != "" && e.TotalCards > 0 {
-			fmt.Fprintf(w, "%s #%d/%d", e.Title, card.Number, e.TotalCards)
+			fmt.Fprintf(w, "%s #%s/%d", e.Title, card.Label(), e.TotalCards)
 		} else if e.TotalCards > 0 {
-			fmt.Fprintf(w, "Card #%d/%d", card.Number, e.TotalCards)
+			fmt.Fprintf(w, "Card #%s/%d", card.Label(), e.TotalCards)
 		} else {
-			fmt.Fprintf(w, "Card #%d", card.Number)
+			fmt.Fprintf(w, "Card #%s", card.Label())
 		}
 
 		fmt.Fprintf(w, "</text>\n")
@@ -272,7 +281,7 @@ func (e *SVGExporter) renderCardContent(w io.Writer, card *Card, widthPx, height
 		infoY := heightPx - TextHeight*MMToPixel*0.3
 		fmt.Fprintf(w, `    <text x="%.2f" y="%.2f" font-family="monospace" font-size="%.2f" text-anchor="middle" fill="gray">`,
 			widthPx/2, infoY, TextHeight*MMToPixel*0.5)
-		fmt.Fprintf(w, "%dx%d | %d holes | Card %d", card.Width, card.Height, card.CountHoles(), card.Number)
+		fmt.Fprintf(w, "%dx%d | %d holes | Card %s", card.Width, card.Height, card.CountHoles(), card.Label())
 		fmt.Fprintf(w, "</text>\n")
 	}
 }
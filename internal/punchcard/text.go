@@ -11,10 +11,11 @@ import (
 // - # or O for punched holes
 // - . for no holes
 type TextExporter struct {
-	Title        string // Pattern title
-	TotalCards   int    // Total number of cards in the series
-	HoleChar     rune   // Character to represent holes (default: #)
-	NoHoleChar   rune   // Character to represent no holes (default: .)
+	Title        string    // Pattern title
+	TotalCards   int       // Total number of cards in the series
+	HoleChar     rune      // Character to represent holes (default: #)
+	NoHoleChar   rune      // Character to represent no holes (default: .)
+	Transform    Transform // Orientation applied to the cards, recorded in the header
 }
 
 // NewTextExporter creates a new text exporter with default settings
@@ -48,6 +49,9 @@ func (e *TextExporter) ExportCards(cards []*Card, w io.Writer) error {
 	}
 	fmt.Fprintf(w, "Cards: %d\n", len(cards))
 	fmt.Fprintf(w, "Holes per card: %d\n", holesPerCard)
+	if !e.Transform.IsIdentity() {
+		fmt.Fprintf(w, "Transform: %s\n", e.Transform)
+	}
 	fmt.Fprintf(w, "\n")
 
 	// Write each card
@@ -57,7 +61,7 @@ func (e *TextExporter) ExportCards(cards []*Card, w io.Writer) error {
 		}
 
 		// Card header
-		fmt.Fprintf(w, "Card %d:\n", card.Number)
+		fmt.Fprintf(w, "Card %s:\n", card.Label())
 
 		// Write the card matrix
 		// Each row is CardWidth (26) columns wide
@@ -91,9 +95,12 @@ func NewTextParser() *TextParser {
 
 // ParseResult contains the parsed data
 type ParseResult struct {
-	Title      string
-	Cards      []*Card
-	TotalCards int
+	Title        string
+	CardType     CardType
+	Settings     *GenerationSettings // Only set when parsed from JSON
+	Transform    Transform           // Orientation already applied to the cards
+	Cards        []*Card
+	TotalCards   int
 	HolesPerCard int
 }
 
@@ -136,6 +143,22 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 	}
 	lineIdx++
 
+	// The card type follows from the number of holes per card
+	dims, err := dimensionsForHoles(result.HolesPerCard)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Holes per card value on line %d: %w", lineIdx, err)
+	}
+	result.CardType, _ = CardTypeForDimensions(dims)
+
+	// Parse optional Transform header
+	if lineIdx < len(lines) && strings.HasPrefix(lines[lineIdx], "Transform: ") {
+		result.Transform, err = ParseTransform(strings.TrimPrefix(lines[lineIdx], "Transform: "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Transform value on line %d: %w", lineIdx+1, err)
+		}
+		lineIdx++
+	}
+
 	// Skip empty line after header
 	if lineIdx < len(lines) && strings.TrimSpace(lines[lineIdx]) == "" {
 		lineIdx++
@@ -143,7 +166,7 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 
 	// Parse cards
 	result.Cards = make([]*Card, 0, result.TotalCards)
-	cardNumber := 1
+	cardNumbers := map[string]int{} // Cards are numbered from 1 in each section
 
 	for lineIdx < len(lines) {
 		// Skip empty lines
@@ -152,7 +175,7 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 			continue
 		}
 
-		// Parse card header "Card N:"
+		// Parse card header "Card N:", or "Card S-N:" for a card of section S
 		var parsedCardNum int
 		if !strings.HasPrefix(lines[lineIdx], "Card ") {
 			// If we've parsed all expected cards, we're done
@@ -161,16 +184,21 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 			}
 			return nil, fmt.Errorf("expected Card header on line %d, got: %s", lineIdx+1, lines[lineIdx])
 		}
-		_, err = fmt.Sscanf(lines[lineIdx], "Card %d:", &parsedCardNum)
+		header := strings.TrimPrefix(lines[lineIdx], "Card ")
+		section, number, hasSection := strings.Cut(header, "-")
+		if !hasSection {
+			section, number = "", header
+		}
+		_, err = fmt.Sscanf(number, "%d:", &parsedCardNum)
 		if err != nil {
 			return nil, fmt.Errorf("invalid Card header on line %d: %w", lineIdx+1, err)
 		}
 		lineIdx++
 
-		// Parse card matrix (CardHeight rows of CardWidth columns)
-		matrix := make([][]int, 0, CardHeight)
+		// Parse card matrix (Height rows of Width columns)
+		matrix := make([][]int, 0, dims.Height)
 
-		for row := 0; row < CardHeight; row++ {
+		for row := 0; row < dims.Height; row++ {
 			if lineIdx >= len(lines) {
 				return nil, fmt.Errorf("unexpected end of file while parsing card %d row %d", parsedCardNum, row+1)
 			}
@@ -179,12 +207,12 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 			lineIdx++
 
 			// Parse the row
-			if len(line) != CardWidth {
-				return nil, fmt.Errorf("card %d row %d has incorrect width: expected %d, got %d",
-					parsedCardNum, row+1, CardWidth, len(line))
+			if len(line) != dims.Width {
+				return nil, fmt.Errorf("card %d row %d has %w: expected %d, got %d",
+					parsedCardNum, row+1, ErrWidthMismatch, dims.Width, len(line))
 			}
 
-			rowData := make([]int, CardWidth)
+			rowData := make([]int, dims.Width)
 			for col, char := range line {
 				switch char {
 				case '#', 'O', 'o':
@@ -200,11 +228,14 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 		}
 
 		// Create the card
+		cardNumbers[section]++
+		cardNumber := cardNumbers[section]
 		card := &Card{
-			Number: cardNumber,
-			Matrix: matrix,
-			Width:  CardWidth,
-			Height: CardHeight,
+			Number:  cardNumber,
+			Matrix:  matrix,
+			Width:   dims.Width,
+			Height:  dims.Height,
+			Section: section,
 		}
 
 		// Validate the card
@@ -213,7 +244,6 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 		}
 
 		result.Cards = append(result.Cards, card)
-		cardNumber++
 	}
 
 	// Verify we got all cards
@@ -223,3 +253,14 @@ func (p *TextParser) Parse(content string) (*ParseResult, error) {
 
 	return result, nil
 }
+
+// dimensionsForHoles returns the dimensions of the card type with the given number of holes
+func dimensionsForHoles(holes int) (CardDimensions, error) {
+	for _, cardType := range []CardType{CardType26x8, CardType50x12} {
+		dims := GetCardDimensions(cardType)
+		if dims.Width*dims.Height == holes {
+			return dims, nil
+		}
+	}
+	return CardDimensions{}, fmt.Errorf("no card type has %d holes", holes)
+}
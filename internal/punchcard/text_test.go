@@ -2,6 +2,7 @@ package punchcard
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -234,6 +235,14 @@ func TestTextParser_ParseInvalidFormat(t *testing.T) {
 	}
 }
 
+func TestTextParser_ParseWidthMismatch(t *testing.T) {
+	input := "Title: Test\nCards: 1\nHoles per card: 208\n\nCard 1:\n" + strings.Repeat("#", CardWidth-1) + "\n"
+	_, err := NewTextParser().Parse(input)
+	if !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Parse() error = %v, want ErrWidthMismatch", err)
+	}
+}
+
 func TestTextRoundTrip(t *testing.T) {
 	// Create test cards
 	cards := []*Card{
@@ -288,3 +297,37 @@ func TestTextRoundTrip(t *testing.T) {
 	}
 }
 
+
+func TestTextParser_Parse50x12(t *testing.T) {
+	generator := NewGeneratorWithType(CardType50x12)
+	dims := generator.Dimensions
+	cards, err := generator.Generate(createTestMatrix(2, dims.Width*dims.Height))
+	if err != nil {
+		t.Fatalf("Failed to generate cards: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewTextExporter().ExportCards(cards, &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	result, err := NewTextParser().Parse(buf.String())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result.CardType != CardType50x12 {
+		t.Errorf("CardType = %q, want %q", result.CardType, CardType50x12)
+	}
+	for i, card := range result.Cards {
+		if card.Width != dims.Width || card.Height != dims.Height {
+			t.Errorf("Card %d has dimensions %dx%d, want %dx%d", i+1, card.Width, card.Height, dims.Width, dims.Height)
+		}
+	}
+}
+
+func TestTextParser_ParseUnknownHoleCount(t *testing.T) {
+	input := "Title: Test\nCards: 1\nHoles per card: 100\n\nCard 1:\n" + strings.Repeat("#", 10) + "\n"
+	if _, err := NewTextParser().Parse(input); err == nil {
+		t.Error("Expected parse to fail for an unknown hole count")
+	}
+}
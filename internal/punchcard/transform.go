@@ -0,0 +1,162 @@
+package punchcard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transform names used in output headers and option strings
+const (
+	TransformNone             = "none"
+	TransformMirrorHorizontal = "mirror-h"
+	TransformMirrorVertical   = "mirror-v"
+	TransformRotate180        = "rotate-180"
+	TransformReverseOrder     = "reverse"
+	TransformInvertPolarity   = "invert"
+)
+
+// Transform reorients a card set for the way a particular loom reads its cards
+// The untransformed set uses the default lift semantics: 1 = dark = punched,
+// hook 1 at the left of the top card row, and the first card at the top of the chain
+type Transform struct {
+	MirrorHorizontal bool // Flip each card left to right (card read from the right)
+	MirrorVertical   bool // Flip each card top to bottom
+	Rotate180        bool // Turn each card half way round (card read face-down and end-for-end)
+	ReverseOrder     bool // Reverse the chain so the last pick is laced first
+	InvertPolarity   bool // Punch where the design has no lift (mechanism lifts on no-hole)
+}
+
+// IsIdentity reports whether the transform leaves cards unchanged
+func (t Transform) IsIdentity() bool {
+	n := t.normalized()
+	return !n.MirrorHorizontal && !n.MirrorVertical && !n.Rotate180 && !n.ReverseOrder && !n.InvertPolarity
+}
+
+// normalized folds the mirrors and rotation into a canonical form:
+// a rotation is the same as mirroring both ways, so two mirrors are written as one rotation
+func (t Transform) normalized() Transform {
+	h := t.MirrorHorizontal != t.Rotate180
+	v := t.MirrorVertical != t.Rotate180
+	return Transform{
+		MirrorHorizontal: h && !v,
+		MirrorVertical:   v && !h,
+		Rotate180:        h && v,
+		ReverseOrder:     t.ReverseOrder,
+		InvertPolarity:   t.InvertPolarity,
+	}
+}
+
+// Then returns the transform equivalent to applying t followed by next
+func (t Transform) Then(next Transform) Transform {
+	a, b := t.normalized(), next.normalized()
+	return Transform{
+		MirrorHorizontal: a.MirrorHorizontal != b.MirrorHorizontal,
+		MirrorVertical:   a.MirrorVertical != b.MirrorVertical,
+		Rotate180:        a.Rotate180 != b.Rotate180,
+		ReverseOrder:     a.ReverseOrder != b.ReverseOrder,
+		InvertPolarity:   a.InvertPolarity != b.InvertPolarity,
+	}.normalized()
+}
+
+// String returns the transform as a comma-separated list, or "none"
+func (t Transform) String() string {
+	n := t.normalized()
+	var parts []string
+	if n.MirrorHorizontal {
+		parts = append(parts, TransformMirrorHorizontal)
+	}
+	if n.MirrorVertical {
+		parts = append(parts, TransformMirrorVertical)
+	}
+	if n.Rotate180 {
+		parts = append(parts, TransformRotate180)
+	}
+	if n.ReverseOrder {
+		parts = append(parts, TransformReverseOrder)
+	}
+	if n.InvertPolarity {
+		parts = append(parts, TransformInvertPolarity)
+	}
+	if len(parts) == 0 {
+		return TransformNone
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseTransform parses a comma-separated list of transform names as written by String
+func ParseTransform(s string) (Transform, error) {
+	var t Transform
+	for _, part := range strings.Split(s, ",") {
+		switch strings.TrimSpace(part) {
+		case "", TransformNone:
+		case TransformMirrorHorizontal:
+			t.MirrorHorizontal = !t.MirrorHorizontal
+		case TransformMirrorVertical:
+			t.MirrorVertical = !t.MirrorVertical
+		case TransformRotate180:
+			t.Rotate180 = !t.Rotate180
+		case TransformReverseOrder:
+			t.ReverseOrder = !t.ReverseOrder
+		case TransformInvertPolarity:
+			t.InvertPolarity = !t.InvertPolarity
+		default:
+			return Transform{}, fmt.Errorf("invalid transform: %s (must be one of mirror-h, mirror-v, rotate-180, reverse, invert)", part)
+		}
+	}
+	return t.normalized(), nil
+}
+
+// MapPosition returns where the hole at (row, col) of a card with the given size ends up
+func (t Transform) MapPosition(row, col, height, width int) (int, int) {
+	n := t.normalized()
+	if n.MirrorHorizontal || n.Rotate180 {
+		col = width - 1 - col
+	}
+	if n.MirrorVertical || n.Rotate180 {
+		row = height - 1 - row
+	}
+	return row, col
+}
+
+// Apply returns a transformed copy of the cards; the input cards are not modified
+// Cards are renumbered in their new chain order so the numbers still give the lacing sequence
+func (t Transform) Apply(cards []*Card) []*Card {
+	// The sections of a wide design are separate chains, each reordered on its own
+	if sections := SplitSections(cards); len(sections) > 1 {
+		var result []*Card
+		for _, s := range sections {
+			result = append(result, t.Apply(s.Cards)...)
+		}
+		return result
+	}
+
+	n := t.normalized()
+
+	result := make([]*Card, len(cards))
+	for i, card := range cards {
+		clone := card.Clone()
+		for y := 0; y < card.Height; y++ {
+			for x := 0; x < card.Width; x++ {
+				ty, tx := n.MapPosition(y, x, card.Height, card.Width)
+				clone.Matrix[ty][tx] = card.Matrix[y][x]
+			}
+		}
+		if n.InvertPolarity {
+			clone.Invert()
+		}
+
+		pos := i
+		if n.ReverseOrder {
+			pos = len(cards) - 1 - i
+		}
+		result[pos] = clone
+	}
+
+	if n.ReverseOrder {
+		for i, card := range result {
+			card.Number = i + 1
+		}
+	}
+
+	return result
+}
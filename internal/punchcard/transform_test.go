@@ -0,0 +1,152 @@
+package punchcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func createTransformTestCards() []*Card {
+	return []*Card{
+		{Number: 1, Width: 3, Height: 2, Matrix: [][]int{{1, 0, 0}, {0, 0, 0}}},
+		{Number: 2, Width: 3, Height: 2, Matrix: [][]int{{0, 0, 0}, {0, 1, 1}}},
+	}
+}
+
+func TestTransformApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform Transform
+		want      [][][]int
+	}{
+		{"identity", Transform{}, [][][]int{
+			{{1, 0, 0}, {0, 0, 0}},
+			{{0, 0, 0}, {0, 1, 1}},
+		}},
+		{"mirror horizontal", Transform{MirrorHorizontal: true}, [][][]int{
+			{{0, 0, 1}, {0, 0, 0}},
+			{{0, 0, 0}, {1, 1, 0}},
+		}},
+		{"mirror vertical", Transform{MirrorVertical: true}, [][][]int{
+			{{0, 0, 0}, {1, 0, 0}},
+			{{0, 1, 1}, {0, 0, 0}},
+		}},
+		{"rotate 180", Transform{Rotate180: true}, [][][]int{
+			{{0, 0, 0}, {0, 0, 1}},
+			{{1, 1, 0}, {0, 0, 0}},
+		}},
+		{"reverse order", Transform{ReverseOrder: true}, [][][]int{
+			{{0, 0, 0}, {0, 1, 1}},
+			{{1, 0, 0}, {0, 0, 0}},
+		}},
+		{"invert polarity", Transform{InvertPolarity: true}, [][][]int{
+			{{0, 1, 1}, {1, 1, 1}},
+			{{1, 1, 1}, {1, 0, 0}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards := createTransformTestCards()
+			result := tt.transform.Apply(cards)
+
+			if len(result) != len(tt.want) {
+				t.Fatalf("got %d cards, want %d", len(result), len(tt.want))
+			}
+			for i, card := range result {
+				if card.Number != i+1 {
+					t.Errorf("card %d has number %d", i+1, card.Number)
+				}
+				for y := range tt.want[i] {
+					for x := range tt.want[i][y] {
+						if card.Matrix[y][x] != tt.want[i][y][x] {
+							t.Errorf("card %d [%d][%d] = %d, want %d", i+1, y, x, card.Matrix[y][x], tt.want[i][y][x])
+						}
+					}
+				}
+			}
+
+			// The input must not be modified
+			if cards[0].Matrix[0][0] != 1 || cards[0].Number != 1 {
+				t.Error("Apply() modified the input cards")
+			}
+		})
+	}
+}
+
+func TestTransformNormalization(t *testing.T) {
+	both := Transform{MirrorHorizontal: true, MirrorVertical: true}
+	if both.String() != TransformRotate180 {
+		t.Errorf("mirroring both ways should equal %s, got %s", TransformRotate180, both.String())
+	}
+
+	undo := Transform{Rotate180: true}.Then(Transform{Rotate180: true})
+	if !undo.IsIdentity() {
+		t.Errorf("rotating twice should be the identity, got %s", undo.String())
+	}
+
+	combined := Transform{MirrorHorizontal: true}.Then(Transform{Rotate180: true, InvertPolarity: true})
+	if combined.String() != "mirror-v,invert" {
+		t.Errorf("combined transform = %s, want mirror-v,invert", combined.String())
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      string
+		wantError bool
+	}{
+		{"", TransformNone, false},
+		{"none", TransformNone, false},
+		{"mirror-h", "mirror-h", false},
+		{"reverse, invert", "reverse,invert", false},
+		{"mirror-h,mirror-v", "rotate-180", false},
+		{"rotate-90", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTransform(tt.input)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("ParseTransform(%q) error = %v, wantError %v", tt.input, err, tt.wantError)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseTransform(%q) = %s, want %s", tt.input, got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformRecordedInOutput(t *testing.T) {
+	transform := Transform{MirrorHorizontal: true, ReverseOrder: true}
+	cards := transform.Apply([]*Card{createTestCard(1), createTestCard(2)})
+
+	textExporter := NewTextExporter()
+	textExporter.Transform = transform
+	var textBuf bytes.Buffer
+	if err := textExporter.ExportCards(cards, &textBuf); err != nil {
+		t.Fatalf("text export failed: %v", err)
+	}
+	if !strings.Contains(textBuf.String(), "Transform: mirror-h,reverse\n") {
+		t.Error("text output should record the transform in its header")
+	}
+
+	result, err := NewTextParser().Parse(textBuf.String())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if result.Transform != transform {
+		t.Errorf("parsed transform = %s, want %s", result.Transform, transform)
+	}
+
+	svgExporter := NewSVGExporter()
+	svgExporter.Transform = transform
+	var svgBuf bytes.Buffer
+	if err := svgExporter.ExportCards(cards, &svgBuf); err != nil {
+		t.Fatalf("SVG export failed: %v", err)
+	}
+	if !strings.Contains(svgBuf.String(), "(transform: mirror-h,reverse)") {
+		t.Error("SVG output should record the transform in its description")
+	}
+}
@@ -0,0 +1,320 @@
+package punchcard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxWeaveRepeat is the most ends or picks a weave repeat may have
+const MaxWeaveRepeat = 64
+
+// Weave is a weave structure: a repeat of lifts tiled over the cloth by hook and pick
+// Lifts[pick][end] is 1 where the end is raised, putting warp on the face
+type Weave struct {
+	Name  string
+	Lifts [][]int
+}
+
+// Ends returns the width of the repeat in ends (hooks)
+func (w *Weave) Ends() int {
+	return len(w.Lifts[0])
+}
+
+// Picks returns the height of the repeat in picks (cards)
+func (w *Weave) Picks() int {
+	return len(w.Lifts)
+}
+
+// Lift returns the lift (1) or no lift (0) of hook x on pick y
+func (w *Weave) Lift(x, y int) int {
+	return w.Lifts[y%w.Picks()][x%w.Ends()]
+}
+
+// Validate checks that the repeat is rectangular, within MaxWeaveRepeat, and interlaces:
+// every pick raises some ends and leaves others down, and every end is raised on some picks but not all
+func (w *Weave) Validate() error {
+	if len(w.Lifts) == 0 || len(w.Lifts[0]) == 0 {
+		return fmt.Errorf("invalid weave %s: empty repeat", w.Name)
+	}
+	ends, picks := w.Ends(), w.Picks()
+	if ends > MaxWeaveRepeat || picks > MaxWeaveRepeat {
+		return fmt.Errorf("invalid weave %s: %dx%d repeat (must be at most %d ends and picks)", w.Name, ends, picks, MaxWeaveRepeat)
+	}
+	endLifts := make([]int, ends)
+	for y, row := range w.Lifts {
+		if len(row) != ends {
+			return fmt.Errorf("invalid weave %s: pick %d has %d ends (must have %d)", w.Name, y+1, len(row), ends)
+		}
+		lifts := 0
+		for x, v := range row {
+			lifts += v
+			endLifts[x] += v
+		}
+		if lifts == 0 || lifts == ends {
+			return fmt.Errorf("invalid weave %s: pick %d does not interlace (it must raise some ends but not all)", w.Name, y+1)
+		}
+	}
+	for x, lifts := range endLifts {
+		if lifts == 0 || lifts == picks {
+			return fmt.Errorf("invalid weave %s: end %d does not interlace (it must be raised on some picks but not all)", w.Name, x+1)
+		}
+	}
+	return nil
+}
+
+// String returns the weave in the text format read by ParseWeave
+func (w *Weave) String() string {
+	var b strings.Builder
+	if w.Name != "" {
+		fmt.Fprintf(&b, "Name: %s\n", w.Name)
+	}
+	for _, row := range w.Lifts {
+		for _, v := range row {
+			if v == 1 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ParseWeave reads a weave in the text format of card sets: an optional "Name: " header,
+// then one line per pick with # (or O) for a raised end and . for an end left down
+func ParseWeave(content string) (*Weave, error) {
+	w := &Weave{Name: "custom"}
+	for i, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "Name: ") {
+			if len(w.Lifts) > 0 {
+				return nil, fmt.Errorf("invalid weave: Name header on line %d must come before the picks", i+1)
+			}
+			w.Name = strings.TrimSpace(strings.TrimPrefix(line, "Name: "))
+			continue
+		}
+
+		row := make([]int, 0, len(line))
+		for col, char := range line {
+			switch char {
+			case '#', 'O', 'o':
+				row = append(row, 1)
+			case '.':
+				row = append(row, 0)
+			default:
+				return nil, fmt.Errorf("invalid character '%c' in weave line %d col %d (expected #, O, or .)", char, i+1, col+1)
+			}
+		}
+		w.Lifts = append(w.Lifts, row)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// weaveAliases are short names of common weaves
+var weaveAliases = map[string]string{
+	"tabby":  "plain",
+	"twill":  "twill-2/2",
+	"basket": "basket-2",
+	"satin":  "satin-5",
+	"sateen": "sateen-5",
+}
+
+// WeaveNames lists the names of the built-in weaves, as examples of the families WeaveByName accepts
+func WeaveNames() []string {
+	return []string{
+		"plain", "twill-1/2", "twill-2/1", "twill-1/3", "twill-2/2", "twill-3/1",
+		"satin-5", "satin-8", "sateen-5", "sateen-8", "basket-2", "basket-3",
+	}
+}
+
+// WeaveByName returns a built-in weave:
+//   - plain (or tabby)
+//   - twill-U/D: U ends up and D down, stepping one end each pick, e.g. twill-2/2 (or twill)
+//   - satin-N: warp-faced N-end satin, N 5 or more except 6, e.g. satin-5 (or satin) and satin-8
+//   - sateen-N: the weft-faced satin, e.g. sateen-5 (or sateen) and sateen-8
+//   - basket-N: plain weave with ends and picks in groups of N, e.g. basket-2 (or basket)
+func WeaveByName(name string) (*Weave, error) {
+	if alias, ok := weaveAliases[name]; ok {
+		name = alias
+	}
+	family, size, _ := strings.Cut(name, "-")
+
+	var w *Weave
+	var err error
+	switch family {
+	case "plain":
+		if size == "" {
+			w = newWeave(name, 2, 2, func(x, y int) bool { return (x+y)%2 == 0 })
+		}
+	case "twill":
+		up, down, ok := strings.Cut(size, "/")
+		if ok {
+			w, err = twill(name, up, down)
+		}
+	case "satin", "sateen":
+		w, err = satin(name, size, family == "satin")
+	case "basket":
+		n, convErr := strconv.Atoi(size)
+		if convErr == nil && n >= 2 && 2*n <= MaxWeaveRepeat {
+			w = newWeave(name, 2*n, 2*n, func(x, y int) bool { return (x/n+y/n)%2 == 0 })
+		} else if convErr == nil {
+			err = fmt.Errorf("invalid weave: %s (basket groups must be 2 to %d)", name, MaxWeaveRepeat/2)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, fmt.Errorf("invalid weave: %s (must be plain, twill-U/D, satin-N, sateen-N or basket-N, e.g. %s)",
+			name, strings.Join(WeaveNames(), ", "))
+	}
+	return w, nil
+}
+
+// twill builds an up/down twill
+func twill(name, upText, downText string) (*Weave, error) {
+	up, err1 := strconv.Atoi(upText)
+	down, err2 := strconv.Atoi(downText)
+	if err1 != nil || err2 != nil || up < 1 || down < 1 || up+down < 3 || up+down > MaxWeaveRepeat {
+		return nil, fmt.Errorf("invalid weave: %s (a twill needs 1 or more ends up and down, 3 to %d in all)", name, MaxWeaveRepeat)
+	}
+	n := up + down
+	return newWeave(name, n, n, func(x, y int) bool { return (x+y)%n < up }), nil
+}
+
+// satin builds an N-end satin, warp-faced (one end down per pick) or weft-faced (one end up)
+// The raised or lowered end moves by a counter that shares no factor with N, so no two
+// neighbouring picks bind next to each other and the binding points make no twill line
+func satin(name, size string, warpFaced bool) (*Weave, error) {
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 5 || n > MaxWeaveRepeat {
+		return nil, fmt.Errorf("invalid weave: %s (a satin needs 5 to %d ends)", name, MaxWeaveRepeat)
+	}
+	step := 0
+	for s := 2; s < n-1; s++ {
+		if gcd(s, n) == 1 {
+			step = s
+			break
+		}
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("invalid weave: %s (there is no regular %d-end satin)", name, n)
+	}
+	return newWeave(name, n, n, func(x, y int) bool { return (x == y*step%n) != warpFaced }), nil
+}
+
+// newWeave builds a repeat from a function reporting the raised ends
+func newWeave(name string, ends, picks int, raised func(x, y int) bool) *Weave {
+	lifts := make([][]int, picks)
+	for y := range lifts {
+		lifts[y] = make([]int, ends)
+		for x := range lifts[y] {
+			if raised(x, y) {
+				lifts[y][x] = 1
+			}
+		}
+	}
+	return &Weave{Name: name, Lifts: lifts}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Damask fills the figure of a design (pixels that are 1) with one weave and its ground
+// (pixels that are 0) with another, tiled by hook and pick, so no area floats unbound
+// A nil weave leaves its pixels as they are: figure raised and ground down
+type Damask struct {
+	Figure *Weave
+	Ground *Weave
+}
+
+// Apply returns a copy of a binary matrix, one row per pick, with the weaves filled in
+func (d *Damask) Apply(matrix [][]int) [][]int {
+	woven := make([][]int, len(matrix))
+	for y, row := range matrix {
+		woven[y] = make([]int, len(row))
+		for x, v := range row {
+			woven[y][x] = d.Lift(v, x, y)
+		}
+	}
+	return woven
+}
+
+// Lift returns the lift of a design pixel v at hook x and pick y: the figure or ground weave there,
+// or v itself when that weave, or the damask, is nil
+func (d *Damask) Lift(v, x, y int) int {
+	switch {
+	case d == nil:
+		return v
+	case v == 1 && d.Figure != nil:
+		return d.Figure.Lift(x, y)
+	case v == 0 && d.Ground != nil:
+		return d.Ground.Lift(x, y)
+	default:
+		return v
+	}
+}
+
+// String describes the damask, e.g. "figure satin-8, ground sateen-8"
+func (d *Damask) String() string {
+	name := func(w *Weave) string {
+		if w == nil {
+			return "solid"
+		}
+		return w.Name
+	}
+	return fmt.Sprintf("figure %s, ground %s", name(d.Figure), name(d.Ground))
+}
+
+// LongestFloats returns the longest floats in the cloth woven from a card set:
+// the most consecutive picks an end stays up or down, and the most consecutive
+// hooks a pick leaves up or down
+func LongestFloats(cards []*Card) (warp, weft int) {
+	if len(cards) == 0 {
+		return 0, 0
+	}
+	hooks := cards[0].Width * cards[0].Height
+	lift := func(card *Card, hook int) int {
+		return card.Matrix[hook/card.Width][hook%card.Width]
+	}
+
+	for hook := 0; hook < hooks; hook++ {
+		run := 0
+		for i, card := range cards {
+			if i > 0 && lift(card, hook) == lift(cards[i-1], hook) {
+				run++
+			} else {
+				run = 1
+			}
+			if run > warp {
+				warp = run
+			}
+		}
+	}
+	for _, card := range cards {
+		run := 0
+		for hook := 0; hook < hooks; hook++ {
+			if hook > 0 && lift(card, hook) == lift(card, hook-1) {
+				run++
+			} else {
+				run = 1
+			}
+			if run > weft {
+				weft = run
+			}
+		}
+	}
+	return warp, weft
+}
@@ -0,0 +1,172 @@
+package punchcard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWeaveByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // The repeat in the text format
+	}{
+		{"plain", "#.\n.#\n"},
+		{"tabby", "#.\n.#\n"},
+		{"twill-2/2", "##..\n#..#\n..##\n.##.\n"},
+		{"twill-1/2", "#..\n..#\n.#.\n"},
+		{"satin-5", ".####\n##.##\n####.\n#.###\n###.#\n"},
+		{"sateen-5", "#....\n..#..\n....#\n.#...\n...#.\n"},
+		{"basket-2", "##..\n##..\n..##\n..##\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := WeaveByName(tt.name)
+			if err != nil {
+				t.Fatalf("WeaveByName() error = %v", err)
+			}
+			got := strings.SplitN(w.String(), "\n", 2)[1] // Without the Name header
+			if got != tt.want {
+				t.Errorf("WeaveByName(%q) =\n%s\nwant\n%s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltInWeavesInterlace(t *testing.T) {
+	for _, name := range append(WeaveNames(), "satin-7", "satin-10", "twill-5/3", "basket-4") {
+		w, err := WeaveByName(name)
+		if err != nil {
+			t.Errorf("WeaveByName(%q) error = %v", name, err)
+			continue
+		}
+		if err := w.Validate(); err != nil {
+			t.Errorf("%s does not validate: %v", name, err)
+		}
+	}
+}
+
+func TestSatinBindingPoints(t *testing.T) {
+	// An 8-end sateen binds each end once per repeat, with no two binding points touching
+	w, err := WeaveByName("sateen-8")
+	if err != nil {
+		t.Fatalf("WeaveByName() error = %v", err)
+	}
+	raised := func(row []int) int {
+		for x, v := range row {
+			if v == 1 {
+				return x
+			}
+		}
+		return -1
+	}
+	used := map[int]bool{}
+	for y, row := range w.Lifts {
+		x := raised(row)
+		if x < 0 || used[x] {
+			t.Fatalf("pick %d binds end %d twice or not at all", y+1, x+1)
+		}
+		used[x] = true
+		next := raised(w.Lifts[(y+1)%w.Picks()])
+		if d := (next - x + 8) % 8; d == 1 || d == 7 {
+			t.Errorf("picks %d and %d bind neighbouring ends", y+1, y+2)
+		}
+	}
+}
+
+func TestWeaveByNameInvalid(t *testing.T) {
+	for _, name := range []string{"", "velvet", "plain-2", "twill-0/3", "twill-1/1", "twill-2", "satin-4", "satin-6", "basket-1", "basket-x"} {
+		if _, err := WeaveByName(name); err == nil {
+			t.Errorf("WeaveByName(%q) should fail", name)
+		}
+	}
+}
+
+func TestParseWeave(t *testing.T) {
+	w, err := ParseWeave("Name: broken twill\n\n##..\n.##.\n##..\r\n..##\n")
+	if err != nil {
+		t.Fatalf("ParseWeave() error = %v", err)
+	}
+	if w.Name != "broken twill" || w.Ends() != 4 || w.Picks() != 4 {
+		t.Errorf("ParseWeave() = %s %dx%d", w.Name, w.Ends(), w.Picks())
+	}
+	again, err := ParseWeave(w.String())
+	if err != nil || again.String() != w.String() {
+		t.Errorf("ParseWeave(String()) = %v, %v", again, err)
+	}
+
+	for _, bad := range []string{
+		"",                // No picks
+		"#.\n#",           // Ragged
+		"#x\n.#",          // Unknown character
+		"##\n.#",          // Pick raising every end
+		"#.\n#.",          // End raised on every pick
+		"#.\nName: x\n.#", // Header after the picks
+	} {
+		if _, err := ParseWeave(bad); err == nil {
+			t.Errorf("ParseWeave(%q) should fail", bad)
+		}
+	}
+}
+
+func TestDamaskApply(t *testing.T) {
+	satin, _ := WeaveByName("satin-5")
+	sateen, _ := WeaveByName("sateen-5")
+	d := &Damask{Figure: satin, Ground: sateen}
+
+	matrix := [][]int{
+		{1, 1, 0, 0, 1, 1, 1},
+		{1, 1, 0, 0, 1, 1, 1},
+	}
+	got := d.Apply(matrix)
+	for y, row := range got {
+		for x, v := range row {
+			want := sateen.Lift(x, y)
+			if matrix[y][x] == 1 {
+				want = satin.Lift(x, y)
+			}
+			if v != want {
+				t.Errorf("Apply()[%d][%d] = %d, want %d", y, x, v, want)
+			}
+		}
+	}
+	if matrix[0][2] != 0 || matrix[0][0] != 1 {
+		t.Error("Apply() changed its input")
+	}
+
+	// A nil weave leaves its pixels solid
+	solid := (&Damask{Ground: sateen}).Apply(matrix)
+	if solid[0][0] != 1 || solid[0][1] != 1 {
+		t.Errorf("Apply() with no figure weave = %v, want the figure left raised", solid[0])
+	}
+}
+
+func TestGenerateWithDamask(t *testing.T) {
+	// A solid design floats across every hook and pick; a damask binds it
+	matrix := make([][]int, 20)
+	for y := range matrix {
+		matrix[y] = make([]int, 208)
+		for x := range matrix[y] {
+			matrix[y][x] = 1
+		}
+	}
+
+	g := NewGenerator()
+	cards, err := g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if warp, weft := LongestFloats(cards); warp != 20 || weft != 208 {
+		t.Errorf("LongestFloats() of a solid design = %d, %d, want 20, 208", warp, weft)
+	}
+
+	twill, _ := WeaveByName("twill-2/2")
+	g.Damask = &Damask{Figure: twill}
+	cards, err = g.Generate(matrix)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if warp, weft := LongestFloats(cards); warp != 2 || weft != 2 {
+		t.Errorf("LongestFloats() of a 2/2 twill = %d, %d, want 2, 2", warp, weft)
+	}
+}
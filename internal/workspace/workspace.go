@@ -0,0 +1,315 @@
+package workspace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+)
+
+// Kinds of item a workspace holds
+const (
+	KindImages   = "images"   // Uploaded design images
+	KindCardSets = "cardsets" // Card sets in the text format
+	KindPresets  = "presets"  // Saved conversion options
+)
+
+// Kinds lists every kind of item, in the order they are shown
+var Kinds = []string{KindImages, KindCardSets, KindPresets}
+
+// Errors returned by workspaces
+var (
+	ErrNotFound      = errors.New("item not found")
+	ErrQuotaExceeded = errors.New("workspace quota exceeded")
+)
+
+// Item describes a stored file; its content is read with Get
+type Item struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Created     time.Time `json:"created"`
+}
+
+// Usage is how much of their quota an owner has used
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Items int   `json:"items"`
+}
+
+// Workspaces keeps each owner's items in their own directory under root,
+// as root/<owner>/<kind>/<id> with an index.json describing them
+type Workspaces struct {
+	root string
+
+	mu sync.Mutex // Guards every index; workspaces are small, so one lock is enough
+}
+
+// Open creates the root directory if needed and returns the workspaces in it
+func Open(root string) (*Workspaces, error) {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	return &Workspaces{root: root}, nil
+}
+
+// ValidateKind checks that kind is one of Kinds
+func ValidateKind(kind string) error {
+	for _, k := range Kinds {
+		if k == kind {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid kind: %s (must be 'images', 'cardsets' or 'presets')", kind)
+}
+
+// List returns an owner's items of one kind, newest first
+func (w *Workspaces) List(owner, kind string) ([]Item, error) {
+	if err := ValidateKind(kind); err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	items, err := w.readIndex(owner)
+	if err != nil {
+		return nil, err
+	}
+	list := []Item{}
+	for _, it := range items {
+		if it.Kind == kind {
+			list = append(list, it)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Put stores a new item, failing with ErrQuotaExceeded when it would take the owner over quota
+func (w *Workspaces) Put(owner, kind, name, contentType string, data []byte, quota auth.Quota) (Item, error) {
+	if err := ValidateKind(kind); err != nil {
+		return Item{}, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	items, err := w.readIndex(owner)
+	if err != nil {
+		return Item{}, err
+	}
+
+	usage := usageOf(items)
+	if quota.MaxItems > 0 && usage.Items+1 > quota.MaxItems {
+		return Item{}, fmt.Errorf("%w: %d of %d items used", ErrQuotaExceeded, usage.Items, quota.MaxItems)
+	}
+	if quota.MaxBytes > 0 && usage.Bytes+int64(len(data)) > quota.MaxBytes {
+		return Item{}, fmt.Errorf("%w: %d of %d bytes used, %d more needed",
+			ErrQuotaExceeded, usage.Bytes, quota.MaxBytes, len(data))
+	}
+
+	item := Item{
+		ID:          newID(),
+		Kind:        kind,
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Created:     time.Now().UTC(),
+	}
+	path := w.itemPath(owner, item)
+	if err := writeFile(path, data); err != nil {
+		return Item{}, fmt.Errorf("failed to store item: %w", err)
+	}
+	if err := w.writeIndex(owner, append(items, item)); err != nil {
+		os.Remove(path)
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Get returns an item and its content
+func (w *Workspaces) Get(owner, kind, id string) (Item, []byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	item, _, err := w.find(owner, kind, id)
+	if err != nil {
+		return Item{}, nil, err
+	}
+	data, err := os.ReadFile(w.itemPath(owner, item))
+	if err != nil {
+		return Item{}, nil, fmt.Errorf("failed to read item: %w", err)
+	}
+	return item, data, nil
+}
+
+// Delete removes an item
+func (w *Workspaces) Delete(owner, kind, id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	item, items, err := w.find(owner, kind, id)
+	if err != nil {
+		return err
+	}
+	kept := make([]Item, 0, len(items)-1)
+	for _, it := range items {
+		if it.ID != item.ID {
+			kept = append(kept, it)
+		}
+	}
+	if err := w.writeIndex(owner, kept); err != nil {
+		return err
+	}
+	if err := os.Remove(w.itemPath(owner, item)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+// Usage returns how many items and bytes an owner has stored
+func (w *Workspaces) Usage(owner string) (Usage, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	items, err := w.readIndex(owner)
+	if err != nil {
+		return Usage{}, err
+	}
+	return usageOf(items), nil
+}
+
+// DeleteOwner removes an owner's whole workspace
+func (w *Workspaces) DeleteOwner(owner string) error {
+	dir, err := w.ownerDir(owner)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	return nil
+}
+
+// find returns an item of an owner's index, along with the whole index; w.mu must be held
+func (w *Workspaces) find(owner, kind, id string) (Item, []Item, error) {
+	items, err := w.readIndex(owner)
+	if err != nil {
+		return Item{}, nil, err
+	}
+	for _, it := range items {
+		if it.ID == id && it.Kind == kind {
+			return it, items, nil
+		}
+	}
+	return Item{}, nil, ErrNotFound
+}
+
+// readIndex loads an owner's index, which is empty until they store something; w.mu must be held
+func (w *Workspaces) readIndex(owner string) ([]Item, error) {
+	dir, err := w.ownerDir(owner)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace index: %w", err)
+	}
+	var items []Item
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace index of %s: %w", owner, err)
+	}
+	return items, nil
+}
+
+// writeIndex saves an owner's index; w.mu must be held
+func (w *Workspaces) writeIndex(owner string, items []Item) error {
+	dir, err := w.ownerDir(owner)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "index.json"), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save workspace index: %w", err)
+	}
+	return nil
+}
+
+// ownerDir returns the directory of an owner, refusing IDs that could escape the root
+func (w *Workspaces) ownerDir(owner string) (string, error) {
+	if !validID(owner) {
+		return "", fmt.Errorf("invalid workspace owner: %q", owner)
+	}
+	return filepath.Join(w.root, owner), nil
+}
+
+// itemPath returns where an item's content is stored; the owner has been checked by readIndex
+func (w *Workspaces) itemPath(owner string, item Item) string {
+	return filepath.Join(w.root, owner, item.Kind, item.ID)
+}
+
+// usageOf adds up the items of an index
+func usageOf(items []Item) Usage {
+	u := Usage{Items: len(items)}
+	for _, it := range items {
+		u.Bytes += it.Size
+	}
+	return u
+}
+
+// validID reports whether an owner ID is 1 to 64 letters, digits, dashes or underscores
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns a random item ID
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("workspace: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// writeFile writes data through a temporary file, so a crash never leaves a file half written
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
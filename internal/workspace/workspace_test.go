@@ -0,0 +1,103 @@
+package workspace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oscaralmgren/loom-punchcards/internal/auth"
+)
+
+func newTestWorkspaces(t *testing.T) *Workspaces {
+	t.Helper()
+	w, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return w
+}
+
+func TestPutGetDelete(t *testing.T) {
+	w := newTestWorkspaces(t)
+
+	item, err := w.Put("u1", KindImages, "rose.png", "image/png", []byte("png data"), auth.Quota{})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if item.Size != 8 || item.Kind != KindImages {
+		t.Errorf("Put() item = %+v", item)
+	}
+
+	got, data, err := w.Get("u1", KindImages, item.ID)
+	if err != nil || got.Name != "rose.png" || string(data) != "png data" {
+		t.Errorf("Get() = %+v, %q, %v", got, data, err)
+	}
+
+	// Items are only found under their own owner and kind
+	if _, _, err := w.Get("u2", KindImages, item.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() from another owner error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := w.Get("u1", KindCardSets, item.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of another kind error = %v, want ErrNotFound", err)
+	}
+
+	list, err := w.List("u1", KindImages)
+	if err != nil || len(list) != 1 {
+		t.Errorf("List() = %v, %v", list, err)
+	}
+	if list, _ := w.List("u1", KindPresets); len(list) != 0 {
+		t.Errorf("List() of presets = %v, want none", list)
+	}
+
+	if err := w.Delete("u1", KindImages, item.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if usage, _ := w.Usage("u1"); usage != (Usage{}) {
+		t.Errorf("Usage() after Delete() = %+v, want zero", usage)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	w := newTestWorkspaces(t)
+	quota := auth.Quota{MaxBytes: 10, MaxItems: 2}
+
+	if _, err := w.Put("u1", KindCardSets, "a", "text/plain", []byte("123456"), quota); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := w.Put("u1", KindCardSets, "b", "text/plain", []byte("12345"), quota); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Put() over the byte quota error = %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := w.Put("u1", KindCardSets, "b", "text/plain", []byte("1234"), quota); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := w.Put("u1", KindPresets, "c", "application/json", nil, quota); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Put() over the item quota error = %v, want ErrQuotaExceeded", err)
+	}
+
+	usage, err := w.Usage("u1")
+	if err != nil || usage != (Usage{Bytes: 10, Items: 2}) {
+		t.Errorf("Usage() = %+v, %v", usage, err)
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	w := newTestWorkspaces(t)
+	if _, err := w.Put("../u1", KindImages, "x", "image/png", nil, auth.Quota{}); err == nil {
+		t.Error("Put() should reject an owner that escapes the root")
+	}
+	if _, err := w.List("u1", "secrets"); err == nil {
+		t.Error("List() should reject an unknown kind")
+	}
+}
+
+func TestDeleteOwner(t *testing.T) {
+	w := newTestWorkspaces(t)
+	if _, err := w.Put("u1", KindImages, "x", "image/png", []byte("x"), auth.Quota{}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := w.DeleteOwner("u1"); err != nil {
+		t.Fatalf("DeleteOwner() error = %v", err)
+	}
+	if list, err := w.List("u1", KindImages); err != nil || len(list) != 0 {
+		t.Errorf("List() after DeleteOwner() = %v, %v", list, err)
+	}
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates/*.html static
+var files embed.FS
+
+// Templates returns the HTML templates built into the binary
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+// Static returns the static assets built into the binary
+func Static() fs.FS {
+	return sub("static")
+}
+
+// sub returns an embedded directory; the directories are fixed at build time, so it cannot fail
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}